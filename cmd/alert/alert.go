@@ -0,0 +1,339 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	onRules        []string
+	kindNames      []string
+	execCmd        string
+	webhookURL     string
+	ignoreExisting bool
+	execTimeout    time.Duration
+)
+
+func init() {
+	alertWatchCmd.Flags().StringSliceVar(&onRules, "on", []string{"Ready=False"}, "Condition transitions to alert on, as Type=Status, separated by comma")
+	alertWatchCmd.Flags().StringSliceVar(&kindNames, "kinds", nil, "Resources to watch, e.g. xprovider,xkube (default all)")
+	alertWatchCmd.Flags().StringVar(&execCmd, "exec", "", "Shell command run per incident; the incident is passed as JSON on stdin and as SKYCLUSTER_* variables")
+	alertWatchCmd.Flags().StringVar(&webhookURL, "webhook", "", "URL the incident is POSTed to as JSON (defaults to the alert.webhook config key)")
+	alertWatchCmd.Flags().BoolVar(&ignoreExisting, "ignore-existing", false, "Do not alert on resources already matching when the watch starts")
+	alertWatchCmd.Flags().DurationVar(&execTimeout, "exec-timeout", time.Minute, "Time after which the --exec command is killed")
+	alertCmd.AddCommand(alertWatchCmd)
+}
+
+var alertCmd = &cobra.Command{
+	Use:   "alert",
+	Short: "Alert on SkyCluster resource conditions",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var alertWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch resource conditions and run a command or webhook when they change",
+	Long: `Watch the conditions of SkyCluster resources and raise an incident whenever
+one of them changes to a status given with --on. Every incident is printed,
+passed to the --exec command and POSTed to the --webhook URL.
+
+The --exec command runs through sh with the incident as JSON on stdin and in
+the SKYCLUSTER_KIND, SKYCLUSTER_NAME, SKYCLUSTER_NAMESPACE,
+SKYCLUSTER_CONDITION, SKYCLUSTER_STATUS, SKYCLUSTER_PREVIOUS,
+SKYCLUSTER_REASON and SKYCLUSTER_MESSAGE variables.
+
+The command runs until interrupted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rules, err := parseRules(onRules)
+		if err != nil {
+			return err
+		}
+		types, err := watchedTypes(kindNames)
+		if err != nil {
+			return err
+		}
+		url := webhookURL
+		if url == "" {
+			url = viper.GetString("alert.webhook")
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		ctx := cmd.Context()
+		return watchConditions(ctx, dyn, types, rules, url)
+	},
+}
+
+// rule matches a condition reaching a status.
+type rule struct {
+	condition string
+	status    string
+}
+
+// Incident is what an alert reports about a matching transition.
+type Incident struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace,omitempty"`
+	Condition string    `json:"condition"`
+	Status    string    `json:"status"`
+	// Previous is the status before the transition; empty when the
+	// resource was first seen.
+	Previous string `json:"previous,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+func parseRules(specs []string) ([]rule, error) {
+	var rules []rule
+	for _, s := range specs {
+		cond, status, ok := strings.Cut(strings.TrimSpace(s), "=")
+		if !ok || cond == "" || status == "" {
+			return nil, fmt.Errorf("invalid --on %q, expected Type=Status, e.g. Ready=False", s)
+		}
+		switch strings.ToLower(status) {
+		case "true":
+			status = "True"
+		case "false":
+			status = "False"
+		case "unknown":
+			status = "Unknown"
+		default:
+			return nil, fmt.Errorf("invalid status %q in --on %q (True, False or Unknown)", status, s)
+		}
+		rules = append(rules, rule{condition: cond, status: status})
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("at least one --on rule is required")
+	}
+	return rules, nil
+}
+
+func watchedTypes(names []string) ([]*resource.Type, error) {
+	if len(names) == 0 {
+		return resource.All(), nil
+	}
+	var types []*resource.Type
+	for _, n := range names {
+		t, ok := resource.ForName(strings.ToLower(strings.TrimSpace(n)))
+		if !ok {
+			var known []string
+			for _, t := range resource.All() {
+				known = append(known, t.Name)
+			}
+			return nil, fmt.Errorf("unknown kind %q (supported: %s)", n, strings.Join(known, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// watchConditions follows every type and dispatches the incidents one at a
+// time until ctx is done.
+func watchConditions(ctx context.Context, dyn dynamic.Interface, types []*resource.Type, rules []rule, url string) error {
+	incidents := make(chan Incident, 64)
+	var wg sync.WaitGroup
+	for _, t := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			followConditions(ctx, dyn, t, rules, incidents)
+		}()
+	}
+	fmt.Fprintf(os.Stderr, "Watching %d kind(s) for %s\n", len(types), strings.Join(onRules, ", "))
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case in := <-incidents:
+			dispatch(ctx, in, url)
+		}
+	}
+}
+
+// followConditions sends an incident for every condition of a t resource
+// that changes to a status matched by rules.
+func followConditions(ctx context.Context, dyn dynamic.Interface, t *resource.Type, rules []rule, incidents chan<- Incident) {
+	// last holds the status of every watched condition, per resource.
+	last := map[string]map[string]string{}
+	seeded := false
+
+	check := func(obj *unstructured.Unstructured, initial bool) {
+		key := obj.GetNamespace() + "/" + obj.GetName()
+		prev, known := last[key]
+		cur := map[string]string{}
+		for _, r := range rules {
+			status := utils.GetConditionStatus(obj, r.condition)
+			cur[r.condition] = status
+			if status != r.status || (known && prev[r.condition] == status) {
+				continue
+			}
+			if initial && ignoreExisting {
+				continue
+			}
+			reason, message := conditionDetail(obj, r.condition)
+			in := Incident{
+				Time:      time.Now().UTC(),
+				Kind:      t.Kind,
+				Name:      obj.GetName(),
+				Namespace: obj.GetNamespace(),
+				Condition: r.condition,
+				Status:    status,
+				Previous:  prev[r.condition],
+				Reason:    reason,
+				Message:   message,
+			}
+			select {
+			case incidents <- in:
+			case <-ctx.Done():
+			}
+		}
+		last[key] = cur
+	}
+
+	t.Follow(ctx, dyn, resource.Handler{
+		Synced: func(items []unstructured.Unstructured) {
+			present := map[string]bool{}
+			for i := range items {
+				present[items[i].GetNamespace()+"/"+items[i].GetName()] = true
+				check(&items[i], !seeded)
+			}
+			for key := range last {
+				if !present[key] {
+					delete(last, key)
+				}
+			}
+			seeded = true
+		},
+		Changed: func(typ watch.EventType, obj *unstructured.Unstructured) {
+			if typ == watch.Deleted {
+				delete(last, obj.GetNamespace()+"/"+obj.GetName())
+				return
+			}
+			check(obj, false)
+		},
+		Failed: func(err error) {
+			fmt.Fprintf(os.Stderr, "warning: watching %s: %v\n", t.Plural(), err)
+		},
+	})
+}
+
+// conditionDetail returns the reason and message of a condition.
+func conditionDetail(obj *unstructured.Unstructured, condType string) (string, string) {
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conds {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != condType {
+			continue
+		}
+		reason, _ := m["reason"].(string)
+		message, _ := m["message"].(string)
+		return reason, message
+	}
+	return "", ""
+}
+
+// dispatch prints the incident and hands it to the command and the webhook.
+// Their failures are reported but do not stop the watch.
+func dispatch(ctx context.Context, in Incident, url string) {
+	line := fmt.Sprintf("%s %s %s %s=%s", in.Time.Local().Format(time.RFC3339), in.Kind, in.Name, in.Condition, in.Status)
+	if in.Previous != "" {
+		line += fmt.Sprintf(" (was %s)", in.Previous)
+	}
+	if in.Reason != "" || in.Message != "" {
+		line += fmt.Sprintf(": %s %s", in.Reason, in.Message)
+	}
+	fmt.Println(strings.TrimSpace(line))
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: encoding incident: %v\n", err)
+		return
+	}
+	if execCmd != "" {
+		if err := runExec(ctx, in, body); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: --exec for %s %s: %v\n", in.Kind, in.Name, err)
+		}
+	}
+	if url != "" {
+		if err := postIncident(ctx, url, body); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: webhook for %s %s: %v\n", in.Kind, in.Name, err)
+		}
+	}
+}
+
+func runExec(ctx context.Context, in Incident, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, "sh", "-c", execCmd)
+	c.Stdin = bytes.NewReader(body)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	c.Env = append(os.Environ(),
+		"SKYCLUSTER_KIND="+in.Kind,
+		"SKYCLUSTER_NAME="+in.Name,
+		"SKYCLUSTER_NAMESPACE="+in.Namespace,
+		"SKYCLUSTER_CONDITION="+in.Condition,
+		"SKYCLUSTER_STATUS="+in.Status,
+		"SKYCLUSTER_PREVIOUS="+in.Previous,
+		"SKYCLUSTER_REASON="+in.Reason,
+		"SKYCLUSTER_MESSAGE="+in.Message,
+	)
+	debugf("running %q for %s %s", execCmd, in.Kind, in.Name)
+	return c.Run()
+}
+
+// postIncident sends the JSON incident body to url.
+func postIncident(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// debugf logs a debug message of the alert commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("alert", format, args...)
+}
+
+func GetAlertCmd() *cobra.Command {
+	return alertCmd
+}
+
@@ -0,0 +1,82 @@
+package apply
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/policy"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	files          []string
+	showDiff       bool
+	serverSide     bool
+	forceConflicts bool
+)
+
+func init() {
+	applyCmd.Flags().StringSliceVarP(&files, "filename", "f", nil, "YAML file(s) or directories with full SkyCluster resources; multiple documents separated by --- ('-' reads stdin)")
+	applyCmd.Flags().BoolVar(&showDiff, "diff", false, "Only show what would change, without applying")
+	applyCmd.Flags().BoolVar(&serverSide, "server-side", false, "Use server-side apply so only the fields in the manifests are changed (recommended)")
+	applyCmd.Flags().BoolVar(&forceConflicts, "force-conflicts", false, "With --server-side, take over the fields managed by others instead of failing")
+	_ = applyCmd.MarkFlagRequired("filename")
+
+	diffCmd.Flags().StringSliceVarP(&files, "filename", "f", nil, "YAML file(s) or directories with full SkyCluster resources; multiple documents separated by --- ('-' reads stdin)")
+	_ = diffCmd.MarkFlagRequired("filename")
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create or update SkyCluster resources from full, possibly multi-document, YAML manifests",
+	Long: `Create or update SkyCluster resources from full YAML manifests.
+
+Each document must carry apiVersion, kind and metadata.name. Supported kinds are
+the registered SkyCluster resources (XProvider, XKube, XInstance, XSetup and
+ProviderProfile). Every document is parsed
+and checked against policies before anything is sent; documents are then applied
+in order, merging onto existing resources as the create commands do.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		objs, err := resource.ReadManifests(files)
+		if err != nil {
+			return err
+		}
+		if showDiff {
+			return runDiff(cmd.Context(), objs)
+		}
+		for _, u := range objs {
+			if err := policy.Enforce(cmd.Context(), u, debugf); err != nil {
+				return err
+			}
+		}
+
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		for _, u := range objs {
+			t, _ := resource.ForKind(u.GetKind())
+			result, err := t.CreateOrUpdate(cmd.Context(), dyn, u, serverSide, forceConflicts)
+			if err != nil {
+				return fmt.Errorf("apply %s %s: %w", u.GetKind(), u.GetName(), err)
+			}
+			fmt.Fprintf(os.Stdout, "%s/%s %s\n", strings.ToLower(u.GetKind()), u.GetName(), result)
+		}
+		return nil
+	},
+}
+
+func GetApplyCmd() *cobra.Command {
+	return applyCmd
+}
+
+// debugf logs a debug message of the apply commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("apply", format, args...)
+}
@@ -0,0 +1,63 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how apply would change the live resources",
+	Long: `Show how apply would change the live resources.
+
+The live object is compared with the result of merging the manifest onto it,
+which is exactly what apply would send. Nothing is modified.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		objs, err := resource.ReadManifests(files)
+		if err != nil {
+			return err
+		}
+		return runDiff(cmd.Context(), objs)
+	},
+}
+
+func GetDiffCmd() *cobra.Command {
+	return diffCmd
+}
+
+func runDiff(ctx context.Context, objs []*unstructured.Unstructured) error {
+	dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+	if err != nil {
+		return fmt.Errorf("build dynamic client: %w", err)
+	}
+	changed := 0
+	for _, u := range objs {
+		c, err := diffObject(ctx, dyn, u)
+		if err != nil {
+			return fmt.Errorf("diff %s %s: %w", u.GetKind(), u.GetName(), err)
+		}
+		if c {
+			changed++
+		}
+	}
+	if changed == 0 {
+		fmt.Println("No changes.")
+	}
+	return nil
+}
+
+// diffObject prints the diff between the live version of u and the object
+// apply would produce, reporting whether they differ.
+func diffObject(ctx context.Context, dyn dynamic.Interface, u *unstructured.Unstructured) (bool, error) {
+	t, _ := resource.ForKind(u.GetKind())
+	return t.Diff(ctx, os.Stdout, dyn, u)
+}
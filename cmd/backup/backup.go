@@ -0,0 +1,320 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/internal/version"
+)
+
+const (
+	// systemNamespace is where setup keeps the secrets of SkyCluster.
+	systemNamespace = utils.SystemNamespace
+	// secretSelector selects the secrets backed up.
+	secretSelector = "skycluster.io/managed-by=skycluster"
+
+	indexFile         = "backup.yaml"
+	secretsFile       = "secrets.yaml"
+	secretsFileCrypt  = "secrets.yaml.enc"
+	sanitizedKeysNote = "skycluster.io/backup-sanitized"
+)
+
+// groups are the API groups whose resources are backed up.
+var groups = []string{"skycluster.io", "core.skycluster.io", resource.LegacyGroup}
+
+// index describes a backup; it is written last, so a directory without it
+// is an interrupted backup.
+type index struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	CLIVersion string    `json:"cliVersion"`
+	Encrypted  bool      `json:"encrypted"`
+	// Files are the resource files, by GroupResource.
+	Files map[string]string `json:"files"`
+}
+
+var (
+	backupDir     string
+	backupEncrypt bool
+	backupKey     string
+)
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupDir, "output", "o", "", "Directory to write the backup to (required)")
+	backupCmd.Flags().BoolVar(&backupEncrypt, "encrypt", false, "Keep the secret values, encrypted with the private key of the skycluster keypair; without it they are left out")
+	backupCmd.Flags().StringVar(&backupKey, "key", "", "Private key to encrypt with (default: the one of the skycluster-keys secret)")
+	_ = backupCmd.MarkFlagRequired("output")
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Export the SkyCluster resources and secrets to a directory",
+	Long: `Export every resource of the skycluster.io and core.skycluster.io API groups,
+and the secrets of ` + systemNamespace + ` labelled ` + secretSelector + `,
+to a directory that skycluster restore re-applies, e.g. on a new management
+cluster. Server-set fields and status are dropped, and resources composed by
+another one (with a controller owner reference) are left out: the controller
+of their owner recreates them.
+
+The secret values are left out unless --encrypt, which encrypts them with a
+key derived from the private key of the skycluster keypair; keep that key,
+restore needs it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		ctx := cmd.Context()
+		kubeconfig := viper.GetString("kubeconfig")
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		cs, err := utils.GetClientset(kubeconfig)
+		if err != nil {
+			return err
+		}
+		dc, err := utils.GetDiscoveryClient(kubeconfig)
+		if err != nil {
+			return err
+		}
+		if entries, err := os.ReadDir(backupDir); err == nil && len(entries) > 0 {
+			return fmt.Errorf("%s is not empty; back up to a new directory", backupDir)
+		}
+		if err := os.MkdirAll(backupDir, 0o700); err != nil {
+			return err
+		}
+
+		gvrs, err := backedUpResources(dc)
+		if err != nil {
+			return err
+		}
+		idx := index{CreatedAt: time.Now().UTC(), CLIVersion: version.Get(), Encrypted: backupEncrypt, Files: map[string]string{}}
+		for _, gvr := range gvrs {
+			list, err := dyn.Resource(gvr).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("listing %s: %w", gvr.GroupResource(), err)
+			}
+			sort.Slice(list.Items, func(i, j int) bool {
+				return list.Items[i].GetNamespace()+"/"+list.Items[i].GetName() < list.Items[j].GetNamespace()+"/"+list.Items[j].GetName()
+			})
+			var objs []map[string]interface{}
+			for i := range list.Items {
+				// Composed children are recreated by the controller of their
+				// owner; restored on their own, they would be orphans.
+				if owner := metav1.GetControllerOf(&list.Items[i]); owner != nil {
+					debugf("skipping %s %s, composed by %s %s", gvr.Resource, list.Items[i].GetName(), owner.Kind, owner.Name)
+					continue
+				}
+				objs = append(objs, sanitize(&list.Items[i]).Object)
+			}
+			if len(objs) == 0 {
+				continue
+			}
+			name := gvr.GroupResource().String() + ".yaml"
+			if err := writeDocs(filepath.Join(backupDir, name), objs); err != nil {
+				return err
+			}
+			idx.Files[gvr.GroupResource().String()] = name
+			fmt.Printf("%-50s %d\n", gvr.GroupResource(), len(objs))
+		}
+
+		secrets, err := cs.CoreV1().Secrets(systemNamespace).List(ctx, metav1.ListOptions{LabelSelector: secretSelector})
+		if err != nil {
+			return fmt.Errorf("listing the secrets: %w", err)
+		}
+		var objs []map[string]interface{}
+		for i := range secrets.Items {
+			s := secrets.Items[i].DeepCopy()
+			s.APIVersion, s.Kind = "v1", "Secret"
+			if !backupEncrypt {
+				// keep the keys, so that restore can tell what to recreate
+				if s.Annotations == nil {
+					s.Annotations = map[string]string{}
+				}
+				s.Annotations[sanitizedKeysNote] = strings.Join(keys(s.Data), ",")
+				s.Data = nil
+			}
+			u, err := toUnstructured(s)
+			if err != nil {
+				return err
+			}
+			objs = append(objs, sanitize(u).Object)
+		}
+		if backupEncrypt {
+			key, err := encryptionKey(ctx, cs, backupKey)
+			if err != nil {
+				return err
+			}
+			data, err := marshalDocs(objs)
+			if err != nil {
+				return err
+			}
+			sealed, err := seal(key, data)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(backupDir, secretsFileCrypt), sealed, 0o600); err != nil {
+				return err
+			}
+		} else if err := writeDocs(filepath.Join(backupDir, secretsFile), objs); err != nil {
+			return err
+		}
+		fmt.Printf("%-50s %d\n", "secrets", len(objs))
+
+		data, err := yaml.Marshal(idx)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(backupDir, indexFile), data, 0o600); err != nil {
+			return err
+		}
+		if !backupEncrypt {
+			fmt.Fprintln(os.Stderr, "warning: the secret values are not in the backup; use --encrypt to keep them")
+		}
+		fmt.Printf("Backup written to %s\n", backupDir)
+		return nil
+	},
+}
+
+// backedUpResources returns the listable resources of groups the cluster
+// serves, at their preferred version.
+func backedUpResources(dc discovery.DiscoveryInterface) ([]schema.GroupVersionResource, error) {
+	apiGroups, err := dc.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("discovering the API groups: %w", err)
+	}
+	var gvrs []schema.GroupVersionResource
+	for _, g := range apiGroups.Groups {
+		if !slices.Contains(groups, g.Name) {
+			continue
+		}
+		gv := g.PreferredVersion.GroupVersion
+		list, err := dc.ServerResourcesForGroupVersion(gv)
+		if err != nil {
+			return nil, fmt.Errorf("discovering the resources of %s: %w", gv, err)
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || !slices.Contains(r.Verbs, "list") || !slices.Contains(r.Verbs, "create") {
+				continue
+			}
+			gvrs = append(gvrs, schema.GroupVersionResource{Group: g.Name, Version: g.PreferredVersion.Version, Resource: r.Name})
+		}
+	}
+	sort.Slice(gvrs, func(i, j int) bool { return gvrs[i].String() < gvrs[j].String() })
+	debugf("backing up %v", gvrs)
+	return gvrs, nil
+}
+
+// sanitize drops what the API server sets from u, and its status.
+func sanitize(u *unstructured.Unstructured) *unstructured.Unstructured {
+	u = u.DeepCopy()
+	for _, f := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields", "selfLink", "ownerReferences", "deletionTimestamp", "deletionGracePeriodSeconds"} {
+		unstructured.RemoveNestedField(u.Object, "metadata", f)
+	}
+	unstructured.RemoveNestedField(u.Object, "metadata", "annotations", corev1.LastAppliedConfigAnnotation)
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u
+}
+
+// marshalDocs encodes objs as a multi-document YAML file.
+func marshalDocs(objs []map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, o := range objs {
+		data, err := yaml.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeDocs writes objs to path as a multi-document YAML file.
+func writeDocs(path string, objs []map[string]interface{}) error {
+	data, err := marshalDocs(objs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func toUnstructured(obj interface{}) (*unstructured.Unstructured, error) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(data, &u.Object); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func keys(m map[string][]byte) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// debugf logs a debug message of the backup and restore commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("backup", format, args...)
+}
+
+// gvrOf returns the GroupVersionResource of a "<resource>.<group>" key of the
+// index, at the version u carries.
+func gvrOf(key string, u *unstructured.Unstructured) schema.GroupVersionResource {
+	gr := schema.ParseGroupResource(key)
+	return u.GroupVersionKind().GroupVersion().WithResource(gr.Resource)
+}
+
+// clientFor returns the resource interface of u of gvr.
+func clientFor(dyn dynamic.Interface, gvr schema.GroupVersionResource, u *unstructured.Unstructured) dynamic.ResourceInterface {
+	if u.GetNamespace() == "" {
+		return dyn.Resource(gvr)
+	}
+	return dyn.Resource(gvr).Namespace(u.GetNamespace())
+}
+
+// ensureNamespace creates the namespace ns if it is missing.
+func ensureNamespace(ctx context.Context, dyn dynamic.Interface, ns string) error {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": ns},
+	}}
+	return utils.Retry(ctx, func(ctx context.Context) error {
+		_, err := utils.ServerSideApply(ctx, dyn.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}), u, false)
+		return err
+	})
+}
+
+func GetBackupCmd() *cobra.Command {
+	return backupCmd
+}
+
+func GetRestoreCmd() *cobra.Command {
+	return restoreCmd
+}
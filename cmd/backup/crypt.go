@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// sealMagic starts the encrypted secrets file.
+var sealMagic = []byte("skycluster-backup-v1\n")
+
+// encryptionKey derives the AES-256 key of the secrets file from the private
+// key at path or, when path is empty, from the private key of the
+// skycluster-keys secret.
+func encryptionKey(ctx context.Context, cs *kubernetes.Clientset, path string) ([]byte, error) {
+	if path != "" {
+		priv, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading the private key: %w", err)
+		}
+		return deriveKey(priv), nil
+	}
+	s, err := cs.CoreV1().Secrets(systemNamespace).Get(ctx, "skycluster-keys", metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("reading the skycluster keypair (or pass --key): %w", err)
+	}
+	var cfg struct {
+		PrivateKey string `json:"privateKey"`
+	}
+	if err := json.Unmarshal(s.Data["config"], &cfg); err != nil || cfg.PrivateKey == "" {
+		return nil, fmt.Errorf("secret %s/skycluster-keys has no private key; pass --key", systemNamespace)
+	}
+	priv, err := base64.StdEncoding.DecodeString(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the private key of secret %s/skycluster-keys: %w", systemNamespace, err)
+	}
+	return deriveKey(priv), nil
+}
+
+// deriveKey hashes the key material of the PEM private key priv, so that
+// the same key gives the same result whatever its line endings.
+func deriveKey(priv []byte) []byte {
+	material := bytes.TrimSpace(priv)
+	if block, _ := pem.Decode(priv); block != nil {
+		material = block.Bytes
+	}
+	sum := sha256.Sum256(material)
+	return sum[:]
+}
+
+// seal encrypts data with AES-GCM under key.
+func seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, sealMagic...), nonce...)
+	return gcm.Seal(out, nonce, data, sealMagic), nil
+}
+
+// unseal decrypts what seal returned.
+func unseal(key, sealed []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(sealed, sealMagic)
+	if !ok {
+		return nil, errors.New("not an encrypted backup file")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("encrypted backup file is truncated")
+	}
+	data, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], sealMagic)
+	if err != nil {
+		return nil, errors.New("cannot decrypt the secrets: wrong key, or the file was changed")
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,259 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// restoreOrder is the order kinds are restored in: what others refer to
+// first. Kinds not listed come last.
+var restoreOrder = []string{"ProviderProfile", "XSetup", "XProvider", "XKube", "XKubeMesh", "XInstance"}
+
+var (
+	restoreDir    string
+	restoreKey    string
+	restoreWait   time.Duration
+	restoreDryRun bool
+	restoreForce  bool
+)
+
+func init() {
+	restoreCmd.Flags().StringVarP(&restoreDir, "file", "f", "", "Directory written by skycluster backup (required)")
+	restoreCmd.Flags().StringVar(&restoreKey, "key", "", "Private key the secrets were encrypted with (default: the one of the skycluster-keys secret of the cluster)")
+	restoreCmd.Flags().DurationVar(&restoreWait, "wait", 10*time.Minute, "How long to wait for each kind to be Ready before restoring the next; 0 does not wait")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "Only list what would be restored, in order")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force-conflicts", false, "Take over the fields of existing objects managed by others instead of failing")
+	_ = restoreCmd.MarkFlagRequired("file")
+}
+
+// stage is the objects of one kind, restored together.
+type stage struct {
+	kind string
+	gvr  schema.GroupVersionResource
+	objs []*unstructured.Unstructured
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Re-apply a backup of the SkyCluster resources and secrets",
+	Long: `Re-apply a directory written by skycluster backup: the secrets first, then
+ProviderProfiles, XSetups, XProviders, XKubes, XKubeMeshes, XInstances and
+the other resources, waiting for each kind to be Ready before the next. The
+secrets of a backup made without --encrypt have no values; those missing on
+the cluster are listed to be recreated, e.g. with skycluster setup.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var idx index
+		data, err := os.ReadFile(filepath.Join(restoreDir, indexFile))
+		if err != nil {
+			return fmt.Errorf("%s is not a complete backup: %w", restoreDir, err)
+		}
+		if err := yaml.Unmarshal(data, &idx); err != nil {
+			return fmt.Errorf("reading %s: %w", indexFile, err)
+		}
+		cmd.SilenceUsage = true
+		ctx := cmd.Context()
+		kubeconfig := viper.GetString("kubeconfig")
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+
+		secrets, err := readSecrets(ctx, idx)
+		if err != nil {
+			return err
+		}
+		stages, err := readStages(idx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backup of %s (CLI %s)\n", idx.CreatedAt.Local().Format(time.RFC1123), idx.CLIVersion)
+		if restoreDryRun {
+			fmt.Printf("%-20s %d\n", "Secret", len(secrets))
+			for _, s := range stages {
+				fmt.Printf("%-20s %d\n", s.kind, len(s.objs))
+			}
+			return nil
+		}
+
+		if err := ensureNamespace(ctx, dyn, systemNamespace); err != nil {
+			return fmt.Errorf("creating namespace %s: %w", systemNamespace, err)
+		}
+		secretGVR := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+		var missing []string
+		for _, s := range secrets {
+			ri := dyn.Resource(secretGVR).Namespace(s.GetNamespace())
+			if _, sanitized := s.GetAnnotations()[sanitizedKeysNote]; sanitized {
+				if _, err := ri.Get(ctx, s.GetName(), metav1.GetOptions{}); err != nil {
+					missing = append(missing, s.GetName())
+				}
+				continue
+			}
+			if err := apply(ctx, ri, s); err != nil {
+				return fmt.Errorf("restoring secret %s: %w", s.GetName(), err)
+			}
+			debugf("restored secret %s", s.GetName())
+		}
+		fmt.Printf("Restored %d secrets\n", len(secrets)-len(missing))
+		if len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: the backup has no values of secrets %v, and the cluster does not have them; recreate them\n", missing)
+		}
+
+		for _, s := range stages {
+			var wait []utils.WaitResourceSpec
+			for _, u := range s.objs {
+				if err := apply(ctx, clientFor(dyn, s.gvr, u), u); err != nil {
+					return fmt.Errorf("restoring %s %s: %w", s.kind, u.GetName(), err)
+				}
+				if _, ok := resource.ForKind(s.kind); ok {
+					wait = append(wait, utils.WaitResourceSpec{
+						KindDescription: s.kind + " " + u.GetName(),
+						GVR:             s.gvr,
+						Namespace:       u.GetNamespace(),
+						Name:            u.GetName(),
+						ConditionType:   "Ready",
+						Timeout:         restoreWait,
+						PollInterval:    10 * time.Second,
+					})
+				}
+			}
+			fmt.Printf("Restored %d %s\n", len(s.objs), s.kind)
+			if restoreWait == 0 || len(wait) == 0 {
+				continue
+			}
+			if err := utils.WaitWithProgress(ctx, dyn, wait, os.Stdout, debugf); err != nil {
+				return fmt.Errorf("waiting for the %s objects: %w; run restore again once fixed, it is safe to repeat", s.kind, err)
+			}
+		}
+		fmt.Println("Restore done.")
+		return nil
+	},
+}
+
+// apply creates u, or updates the fields of u on the existing object.
+func apply(ctx context.Context, ri dynamic.ResourceInterface, u *unstructured.Unstructured) error {
+	return utils.Retry(ctx, func(ctx context.Context) error {
+		_, err := utils.ServerSideApply(ctx, ri, u, restoreForce)
+		return err
+	})
+}
+
+// readSecrets returns the secrets of the backup, decrypting them if needed.
+func readSecrets(ctx context.Context, idx index) ([]*unstructured.Unstructured, error) {
+	if !idx.Encrypted {
+		data, err := os.ReadFile(filepath.Join(restoreDir, secretsFile))
+		if err != nil {
+			return nil, err
+		}
+		return splitDocs(data)
+	}
+	var key []byte
+	if restoreKey != "" {
+		k, err := encryptionKey(ctx, nil, restoreKey)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	} else {
+		cs, err := utils.GetClientset(viper.GetString("kubeconfig"))
+		if err != nil {
+			return nil, err
+		}
+		if key, err = encryptionKey(ctx, cs, ""); err != nil {
+			return nil, err
+		}
+	}
+	sealed, err := os.ReadFile(filepath.Join(restoreDir, secretsFileCrypt))
+	if err != nil {
+		return nil, err
+	}
+	data, err := unseal(key, sealed)
+	if err != nil {
+		return nil, err
+	}
+	return splitDocs(data)
+}
+
+// readStages reads the resource files of idx, grouped by kind in
+// restoreOrder.
+func readStages(idx index) ([]stage, error) {
+	byKind := map[string]*stage{}
+	for key, file := range idx.Files {
+		data, err := os.ReadFile(filepath.Join(restoreDir, file))
+		if err != nil {
+			return nil, err
+		}
+		objs, err := splitDocs(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, u := range objs {
+			s, ok := byKind[u.GetKind()]
+			if !ok {
+				s = &stage{kind: u.GetKind(), gvr: gvrOf(key, u)}
+				byKind[u.GetKind()] = s
+			}
+			s.objs = append(s.objs, u)
+		}
+	}
+	rank := func(kind string) int {
+		for i, k := range restoreOrder {
+			if k == kind {
+				return i
+			}
+		}
+		return len(restoreOrder)
+	}
+	stages := make([]stage, 0, len(byKind))
+	for _, s := range byKind {
+		stages = append(stages, *s)
+	}
+	sort.Slice(stages, func(i, j int) bool {
+		if ri, rj := rank(stages[i].kind), rank(stages[j].kind); ri != rj {
+			return ri < rj
+		}
+		return stages[i].kind < stages[j].kind
+	})
+	return stages, nil
+}
+
+// splitDocs decodes the YAML documents of data.
+func splitDocs(data []byte) ([]*unstructured.Unstructured, error) {
+	var out []*unstructured.Unstructured
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &u.Object); err != nil {
+			return nil, err
+		}
+		if len(u.Object) > 0 {
+			out = append(out, u)
+		}
+	}
+}
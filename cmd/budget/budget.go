@@ -0,0 +1,369 @@
+package budget
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	// AnnotationMonthly holds the monthly budget of a ProviderProfile, in the
+	// currency of its instance type prices.
+	AnnotationMonthly = "skycluster.io/budget-monthly"
+
+	hoursPerMonth = 730
+)
+
+var (
+	profileGVR      = schema.GroupVersionResource{Group: "core.skycluster.io", Version: "v1alpha1", Resource: "providerprofiles"}
+	instanceTypeGVR = schema.GroupVersionResource{Group: "core.skycluster.io", Version: "v1alpha1", Resource: "instancetypes"}
+	xinstanceGVR    = schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xinstances"}
+)
+
+var (
+	monthly      float64
+	providerName string
+	notify       bool
+	webhookURL   string
+	allProfiles  bool
+)
+
+func init() {
+	budgetSetCmd.Flags().Float64Var(&monthly, "monthly", 0, "Monthly budget for the provider; 0 removes the budget")
+	budgetSetCmd.Flags().StringVarP(&providerName, "provider", "p", "", "Name of the ProviderProfile (required)")
+	_ = budgetSetCmd.MarkFlagRequired("monthly")
+	_ = budgetSetCmd.MarkFlagRequired("provider")
+
+	budgetStatusCmd.Flags().BoolVar(&notify, "notify", false, "POST an alert to the budget webhook when a provider exceeds its budget")
+	budgetStatusCmd.Flags().StringVar(&webhookURL, "webhook", "", "Webhook URL for --notify (defaults to the budget.webhook config key)")
+	budgetStatusCmd.Flags().BoolVarP(&allProfiles, "all-namespaces", "A", false, "Report the ProviderProfiles of all namespaces, not only the one of --namespace")
+
+	budgetCmd.AddCommand(budgetSetCmd)
+	budgetCmd.AddCommand(budgetStatusCmd)
+}
+
+var budgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Budget alarms for providers",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var budgetSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set the monthly budget of a provider",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if monthly < 0 {
+			return errors.New("--monthly must not be negative")
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		ri := dyn.Resource(profileGVR).Namespace(profileNamespace())
+		if _, err := utils.GetWithSuggestions(cmd.Context(), ri, "providerprofile", providerName); err != nil {
+			return err
+		}
+
+		// A null value in a merge patch removes the annotation.
+		var value interface{}
+		if monthly > 0 {
+			value = strconv.FormatFloat(monthly, 'f', -1, 64)
+		}
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{AnnotationMonthly: value},
+			},
+		})
+		debugf("patching providerprofile %s: %s", providerName, patch)
+		if _, err := ri.Patch(cmd.Context(), providerName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("setting budget on %s: %w", providerName, err)
+		}
+		if value == nil {
+			fmt.Printf("Budget removed from %s\n", providerName)
+		} else {
+			fmt.Printf("Monthly budget of %s set to %s\n", providerName, value)
+		}
+		return nil
+	},
+}
+
+var budgetStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Compare the projected monthly spend of each provider with its budget",
+	Long: `Compare the projected monthly spend of each provider with its budget.
+
+The projection assumes every XInstance placed on the provider keeps running for
+a whole month at the on-demand price of its flavor, taken from the provider's
+InstanceTypes. Instances whose price cannot be resolved are reported but count
+as zero.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		reports, err := projectSpend(cmd.Context(), dyn)
+		if err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			fmt.Println("No ProviderProfiles found.")
+			return nil
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		fmt.Fprintln(writer, "PROVIDER\tINSTANCES\tPROJECTED\tBUDGET\tSTATUS")
+		var over []report
+		for _, r := range reports {
+			budget, status := "-", "-"
+			if r.Budget > 0 {
+				budget = fmt.Sprintf("%.2f", r.Budget)
+				status = "OK"
+				if r.Projected > r.Budget {
+					status = "OVER"
+					over = append(over, r)
+				}
+			}
+			fmt.Fprintf(writer, "%s\t%d\t%.2f\t%s\t%s\n", r.Provider, r.Instances, r.Projected, budget, status)
+		}
+		writer.Flush()
+		for _, r := range reports {
+			if len(r.Unpriced) > 0 {
+				fmt.Fprintf(os.Stderr, "warning: no price for %s on %s\n", strings.Join(r.Unpriced, ", "), r.Provider)
+			}
+		}
+
+		if notify && len(over) > 0 {
+			url := webhookURL
+			if url == "" {
+				url = viper.GetString("budget.webhook")
+			}
+			if url == "" {
+				return errors.New("--notify needs --webhook or the budget.webhook config key")
+			}
+			if err := postAlert(cmd.Context(), url, over); err != nil {
+				return fmt.Errorf("sending budget alert: %w", err)
+			}
+			fmt.Printf("Alert sent for %d provider(s) over budget\n", len(over))
+		}
+		return nil
+	},
+}
+
+// report is the projected spend of one ProviderProfile.
+type report struct {
+	Provider  string   `json:"provider"`
+	Instances int      `json:"instances"`
+	Projected float64  `json:"projectedMonthly"`
+	Budget    float64  `json:"budgetMonthly"`
+	Unpriced  []string `json:"unpriced,omitempty"`
+}
+
+// profileNamespace returns the namespace of the ProviderProfiles and their
+// InstanceTypes; empty, for all namespaces, with -A.
+func profileNamespace() string {
+	if allProfiles {
+		return ""
+	}
+	return utils.Namespace(utils.SystemNamespace)
+}
+
+// projectSpend matches XInstances to ProviderProfiles by platform and region
+// and prices them with the flavors found in the profile's InstanceTypes. An
+// instance is counted once, against the profile ownerProfile picks.
+func projectSpend(ctx context.Context, dyn dynamic.Interface) ([]report, error) {
+	profiles, err := dyn.Resource(profileGVR).Namespace(profileNamespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing providerprofiles: %w", err)
+	}
+	instanceTypes, err := dyn.Resource(instanceTypeGVR).Namespace(profileNamespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing instancetypes: %w", err)
+	}
+	instances, err := dyn.Resource(xinstanceGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing xinstances: %w", err)
+	}
+
+	reports := make([]report, len(profiles.Items))
+	prices := make([]map[string]float64, len(profiles.Items))
+	for i, p := range profiles.Items {
+		reports[i] = report{Provider: p.GetName()}
+		if v := p.GetAnnotations()[AnnotationMonthly]; v != "" {
+			if b, err := strconv.ParseFloat(v, 64); err == nil {
+				reports[i].Budget = b
+			} else {
+				debugf("ignoring invalid budget %q on %s: %v", v, p.GetName(), err)
+			}
+		}
+		prices[i] = flavorPrices(instanceTypes.Items, p.GetName())
+	}
+
+	for _, inst := range instances.Items {
+		i := ownerProfile(&inst, profiles.Items, prices)
+		if i < 0 {
+			continue
+		}
+		r := &reports[i]
+		r.Instances++
+		flavor, _, _ := unstructured.NestedString(inst.Object, "spec", "flavor")
+		price, ok := prices[i][flavor]
+		if !ok {
+			r.Unpriced = append(r.Unpriced, inst.GetName())
+			continue
+		}
+		r.Projected += price * hoursPerMonth
+	}
+	return reports, nil
+}
+
+// ownerProfile returns the index in profiles of the one profile inst is
+// counted against, or -1. Of the profiles of its platform and region, those
+// listing the primary zone of inst win, then those pricing its flavor, then
+// the first by name, so that profiles sharing a region do not count it twice.
+func ownerProfile(inst *unstructured.Unstructured, profiles []unstructured.Unstructured, prices []map[string]float64) int {
+	platform, _, _ := unstructured.NestedString(inst.Object, "spec", "providerRef", "platform")
+	region, _, _ := unstructured.NestedString(inst.Object, "spec", "providerRef", "region")
+	zone, _, _ := unstructured.NestedString(inst.Object, "spec", "providerRef", "zones", "primary")
+	flavor, _, _ := unstructured.NestedString(inst.Object, "spec", "flavor")
+
+	best, bestScore, tied := -1, -1, 0
+	for i := range profiles {
+		p := &profiles[i]
+		pPlatform, _, _ := unstructured.NestedString(p.Object, "spec", "platform")
+		pRegion, _, _ := unstructured.NestedString(p.Object, "spec", "region")
+		if pPlatform != platform || pRegion != region {
+			continue
+		}
+		score := 0
+		if zone != "" && slices.Contains(profileZones(p), zone) {
+			score += 2
+		}
+		if _, ok := prices[i][flavor]; ok {
+			score++
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = i, score, 1
+		case score == bestScore:
+			tied++
+			if p.GetName() < profiles[best].GetName() {
+				best = i
+			}
+		}
+	}
+	if tied > 1 {
+		debugf("xinstance %s matches %d profiles alike, counted against %s", inst.GetName(), tied, profiles[best].GetName())
+	}
+	return best
+}
+
+// profileZones returns the names of the zones of the profile p.
+func profileZones(p *unstructured.Unstructured) []string {
+	var zones []string
+	list, _, _ := unstructured.NestedSlice(p.Object, "spec", "zones")
+	for _, z := range list {
+		if zm, ok := z.(map[string]interface{}); ok {
+			if name, _ := zm["name"].(string); name != "" {
+				zones = append(zones, name)
+			}
+		}
+	}
+	return zones
+}
+
+// flavorPrices returns the hourly price per flavor from the InstanceTypes
+// generated for profile (named "<profile>-..."). Both the generic flavor name
+// (nameLabel) and the provider-specific name are indexed.
+func flavorPrices(instanceTypes []unstructured.Unstructured, profile string) map[string]float64 {
+	prices := map[string]float64{}
+	for _, it := range instanceTypes {
+		if !strings.HasPrefix(it.GetName(), profile+"-") {
+			continue
+		}
+		zones, _, _ := unstructured.NestedSlice(it.Object, "spec", "offerings")
+		for _, z := range zones {
+			zm, ok := z.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			offerings, _, _ := unstructured.NestedSlice(zm, "zoneOfferings")
+			for _, o := range offerings {
+				om, ok := o.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				price, err := strconv.ParseFloat(strings.TrimPrefix(fmt.Sprint(om["price"]), "$"), 64)
+				if err != nil {
+					continue
+				}
+				for _, key := range []string{"nameLabel", "name"} {
+					if n, _ := om[key].(string); n != "" {
+						if old, ok := prices[n]; !ok || price < old {
+							prices[n] = price
+						}
+					}
+				}
+			}
+		}
+	}
+	return prices
+}
+
+// postAlert sends the over-budget providers to url as JSON.
+func postAlert(ctx context.Context, url string, over []report) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"text":      fmt.Sprintf("SkyCluster: %d provider(s) projected over their monthly budget", len(over)),
+		"providers": over,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// debugf logs a debug message of the budget commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("budget", format, args...)
+}
+
+func GetBudgetCmd() *cobra.Command {
+	return budgetCmd
+}
+
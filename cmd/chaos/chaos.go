@@ -0,0 +1,251 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	submarinerNS      = "submariner-operator"
+	gatewayDaemonSet  = "submariner-gateway"
+	gatewayNodeLabel  = "submariner.io/gateway"
+	stateConfigMap    = "skycluster-chaos"
+	gatewayWaitPeriod = 2 * time.Minute
+)
+
+var (
+	clusterName string
+	duration    time.Duration
+)
+
+func init() {
+	disconnectCmd.Flags().StringVar(&clusterName, "xkube", "", "Name of the xkube to disconnect (required)")
+	disconnectCmd.Flags().DurationVar(&duration, "duration", 5*time.Minute, "How long the xkube stays disconnected")
+	_ = disconnectCmd.MarkFlagRequired("xkube")
+	restoreCmd.Flags().StringVar(&clusterName, "xkube", "", "Name of the xkube to reconnect (required)")
+	_ = restoreCmd.MarkFlagRequired("xkube")
+
+	chaosCmd.AddCommand(disconnectCmd)
+	chaosCmd.AddCommand(restoreCmd)
+}
+
+var chaosCmd = &cobra.Command{
+	Use:   "chaos",
+	Short: "Disrupt the mesh to test how applications cope",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var disconnectCmd = &cobra.Command{
+	Use:   "disconnect",
+	Short: "Partition an xkube from the mesh for a while",
+	Long: `Stop the submariner gateway of an xkube for --duration, cutting its
+inter-cluster connections, then start it again, e.g.:
+
+  skycluster chaos disconnect --xkube k1 --duration 5m
+
+The gateway is stopped by removing the ` + gatewayNodeLabel + ` label from the
+gateway nodes, so that the ` + gatewayDaemonSet + ` DaemonSet runs no pods;
+the submariner operator does not undo this. The labelled nodes are recorded
+in the ConfigMap ` + submarinerNS + `/` + stateConfigMap + ` of the xkube, so
+that 'skycluster chaos restore --xkube k1' reconnects it if this command is
+killed. Interrupting the command reconnects the xkube right away.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if duration <= 0 {
+			return errors.New("--duration must be positive")
+		}
+		ctx := cmd.Context()
+		cs, err := memberClient(ctx, clusterName)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := disconnect(ctx, cs, time.Now().Add(duration))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("xkube %s disconnected (gateway nodes: %s) until %s\n",
+			clusterName, strings.Join(nodes, ", "), time.Now().Add(duration).Format(time.Kitchen))
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("interrupted; reconnecting")
+		case <-time.After(duration):
+		}
+		// Restore even when interrupted, hence a fresh context.
+		return restore(context.Background(), cs)
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Reconnect an xkube disconnected by chaos disconnect",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cs, err := memberClient(cmd.Context(), clusterName)
+		if err != nil {
+			return err
+		}
+		return restore(cmd.Context(), cs)
+	},
+}
+
+func memberClient(ctx context.Context, name string) (*kubernetes.Clientset, error) {
+	kubeconfig, err := xk.GetConfig(ctx, name, "")
+	if err != nil {
+		return nil, fmt.Errorf("getting kubeconfig of xkube %s: %w", name, err)
+	}
+	cs, err := utils.GetClientsetFromString(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating clientset for xkube %s: %w", name, err)
+	}
+	return cs, nil
+}
+
+// disconnect records the gateway nodes in the state ConfigMap, unlabels them
+// and waits until no gateway pod is ready. It returns the nodes.
+func disconnect(ctx context.Context, cs *kubernetes.Clientset, until time.Time) ([]string, error) {
+	if _, err := cs.CoreV1().ConfigMaps(submarinerNS).Get(ctx, stateConfigMap, metav1.GetOptions{}); err == nil {
+		return nil, fmt.Errorf("xkube %s is already disconnected; run 'skycluster chaos restore --xkube %s' first", clusterName, clusterName)
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting configmap %s/%s: %w", submarinerNS, stateConfigMap, err)
+	}
+	list, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: gatewayNodeLabel + "=true"})
+	if err != nil {
+		return nil, fmt.Errorf("listing gateway nodes: %w", err)
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("xkube %s has no node labelled %s=true; is submariner installed?", clusterName, gatewayNodeLabel)
+	}
+	var nodes []string
+	for _, n := range list.Items {
+		nodes = append(nodes, n.Name)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: stateConfigMap, Namespace: submarinerNS},
+		Data: map[string]string{
+			"gatewayNodes": strings.Join(nodes, ","),
+			"until":        until.UTC().Format(time.RFC3339),
+		},
+	}
+	if _, err := cs.CoreV1().ConfigMaps(submarinerNS).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("recording gateway nodes: %w", err)
+	}
+	if err := labelNodes(ctx, cs, nodes, nil); err != nil {
+		// Put back what was already unlabelled.
+		if rerr := restore(context.Background(), cs); rerr != nil {
+			return nil, errors.Join(err, rerr)
+		}
+		return nil, err
+	}
+	err = utils.RunWithSpinner("Stopping the submariner gateway", func() error {
+		return waitForGateway(ctx, cs, func(ready int32) bool { return ready == 0 })
+	})
+	if err != nil {
+		return nil, errors.Join(err, restore(context.Background(), cs))
+	}
+	return nodes, nil
+}
+
+// restore labels the recorded gateway nodes again, removes the state
+// ConfigMap and waits for a gateway pod to be ready.
+func restore(ctx context.Context, cs *kubernetes.Clientset) error {
+	cm, err := cs.CoreV1().ConfigMaps(submarinerNS).Get(ctx, stateConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		fmt.Printf("xkube %s is not disconnected\n", clusterName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting configmap %s/%s: %w", submarinerNS, stateConfigMap, err)
+	}
+	nodes := slices.DeleteFunc(strings.Split(cm.Data["gatewayNodes"], ","), func(n string) bool { return n == "" })
+	value := "true"
+	if err := labelNodes(ctx, cs, nodes, &value); err != nil {
+		return err
+	}
+	if err := cs.CoreV1().ConfigMaps(submarinerNS).Delete(ctx, stateConfigMap, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting configmap %s/%s: %w", submarinerNS, stateConfigMap, err)
+	}
+	err = utils.RunWithSpinner("Starting the submariner gateway", func() error {
+		return waitForGateway(ctx, cs, func(ready int32) bool { return ready > 0 })
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("xkube %s reconnected\n", clusterName)
+	return nil
+}
+
+// labelNodes sets the gateway label of nodes to value, or removes it when
+// value is nil. Nodes that are gone are skipped.
+func labelNodes(ctx context.Context, cs *kubernetes.Clientset, nodes []string, value *string) error {
+	v := "null"
+	if value != nil {
+		v = fmt.Sprintf("%q", *value)
+	}
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%s}}}`, gatewayNodeLabel, v)
+	var errs []error
+	for _, n := range nodes {
+		debugf("patching node %s: %s", n, patch)
+		_, err := cs.CoreV1().Nodes().Patch(ctx, n, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		if apierrors.IsNotFound(err) {
+			debugf("node %s is gone", n)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("labelling node %s: %w", n, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitForGateway polls the gateway DaemonSet until done accepts its number
+// of ready pods.
+func waitForGateway(ctx context.Context, cs *kubernetes.Clientset, done func(ready int32) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, gatewayWaitPeriod)
+	defer cancel()
+	for {
+		ds, err := cs.AppsV1().DaemonSets(submarinerNS).Get(ctx, gatewayDaemonSet, metav1.GetOptions{})
+		switch {
+		case err == nil && done(ds.Status.NumberReady):
+			return nil
+		case err == nil:
+			debugf("gateway daemonset has %d ready pods", ds.Status.NumberReady)
+		case apierrors.IsNotFound(err):
+			return fmt.Errorf("daemonset %s/%s not found", submarinerNS, gatewayDaemonSet)
+		case ctx.Err() == nil:
+			debugf("getting daemonset %s/%s: %v", submarinerNS, gatewayDaemonSet, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for daemonset %s/%s: %w", submarinerNS, gatewayDaemonSet, ctx.Err())
+		case <-time.After(3 * time.Second):
+		}
+	}
+}
+
+// debugf logs a debug message of the chaos commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("chaos", format, args...)
+}
+
+func GetChaosCmd() *cobra.Command {
+	return chaosCmd
+}
+
@@ -0,0 +1,209 @@
+package ci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	manifests    []string
+	waitTimeout time.Duration
+	condition   string
+)
+
+func init() {
+	ciWaitCmd.Flags().StringSliceVarP(&manifests, "manifest", "f", nil, "YAML file(s) with the applied SkyCluster resources ('-' reads stdin)")
+	ciWaitCmd.Flags().DurationVar(&waitTimeout, "timeout", 30*time.Minute, "Time to wait for all resources")
+	ciWaitCmd.Flags().StringVar(&condition, "condition", "Ready", "Condition every resource must reach")
+	_ = ciWaitCmd.MarkFlagRequired("manifest")
+	ciCmd.AddCommand(ciWaitCmd)
+}
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Commands for CI pipelines",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var ciWaitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait until the resources of applied manifests are ready",
+	Long: `Wait until every resource in the given manifests has its --condition True,
+all of them in parallel, and exit non-zero if any is not by --timeout.
+
+The manifests are the ones passed to 'skycluster apply'. --progress json
+prints one JSON object per line: a "progress" event per resource state change
+and a final "summary" with the outcome of every resource. The other modes
+print a summary table.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		objs, err := resource.ReadManifests(manifests)
+		if err != nil {
+			return err
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+
+		specs := make([]utils.WaitResourceSpec, 0, len(objs))
+		for _, u := range objs {
+			specs = append(specs, waitSpec(u))
+		}
+		// Failing resources are reported in the summary, not with the usage.
+		cmd.SilenceUsage = true
+
+		// JSON lines go to stdout with the summary, plain lines to stderr
+		jsonMode := utils.ProgressMode() == utils.ProgressJSON
+		progress := io.Writer(os.Stderr)
+		if jsonMode {
+			progress = os.Stdout
+		}
+		sink, stop := utils.StartProgress(progress, debugf)
+
+		start := time.Now()
+		results := waitAll(cmd.Context(), dyn, objs, specs, sink)
+		notReady := 0
+		for _, r := range results {
+			if r.Error != "" {
+				notReady++
+			}
+		}
+		var runErr error
+		if notReady > 0 {
+			runErr = fmt.Errorf("%d of %d resource(s) not %s=True", notReady, len(results), condition)
+		}
+		stop(runErr)
+
+		if jsonMode {
+			utils.WriteJSONLine(os.Stdout, map[string]interface{}{
+				"time":      time.Now().UTC(),
+				"event":     "summary",
+				"ready":     len(results) - notReady,
+				"total":     len(results),
+				"elapsed":   time.Since(start).Round(time.Second).String(),
+				"resources": results,
+			})
+		} else {
+			printSummary(os.Stdout, results)
+		}
+		return runErr
+	},
+}
+
+// result is the outcome of one resource, as reported in the summary.
+type result struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Ready     bool   `json:"ready"`
+	Elapsed   string `json:"elapsed"`
+	Error     string `json:"error,omitempty"`
+}
+
+func waitSpec(u *unstructured.Unstructured) utils.WaitResourceSpec {
+	t, _ := resource.ForKind(u.GetKind())
+	ns := u.GetNamespace()
+	if ns == "" {
+		ns = t.Namespace
+	}
+	return utils.WaitResourceSpec{
+		KindDescription: t.Kind + " " + u.GetName(),
+		GVR:             t.GVR,
+		Namespace:       ns,
+		Name:            u.GetName(),
+		ConditionType:   condition,
+		Timeout:         waitTimeout,
+		PollInterval:    5 * time.Second,
+	}
+}
+
+// waitAll waits for every spec in parallel. Unlike
+// WaitForResourcesReadyConcurrent it does not stop at the first failure, so
+// that the summary covers every resource.
+func waitAll(ctx context.Context, dyn dynamic.Interface, objs []*unstructured.Unstructured, specs []utils.WaitResourceSpec, sink utils.ProgressSink) []result {
+	results := make([]result, len(specs))
+	var (
+		mu        sync.Mutex
+		completed int
+		wg        sync.WaitGroup
+	)
+	emit := func(ev utils.ProgressEvent, finished bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			completed++
+		}
+		ev.Total = len(specs)
+		ev.OverallPercent = float64(completed) / float64(len(specs)) * 100
+		sink(ev)
+	}
+
+	start := time.Now()
+	for i, spec := range specs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			base := utils.ProgressEvent{
+				CurrentIndex:    i + 1,
+				KindDescription: spec.KindDescription,
+				Namespace:       spec.Namespace,
+				Name:            spec.Name,
+				GVR:             spec.GVR,
+			}
+			emit(base, false)
+
+			err := utils.WaitForResourceReady(ctx, dyn, spec, debugf)
+			r := result{Kind: objs[i].GetKind(), Name: spec.Name, Namespace: spec.Namespace, Ready: err == nil, Elapsed: time.Since(start).Round(time.Second).String()}
+			ev := base
+			if err != nil {
+				r.Error = err.Error()
+				ev.Err = err
+			} else {
+				ev.ResourceCompleted = true
+			}
+			results[i] = r
+			emit(ev, true)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func printSummary(w io.Writer, results []result) {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "KIND\tNAME\tREADY\tELAPSED\tDETAIL")
+	for _, r := range results {
+		ready := "True"
+		if !r.Ready {
+			ready = "False"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", r.Kind, r.Name, ready, r.Elapsed, r.Error)
+	}
+	writer.Flush()
+}
+
+// debugf logs a debug message of the ci commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("ci", format, args...)
+}
+
+func GetCICmd() *cobra.Command {
+	return ciCmd
+}
+
@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -18,10 +16,12 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	skylog "github.com/etesami/skycluster-cli/internal/log"
 	"github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/internal/version"
 )
 
-const namespace = "skycluster-system"
+const namespace = utils.SystemNamespace
 
 var secretsToDelete = []string{
 	"skycluster-kubeconfig",
@@ -33,14 +33,9 @@ type clientSets struct {
 	clientSet     *kubernetes.Clientset
 }
 
-// debug controls debug output; can be enabled by tests or callers.
-var debug bool
-
-// debugf prints debug messages to stderr when debug is enabled.
+// debugf logs a debug message of the cleanup commands.
 func debugf(format string, args ...interface{}) {
-	if debug {
-		_, _ = fmt.Fprintf(os.Stderr, "DEBUG: "+format+"\n", args...)
-	}
+	skylog.Debugf("cleanup", format, args...)
 }
 
 func init() {
@@ -51,10 +46,6 @@ func GetCleanupCmd() *cobra.Command {
 	return cleanupCmd
 }
 
-func SetDebug(d bool) {
-	debug = d
-}
-
 var cleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Cleans up skycluster-related secrets and pods from the cluster(s)",
@@ -76,15 +67,19 @@ var cleanupCmd = &cobra.Command{
 
 		// best-effort cleanup of prior installations with progress indicator
 		debugf("starting preCleanup (overlay)")
-		utils.RunWithSpinner("Cleaning up prior configurations (overlay)", func() error {
-			_ = preCleanup(localClientSets) // best-effort; ignore errors
+		err := utils.RunWithSpinnerContext(cmd.Context(), "Cleaning up prior configurations (overlay)", func(ctx context.Context) error {
+			_ = preCleanup(ctx, localClientSets) // best-effort; ignore errors
 			return nil
 		})
+		if err != nil {
+			// interrupted; what was deleted so far is reported above
+			return
+		}
 
 		// best-effort cleanup istio
 		debugf("starting performIstioCleanup")
-		utils.RunWithSpinner("Cleaning up prior configurations (istio)", func() error {
-			performIstioCleanup() // best-effort; ignore errors
+		_ = utils.RunWithSpinnerContext(cmd.Context(), "Cleaning up prior configurations (istio)", func(ctx context.Context) error {
+			performIstioCleanup(ctx) // best-effort; ignore errors
 			return nil
 		})
 
@@ -92,8 +87,7 @@ var cleanupCmd = &cobra.Command{
 	},
 }
 
-func preCleanup(clientSets *clientSets) error {
-	ctx := context.Background()
+func preCleanup(ctx context.Context, clientSets *clientSets) error {
 	var errs []string
 
 	clientSet := clientSets.clientSet
@@ -219,7 +213,7 @@ func deleteNamespace(ctx context.Context, clientset *kubernetes.Clientset, ns st
 }
 
 // Istio cleanup stuff
-func performIstioCleanup() {
+func performIstioCleanup(ctx context.Context) {
 	debugf("performIstioCleanup: starting")
 	// local management cluster
 	kubeconfig := viper.GetString("kubeconfig")
@@ -228,7 +222,7 @@ func performIstioCleanup() {
 	csExt, err2 := utils.GetClientsetExtended(kubeconfig)
 	if err1 == nil && err2 == nil {
 		debugf("performIstioCleanup: cleaning up chart on management cluster")
-		_ = cleanupChart(cs, csExt)
+		_ = cleanupChart(ctx, cs, csExt)
 	} else {
 		debugf("performIstioCleanup: skipping cleanupChart on management cluster, client errors: %v %v", err1, err2)
 	}
@@ -236,19 +230,26 @@ func performIstioCleanup() {
 	dyn, err := utils.GetDynamicClient(kubeconfig)
 	if err == nil {
 		debugf("performIstioCleanup: deleting submariner endpoints not matching cluster ID")
-		_ = deleteSubmarinerEndpointsNotMatchingClusterID(context.Background(), dyn)
+		_ = deleteSubmarinerEndpointsNotMatchingClusterID(ctx, dyn)
 	} else {
 		debugf("performIstioCleanup: skipped submariner endpoint cleanup: %v", err)
 	}
 
 	// remote clusters
-	xkubesNames := xk.ListXKubesNames("")
+	xkubesNames := xk.ListXKubesNames(ctx, "")
 	debugf("performIstioCleanup: found remote xkubes: %v", xkubesNames)
-	cleanupKubeconfigSecrets(context.Background(), cs)
+	// Revoke the CLI's access to clusters that are gone and drop their cached
+	// static kubeconfigs.
+	if err := xk.PruneUnregistered(ctx); err != nil {
+		fmt.Printf("warning: pruning the access on unregistered xkubes: %v\n", err)
+	}
 
 	for _, name := range xkubesNames {
+		if ctx.Err() != nil {
+			return
+		}
 		log.Printf("Preparing on xkube %s\n", name)
-		kConfig, err := xk.GetConfig(name, "")
+		kConfig, err := xk.GetConfig(ctx, name, "")
 		if err != nil {
 			fmt.Printf("warning getting kubeconfig for xkube %s: %v\n", name, err)
 			debugf("performIstioCleanup: GetConfig failed for %s: %v", name, err)
@@ -269,13 +270,13 @@ func performIstioCleanup() {
 			debugf("performIstioCleanup: dynamic client creation failed for %s: %v", name, err)
 			continue
 		}
-		_ = deleteSubmariner(context.Background(), dyn)
-		_ = cleanupSubmarinerDaemonSets(context.Background(), cs)
+		_ = deleteSubmariner(ctx, dyn)
+		_ = cleanupSubmarinerDaemonSets(ctx, cs)
 	}
 	debugf("performIstioCleanup: completed")
 }
 
-func cleanupChart(cs *kubernetes.Clientset, csExt *apiextv1.Clientset) error {
+func cleanupChart(ctx context.Context, cs *kubernetes.Clientset, csExt *apiextv1.Clientset) error {
 	debugf("cleanupChart: starting")
 	// ChartSpec represents the static chart metadata you provided.
 	type ChartSpec struct {
@@ -294,8 +295,8 @@ func cleanupChart(cs *kubernetes.Clientset, csExt *apiextv1.Clientset) error {
 	// submariner
 	subm := ChartSpec{
 		Label:       "subm",
-		Version:     "0.20.1",
-		Repo:        "https://submariner-io.github.io/submariner-charts/charts",
+		Version:     pinned("submariner-operator").Version,
+		Repo:        pinned("submariner-operator").Repository,
 		Name:        "submariner-operator",
 		Namespace:   "submariner-operator",
 		BlockingObj: "Submariner/submariner",
@@ -328,8 +329,8 @@ func cleanupChart(cs *kubernetes.Clientset, csExt *apiextv1.Clientset) error {
 	// Two istio charts: base and istiod
 	istioBase := ChartSpec{
 		Label:       "base",
-		Version:     "1.27.0",
-		Repo:        "https://istio-release.storage.googleapis.com/charts",
+		Version:     pinned("base").Version,
+		Repo:        pinned("base").Repository,
 		Name:        "base",
 		Namespace:   "istio-system",
 		BlockingObj: crdBlockingStr,
@@ -337,8 +338,8 @@ func cleanupChart(cs *kubernetes.Clientset, csExt *apiextv1.Clientset) error {
 	}
 	istiod := ChartSpec{
 		Label:       "istiod",
-		Version:     "1.27.0",
-		Repo:        "https://istio-release.storage.googleapis.com/charts",
+		Version:     pinned("istiod").Version,
+		Repo:        pinned("istiod").Repository,
 		Name:        "istiod",
 		Namespace:   "istio-system",
 		BlockingObj: crdBlockingStr, // same CRDs are relevant
@@ -349,11 +350,11 @@ func cleanupChart(cs *kubernetes.Clientset, csExt *apiextv1.Clientset) error {
 	for _, ch := range chartsToCleanup {
 		debugf("cleanupChart: processing chart %s (namespace=%s)", ch.Name, ch.Namespace)
 		if ch.Name == "istiod" {
-			_ = deleteIstioReaderServiceAccount(context.Background(), cs)
+			_ = deleteIstioReaderServiceAccount(ctx, cs)
 		}
-		_ = deleteClusterRolesByPrefix(context.Background(), cs, ch.PrefixObj)
-		_ = deleteClusterRoleBindingsByPrefix(context.Background(), cs, ch.PrefixObj)
-		_ = deleteCRDsForChart(context.Background(), csExt, ch.Name)
+		_ = deleteClusterRolesByPrefix(ctx, cs, ch.PrefixObj)
+		_ = deleteClusterRoleBindingsByPrefix(ctx, cs, ch.PrefixObj)
+		_ = deleteCRDsForChart(ctx, csExt, ch.Name)
 	}
 	debugf("cleanupChart: completed")
 	return nil
@@ -492,6 +493,12 @@ func deleteClusterRoleBindingsByPrefix(ctx context.Context, cs *kubernetes.Clien
 	return nil
 }
 
+// pinned returns the chart name of the versions manifest of the CLI.
+func pinned(name string) version.Chart {
+	c, _ := version.Pinned().Chart(name)
+	return c
+}
+
 // deleteCRDsForChart deletes CRDs 
 // if chartName == "base", match CRDs whose spec.group contains "istio".
 func deleteCRDsForChart(ctx context.Context, apiExtClient *apiextv1.Clientset, chartName string) error {
@@ -548,8 +555,8 @@ func deleteSubmarinerEndpointsNotMatchingClusterID(ctx context.Context, dyn dyna
 	for _, gvr := range gvrs {
 		debugf("processing GVR %s/%s/%s", gvr.Group, gvr.Version, gvr.Resource)
 
-		// List across namespace "skycluster-system"
-		ns := "skycluster-system"
+		// List across namespace utils.SystemNamespace
+		ns := utils.SystemNamespace
 		list, err := dyn.Resource(gvr).Namespace(ns).List(ctx, metav1.ListOptions{})
 		if err != nil {
 			debugf("listing resources for %s failed: %v", gvr.Resource, err)
@@ -577,36 +584,14 @@ func deleteSubmarinerEndpointsNotMatchingClusterID(ctx context.Context, dyn dyna
 				res = dyn.Resource(gvr).Namespace(ns)
 			}
 
-			debugf("attempting normal delete for %s", loc)
-			// 1. Best-effort normal delete
-			_ = res.Delete(ctx, name, metav1.DeleteOptions{})
-
-			// 2. Check if still exists
-			obj, err := res.Get(ctx, name, metav1.GetOptions{})
-			if apierrors.IsNotFound(err) {
-				debugf("%s not found after delete", loc)
+			debugf("deleting %s", loc)
+			forced, err := utils.ForceDelete(ctx, res, name, utils.DefaultFinalizerGrace, debugf)
+			if err != nil {
+				debugf("deleting %s failed: %v", loc, err)
 				continue
 			}
-
-			// 3. Remove finalizers if any
-			if err == nil && len(obj.GetFinalizers()) > 0 {
-				debugf("removing finalizers from %s", loc)
-				obj.SetFinalizers([]string{})
-				_, _ = res.Update(ctx, obj, metav1.UpdateOptions{})
-			}
-
-			// 4. Delete again
-			_ = res.Delete(ctx, name, metav1.DeleteOptions{})
-
-			// 5. Force delete if still present
-			_, err = res.Get(ctx, name, metav1.GetOptions{})
-			if err == nil {
-				fmt.Printf("Force deleting submariner endpoint %s\n", loc)
-				zero := int64(0)
-				_ = res.Delete(ctx, name, metav1.DeleteOptions{
-					GracePeriodSeconds: &zero,
-				})
-				debugf("force deleted %s", loc)
+			if forced {
+				fmt.Printf("Force deleted submariner endpoint %s\n", loc)
 			}
 		}
 	}
@@ -636,37 +621,6 @@ func cleanupSubmarinerDaemonSets(ctx context.Context, cs *kubernetes.Clientset)
 	return nil
 }
 
-func cleanupKubeconfigSecrets(ctx context.Context, cs *kubernetes.Clientset) error {
-	debugf("cleanupKubeconfigSecrets: starting")
-	secretList, err := cs.CoreV1().Secrets("skycluster-system").List(ctx, metav1.ListOptions{
-		LabelSelector: "skycluster.io/secret-type=static-kubeconfig",
-	})
-	if err != nil {
-		debugf("cleanupKubeconfigSecrets: listing secrets failed: %v", err)
-		return err
-	}
-	debugf("cleanupKubeconfigSecrets: found %d secrets", len(secretList.Items))
-
-	extNames := xk.ListXKubesNames("")
-	debugf("cleanupKubeconfigSecrets: external xkube names: %v", extNames)
-
-	for _, secret := range secretList.Items {
-		// if there is an existing xkube with this cluster-id, skip deletion
-		clusterID := secret.Labels["skycluster.io/cluster-id"]
-		if slices.Contains(extNames, clusterID) {
-			debugf("cleanupKubeconfigSecrets: skipping secret %s with cluster-id %q", secret.Name, clusterID)
-			continue
-		}
-
-		debugf("cleanupKubeconfigSecrets: deleting secret %s", secret.Name)
-		// 1. Best-effort normal delete
-		_ = cs.CoreV1().Secrets("skycluster-system").Delete(ctx, secret.Name, metav1.DeleteOptions{})
-	}
-
-	debugf("cleanupKubeconfigSecrets: completed")
-	return nil
-}
-
 func deleteSubmariner(ctx context.Context, dyn dynamic.Interface) error {
 	debugf("deleteSubmariner: starting")
 	gvrs := []schema.GroupVersionResource{
@@ -688,36 +642,14 @@ func deleteSubmariner(ctx context.Context, dyn dynamic.Interface) error {
 
 		for _, item := range list.Items {
 			name := item.GetName()
-			debugf("deleteSubmariner: attempting delete for submariner %s", name)
-			// 1. Best-effort normal delete
-			_ = dyn.Resource(gvr).Namespace("submariner-operator").Delete(ctx, name, metav1.DeleteOptions{})
-
-			// 2. Check if still exists
-			obj, err := dyn.Resource(gvr).Namespace("submariner-operator").Get(ctx, name, metav1.GetOptions{})
-			if apierrors.IsNotFound(err) {
-				debugf("deleteSubmariner: %s not found after delete", name)
+			debugf("deleteSubmariner: deleting submariner %s", name)
+			forced, err := utils.ForceDelete(ctx, dyn.Resource(gvr).Namespace("submariner-operator"), name, utils.DefaultFinalizerGrace, debugf)
+			if err != nil {
+				debugf("deleteSubmariner: deleting %s failed: %v", name, err)
 				continue
 			}
-
-			// 3. Remove finalizers if any
-			if err == nil && len(obj.GetFinalizers()) > 0 {
-				debugf("deleteSubmariner: removing finalizers from %s", name)
-				obj.SetFinalizers([]string{})
-				_, _ = dyn.Resource(gvr).Namespace("submariner-operator").Update(ctx, obj, metav1.UpdateOptions{})
-			}
-
-			// 4. Delete again
-			_ = dyn.Resource(gvr).Namespace("submariner-operator").Delete(ctx, name, metav1.DeleteOptions{})
-
-			// 5. Force delete if still present
-			_, err = dyn.Resource(gvr).Namespace("submariner-operator").Get(ctx, name, metav1.GetOptions{})
-			if err == nil {
-				fmt.Printf("Force deleting submariner endpoint %s\n", name)
-				zero := int64(0)
-				_ = dyn.Resource(gvr).Namespace("submariner-operator").Delete(ctx, name, metav1.DeleteOptions{
-					GracePeriodSeconds: &zero,
-				})
-				debugf("deleteSubmariner: force deleted %s", name)
+			if forced {
+				fmt.Printf("Force deleted submariner %s\n", name)
 			}
 		}
 	}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	yaml "go.yaml.in/yaml/v3"
+	"k8s.io/client-go/tools/clientcmd"
+	sigyaml "sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	initOverwrite bool
+	viewRaw       bool
+)
+
+func init() {
+	configInitCmd.Flags().BoolVar(&initOverwrite, "overwrite", false, "Replace an existing config file")
+	configViewCmd.Flags().BoolVar(&viewRaw, "raw", false, "Show the overlay token instead of redacting it")
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configViewCmd)
+	configCmd.AddCommand(configSetCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Create, show and edit the config file of the CLI",
+	Long: `Create, show and edit the config file of the CLI, ~/.skycluster/config
+or the file of --config. See configs/config.skycluster for all settings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the config file interactively",
+	Long: `Ask for the kubeconfig of the management cluster, the default namespace
+and the overlay settings, validate them and write the config file. An existing
+file is only replaced with --overwrite.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := Path()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err == nil && !initOverwrite {
+			return fmt.Errorf("%s already exists, edit it with skycluster config set or pass --overwrite", path)
+		}
+		if err := utils.CanPrompt(os.Stdin); err != nil {
+			return fmt.Errorf("config init needs a terminal to ask for the settings; copy configs/config.skycluster to %s instead", path)
+		}
+
+		defKubeconfig := "~/.kube/config"
+		if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 {
+			defKubeconfig = paths[0]
+		}
+		answers := []struct {
+			key, title, def string
+		}{
+			{"kubeconfig", "Kubeconfig of the management cluster", defKubeconfig},
+			{"namespace", "Default namespace (empty for none)", ""},
+			{"overlay.server", "Overlay server address", ""},
+			{"overlay.token", "Overlay token", ""},
+			{"overlay.port", "Overlay port", "6443"},
+		}
+		doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+		for _, a := range answers {
+			v, err := utils.Ask(a.title, a.def)
+			if err != nil {
+				return err
+			}
+			if v == "" {
+				continue
+			}
+			if err := set(doc, strings.Split(a.key, "."), v); err != nil {
+				return err
+			}
+		}
+		out, err := encode(doc)
+		if err != nil {
+			return err
+		}
+		if problems := validate(out); len(problems) > 0 {
+			return fmt.Errorf("invalid config, nothing written:\n  %s", strings.Join(problems, "\n  "))
+		}
+		if err := utils.WriteFileAtomic(path, out, 0o600); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s. Check it with: skycluster doctor\n", path)
+		return nil
+	},
+}
+
+var configViewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Show the settings in effect, from the config file and the flags",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings := viper.AllSettings()
+		if !viewRaw {
+			if overlay, ok := settings["overlay"].(map[string]interface{}); ok && overlay["token"] != nil {
+				overlay["token"] = "REDACTED"
+			}
+		}
+		out, err := sigyaml.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		if used := viper.ConfigFileUsed(); used != "" {
+			fmt.Printf("# %s\n", used)
+		} else {
+			fmt.Println("# no config file, create one with: skycluster config init")
+		}
+		fmt.Print(string(out))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a setting of the config file, e.g. overlay.port 6443",
+	Long: `Set a setting of the config file, a dotted key such as kubeconfig or
+output.xkube.sortBy. The value is read as YAML, so numbers and booleans keep
+their type; put -- before a value starting with a dash. The comments and the
+other settings of the file are kept.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := Path()
+		if err != nil {
+			return err
+		}
+		if err := Set(path, args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Set %s in %s\n", args[0], path)
+		return nil
+	},
+}
+
+// Path returns the config file in use, or ~/.skycluster/config when there is
+// none yet.
+func Path() (string, error) {
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used, nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".skycluster", "config"), nil
+}
+
+// Set sets the dotted key of the config file at path to value, keeping the
+// comments and layout of the rest of the file. The file is created if it
+// does not exist; problems of the result are reported as warnings.
+func Set(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a YAML mapping", path)
+	}
+	if key == "" || strings.Contains(key, "..") || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	if err := set(&doc, strings.Split(key, "."), value); err != nil {
+		return err
+	}
+	out, err := encode(&doc)
+	if err != nil {
+		return err
+	}
+	for _, p := range validate(out) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", p)
+	}
+	perm := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	debugf("setting %s in %s", key, path)
+	return utils.WriteFileAtomic(path, out, perm)
+}
+
+// set sets the value at keys in doc, creating the mappings on the way. Keys
+// match regardless of case, as they do in viper. A key that holds a scalar on the way cannot be descended into.
+func set(doc *yaml.Node, keys []string, value string) error {
+	node := doc.Content[0]
+	for i, k := range keys {
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if strings.EqualFold(node.Content[j].Value, k) {
+				child = node.Content[j+1]
+				break
+			}
+		}
+		last := i == len(keys)-1
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			if last {
+				child = &yaml.Node{Kind: yaml.ScalarNode}
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, child)
+		}
+		if last {
+			// The tag is left for the encoder to resolve, so that 6443 stays a
+			// number and true a boolean.
+			*child = yaml.Node{Kind: yaml.ScalarNode, Value: value,
+				HeadComment: child.HeadComment, LineComment: child.LineComment, FootComment: child.FootComment}
+			return nil
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", strings.Join(keys[:i+1], "."))
+		}
+		node = child
+	}
+	return nil
+}
+
+func encode(doc *yaml.Node) ([]byte, error) {
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// validate returns the problems of the config file data: a kubeconfig that
+// cannot be loaded or lacks the context of the config, and an overlay port
+// out of range.
+func validate(data []byte) []string {
+	var c struct {
+		Kubeconfig string `json:"kubeconfig"`
+		Context    string `json:"context"`
+		Overlay    struct {
+			Port interface{} `json:"port"`
+		} `json:"overlay"`
+	}
+	if err := sigyaml.Unmarshal(data, &c); err != nil {
+		return []string{err.Error()}
+	}
+	var problems []string
+	if c.Kubeconfig != "" {
+		path := resource.ExpandPath(c.Kubeconfig)
+		kc, err := clientcmd.LoadFromFile(path)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("kubeconfig %s: %v", path, err))
+		case c.Context != "" && kc.Contexts[c.Context] == nil:
+			problems = append(problems, fmt.Sprintf("kubeconfig %s has no context %q", path, c.Context))
+		case c.Context == "" && kc.CurrentContext == "":
+			problems = append(problems, fmt.Sprintf("kubeconfig %s has no current context", path))
+		}
+	}
+	if c.Overlay.Port != nil {
+		if p, err := strconv.Atoi(fmt.Sprint(c.Overlay.Port)); err != nil || p < 1 || p > 65535 {
+			problems = append(problems, fmt.Sprintf("overlay.port %v is not a port number", c.Overlay.Port))
+		}
+	}
+	return problems
+}
+
+// debugf logs a debug message of the config commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("config", format, args...)
+}
+
+func GetConfigCmd() *cobra.Command {
+	return configCmd
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	k8 "github.com/etesami/skycluster-cli/cmd/xkube"
+	skylog "github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/metrics"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	leaderElect    bool
+	leaseName      string
+	leaseNamespace string
+	propagate      []string
+	resyncPeriod   time.Duration
+	secretsNS      string
+	metricsAddr    string
+)
+
+func init() {
+	runCmd.Flags().BoolVar(&leaderElect, "leader-elect", false, "Hold a Lease so that only one replica propagates (default true in a pod)")
+	runCmd.Flags().StringVar(&leaseName, "lease-name", "skycluster-controller", "Name of the leader election Lease")
+	runCmd.Flags().StringVar(&leaseNamespace, "lease-namespace", utils.SystemNamespace, "Namespace of the leader election Lease")
+	runCmd.Flags().StringArrayVar(&propagate, "propagate", nil, "Propagate the secrets matching <selector>[:<key>]; repeatable, overrides propagation.secrets")
+	runCmd.Flags().DurationVar(&resyncPeriod, "resync", 30*time.Second, "How often every ready xkube is checked again")
+	runCmd.Flags().StringVar(&secretsNS, "secrets-namespace", "", "Namespace of the secrets to propagate; all namespaces when empty")
+	runCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Address to serve /metrics and /healthz on; empty disables (default :8080 in a pod)")
+	controllerCmd.AddCommand(runCmd)
+}
+
+var controllerCmd = &cobra.Command{
+	Use:   "controller",
+	Short: "Run the SkyCluster controllers",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Propagate secrets to the xkubes until stopped",
+	Long: `Run the secret-propagation controller of 'xkube mesh --enable' until
+interrupted, so that xkubes joining later also receive the remote CA secrets
+of the others, and any other secrets selected with --propagate or the
+propagation.secrets config key.
+
+In a pod the in-cluster credentials are used and, unless --leader-elect=false
+is given, the replicas elect a leader through the --lease-name Lease so that
+only one of them propagates at a time. Elsewhere the kubeconfig of the config
+file is used.
+
+With --metrics-addr, on by default in a pod, /metrics serves the Prometheus
+metrics (secrets propagated, propagation failures, watch restarts, the Ready
+and synced state of every xkube and whether this replica leads) and /healthz
+answers liveness probes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if resyncPeriod <= 0 {
+			return errors.New("--resync must be positive")
+		}
+		cfg, inCluster, err := restConfig()
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("leader-elect") {
+			leaderElect = inCluster
+		}
+		if !cmd.Flags().Changed("metrics-addr") && inCluster {
+			metricsAddr = ":8080"
+		}
+		c, err := k8.NewControllerForConfig(cfg, secretsNS)
+		if err != nil {
+			return err
+		}
+		rules, err := propagationRules()
+		if err != nil {
+			return err
+		}
+		c.SetPropagationRules(rules)
+		c.SetResyncPeriod(resyncPeriod)
+
+		ctx := cmd.Context()
+		leader := c.Metrics().NewGauge("skycluster_controller_leader", "Whether this replica propagates the secrets, holding the lease when elected.")
+		if metricsAddr != "" {
+			if err := serveMetrics(ctx, metricsAddr, c.Metrics()); err != nil {
+				return err
+			}
+		}
+		if !leaderElect {
+			leader.Set(1)
+			log.Printf("propagating secrets (resync every %s)", resyncPeriod)
+			return c.RunForever(ctx)
+		}
+
+		cs, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("creating kubernetes clientset: %w", err)
+		}
+		lock := &leaseLock{
+			cs:            cs,
+			namespace:     leaseNamespace,
+			name:          leaseName,
+			identity:      identity(),
+			leaseDuration: 15 * time.Second,
+			retryPeriod:   2 * time.Second,
+		}
+		log.Printf("waiting for lease %s as %s", lock, lock.identity)
+		if err := lock.acquire(ctx); err != nil {
+			return nil // interrupted before becoming leader
+		}
+		log.Printf("acquired lease %s; propagating secrets (resync every %s)", lock, resyncPeriod)
+		leader.Set(1)
+		defer leader.Set(0)
+
+		leading, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var lostLease atomic.Bool
+		go lock.hold(leading, func() {
+			lostLease.Store(true)
+			cancel()
+		})
+		err = c.RunForever(leading)
+		cancel()
+		if lostLease.Load() {
+			// Another replica may be propagating already; exit so that this
+			// one restarts as a candidate.
+			return fmt.Errorf("lost lease %s", lock)
+		}
+		return err
+	},
+}
+
+// serveMetrics serves the metrics of r on /metrics and liveness on /healthz
+// at addr until ctx is done.
+func serveMetrics(ctx context.Context, addr string, r *metrics.Registry) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("serving metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", r.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("warning: serving metrics: %v", err)
+		}
+	}()
+	log.Printf("serving metrics on %s", ln.Addr())
+	return nil
+}
+
+// propagationRules returns the rules of --propagate, or of the config file
+// when none is given.
+func propagationRules() ([]k8.PropagationRule, error) {
+	if len(propagate) == 0 {
+		return k8.PropagationRules()
+	}
+	var rules []k8.PropagationRule
+	for _, p := range propagate {
+		r, err := k8.ParsePropagationRule(p)
+		if err != nil {
+			return nil, fmt.Errorf("--propagate %q: %w", p, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// restConfig returns the config of the kubeconfig file, or the in-cluster
+// config when running in a pod without one, and whether it is the latter.
+func restConfig() (*rest.Config, bool, error) {
+	kubeconfig := viper.GetString("kubeconfig")
+	cfg, err := utils.RESTConfig(kubeconfig)
+	if err != nil {
+		return nil, false, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	inCluster := kubeconfig == ""
+	if inCluster {
+		debugf("using in-cluster config")
+	}
+	return cfg, inCluster, nil
+}
+
+// identity names this replica in the Lease: the hostname, which is the pod
+// name in a pod, and a random suffix.
+func identity() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "_" + hex.EncodeToString(b)
+}
+
+// debugf logs a debug message of the controller commands.
+func debugf(format string, args ...interface{}) {
+	skylog.Debugf("controller", format, args...)
+}
+
+func GetControllerCmd() *cobra.Command {
+	return controllerCmd
+}
+
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+// leaseLock is a leader lock held through a coordination.k8s.io Lease: the
+// holder renews it every retry period, and another candidate takes it over
+// once it has not been renewed for the lease duration.
+type leaseLock struct {
+	cs        kubernetes.Interface
+	namespace string
+	name      string
+	identity  string
+
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+}
+
+// acquire blocks until the lock is held or ctx is done.
+func (l *leaseLock) acquire(ctx context.Context) error {
+	for {
+		ok, err := l.tryAcquireOrRenew(ctx)
+		if err != nil {
+			debugf("acquiring lease %s/%s: %v", l.namespace, l.name, err)
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.retryPeriod):
+		}
+	}
+}
+
+// hold renews the lock until ctx is done, then releases it. It calls lost
+// and returns when the lock could not be renewed within the lease duration.
+func (l *leaseLock) hold(ctx context.Context, lost func()) {
+	lastRenew := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			l.release()
+			return
+		case <-time.After(l.retryPeriod):
+		}
+		ok, err := l.tryAcquireOrRenew(ctx)
+		if ok {
+			lastRenew = time.Now()
+			continue
+		}
+		if err != nil {
+			debugf("renewing lease %s/%s: %v", l.namespace, l.name, err)
+		}
+		if time.Since(lastRenew) >= l.leaseDuration || (err == nil && ctx.Err() == nil) {
+			lost()
+			return
+		}
+	}
+}
+
+// tryAcquireOrRenew takes the lease when it is free, expired or already
+// ours, and reports whether it is held now.
+func (l *leaseLock) tryAcquireOrRenew(ctx context.Context) (bool, error) {
+	leases := l.cs.CoordinationV1().Leases(l.namespace)
+	now := metav1.NewMicroTime(time.Now())
+	lease, err := leases.Get(ctx, l.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: l.name, Namespace: l.namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(l.identity),
+				LeaseDurationSeconds: ptr.To(int32(l.leaseDuration.Seconds())),
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	holder := ptr.Deref(lease.Spec.HolderIdentity, "")
+	if holder != "" && holder != l.identity && !expired(lease) {
+		return false, nil
+	}
+	if holder != l.identity {
+		lease.Spec.AcquireTime = &now
+		lease.Spec.LeaseTransitions = ptr.To(ptr.Deref(lease.Spec.LeaseTransitions, 0) + 1)
+	}
+	lease.Spec.HolderIdentity = ptr.To(l.identity)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(l.leaseDuration.Seconds()))
+	lease.Spec.RenewTime = &now
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// release gives the lease up so that another candidate takes over without
+// waiting for it to expire.
+func (l *leaseLock) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	leases := l.cs.CoordinationV1().Leases(l.namespace)
+	lease, err := leases.Get(ctx, l.name, metav1.GetOptions{})
+	if err != nil || ptr.Deref(lease.Spec.HolderIdentity, "") != l.identity {
+		return
+	}
+	lease.Spec.HolderIdentity = nil
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		debugf("releasing lease %s/%s: %v", l.namespace, l.name, err)
+	}
+}
+
+func expired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	d := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return time.Since(lease.Spec.RenewTime.Time) > d
+}
+
+func (l *leaseLock) String() string {
+	return fmt.Sprintf("%s/%s", l.namespace, l.name)
+}
@@ -0,0 +1,153 @@
+package ctx
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/etesami/skycluster-cli/cmd/config"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// currentKey is the top-level key of the config file naming the selected
+// management cluster.
+const currentKey = "currentCluster"
+
+var ctxHeaders = []string{"CURRENT", "NAME", "KUBECONFIG", "CONTEXT"}
+
+var listTable utils.TableOptions
+
+// Cluster is a named management cluster of the clusters section of the
+// config file.
+type Cluster struct {
+	Name       string
+	Kubeconfig string
+	Context    string
+}
+
+// Clusters returns the management clusters of the config file, by name.
+func Clusters() []Cluster {
+	var out []Cluster
+	for name := range viper.GetStringMap("clusters") {
+		out = append(out, Cluster{
+			Name:       name,
+			Kubeconfig: viper.GetString("clusters." + name + ".kubeconfig"),
+			Context:    viper.GetString("clusters." + name + ".context"),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Lookup returns the management cluster called name.
+func Lookup(name string) (Cluster, bool) {
+	for _, c := range Clusters() {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Cluster{}, false
+}
+
+// Selected returns the name of the management cluster the commands use: the
+// one of --cluster, or else the currentCluster of the config file.
+func Selected() string {
+	if name := viper.GetString("cluster"); name != "" {
+		return name
+	}
+	return viper.GetString(currentKey)
+}
+
+func init() {
+	utils.AddTableFlags(ctxListCmd, &listTable, "")
+	ctxCmd.AddCommand(ctxListCmd)
+	ctxCmd.AddCommand(ctxUseCmd)
+}
+
+var ctxCmd = &cobra.Command{
+	Use:   "ctx",
+	Short: "List and switch the management clusters of the config file",
+	Long: `List and switch the management clusters of the clusters section of the
+config file, each with its kubeconfig and optionally a context of it:
+
+  clusters:
+    prod:
+      kubeconfig: ~/.kube/prod
+    staging:
+      kubeconfig: ~/.kube/config
+      context: staging-admin
+  currentCluster: prod
+
+All commands use the current cluster, or the one of --cluster for a single
+command; --kubeconfig and --context still override it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var ctxListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the management clusters, marking the current one",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.NewTable(ctxHeaders...).Check(listTable); err != nil {
+			return err
+		}
+		clusters := Clusters()
+		if len(clusters) == 0 {
+			fmt.Println("No clusters in the config file, the kubeconfig of the config file is used.")
+			return nil
+		}
+		selected := Selected()
+		tbl := utils.NewTable(ctxHeaders...)
+		for _, c := range clusters {
+			current := ""
+			if strings.EqualFold(c.Name, selected) {
+				current = "*"
+			}
+			context := c.Context
+			if context == "" {
+				context = "-"
+			}
+			tbl.Append(current, c.Name, c.Kubeconfig, context)
+		}
+		return tbl.Write(os.Stdout, listTable)
+	},
+}
+
+var ctxUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Make a management cluster the current one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, ok := Lookup(args[0])
+		if !ok {
+			return fmt.Errorf("unknown cluster %q (see: skycluster ctx list)", args[0])
+		}
+		path, err := config.Path()
+		if err != nil {
+			return err
+		}
+		debugf("setting %s to %s in %s", currentKey, c.Name, path)
+		if err := config.Set(path, currentKey, c.Name); err != nil {
+			return err
+		}
+		fmt.Printf("Switched to cluster %q (%s).\n", c.Name, resource.ExpandPath(c.Kubeconfig))
+		return nil
+	},
+}
+
+// debugf logs a debug message of the ctx commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("ctx", format, args...)
+}
+
+func GetCtxCmd() *cobra.Command {
+	return ctxCmd
+}
@@ -0,0 +1,317 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const skyNamespace = utils.SystemNamespace
+
+// requiredSecrets are created by 'skycluster setup'.
+var requiredSecrets = []string{"skycluster-keys", "skycluster-management"}
+
+var crossplaneProviders = schema.GroupVersionResource{Group: "pkg.crossplane.io", Version: "v1", Resource: "providers"}
+
+var checkTimeout time.Duration
+
+func init() {
+	doctorCmd.Flags().DurationVar(&checkTimeout, "timeout", 10*time.Second, "Timeout of each check")
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the CLI and the management cluster are ready for use",
+	Long: `Run a series of checks and report each as PASS, WARN or FAIL, with a hint
+on how to fix it:
+
+  - the kubeconfig of the config file reaches the management cluster
+  - the SkyCluster CRDs are installed
+  - the Crossplane providers are installed and healthy
+  - the skycluster-system namespace and the secrets of 'skycluster setup' exist
+  - the apiServer of the XSetup is reachable
+  - gcloud, needed for the GKE xkubes, is installed
+
+The checks that need the cluster are skipped when it cannot be reached. The
+command fails when any check fails; warnings do not fail it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Failures are reported as checks, not with the usage.
+		cmd.SilenceUsage = true
+		results := run(cmd.Context())
+		failed := 0
+		for _, r := range results {
+			fmt.Printf("%s  %s: %s\n", r.status.label(), r.name, r.detail)
+			if r.hint != "" && r.status != pass {
+				fmt.Printf("      hint: %s\n", r.hint)
+			}
+			if r.status == fail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+type status int
+
+const (
+	pass status = iota
+	warn
+	fail
+	skip
+)
+
+func (s status) label() string {
+	switch s {
+	case pass:
+		return pterm.Green("PASS")
+	case warn:
+		return pterm.Yellow("WARN")
+	case fail:
+		return pterm.Red("FAIL")
+	default:
+		return pterm.Gray("SKIP")
+	}
+}
+
+// result is the outcome of one check.
+type result struct {
+	name   string
+	status status
+	detail string
+	hint   string
+}
+
+// clients are those of the management cluster, nil when it is unreachable.
+type clients struct {
+	dyn dynamic.Interface
+	cs  kubernetes.Interface
+}
+
+// run runs the checks in order; those after a failed kubeconfig check that
+// need the cluster are skipped.
+func run(ctx context.Context) []result {
+	var out []result
+	c, r := checkKubeconfig(ctx)
+	out = append(out, r)
+	clusterChecks := []struct {
+		name string
+		fn   func(context.Context, *clients) result
+	}{
+		{"CRDs", checkCRDs},
+		{"Crossplane providers", checkProviders},
+		{"Namespace and secrets", checkSecrets},
+		{"API server", checkAPIServer},
+	}
+	for _, chk := range clusterChecks {
+		if c == nil {
+			out = append(out, result{name: chk.name, status: skip, detail: "management cluster not reachable"})
+			continue
+		}
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		r := chk.fn(ctx, c)
+		cancel()
+		r.name = chk.name
+		out = append(out, r)
+	}
+	return append(out, checkGcloud(ctx))
+}
+
+func checkKubeconfig(ctx context.Context) (*clients, result) {
+	r := result{name: "Kubeconfig"}
+	path := resource.ExpandPath(viper.GetString("kubeconfig"))
+	if path == "" && !utils.InCluster() {
+		r.status, r.detail = fail, "no kubeconfig in the config file"
+		r.hint = "set kubeconfig in the config file to the kubeconfig of the management cluster"
+		return nil, r
+	}
+	// In a pod without a kubeconfig, the service account is used.
+	source := path
+	if path == "" {
+		source = "in-cluster config"
+	} else if _, err := os.Stat(path); err != nil {
+		r.status, r.detail = fail, err.Error()
+		r.hint = "set kubeconfig in the config file to an existing file"
+		return nil, r
+	}
+	cs, err := utils.GetClientset(path)
+	if err != nil {
+		r.status, r.detail = fail, fmt.Sprintf("invalid kubeconfig %s: %v", source, err)
+		return nil, r
+	}
+	dyn, err := utils.GetDynamicClient(path)
+	if err != nil {
+		r.status, r.detail = fail, fmt.Sprintf("invalid kubeconfig %s: %v", source, err)
+		return nil, r
+	}
+	v, err := cs.Discovery().ServerVersion()
+	if err != nil {
+		r.status, r.detail = fail, fmt.Sprintf("cannot reach the management cluster: %v", err)
+		if path != "" {
+			r.hint = "check the server and credentials of " + path + ", e.g. with kubectl --kubeconfig " + path + " version"
+		}
+		return nil, r
+	}
+	debugf("management cluster version %s", v.GitVersion)
+	r.status, r.detail = pass, fmt.Sprintf("%s reaches Kubernetes %s", source, v.GitVersion)
+	return &clients{dyn: dyn, cs: cs}, r
+}
+
+func checkCRDs(ctx context.Context, c *clients) result {
+	var missing []string
+	types := resource.All()
+	for _, t := range types {
+		if _, err := utils.IsNamespaced(c.cs.Discovery(), t.GVR); err != nil {
+			debugf("CRD of %s: %v", t.Kind, err)
+			missing = append(missing, t.GVR.Resource+"."+t.GVR.Group)
+		}
+	}
+	if len(missing) > 0 {
+		return result{status: fail, detail: "missing " + strings.Join(missing, ", "),
+			hint: "install SkyCluster on the management cluster (see: skycluster examples setup)"}
+	}
+	return result{status: pass, detail: fmt.Sprintf("%d SkyCluster resources served", len(types))}
+}
+
+func checkProviders(ctx context.Context, c *clients) result {
+	list, err := c.dyn.Resource(crossplaneProviders).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return result{status: fail, detail: fmt.Sprintf("listing Crossplane providers: %v", err),
+			hint: "install Crossplane on the management cluster"}
+	}
+	if len(list.Items) == 0 {
+		return result{status: warn, detail: "no Crossplane provider installed",
+			hint: "install the Crossplane providers of the platforms you use"}
+	}
+	var unhealthy []string
+	for i := range list.Items {
+		p := &list.Items[i]
+		installed := utils.GetConditionStatus(p, "Installed")
+		healthy := utils.GetConditionStatus(p, "Healthy")
+		debugf("provider %s: Installed=%q Healthy=%q", p.GetName(), installed, healthy)
+		if installed != "True" || healthy != "True" {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s (Installed=%s, Healthy=%s)", p.GetName(), orUnknown(installed), orUnknown(healthy)))
+		}
+	}
+	if len(unhealthy) > 0 {
+		return result{status: fail, detail: "unhealthy: " + strings.Join(unhealthy, ", "),
+			hint: "see why with: kubectl describe providers.pkg.crossplane.io <name>"}
+	}
+	return result{status: pass, detail: fmt.Sprintf("%d providers installed and healthy", len(list.Items))}
+}
+
+func checkSecrets(ctx context.Context, c *clients) result {
+	const hint = "run skycluster setup to create them"
+	if _, err := c.cs.CoreV1().Namespaces().Get(ctx, skyNamespace, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return result{status: fail, detail: "namespace " + skyNamespace + " not found", hint: hint}
+		}
+		return result{status: fail, detail: fmt.Sprintf("getting namespace %s: %v", skyNamespace, err)}
+	}
+	var missing []string
+	for _, name := range requiredSecrets {
+		_, err := c.cs.CoreV1().Secrets(skyNamespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			missing = append(missing, name)
+		} else if err != nil {
+			return result{status: fail, detail: fmt.Sprintf("getting secret %s/%s: %v", skyNamespace, name, err)}
+		}
+	}
+	if len(missing) > 0 {
+		return result{status: fail, detail: "missing secrets " + strings.Join(missing, ", ") + " in " + skyNamespace, hint: hint}
+	}
+	return result{status: pass, detail: fmt.Sprintf("namespace %s with %s", skyNamespace, strings.Join(requiredSecrets, ", "))}
+}
+
+// checkAPIServer dials the spec.apiServer of the XSetups, the address the
+// other clusters use to reach the management cluster.
+func checkAPIServer(ctx context.Context, c *clients) result {
+	setups, err := resource.XSetup.List(ctx, c.dyn)
+	if err != nil {
+		return result{status: fail, detail: fmt.Sprintf("listing xsetups: %v", err)}
+	}
+	var addrs []string
+	for i := range setups {
+		if a, _, _ := unstructured.NestedString(setups[i].Object, "spec", "apiServer"); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		return result{status: warn, detail: "no XSetup with spec.apiServer",
+			hint: "run skycluster setup --apiserver <host:port>"}
+	}
+	var d net.Dialer
+	for _, a := range addrs {
+		addr := a
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "6443")
+		}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return result{status: fail, detail: fmt.Sprintf("%s not reachable: %v", addr, err),
+				hint: "check that the address is public and port open to the other clusters"}
+		}
+		conn.Close()
+	}
+	return result{status: pass, detail: strings.Join(addrs, ", ") + " reachable"}
+}
+
+func checkGcloud(ctx context.Context) result {
+	r := result{name: "gcloud"}
+	path, err := exec.LookPath("gcloud")
+	if err != nil {
+		r.status, r.detail = warn, "not found in PATH"
+		r.hint = "install the Google Cloud SDK to fetch the kubeconfig of GKE xkubes"
+		return r
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "version").Output()
+	if err != nil {
+		r.status, r.detail = warn, fmt.Sprintf("%s version failed: %v", path, err)
+		r.hint = "check the gcloud installation with: gcloud version"
+		return r
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	r.status, r.detail = pass, version
+	return r
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "Unknown"
+	}
+	return s
+}
+
+// debugf logs a debug message of the doctor commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("doctor", format, args...)
+}
+
+func GetDoctorCmd() *cobra.Command {
+	return doctorCmd
+}
+
@@ -0,0 +1,269 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// skyGroup is the API group suffix of the SkyCluster resources; events of
+// any object in such a group are shown even once the object is gone.
+const skyGroup = "skycluster.io"
+
+var (
+	forRef    string
+	follow    bool
+	since     time.Duration
+	eventType string
+	depth     int
+)
+
+func init() {
+	eventsCmd.Flags().StringVar(&forRef, "for", "", "Only the events of this resource and of those composed for it, as kind/name, e.g. xkube/gcp-us-east1")
+	eventsCmd.Flags().BoolVarP(&follow, "watch", "w", false, "Keep printing new events")
+	eventsCmd.Flags().DurationVar(&since, "since", 0, "Only events seen within this duration, e.g. 1h")
+	eventsCmd.Flags().StringVar(&eventType, "type", "", "Only events of this type: Normal or Warning")
+	eventsCmd.Flags().IntVar(&depth, "depth", 3, "Levels of composed resources to follow")
+}
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show the Kubernetes events of the SkyCluster resources",
+	Long: `Show the Kubernetes events of the SkyCluster resources and of the
+Crossplane resources composed for them, oldest first, e.g.:
+
+  skycluster events --for xkube/gcp-us-east1 --type Warning -w
+
+Without --for, the events of every registered SkyCluster resource are shown.
+With -w, new events are printed as they are recorded. The resources composed
+later are only picked up when the command is run again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if eventType != "" && eventType != corev1.EventTypeNormal && eventType != corev1.EventTypeWarning {
+			return fmt.Errorf("invalid --type %q (Normal or Warning)", eventType)
+		}
+		kubeconfig := viper.GetString("kubeconfig")
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		cs, err := utils.GetClientset(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build clientset: %w", err)
+		}
+		groups, err := restmapper.GetAPIGroupResources(cs.Discovery())
+		if err != nil {
+			return fmt.Errorf("discovering API resources: %w", err)
+		}
+		c := collector{dyn: dyn, mapper: restmapper.NewDiscoveryRESTMapper(groups), uids: map[types.UID]bool{}}
+
+		var roots []unstructured.Unstructured
+		if forRef != "" {
+			obj, err := getFor(ctx, dyn, forRef)
+			if err != nil {
+				return err
+			}
+			roots = append(roots, *obj)
+		} else {
+			for _, t := range resource.All() {
+				items, err := t.List(ctx, dyn)
+				if err != nil {
+					debugf("listing %s: %v", t.Plural(), err)
+					continue
+				}
+				roots = append(roots, items...)
+			}
+		}
+		for i := range roots {
+			c.add(ctx, &roots[i], 0)
+		}
+		debugf("collected %d related objects", len(c.uids))
+
+		return show(ctx, os.Stdout, cs, c.matches)
+	},
+}
+
+// getFor returns the resource of a kind/name reference.
+func getFor(ctx context.Context, dyn dynamic.Interface, ref string) (*unstructured.Unstructured, error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("invalid --for %q, expected kind/name, e.g. xkube/gcp-us-east1", ref)
+	}
+	t, ok := resource.ForName(strings.ToLower(kind))
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %q (supported: %s)", kind, strings.Join(typeNames(), ", "))
+	}
+	return utils.GetWithSuggestions(ctx, t.Client(dyn), t.Name, name)
+}
+
+// collector gathers the UIDs of the resources whose events are shown.
+type collector struct {
+	dyn    dynamic.Interface
+	mapper meta.RESTMapper
+	uids   map[types.UID]bool
+}
+
+// add records obj and the resources composed for it, while level is below
+// --depth.
+func (c *collector) add(ctx context.Context, obj *unstructured.Unstructured, level int) {
+	if c.uids[obj.GetUID()] {
+		return
+	}
+	c.uids[obj.GetUID()] = true
+	if level >= depth {
+		return
+	}
+	for _, ref := range utils.ResourceRefs(obj) {
+		child, err := utils.GetRef(ctx, c.dyn, c.mapper, ref)
+		if err != nil {
+			debugf("skipping composed %s %s: %v", ref.GetKind(), ref.GetName(), err)
+			continue
+		}
+		c.add(ctx, child, level+1)
+	}
+}
+
+// matches reports whether ev passes --type and --since and is about one of
+// the collected resources or, without --for, any skycluster.io object.
+func (c *collector) matches(ev *corev1.Event) bool {
+	if eventType != "" && ev.Type != eventType {
+		return false
+	}
+	if since > 0 && time.Since(utils.EventTime(ev)) > since {
+		return false
+	}
+	if c.uids[ev.InvolvedObject.UID] {
+		return true
+	}
+	if forRef != "" {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(ev.InvolvedObject.APIVersion)
+	return err == nil && (gv.Group == skyGroup || strings.HasSuffix(gv.Group, "."+skyGroup))
+}
+
+// show prints the matching events, oldest first, then with --watch the new
+// ones as they arrive, until ctx is done.
+func show(ctx context.Context, w io.Writer, cs kubernetes.Interface, match func(*corev1.Event) bool) error {
+	list, err := cs.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing events: %w", err)
+	}
+	var shown []corev1.Event
+	for _, ev := range list.Items {
+		if match(&ev) {
+			shown = append(shown, ev)
+		}
+	}
+	sort.SliceStable(shown, func(i, j int) bool {
+		return utils.EventTime(&shown[i]).Before(utils.EventTime(&shown[j]))
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAST SEEN\tTYPE\tREASON\tOBJECT\tMESSAGE")
+	for i := range shown {
+		writeEvent(tw, &shown[i])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(shown) == 0 && !follow {
+		fmt.Fprintln(w, "No events found.")
+	}
+	if !follow {
+		return nil
+	}
+
+	rv := list.ResourceVersion
+	for ctx.Err() == nil {
+		if rv == "" {
+			fresh, err := cs.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{Limit: 1})
+			if err != nil {
+				return fmt.Errorf("listing events: %w", err)
+			}
+			rv = fresh.ResourceVersion
+		}
+		watcher, err := cs.CoreV1().Events(metav1.NamespaceAll).Watch(ctx, metav1.ListOptions{ResourceVersion: rv})
+		if err != nil {
+			return fmt.Errorf("watching events: %w", err)
+		}
+		for e := range watcher.ResultChan() {
+			if e.Type == watch.Error {
+				// The resource version expired; go on from the current one,
+				// missing what happened in between rather than repeating it.
+				debugf("watch error: %v", e.Object)
+				rv = ""
+				break
+			}
+			ev, ok := e.Object.(*corev1.Event)
+			if !ok {
+				continue
+			}
+			rv = ev.ResourceVersion
+			if (e.Type == watch.Added || e.Type == watch.Modified) && match(ev) {
+				writeEvent(tw, ev)
+				tw.Flush()
+			}
+		}
+		watcher.Stop()
+	}
+	return nil
+}
+
+func writeEvent(w io.Writer, ev *corev1.Event) {
+	msg := strings.TrimSpace(ev.Message)
+	if ev.Count > 1 {
+		msg += fmt.Sprintf(" (x%d)", ev.Count)
+	}
+	obj := strings.ToLower(ev.InvolvedObject.Kind) + "/" + ev.InvolvedObject.Name
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", age(utils.EventTime(ev)), ev.Type, ev.Reason, obj, msg)
+}
+
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return duration.HumanDuration(time.Since(t)) + " ago"
+}
+
+func typeNames() []string {
+	var names []string
+	for _, t := range resource.All() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// debugf logs a debug message of the events commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("events", format, args...)
+}
+
+func GetEventsCmd() *cobra.Command {
+	return eventsCmd
+}
+
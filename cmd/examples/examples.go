@@ -0,0 +1,77 @@
+package examples
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed data/*.txt
+var data embed.FS
+
+// topics maps an example name to its one-line description; the content is
+// read from data/<name>.txt.
+var topics = map[string]string{
+	"xprovider-create": "Spec file and commands to create an XProvider",
+	"xkube-create":     "Spec file and commands to create an XKube",
+	"xinstance-create": "Spec file and commands to create an XInstance",
+	"profile-create":   "Spec file and commands to create a ProviderProfile",
+	"mesh":             "Connect all XKubes into a mesh",
+	"setup":            "Install SkyCluster on the management cluster",
+	"policies":         "Guardrail policy file evaluated by create commands",
+	"apply":            "Apply a whole environment from one multi-document manifest",
+	"tenant":           "Quota-bound tenant namespaces on every xkube",
+	"inventory":        "Record xkube versions and compare them to the fleet standard",
+}
+
+var examplesCmd = &cobra.Command{
+	Use:       "examples [topic]",
+	Short:     "Print copy-pasteable spec files and command recipes",
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: topicNames(),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			listTopics()
+			return
+		}
+		if _, ok := topics[args[0]]; !ok {
+			fmt.Fprintf(os.Stderr, "error: unknown example %q\n\n", args[0])
+			listTopics()
+			os.Exit(1)
+		}
+		content, err := data.ReadFile("data/" + args[0] + ".txt")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: reading example %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		fmt.Print(string(content))
+	},
+}
+
+func GetExamplesCmd() *cobra.Command {
+	return examplesCmd
+}
+
+func listTopics() {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(writer, "TOPIC\tDESCRIPTION")
+	for _, name := range topicNames() {
+		fmt.Fprintf(writer, "%s\t%s\n", name, topics[name])
+	}
+	writer.Flush()
+	fmt.Printf("\nRun 'skycluster examples <topic>' to print one (%s).\n", strings.Join(topicNames(), ", "))
+}
+
+func topicNames() []string {
+	names := make([]string, 0, len(topics))
+	for n := range topics {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,193 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// exported are the types export writes, in the order apply must create
+// them: a directory is named after its place in this list.
+var exported = []*resource.Type{resource.ProviderProfile, resource.XProvider, resource.XKube, resource.XInstance}
+
+var exportDir string
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportDir, "output", "o", "", "Directory to write the manifests to (required)")
+	_ = exportCmd.MarkFlagRequired("output")
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [type]...",
+	Short: "Write the live SkyCluster resources as spec-only manifests that apply re-creates",
+	Long: `Write the live ProviderProfiles, XProviders, XKubes and XInstances, or only
+the given types (profile, xprovider, xkube, xinstance), as one YAML file per
+resource:
+
+  dir/01-providerprofiles/<name>.yaml
+  dir/02-xproviders/<name>.yaml
+  dir/03-xkubes/<name>.yaml
+  dir/04-xinstances/<name>.yaml
+
+Status, server-set metadata, the references Crossplane fills in and the CLI
+audit annotations are left out, so the files can be kept in git and applied
+to any cluster with skycluster apply -f dir/, which reads the directories in
+that order.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		types, err := selectTypes(args)
+		if err != nil {
+			return err
+		}
+		if entries, err := os.ReadDir(exportDir); err == nil && len(entries) > 0 {
+			return fmt.Errorf("%s is not empty; export to a new directory", exportDir)
+		}
+		cmd.SilenceUsage = true
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+
+		total := 0
+		for i, t := range exported {
+			if !types[t] {
+				continue
+			}
+			items, err := t.List(cmd.Context(), dyn)
+			if err != nil {
+				return fmt.Errorf("listing %s: %w", t.GVR.Resource, err)
+			}
+			if len(items) == 0 {
+				continue
+			}
+			dir := filepath.Join(exportDir, fmt.Sprintf("%02d-%s", i+1, t.GVR.Resource))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			for j := range items {
+				data, err := yaml.Marshal(clean(&items[j]).Object)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(filepath.Join(dir, items[j].GetName()+".yaml"), data, 0o644); err != nil {
+					return err
+				}
+			}
+			debugf("wrote %d %s to %s", len(items), t.GVR.Resource, dir)
+			fmt.Printf("%-20s %d\n", t.Kind, len(items))
+			total += len(items)
+		}
+		if total == 0 {
+			fmt.Println("No resources to export.")
+			return nil
+		}
+		fmt.Printf("Exported %d resources to %s; re-create them with: skycluster apply -f %s --server-side\n", total, exportDir, exportDir)
+		return nil
+	},
+}
+
+// selectTypes returns the exported types named in args, all of them when
+// args is empty.
+func selectTypes(args []string) (map[*resource.Type]bool, error) {
+	types := map[*resource.Type]bool{}
+	if len(args) == 0 {
+		for _, t := range exported {
+			types[t] = true
+		}
+		return types, nil
+	}
+	var names []string
+	for _, t := range exported {
+		names = append(names, t.Name)
+	}
+	for _, a := range args {
+		t, ok := resource.ForName(strings.ToLower(a))
+		if !ok || !slices.Contains(exported, t) {
+			return nil, fmt.Errorf("cannot export %q (supported: %s)", a, strings.Join(names, ", "))
+		}
+		types[t] = true
+	}
+	return types, nil
+}
+
+// generatedSpec are the spec fields Crossplane fills in; the composition is
+// picked again when the manifest is applied.
+var generatedSpec = [][]string{
+	{"resourceRefs"},
+	{"claimRef"},
+	{"compositionRef"},
+	{"compositionRevisionRef"},
+	{"crossplane", "resourceRefs"},
+	{"crossplane", "claimRef"},
+	{"crossplane", "compositionRef"},
+	{"crossplane", "compositionRevisionRef"},
+}
+
+// clean returns the spec-only manifest of u: apiVersion, kind, name,
+// namespace, the labels and annotations users set, and spec without the
+// fields Crossplane generates.
+func clean(u *unstructured.Unstructured) *unstructured.Unstructured {
+	out := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	out.SetAPIVersion(u.GetAPIVersion())
+	out.SetKind(u.GetKind())
+	out.SetName(u.GetName())
+	out.SetNamespace(u.GetNamespace())
+	if labels := userKeys(u.GetLabels()); len(labels) > 0 {
+		out.SetLabels(labels)
+	}
+	if annotations := userKeys(u.GetAnnotations()); len(annotations) > 0 {
+		out.SetAnnotations(annotations)
+	}
+	spec, found, _ := unstructured.NestedMap(u.Object, "spec")
+	if !found {
+		return out
+	}
+	for _, path := range generatedSpec {
+		unstructured.RemoveNestedField(spec, path...)
+	}
+	if cp, ok := spec["crossplane"].(map[string]interface{}); ok && len(cp) == 0 {
+		delete(spec, "crossplane")
+	}
+	out.Object["spec"] = spec
+	return out
+}
+
+// userKeys drops the labels or annotations Crossplane, kubectl and the CLI
+// audit set from m.
+func userKeys(m map[string]string) map[string]string {
+	out := map[string]string{}
+	for k, v := range m {
+		switch {
+		case strings.HasPrefix(k, "crossplane.io/"),
+			strings.HasPrefix(k, "kubectl.kubernetes.io/"),
+			k == audit.AnnotationCreatedBy, k == audit.AnnotationCreatedAt,
+			k == audit.AnnotationUpdatedBy, k == audit.AnnotationUpdatedAt,
+			k == audit.AnnotationCLIVersion, k == audit.AnnotationOperationID,
+			k == audit.AnnotationOperationStartedAt, k == audit.AnnotationOperationDuration,
+			k == "skycluster.io/rotated-at":
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// debugf logs a debug message of the export command.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("export", format, args...)
+}
+
+func GetExportCmd() *cobra.Command {
+	return exportCmd
+}
@@ -0,0 +1,319 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	xkubeNamespace = utils.SystemNamespace
+	configMapName  = "skycluster-inventory"
+)
+
+var (
+	clusters []string
+	noRecord bool
+)
+
+func init() {
+	inventoryCmd.Flags().StringSliceVar(&clusters, "clusters", []string{"all"}, "XKubes to inventory, separated by comma, or all")
+	inventoryCmd.Flags().BoolVar(&noRecord, "no-record", false, "Only print the table, do not update the inventory ConfigMap")
+}
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Record and compare the component versions of every xkube",
+	Long: `Collect the Kubernetes version, CNI, istio and submariner versions and the
+node counts of every selected xkube, record them in the ConfigMap
+` + xkubeNamespace + `/` + configMapName + ` of the management cluster and print them side by side.
+
+Versions that differ from the fleet standard declared in the XSetup
+(spec.versions.kubernetes, cni, istio and submariner) are highlighted. A
+standard matches any version it is a prefix of, so "1.30" accepts v1.30.4.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kubeconfig := viper.GetString("kubeconfig")
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		cs, err := utils.GetClientset(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build clientset: %w", err)
+		}
+		ctx := cmd.Context()
+
+		std, err := fleetStandard(ctx, dyn)
+		if err != nil {
+			return err
+		}
+		names := clusters
+		if slices.Contains(names, "all") {
+			names = xk.ListXKubesNames(ctx, "")
+		}
+		if len(names) == 0 {
+			return errors.New("no xkubes found")
+		}
+
+		var entries []Entry
+		for _, name := range names {
+			e := collect(ctx, name)
+			if e.Error != "" {
+				fmt.Fprintf(os.Stderr, "warning: %s: %s\n", name, e.Error)
+			}
+			entries = append(entries, e)
+		}
+		if !noRecord {
+			if err := record(ctx, cs, entries); err != nil {
+				return fmt.Errorf("recording inventory: %w", err)
+			}
+			debugf("recorded %d xkube(s) in %s/%s", len(entries), xkubeNamespace, configMapName)
+		}
+		printTable(entries, std)
+		return nil
+	},
+}
+
+// Entry is the inventory of one xkube, as recorded in the ConfigMap.
+type Entry struct {
+	Cluster    string    `json:"cluster"`
+	Kubernetes string    `json:"kubernetes,omitempty"`
+	CNI        string    `json:"cni,omitempty"`
+	CNIVersion string    `json:"cniVersion,omitempty"`
+	Istio      string    `json:"istio,omitempty"`
+	Submariner string    `json:"submariner,omitempty"`
+	Nodes      int       `json:"nodes"`
+	ReadyNodes int       `json:"readyNodes"`
+	Collected  time.Time `json:"collected"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// standard holds the fleet-standard versions of the XSetup; empty fields are
+// not checked.
+type standard struct {
+	Kubernetes string
+	CNI        string
+	Istio      string
+	Submariner string
+}
+
+// fleetStandard reads spec.versions of the XSetup.
+func fleetStandard(ctx context.Context, dyn dynamic.Interface) (standard, error) {
+	list, err := resource.XSetup.Client(dyn).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return standard{}, fmt.Errorf("listing XSetups: %w", err)
+	}
+	if len(list.Items) == 0 {
+		debugf("no XSetup found, no fleet standard to compare against")
+		return standard{}, nil
+	}
+	obj := list.Items[0].Object
+	get := func(field string) string {
+		v, _, _ := unstructured.NestedString(obj, "spec", "versions", field)
+		return v
+	}
+	return standard{
+		Kubernetes: get("kubernetes"),
+		CNI:        get("cni"),
+		Istio:      get("istio"),
+		Submariner: get("submariner"),
+	}, nil
+}
+
+// cniDaemonSets maps the kube-system DaemonSet of each known CNI to its name.
+var cniDaemonSets = []struct{ daemonSet, cni string }{
+	{"cilium", "cilium"},
+	{"calico-node", "calico"},
+	{"kube-flannel-ds", "flannel"},
+	{"weave-net", "weave"},
+	{"antrea-agent", "antrea"},
+	{"aws-node", "aws-vpc-cni"},
+	{"azure-cns", "azure-cni"},
+}
+
+// collect gathers the inventory of one xkube. Errors are recorded in the
+// entry so that one unreachable cluster does not hide the others.
+func collect(ctx context.Context, name string) Entry {
+	e := Entry{Cluster: name, Collected: time.Now().UTC()}
+	kubeconfig, err := xk.GetConfig(ctx, name, xkubeNamespace)
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	cs, err := utils.GetClientsetFromString(kubeconfig)
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+
+	v, err := cs.Discovery().ServerVersion()
+	if err != nil {
+		e.Error = fmt.Sprintf("getting server version: %v", err)
+		return e
+	}
+	e.Kubernetes = v.GitVersion
+
+	nodes, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		e.Error = fmt.Sprintf("listing nodes: %v", err)
+		return e
+	}
+	e.Nodes = len(nodes.Items)
+	for _, n := range nodes.Items {
+		for _, c := range n.Status.Conditions {
+			if c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue {
+				e.ReadyNodes++
+			}
+		}
+	}
+
+	for _, c := range cniDaemonSets {
+		ds, err := cs.AppsV1().DaemonSets("kube-system").Get(ctx, c.daemonSet, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		e.CNI = c.cni
+		e.CNIVersion = imageTag(ds.Spec.Template.Spec.Containers)
+		break
+	}
+	e.Istio = deploymentVersion(ctx, cs, "istio-system", "istiod")
+	e.Submariner = deploymentVersion(ctx, cs, "submariner-operator", "submariner-operator")
+	return e
+}
+
+// deploymentVersion returns the image tag of a deployment, or "" if it is not
+// installed.
+func deploymentVersion(ctx context.Context, cs *kubernetes.Clientset, ns, name string) string {
+	d, err := cs.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			debugf("getting deployment %s/%s: %v", ns, name, err)
+		}
+		return ""
+	}
+	return imageTag(d.Spec.Template.Spec.Containers)
+}
+
+// imageTag returns the tag of the first container image, without any digest.
+func imageTag(containers []corev1.Container) string {
+	if len(containers) == 0 {
+		return ""
+	}
+	image, _, _ := strings.Cut(containers[0].Image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+// record stores every entry as JSON under its xkube name in the inventory
+// ConfigMap. Each xkube is applied with its own field manager, so entries of
+// xkubes not collected this time are kept.
+func record(ctx context.Context, cs *kubernetes.Clientset, entries []Entry) error {
+	labels := map[string]string{"skycluster.io/managed-by": "skycluster"}
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		cm := corev1ac.ConfigMap(configMapName, xkubeNamespace).
+			WithLabels(labels).
+			WithData(map[string]string{e.Cluster: string(b)})
+		opts := metav1.ApplyOptions{FieldManager: utils.FieldManager + "-inventory-" + e.Cluster, Force: true}
+		if _, err := cs.CoreV1().ConfigMaps(xkubeNamespace).Apply(ctx, cm, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printTable prints one row per xkube, highlighting versions that drift from
+// the fleet standard.
+func printTable(entries []Entry, std standard) {
+	rows := [][]string{{"XKUBE", "KUBERNETES", "CNI", "ISTIO", "SUBMARINER", "NODES"}}
+	drifting := 0
+	for _, e := range entries {
+		if e.Error != "" {
+			rows = append(rows, []string{e.Cluster, pterm.Red("unreachable"), "-", "-", "-", "-"})
+			continue
+		}
+		drift := false
+		cell := func(actual, want string) string {
+			if want == "" || matches(actual, want) {
+				return orDash(actual)
+			}
+			drift = true
+			return pterm.Red(fmt.Sprintf("%s (want %s)", orDash(actual), want))
+		}
+		cni := orDash(strings.TrimSpace(e.CNI + " " + e.CNIVersion))
+		if std.CNI != "" && e.CNI != std.CNI {
+			drift = true
+			cni = pterm.Red(fmt.Sprintf("%s (want %s)", cni, std.CNI))
+		}
+		rows = append(rows, []string{
+			e.Cluster,
+			cell(e.Kubernetes, std.Kubernetes),
+			cni,
+			cell(e.Istio, std.Istio),
+			cell(e.Submariner, std.Submariner),
+			fmt.Sprintf("%d/%d", e.ReadyNodes, e.Nodes),
+		})
+		if drift {
+			drifting++
+		}
+	}
+	out, _ := pterm.DefaultTable.WithHasHeader().WithData(rows).Srender()
+	fmt.Println(out)
+	if drifting > 0 {
+		fmt.Printf("%d of %d xkube(s) drift from the fleet standard.\n", drifting, len(entries))
+	}
+}
+
+// matches reports whether version is the wanted one or a more specific
+// version of it, ignoring a leading "v".
+func matches(version, want string) bool {
+	version = strings.TrimPrefix(version, "v")
+	want = strings.TrimPrefix(want, "v")
+	if version == want {
+		return true
+	}
+	return strings.HasPrefix(version, want+".") || strings.HasPrefix(version, want+"-") || strings.HasPrefix(version, want+"+")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// debugf logs a debug message of the inventory commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("inventory", format, args...)
+}
+
+func GetInventoryCmd() *cobra.Command {
+	return inventoryCmd
+}
+
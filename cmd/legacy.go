@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// legacyCommands maps the commands of earlier releases, which worked on the
+// old API groups, to the commands that replaced them.
+var legacyCommands = map[string]string{
+	"skyvm":        "xinstance",
+	"xvms":         "xinstance",
+	"skyprovider":  "xprovider",
+	"k8s":          "xkube",
+	"xk8sclusters": "xkube",
+}
+
+// rewriteLegacyArgs replaces a legacy command in args with its replacement,
+// warning on w, so that e.g. "skyvm list" runs "xinstance list". Only the
+// first positional argument is considered; the global flags before it are
+// skipped along with their values.
+func rewriteLegacyArgs(root *cobra.Command, args []string, w io.Writer) []string {
+	i := firstPositional(root, args)
+	if i < 0 {
+		return args
+	}
+	repl, ok := legacyCommands[args[i]]
+	if !ok || hasCommand(root, args[i]) {
+		return args
+	}
+	fmt.Fprintf(w, "warning: %q is deprecated and will be removed, use %q instead\n", args[i], repl)
+	out := append([]string{}, args...)
+	out[i] = repl
+	return out
+}
+
+// firstPositional returns the index of the first argument of args that is
+// not a global flag or its value, the command name, or -1 if there is none.
+func firstPositional(root *cobra.Command, args []string) int {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return -1
+		}
+		if strings.HasPrefix(a, "-") {
+			if !strings.Contains(a, "=") && flagTakesValue(root, a) {
+				i++
+			}
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+func flagTakesValue(root *cobra.Command, arg string) bool {
+	name := strings.TrimLeft(arg, "-")
+	f := root.PersistentFlags().Lookup(name)
+	if f == nil && len(name) == 1 {
+		f = root.PersistentFlags().ShorthandLookup(name)
+	}
+	return f != nil && f.NoOptDefVal == ""
+}
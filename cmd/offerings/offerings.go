@@ -0,0 +1,263 @@
+package offerings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// mappingsSelector selects the provider-mappings ConfigMaps, which list the
+// flavors and images each provider offers.
+const mappingsSelector = "skycluster.io/managed-by=skycluster, skycluster.io/config-type=provider-mappings"
+
+var (
+	flavor    string
+	image     string
+	platforms []string
+	region    string
+	sortBy    string
+	output    string
+	allNS     bool
+)
+
+func init() {
+	offeringsCmd.Flags().StringVar(&flavor, "flavor", "", "Generic flavor to run, e.g. 4vCPU-16GB")
+	offeringsCmd.Flags().StringVar(&image, "image", "", "Generic image to run, e.g. ubuntu-22.04")
+	offeringsCmd.Flags().StringSliceVarP(&platforms, "provider-name", "p", nil, "Only consider these platforms, seperated by comma")
+	offeringsCmd.Flags().StringVar(&region, "region", "", "Only consider this region")
+	offeringsCmd.Flags().StringVar(&sortBy, "sort-by", "location", "Rank the locations by location or price; unknown prices go last")
+	offeringsCmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	offeringsCmd.Flags().BoolVarP(&allNS, "all-namespaces", "A", false, "Consider the ProviderProfiles of all namespaces, not only the one of --namespace")
+}
+
+// Offering is a zone of a ProviderProfile that can run the requested flavor
+// and image, with what they are called there.
+type Offering struct {
+	Platform string  `json:"platform"`
+	Region   string  `json:"region"`
+	Zone     string  `json:"zone"`
+	Profile  string  `json:"profile"`
+	Flavor   string  `json:"flavor,omitempty"`
+	Image    string  `json:"image,omitempty"`
+	Price    float64 `json:"price,omitempty"`
+}
+
+var offeringsCmd = &cobra.Command{
+	Use:   "offerings",
+	Short: "Show which providers, regions and zones can run a flavor and image",
+	Long: `Join the ProviderProfiles with the flavor and image mappings of the
+provider-mappings ConfigMaps, and show each enabled zone that offers both
+--flavor and --image, with the provider flavor and image they resolve to and
+the hourly price of the flavor. Without --flavor and --image every enabled zone
+with mappings is shown. With --sort-by price the cheapest zones come first.`,
+	Example: `  skycluster offerings --flavor 4vCPU-16GB --image ubuntu-22.04 --sort-by price
+  skycluster offerings --flavor 8vCPU-61GB-1xV100-16GB -p aws,gcp -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sortBy != "location" && sortBy != "price" {
+			return fmt.Errorf("unknown sort key %q (supported: location, price)", sortBy)
+		}
+		if output != "table" && output != "json" {
+			return fmt.Errorf("unknown output format %q (supported: table, json)", output)
+		}
+		cmd.SilenceUsage = true
+		offerings, err := find(cmd.Context())
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(offerings, func(i, j int) bool {
+			a, b := offerings[i], offerings[j]
+			if sortBy == "price" && a.Price != b.Price {
+				if (a.Price == 0) != (b.Price == 0) {
+					return b.Price == 0
+				}
+				return a.Price < b.Price
+			}
+			return a.Platform+"/"+a.Region+"/"+a.Zone < b.Platform+"/"+b.Region+"/"+b.Zone
+		})
+
+		if output == "json" {
+			out, err := json.MarshalIndent(offerings, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(out))
+			return err
+		}
+		if len(offerings) == 0 {
+			fmt.Println("No zone offers " + wanted() + ".")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		fmt.Fprintln(w, "PLATFORM\tREGION\tZONE\tPROFILE\tPROVIDER_FLAVOR\tPROVIDER_IMAGE\tPRICE/H")
+		for _, o := range offerings {
+			price := "-"
+			if o.Price > 0 {
+				price = strconv.FormatFloat(o.Price, 'f', -1, 64)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", o.Platform, o.Region, o.Zone, o.Profile, dash(o.Flavor), dash(o.Image), price)
+		}
+		return w.Flush()
+	},
+}
+
+// find returns the enabled zones of the ProviderProfiles whose mappings
+// offer the flavor and the image asked for.
+func find(ctx context.Context) ([]Offering, error) {
+	kubeconfig := viper.GetString("kubeconfig")
+	dyn, err := utils.GetDynamicClient(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("build dynamic client: %w", err)
+	}
+	cs, err := utils.GetClientset(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	list := resource.ProviderProfile.List
+	if allNS {
+		list = resource.ProviderProfile.ListAll
+	}
+	profiles, err := list(ctx, dyn)
+	if err != nil {
+		return nil, err
+	}
+	cms, err := cs.CoreV1().ConfigMaps(utils.SystemNamespace).List(ctx, metav1.ListOptions{LabelSelector: mappingsSelector})
+	if err != nil {
+		return nil, fmt.Errorf("listing the provider mappings: %w", err)
+	}
+	// mappings holds the data of the ConfigMaps by platform/region, then zone.
+	mappings := map[string]map[string]map[string]string{}
+	for _, cm := range cms.Items {
+		pr := cm.Labels["skycluster.io/provider-name"] + "/" + cm.Labels["skycluster.io/provider-region"]
+		if mappings[pr] == nil {
+			mappings[pr] = map[string]map[string]string{}
+		}
+		mappings[pr][cm.Labels["skycluster.io/provider-zone"]] = cm.Data
+	}
+
+	offerings := []Offering{}
+	for i := range profiles {
+		p := &profiles[i]
+		platform, reg := specOrStatus(p, "platform"), specOrStatus(p, "region")
+		if (len(platforms) > 0 && !slices.Contains(platforms, platform)) || (region != "" && reg != region) {
+			continue
+		}
+		byZone := mappings[platform+"/"+reg]
+		if len(byZone) == 0 {
+			debugf("no provider mappings for profile %s (%s/%s)", p.GetName(), platform, reg)
+			continue
+		}
+		for _, zone := range enabledZones(p, byZone) {
+			data, ok := byZone[zone]
+			if !ok {
+				debugf("no provider mappings for zone %s of profile %s", zone, p.GetName())
+				continue
+			}
+			o := Offering{Platform: platform, Region: reg, Zone: zone, Profile: p.GetName()}
+			if flavor != "" {
+				value, ok := lookup(data, "flavor", flavor)
+				if !ok {
+					continue
+				}
+				f := utils.ParseFlavor(flavor, value)
+				o.Flavor, o.Price = f.Name, f.Price
+			}
+			if image != "" {
+				value, ok := lookup(data, "image", image)
+				if !ok {
+					continue
+				}
+				o.Image = strings.TrimSpace(value)
+			}
+			offerings = append(offerings, o)
+		}
+	}
+	return offerings, nil
+}
+
+// enabledZones returns the zones of the profile p that are not disabled, or
+// the zones of its mappings if it lists none.
+func enabledZones(p *unstructured.Unstructured, byZone map[string]map[string]string) []string {
+	var zones []string
+	list, _, _ := unstructured.NestedSlice(p.Object, "spec", "zones")
+	for _, z := range list {
+		zm, ok := z.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := zm["name"].(string)
+		if enabled, ok := zm["enabled"].(bool); name == "" || (ok && !enabled) {
+			continue
+		}
+		zones = append(zones, name)
+	}
+	if len(list) == 0 {
+		for z := range byZone {
+			zones = append(zones, z)
+		}
+	}
+	return zones
+}
+
+// lookup returns the value of the key of data that offers name, either as
+// the key itself or after a leading kind.
+func lookup(data map[string]string, kind, name string) (string, bool) {
+	for k, v := range data {
+		if strings.Contains(k, kind) && (k == name || utils.MappingName(k, kind) == name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func specOrStatus(obj *unstructured.Unstructured, key string) string {
+	if s, _, _ := unstructured.NestedString(obj.Object, "spec", key); s != "" {
+		return s
+	}
+	s, _, _ := unstructured.NestedString(obj.Object, "status", key)
+	return s
+}
+
+// wanted describes what was asked for, for messages.
+func wanted() string {
+	switch {
+	case flavor != "" && image != "":
+		return fmt.Sprintf("flavor %s with image %s", flavor, image)
+	case flavor != "":
+		return "flavor " + flavor
+	case image != "":
+		return "image " + image
+	}
+	return "anything"
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// debugf logs a debug message of the offerings command.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("offerings", format, args...)
+}
+
+func GetOfferingsCmd() *cobra.Command {
+	return offeringsCmd
+}
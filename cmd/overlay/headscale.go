@@ -0,0 +1,161 @@
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// headscale is a client of the REST API of the headscale server.
+type headscale struct {
+	server string
+	apiKey string
+	http   *http.Client
+}
+
+// hsNode is a node registered with headscale.
+type hsNode struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	GivenName   string    `json:"givenName"`
+	User        hsUser    `json:"user"`
+	IPAddresses []string  `json:"ipAddresses"`
+	Online      bool      `json:"online"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+type hsUser struct {
+	Name string `json:"name"`
+}
+
+// displayName is the name headscale shows for n.
+func (n hsNode) displayName() string {
+	if n.GivenName != "" {
+		return n.GivenName
+	}
+	return n.Name
+}
+
+// hsPreAuthKey is a pre-auth key of a headscale user.
+type hsPreAuthKey struct {
+	ID         string    `json:"id"`
+	User       string    `json:"user"`
+	Key        string    `json:"key"`
+	Reusable   bool      `json:"reusable"`
+	Ephemeral  bool      `json:"ephemeral"`
+	Used       bool      `json:"used"`
+	Expiration time.Time `json:"expiration"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// expired reports whether k can no longer be used to join.
+func (k hsPreAuthKey) expired() bool {
+	return !k.Expiration.IsZero() && !k.Expiration.After(time.Now())
+}
+
+// newHeadscale returns a client of the API of the server of c.
+func newHeadscale(c connectionInfo) (*headscale, error) {
+	if c.apiKey == "" {
+		return nil, fmt.Errorf("no headscale API key: secret %s/%s not found and overlay.apiKey not set in the config file", systemNamespace, apiSecret)
+	}
+	return &headscale{server: strings.TrimSuffix(c.loginServer, "/"), apiKey: c.apiKey, http: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// do sends a request with the JSON of in, when not nil, to path and decodes
+// the response into out, when not nil.
+func (h *headscale) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.server+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	debugf("%s %s", method, path)
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return fmt.Errorf("headscale %s %s: %s: %s", method, path, resp.Status, e.Message)
+		}
+		return fmt.Errorf("headscale %s %s: %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding the response of headscale %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func (h *headscale) nodes(ctx context.Context) ([]hsNode, error) {
+	var out struct {
+		Nodes []hsNode `json:"nodes"`
+	}
+	if err := h.do(ctx, http.MethodGet, "/api/v1/node", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Nodes, nil
+}
+
+func (h *headscale) deleteNode(ctx context.Context, id string) error {
+	return h.do(ctx, http.MethodDelete, "/api/v1/node/"+url.PathEscape(id), nil, nil)
+}
+
+func (h *headscale) preAuthKeys(ctx context.Context, user string) ([]hsPreAuthKey, error) {
+	var out struct {
+		PreAuthKeys []hsPreAuthKey `json:"preAuthKeys"`
+	}
+	if err := h.do(ctx, http.MethodGet, "/api/v1/preauthkey?user="+url.QueryEscape(user), nil, &out); err != nil {
+		return nil, err
+	}
+	return out.PreAuthKeys, nil
+}
+
+// createPreAuthKey creates a key of user valid for ttl.
+func (h *headscale) createPreAuthKey(ctx context.Context, user string, reusable bool, ttl time.Duration) (hsPreAuthKey, error) {
+	in := map[string]interface{}{
+		"user":       user,
+		"reusable":   reusable,
+		"ephemeral":  false,
+		"expiration": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+	}
+	var out struct {
+		PreAuthKey hsPreAuthKey `json:"preAuthKey"`
+	}
+	if err := h.do(ctx, http.MethodPost, "/api/v1/preauthkey", in, &out); err != nil {
+		return hsPreAuthKey{}, err
+	}
+	return out.PreAuthKey, nil
+}
+
+func (h *headscale) expirePreAuthKey(ctx context.Context, user, key string) error {
+	return h.do(ctx, http.MethodPost, "/api/v1/preauthkey/expire", map[string]string{"user": user, "key": key}, nil)
+}
@@ -0,0 +1,322 @@
+package overlay
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	"github.com/etesami/skycluster-cli/cmd/config"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// defaultUser is the headscale user of the pre-auth keys unless --user or
+// overlay.user is set.
+const defaultUser = "skycluster"
+
+var (
+	removeStale  time.Duration
+	keysUser     string
+	keysAll      bool
+	rotateTTL    time.Duration
+	rotateSingle bool
+	rotateSave   bool
+	nodesTable   utils.TableOptions
+	keysTable    utils.TableOptions
+)
+
+var (
+	nodeHeaders = []string{"ID", "NAME", "USER", "ADDRESSES", "ONLINE", "LAST_SEEN"}
+	keyHeaders  = []string{"ID", "KEY", "REUSABLE", "USED", "EXPIRES", "CREATED"}
+)
+
+func init() {
+	nodesRemoveCmd.Flags().DurationVar(&removeStale, "stale", 0, "Remove the offline nodes not seen for this long, e.g. 168h, instead of the named ones")
+	utils.AddTableFlags(overlayNodesCmd, &nodesTable, "")
+	overlayNodesCmd.AddCommand(nodesRemoveCmd)
+	overlayCmd.AddCommand(overlayNodesCmd)
+
+	overlayKeysCmd.PersistentFlags().StringVar(&keysUser, "user", "", "Headscale user of the keys (default: overlay.user of the config file, or "+defaultUser+")")
+	keysListCmd.Flags().BoolVarP(&keysAll, "all", "a", false, "Also list the expired keys")
+	utils.AddTableFlags(keysListCmd, &keysTable, "")
+	keysRotateCmd.Flags().DurationVar(&rotateTTL, "ttl", 30*24*time.Hour, "How long the new key is valid")
+	keysRotateCmd.Flags().BoolVar(&rotateSingle, "single-use", false, "Make the new key usable for one join only")
+	keysRotateCmd.Flags().BoolVar(&rotateSave, "save", false, "Save the new key as overlay.token in the config file")
+	overlayKeysCmd.AddCommand(keysListCmd)
+	overlayKeysCmd.AddCommand(keysExpireCmd)
+	overlayKeysCmd.AddCommand(keysRotateCmd)
+	overlayCmd.AddCommand(overlayKeysCmd)
+}
+
+var overlayNodesCmd = &cobra.Command{
+	Use:   "nodes",
+	Short: "List the nodes registered with the headscale server",
+	Long: `List the nodes registered with the headscale server of the overlay, through
+its API with the key of the ` + apiSecret + ` secret setup creates, or else
+overlay.apiKey of the config file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.NewTable(nodeHeaders...).Check(nodesTable); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		hs, err := headscaleClient(cmd)
+		if err != nil {
+			return err
+		}
+		nodes, err := hs.nodes(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			fmt.Println("No nodes.")
+			return nil
+		}
+		return printNodes(nodes, nodesTable)
+	},
+}
+
+var nodesRemoveCmd = &cobra.Command{
+	Use:   "remove [name|id]...",
+	Short: "Remove nodes from the headscale server, by name or with --stale",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 0) == (removeStale == 0) {
+			return fmt.Errorf("name the nodes to remove or pass --stale")
+		}
+		cmd.SilenceUsage = true
+		hs, err := headscaleClient(cmd)
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		nodes, err := hs.nodes(ctx)
+		if err != nil {
+			return err
+		}
+		var remove []hsNode
+		if removeStale > 0 {
+			for _, n := range nodes {
+				if !n.Online && !n.LastSeen.IsZero() && time.Since(n.LastSeen) > removeStale {
+					remove = append(remove, n)
+				}
+			}
+		} else {
+			for _, a := range args {
+				found := false
+				for _, n := range nodes {
+					if n.ID == a || n.Name == a || n.GivenName == a {
+						remove = append(remove, n)
+						found = true
+					}
+				}
+				if !found {
+					return fmt.Errorf("no node %q (see: skycluster overlay nodes)", a)
+				}
+			}
+		}
+		if len(remove) == 0 {
+			fmt.Printf("No node has been offline for more than %s.\n", removeStale)
+			return nil
+		}
+		_ = printNodes(remove, utils.TableOptions{})
+		ok, err := resource.AskDelete(os.Stdin, os.Stdout, "nodes", len(remove))
+		if err != nil || !ok {
+			return err
+		}
+		for _, n := range remove {
+			if err := hs.deleteNode(ctx, n.ID); err != nil {
+				return fmt.Errorf("removing node %s: %w", n.displayName(), err)
+			}
+			fmt.Printf("Removed node %s\n", n.displayName())
+		}
+		return nil
+	},
+}
+
+var overlayKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "List, expire and rotate the pre-auth keys of the headscale server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pre-auth keys of the user",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.NewTable(keyHeaders...).Check(keysTable); err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		hs, err := headscaleClient(cmd)
+		if err != nil {
+			return err
+		}
+		keys, err := hs.preAuthKeys(cmd.Context(), user())
+		if err != nil {
+			return err
+		}
+		tbl := utils.NewTable(keyHeaders...)
+		for _, k := range keys {
+			if k.expired() && !keysAll {
+				continue
+			}
+			expires := "-"
+			if !k.Expiration.IsZero() {
+				expires = k.Expiration.Local().Format(time.RFC3339)
+				if k.expired() {
+					expires = "expired"
+				}
+			}
+			tbl.Append(k.ID, maskKey(k.Key), strconv.FormatBool(k.Reusable), strconv.FormatBool(k.Used), expires,
+				duration.HumanDuration(time.Since(k.CreatedAt))+" ago")
+		}
+		if len(tbl.Rows) == 0 {
+			fmt.Printf("No valid pre-auth keys of user %s.\n", user())
+			return nil
+		}
+		return tbl.Write(os.Stdout, keysTable)
+	},
+}
+
+var keysExpireCmd = &cobra.Command{
+	Use:   "expire <key>...",
+	Short: "Expire pre-auth keys, given in full or by the start shown by keys list",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		hs, err := headscaleClient(cmd)
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		keys, err := hs.preAuthKeys(ctx, user())
+		if err != nil {
+			return err
+		}
+		for _, a := range args {
+			k, err := findKey(keys, strings.TrimSuffix(a, "..."))
+			if err != nil {
+				return err
+			}
+			if err := hs.expirePreAuthKey(ctx, user(), k.Key); err != nil {
+				return err
+			}
+			fmt.Printf("Expired key %s\n", maskKey(k.Key))
+		}
+		return nil
+	},
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Create a new pre-auth key and expire the other valid keys of the user",
+	Long: `Create a new pre-auth key and expire the other valid keys of the user, so
+that only the new key can join machines from now on. The nodes that joined
+already stay. The new key is printed, and saved as overlay.token in the
+config file with --save.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		hs, err := headscaleClient(cmd)
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		old, err := hs.preAuthKeys(ctx, user())
+		if err != nil {
+			return err
+		}
+		k, err := hs.createPreAuthKey(ctx, user(), !rotateSingle, rotateTTL)
+		if err != nil {
+			return err
+		}
+		for _, o := range old {
+			if o.expired() || o.Key == k.Key {
+				continue
+			}
+			if err := hs.expirePreAuthKey(ctx, user(), o.Key); err != nil {
+				return fmt.Errorf("expiring key %s (the new key is %s): %w", maskKey(o.Key), k.Key, err)
+			}
+			debugf("expired key %s", maskKey(o.Key))
+		}
+		fmt.Printf("New pre-auth key of user %s, valid until %s:\n%s\n", user(), k.Expiration.Local().Format(time.RFC3339), k.Key)
+		if !rotateSave {
+			return nil
+		}
+		path, err := config.Path()
+		if err != nil {
+			return err
+		}
+		if err := config.Set(path, "overlay.token", k.Key); err != nil {
+			return err
+		}
+		fmt.Printf("Saved it as overlay.token in %s\n", path)
+		return nil
+	},
+}
+
+// headscaleClient returns a client of the API of the headscale server.
+func headscaleClient(cmd *cobra.Command) (*headscale, error) {
+	c, err := connection(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+	return newHeadscale(c)
+}
+
+// user returns the headscale user of the keys commands.
+func user() string {
+	if keysUser != "" {
+		return keysUser
+	}
+	if u := viper.GetString("overlay.user"); u != "" {
+		return u
+	}
+	return defaultUser
+}
+
+// findKey returns the key of keys starting with prefix, which must match one.
+func findKey(keys []hsPreAuthKey, prefix string) (hsPreAuthKey, error) {
+	var found []hsPreAuthKey
+	for _, k := range keys {
+		if prefix != "" && strings.HasPrefix(k.Key, prefix) {
+			found = append(found, k)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return hsPreAuthKey{}, fmt.Errorf("no pre-auth key %s of user %s (see: skycluster overlay keys list)", prefix, user())
+	case 1:
+		return found[0], nil
+	}
+	return hsPreAuthKey{}, fmt.Errorf("%s matches %d pre-auth keys, give more of it", prefix, len(found))
+}
+
+// maskKey shows the start of key only, enough to tell the keys apart.
+func maskKey(key string) string {
+	if len(key) <= 12 {
+		return key
+	}
+	return key[:12] + "..."
+}
+
+func printNodes(nodes []hsNode, opts utils.TableOptions) error {
+	tbl := utils.NewTable(nodeHeaders...)
+	for _, n := range nodes {
+		seen := "-"
+		if !n.LastSeen.IsZero() {
+			seen = duration.HumanDuration(time.Since(n.LastSeen)) + " ago"
+		}
+		tbl.Append(n.ID, n.displayName(), n.User.Name, strings.Join(n.IPAddresses, ","), strconv.FormatBool(n.Online), seen)
+	}
+	return tbl.Write(os.Stdout, opts)
+}
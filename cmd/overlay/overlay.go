@@ -0,0 +1,261 @@
+package overlay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/state"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	// connectionSecret is the secret setup creates with the address of the
+	// headscale server and a pre-auth key of it.
+	connectionSecret = "headscale-connection-secret"
+	// apiSecret is the secret setup creates with a key of the API of the
+	// headscale server.
+	apiSecret = "headscale-api-key"
+	// systemNamespace is where setup installs the control plane.
+	systemNamespace = utils.SystemNamespace
+
+	// nodeState is the state file recording the node joined by this machine.
+	nodeState        = "overlay"
+	nodeStateVersion = 1
+)
+
+// node is what join records about the machine in the overlay.
+type node struct {
+	LoginServer string    `json:"loginServer"`
+	Hostname    string    `json:"hostname"`
+	IPs         []string  `json:"ips"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+var (
+	joinHostname     string
+	joinAcceptRoutes bool
+)
+
+func init() {
+	overlayJoinCmd.Flags().StringVar(&joinHostname, "hostname", "", "Name of this machine in the overlay (default: its hostname)")
+	overlayJoinCmd.Flags().BoolVar(&joinAcceptRoutes, "accept-routes", true, "Reach the subnets the gateways advertise")
+	overlayCmd.AddCommand(overlayJoinCmd)
+	overlayCmd.AddCommand(overlayStatusCmd)
+	overlayCmd.AddCommand(overlayLeaveCmd)
+}
+
+var overlayCmd = &cobra.Command{
+	Use:   "overlay",
+	Short: "Join this machine to the overlay network of the gateways",
+	Long: `Join this machine to the overlay network that connects the gateways of the
+XProviders, run by the headscale server setup deploys. The machine is
+registered through the local tailscale daemon, which must be installed and
+running. The commands refuse to touch a daemon that is logged into another
+control server.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var overlayJoinCmd = &cobra.Command{
+	Use:   "join",
+	Short: "Register this machine with the headscale server of the overlay",
+	Long: `Register this machine with the headscale server of the overlay, using the
+address and pre-auth key of the ` + connectionSecret + ` secret setup creates,
+or else overlay.server, overlay.port and overlay.token of the config file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		ctx := cmd.Context()
+		if err := checkTailscale(); err != nil {
+			return err
+		}
+		c, err := connection(ctx)
+		if err != nil {
+			return err
+		}
+		if c.authKey == "" {
+			return fmt.Errorf("no pre-auth key: secret %s/%s has none and overlay.token is not set in the config file", systemNamespace, connectionSecret)
+		}
+		hostname := joinHostname
+		if hostname == "" {
+			if hostname, err = os.Hostname(); err != nil {
+				return fmt.Errorf("getting the hostname: %w", err)
+			}
+		}
+
+		if err := checkControlServer(ctx, c.loginServer); err != nil {
+			return err
+		}
+		debugf("joining %s as %s", c.loginServer, hostname)
+		err = utils.RunWithSpinnerContext(ctx, fmt.Sprintf("Joining the overlay at %s", c.loginServer), func(ctx context.Context) error {
+			return tailscaleUp(ctx, c.loginServer, c.authKey, hostname, joinAcceptRoutes)
+		})
+		if err != nil {
+			return err
+		}
+		st, err := tailscaleStatus(ctx)
+		if err != nil {
+			return err
+		}
+		n := node{LoginServer: c.loginServer, Hostname: hostname, IPs: st.Self.TailscaleIPs, JoinedAt: time.Now().UTC()}
+		if err := state.Save(nodeState, nodeStateVersion, n); err != nil {
+			return err
+		}
+		fmt.Printf("Joined the overlay as %s (%s)\n", hostname, strings.Join(n.IPs, ", "))
+		return nil
+	},
+}
+
+var overlayStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether this machine is in the overlay, and its peers",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		var n node
+		if err := state.Load(nodeState, nodeStateVersion, &n); err != nil {
+			return err
+		}
+		if n.LoginServer == "" {
+			fmt.Println("Not joined; join with: skycluster overlay join")
+			return nil
+		}
+		fmt.Printf("Joined %s as %s on %s\n", n.LoginServer, n.Hostname, n.JoinedAt.Local().Format(time.RFC1123))
+		if err := checkTailscale(); err != nil {
+			return err
+		}
+		st, err := tailscaleStatus(cmd.Context())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("State: %s, addresses: %s\n", st.BackendState, strings.Join(st.Self.TailscaleIPs, ", "))
+		if len(st.Peer) == 0 {
+			fmt.Println("No peers.")
+			return nil
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "PEER\tADDRESSES\tONLINE\tLAST SEEN")
+		for _, p := range st.sortedPeers() {
+			seen := "-"
+			if !p.LastSeen.IsZero() {
+				seen = p.LastSeen.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", p.HostName, strings.Join(p.TailscaleIPs, ","), p.Online, seen)
+		}
+		return w.Flush()
+	},
+}
+
+var overlayLeaveCmd = &cobra.Command{
+	Use:   "leave",
+	Short: "Log this machine out of the overlay and forget its node",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if err := checkTailscale(); err != nil {
+			return err
+		}
+		var n node
+		if err := state.Load(nodeState, nodeStateVersion, &n); err != nil {
+			return err
+		}
+		if n.LoginServer == "" {
+			return fmt.Errorf("no overlay join recorded; not logging out the tailscale daemon")
+		}
+		if err := checkControlServer(cmd.Context(), n.LoginServer); err != nil {
+			return err
+		}
+		if err := tailscaleLogout(cmd.Context()); err != nil {
+			return err
+		}
+		if err := state.Remove(nodeState); err != nil {
+			return err
+		}
+		fmt.Println("Left the overlay")
+		return nil
+	},
+}
+
+// connectionInfo is how to reach the headscale server.
+type connectionInfo struct {
+	loginServer string
+	// authKey is a pre-auth key to join with.
+	authKey string
+	// apiKey is a key of the API of headscale, to manage the nodes and the
+	// pre-auth keys.
+	apiKey string
+}
+
+// connection reads the connection and API secrets of the management
+// cluster, and falls back to the overlay settings of the config file for
+// what it cannot read.
+func connection(ctx context.Context) (connectionInfo, error) {
+	server, port := viper.GetString("overlay.server"), viper.GetString("overlay.port")
+	c := connectionInfo{authKey: viper.GetString("overlay.token"), apiKey: viper.GetString("overlay.apiKey")}
+	if c.authKey == "token" {
+		// the placeholder of configs/config.skycluster
+		c.authKey = ""
+	}
+	cs, err := utils.GetClientset(viper.GetString("kubeconfig"))
+	if err != nil {
+		debugf("no management cluster, using the config file: %v", err)
+	} else {
+		if s, err := cs.CoreV1().Secrets(systemNamespace).Get(ctx, connectionSecret, metav1.GetOptions{}); err != nil {
+			debugf("reading secret %s/%s failed, using the config file: %v", systemNamespace, connectionSecret, err)
+		} else {
+			setIf(&server, s.Data["server"])
+			setIf(&port, s.Data["port"])
+			setIf(&c.authKey, s.Data["token"])
+		}
+		if s, err := cs.CoreV1().Secrets(systemNamespace).Get(ctx, apiSecret, metav1.GetOptions{}); err != nil {
+			debugf("reading secret %s/%s failed, using the config file: %v", systemNamespace, apiSecret, err)
+		} else {
+			setIf(&c.apiKey, s.Data["apiKey"])
+		}
+	}
+	if server == "" || server == "server_ip" {
+		return connectionInfo{}, fmt.Errorf("no overlay server: secret %s/%s not found and overlay.server not set in the config file", systemNamespace, connectionSecret)
+	}
+	c.loginServer = loginServer(server, port)
+	return c, nil
+}
+
+func setIf(dst *string, v []byte) {
+	if len(v) > 0 {
+		*dst = string(v)
+	}
+}
+
+// loginServer returns the URL of the headscale server at server, which may
+// already be a URL, and port.
+func loginServer(server, port string) string {
+	if strings.Contains(server, "://") {
+		return server
+	}
+	if _, err := strconv.Atoi(port); err != nil || port == "443" {
+		return "https://" + server
+	}
+	return "https://" + net.JoinHostPort(server, port)
+}
+
+// debugf logs a debug message of the overlay commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("overlay", format, args...)
+}
+
+func GetOverlayCmd() *cobra.Command {
+	return overlayCmd
+}
@@ -0,0 +1,142 @@
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// status is the part of the output of tailscale status --json the overlay
+// commands show.
+type status struct {
+	BackendState string
+	Self         peer
+	Peer         map[string]peer
+}
+
+type peer struct {
+	HostName     string
+	TailscaleIPs []string
+	Online       bool
+	LastSeen     time.Time
+}
+
+func (s status) sortedPeers() []peer {
+	peers := make([]peer, 0, len(s.Peer))
+	for _, p := range s.Peer {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].HostName < peers[j].HostName })
+	return peers
+}
+
+// checkTailscale returns an error if the tailscale CLI is not installed.
+func checkTailscale() error {
+	if _, err := exec.LookPath("tailscale"); err != nil {
+		return fmt.Errorf("the tailscale CLI is not installed; see https://tailscale.com/download")
+	}
+	return nil
+}
+
+// tailscale runs the tailscale CLI with args and returns its output. Its
+// stderr is in the error.
+func tailscale(ctx context.Context, args ...string) ([]byte, error) {
+	debugf("running tailscale %s", strings.Join(redact(args), " "))
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "tailscale", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("tailscale %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("tailscale %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// redact hides the auth key of args from the logs.
+func redact(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		if strings.HasPrefix(a, "--auth-key=") {
+			a = "--auth-key=REDACTED"
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// tailscaleUp registers the machine with the headscale server at
+// loginServer. The other settings of the daemon are left alone, and the key
+// is passed in a file private to the user so that it does not show in ps.
+func tailscaleUp(ctx context.Context, loginServer, authKey, hostname string, acceptRoutes bool) error {
+	f, err := os.CreateTemp("", "skycluster-authkey-")
+	if err != nil {
+		return fmt.Errorf("writing the pre-auth key: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(authKey); err != nil {
+		f.Close()
+		return fmt.Errorf("writing the pre-auth key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing the pre-auth key: %w", err)
+	}
+	_, err = tailscale(ctx, "up",
+		"--login-server="+loginServer,
+		"--auth-key=file:"+f.Name(),
+		"--hostname="+hostname,
+		fmt.Sprintf("--accept-routes=%t", acceptRoutes))
+	return err
+}
+
+// checkControlServer returns an error if the tailscale daemon is logged into
+// a control server other than loginServer, so that the overlay commands never
+// move the user off a tailnet of their own.
+func checkControlServer(ctx context.Context, loginServer string) error {
+	st, err := tailscaleStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if st.BackendState == "NeedsLogin" || st.BackendState == "NoState" {
+		return nil
+	}
+	out, err := tailscale(ctx, "debug", "prefs")
+	if err != nil {
+		return err
+	}
+	var prefs struct{ ControlURL string }
+	if err := json.Unmarshal(out, &prefs); err != nil {
+		return fmt.Errorf("parsing tailscale prefs: %w", err)
+	}
+	if strings.TrimSuffix(prefs.ControlURL, "/") != strings.TrimSuffix(loginServer, "/") {
+		return fmt.Errorf("the tailscale daemon is logged into %s, not the overlay at %s; run tailscale logout first if it is safe to leave that tailnet", prefs.ControlURL, loginServer)
+	}
+	return nil
+}
+
+func tailscaleStatus(ctx context.Context) (status, error) {
+	out, err := tailscale(ctx, "status", "--json")
+	if err != nil {
+		return status{}, err
+	}
+	var s status
+	if err := json.Unmarshal(out, &s); err != nil {
+		return status{}, fmt.Errorf("parsing tailscale status: %w", err)
+	}
+	return s, nil
+}
+
+// tailscaleLogout disconnects the machine and expires its node key on the
+// server.
+func tailscaleLogout(ctx context.Context) error {
+	_, err := tailscale(ctx, "logout")
+	return err
+}
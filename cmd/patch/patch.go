@@ -0,0 +1,160 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/policy"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	patchType string
+	patchData string
+	patchFile string
+	dryRun    bool
+)
+
+func init() {
+	patchCmd.Flags().StringVar(&patchType, "type", "merge", "Patch type: merge or json")
+	patchCmd.Flags().StringVarP(&patchData, "patch", "p", "", "The patch, as JSON or YAML")
+	patchCmd.Flags().StringVar(&patchFile, "patch-file", "", "File holding the patch, as JSON or YAML")
+	patchCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only validate the patch and show the diff")
+	patchCmd.MarkFlagsMutuallyExclusive("patch", "patch-file")
+	patchCmd.MarkFlagsOneRequired("patch", "patch-file")
+}
+
+var patchCmd = &cobra.Command{
+	Use:   "patch <kind> <name>",
+	Short: "Patch a SkyCluster resource and show what changed",
+	Long: `Patch a SkyCluster resource in place, e.g.:
+
+  skycluster patch xkube gcp-us-east1 --type merge -p '{"spec":{"nodes":5}}'
+
+--type merge takes a JSON merge patch (RFC 7386), --type json a JSON patch
+(RFC 6902). The patch is first sent as a dry run, so the API server validates
+the result against the resource schema; the diff against the live object is
+shown, the result is checked against policies and it is then saved. With
+--dry-run nothing is saved.`,
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: typeNames(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		t, ok := resource.ForName(strings.ToLower(args[0]))
+		if !ok {
+			return fmt.Errorf("unknown kind %q (supported: %s)", args[0], strings.Join(typeNames(), ", "))
+		}
+		pt, err := parseType(patchType)
+		if err != nil {
+			return err
+		}
+		data, err := readPatch()
+		if err != nil {
+			return err
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		ri := t.Client(dyn)
+		live, err := utils.GetWithSuggestions(ctx, ri, t.Name, args[1])
+		if err != nil {
+			return err
+		}
+
+		debugf("dry-run %s patch of %s %s: %s", patchType, t.Kind, live.GetName(), string(data))
+		patched, err := ri.Patch(ctx, live.GetName(), pt, data, metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}})
+		if err != nil {
+			return fmt.Errorf("invalid patch for %s %s: %w", t.Kind, live.GetName(), err)
+		}
+		changed, err := utils.WriteObjectDiff(os.Stdout, live, patched)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Println("No changes.")
+			return nil
+		}
+		if err := policy.Enforce(ctx, patched, debugf); err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+
+		// Save the validated result against the live version, so a concurrent
+		// change makes the update fail instead of being overwritten.
+		patched.SetResourceVersion(live.GetResourceVersion())
+		audit.StampUpdate(patched, live)
+		err = utils.Retry(ctx, func(ctx context.Context) error {
+			_, err := ri.Update(ctx, patched, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("patching %s %s: %w", t.Kind, live.GetName(), err)
+		}
+		fmt.Printf("%s %s patched\n", t.Kind, live.GetName())
+		return nil
+	},
+}
+
+func parseType(s string) (types.PatchType, error) {
+	switch s {
+	case "merge":
+		return types.MergePatchType, nil
+	case "json":
+		return types.JSONPatchType, nil
+	default:
+		return "", fmt.Errorf("unsupported --type %q (merge or json)", s)
+	}
+}
+
+// readPatch returns the patch given with -p or --patch-file as JSON.
+func readPatch() ([]byte, error) {
+	raw := []byte(patchData)
+	if patchFile != "" {
+		b, err := os.ReadFile(resource.ExpandPath(patchFile))
+		if err != nil {
+			return nil, fmt.Errorf("reading patch file: %w", err)
+		}
+		raw = b
+	}
+	if strings.TrimSpace(string(raw)) == "" {
+		return nil, errors.New("the patch is empty")
+	}
+	data, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing patch: %w", err)
+	}
+	return data, nil
+}
+
+func typeNames() []string {
+	var names []string
+	for _, t := range resource.All() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// debugf logs a debug message of the patch commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("patch", format, args...)
+}
+
+func GetPatchCmd() *cobra.Command {
+	return patchCmd
+}
+
@@ -0,0 +1,183 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// Prefix is the prefix of the executables on PATH that become subcommands:
+// skycluster-billing runs as skycluster billing.
+const Prefix = "skycluster-"
+
+// Plugin is an executable found on PATH.
+type Plugin struct {
+	Name string
+	Path string
+}
+
+// List returns the plugins on PATH, in the order of PATH; a name found in
+// several directories is listed for each, the first one being the one run.
+func List() []Plugin {
+	var out []Plugin
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), Prefix)
+			if !ok || name == "" || e.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if info, err := os.Stat(path); err != nil || info.Mode()&0o111 == 0 {
+				continue
+			}
+			out = append(out, Plugin{Name: name, Path: path})
+		}
+	}
+	return out
+}
+
+// Lookup returns the path of the plugin name on PATH.
+func Lookup(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	path, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return "", false
+	}
+	debugf("found plugin %s at %s", name, path)
+	return path, true
+}
+
+// NewCommand returns the subcommand running the plugin at path. Its
+// arguments and flags are all passed to the plugin, along with the
+// settings of the CLI in the environment (see Env).
+func NewCommand(name, path string) *cobra.Command {
+	return &cobra.Command{
+		Use:                name,
+		Short:              "Plugin " + path,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			c := exec.Command(path, args...)
+			c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+			c.Env = append(os.Environ(), Env()...)
+			debugf("running %s %v", path, args)
+			// Interrupts reach the plugin through the terminal; keep running until it exits.
+			signal.Ignore(os.Interrupt)
+			err := c.Run()
+			var ee *exec.ExitError
+			if errors.As(err, &ee) {
+				code := ee.ExitCode()
+				if code < 0 {
+					code = 1
+				}
+				os.Exit(code)
+			}
+			return err
+		},
+	}
+}
+
+// Env returns the settings of the CLI for a plugin, with the management
+// cluster resolved from the flags, the config file and KUBECONFIG.
+func Env() []string {
+	self, _ := os.Executable()
+	return []string{
+		"SKYCLUSTER_KUBECONFIG=" + viper.GetString("kubeconfig"),
+		"SKYCLUSTER_CONTEXT=" + viper.GetString("context"),
+		"SKYCLUSTER_NAMESPACE=" + utils.Namespace(utils.SystemNamespace),
+		"SKYCLUSTER_CONFIG=" + viper.ConfigFileUsed(),
+		"SKYCLUSTER_BIN=" + self,
+	}
+}
+
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Commands for the plugins of the CLI",
+	Long: `Executables named skycluster-<name> on PATH run as skycluster <name>, with
+all the arguments after <name>, so that teams can add their own commands
+without changing the CLI. The global flags go before <name>:
+
+  skycluster --cluster staging billing --month 2026-09
+
+A plugin receives the settings of the CLI in the environment:
+
+  SKYCLUSTER_KUBECONFIG  kubeconfig of the management cluster; empty for the
+                         default of client-go
+  SKYCLUSTER_CONTEXT     context of the kubeconfig; empty for its current one
+  SKYCLUSTER_NAMESPACE   namespace of --namespace, or skycluster-system
+  SKYCLUSTER_CONFIG      config file in use, if any
+  SKYCLUSTER_BIN         path of the skycluster binary, to call it back
+
+Built-in commands take precedence over plugins of the same name.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the plugins found on PATH",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins := List()
+		if len(plugins) == 0 {
+			fmt.Printf("No plugins found: no executables named %s<name> on PATH.\n", Prefix)
+			return nil
+		}
+		tbl := utils.NewTable("NAME", "PATH", "NOTE")
+		first := map[string]string{}
+		for _, p := range plugins {
+			note := "-"
+			switch {
+			case builtin(cmd.Root(), p.Name):
+				note = "overridden by the built-in command"
+			case first[p.Name] != "":
+				note = "shadowed by " + first[p.Name]
+			default:
+				first[p.Name] = p.Path
+			}
+			tbl.Append(p.Name, p.Path, note)
+		}
+		return tbl.Write(os.Stdout, utils.TableOptions{})
+	},
+}
+
+// builtin reports whether root has a command or alias name that is not a
+// plugin.
+func builtin(root *cobra.Command, name string) bool {
+	for _, c := range root.Commands() {
+		if (c.Name() == name || c.HasAlias(name)) && !c.DisableFlagParsing {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	pluginCmd.AddCommand(pluginListCmd)
+}
+
+// debugf logs a debug message of the plugin commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("plugin", format, args...)
+}
+
+func GetPluginCmd() *cobra.Command {
+	return pluginCmd
+}
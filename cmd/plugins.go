@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/etesami/skycluster-cli/cmd/plugin"
+)
+
+// addPlugin adds the plugin named by the first positional argument of args
+// to root when root has no command of that name, and returns the args to
+// run it with. The plugin command passes all its flags to the plugin, so the
+// global flags before its name are parsed here.
+func addPlugin(root *cobra.Command, args []string) []string {
+	i := firstPositional(root, args)
+	if i < 0 || hasCommand(root, args[i]) {
+		return args
+	}
+	path, ok := plugin.Lookup(args[i])
+	if !ok {
+		return args
+	}
+	if err := root.PersistentFlags().Parse(args[:i]); err != nil {
+		// let cobra report the flag error
+		return args
+	}
+	root.AddCommand(plugin.NewCommand(args[i], path))
+	return args[i:]
+}
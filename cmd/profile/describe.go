@@ -0,0 +1,310 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var describeOutput string
+
+// generatedGVRs are the resources the provider generates for a profile,
+// named "<profile>-...".
+var generatedGVRs = []struct {
+	title string
+	gvr   schema.GroupVersionResource
+}{
+	{"Images", schema.GroupVersionResource{Group: "core.skycluster.io", Version: "v1alpha1", Resource: "images"}},
+	{"Instance types", schema.GroupVersionResource{Group: "core.skycluster.io", Version: "v1alpha1", Resource: "instancetypes"}},
+}
+
+func init() {
+	profileDescribeCmd.Flags().StringVarP(&describeOutput, "output", "o", "", "Output format: yaml or json; the default is a readable summary")
+	profileCmd.AddCommand(profileDescribeCmd)
+}
+
+var profileDescribeCmd = &cobra.Command{
+	Use:     "describe <name>",
+	Aliases: []string{"get"},
+	Short:   "Show the zones, offerings, references and conditions of a ProviderProfile",
+	Long: `Show a ProviderProfile in full: its platform and region, the zones and
+whether they are enabled, the other spec fields such as the enabled services,
+the provider configs it references, a summary of the images and instance
+types generated for it with their price range, and its conditions.
+
+With -o yaml or -o json the ProviderProfile itself is printed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		obj, err := utils.GetWithSuggestions(ctx, resource.ProviderProfile.Client(dyn), resource.ProviderProfile.Name, args[0])
+		if err != nil {
+			return err
+		}
+		obj = obj.DeepCopy()
+		unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+
+		switch describeOutput {
+		case "":
+			return describe(ctx, os.Stdout, dyn, obj)
+		case "yaml":
+			out, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(out)
+			return err
+		case "json":
+			out, err := json.MarshalIndent(obj.Object, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(out))
+			return err
+		}
+		return fmt.Errorf("unknown output format %q (supported: yaml, json)", describeOutput)
+	},
+}
+
+// describe writes a readable summary of the ProviderProfile obj to w.
+func describe(ctx context.Context, w io.Writer, dyn dynamic.Interface, obj *unstructured.Unstructured) error {
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	status, _, _ := unstructured.NestedMap(obj.Object, "status")
+	str := func(key string) string {
+		s, _ := spec[key].(string)
+		if s == "" {
+			s, _ = status[key].(string)
+		}
+		return orDash(s)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", obj.GetName())
+	fmt.Fprintf(tw, "Platform:\t%s\n", str("platform"))
+	fmt.Fprintf(tw, "Region:\t%s\n", str("region"))
+	fmt.Fprintf(tw, "Region alias:\t%s\n", str("regionAlias"))
+	fmt.Fprintf(tw, "Ready:\t%s\n", orDash(utils.GetConditionStatus(obj, "Ready")))
+	fmt.Fprintf(tw, "Age:\t%s\n", duration.HumanDuration(time.Since(obj.GetCreationTimestamp().Time)))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nZones:")
+	zones, _, _ := unstructured.NestedSlice(obj.Object, "spec", "zones")
+	if len(zones) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  NAME\tENABLED\tDEFAULT\tTYPE")
+		for _, z := range zones {
+			zm, ok := z.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", orDash(fmt.Sprint(zm["name"])), boolField(zm, "enabled"), boolField(zm, "defaultZone"), orDash(stringField(zm, "type")))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	// The remaining spec fields, e.g. enabled services and offering settings,
+	// differ between platforms and are shown as they are.
+	rest := map[string]interface{}{}
+	for k, v := range spec {
+		if !slices.Contains([]string{"platform", "region", "regionAlias", "zones"}, k) {
+			rest[k] = v
+		}
+	}
+	if len(rest) > 0 {
+		out, err := yaml.Marshal(rest)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "\nSpec:")
+		fmt.Fprint(w, indent(string(out), "  "))
+	}
+
+	fmt.Fprintln(w, "\nProvider configs:")
+	refs := append(references(spec, "spec"), references(status, "status")...)
+	if len(refs) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	}
+	for _, r := range refs {
+		fmt.Fprintf(w, "  %s\n", r)
+	}
+
+	fmt.Fprintln(w, "\nOfferings:")
+	for _, g := range generatedGVRs {
+		list, err := dyn.Resource(g.gvr).Namespace(obj.GetNamespace()).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			fmt.Fprintf(w, "  %s: %v\n", g.title, err)
+			continue
+		}
+		var ready, total int
+		for i := range list.Items {
+			if !strings.HasPrefix(list.Items[i].GetName(), obj.GetName()+"-") {
+				continue
+			}
+			total++
+			if utils.GetConditionStatus(&list.Items[i], "Ready") == "True" {
+				ready++
+			}
+		}
+		fmt.Fprintf(w, "  %s: %d (%d ready)\n", g.title, total, ready)
+		if g.gvr.Resource == "instancetypes" {
+			if s := priceRange(list.Items, obj.GetName()); s != "" {
+				fmt.Fprintf(w, "  Hourly price: %s\n", s)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "\nConditions:")
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if len(conds) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return nil
+	}
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+	for _, c := range conds {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		age := "-"
+		if t, err := time.Parse(time.RFC3339, stringField(cm, "lastTransitionTime")); err == nil {
+			age = duration.HumanDuration(time.Since(t))
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", orDash(stringField(cm, "type")), orDash(stringField(cm, "status")),
+			orDash(stringField(cm, "reason")), age, orDash(stringField(cm, "message")))
+	}
+	return tw.Flush()
+}
+
+// references returns the provider config references found in m, as
+// "<path>: <name>", looking at fields named *providerConfig* or ending in
+// Ref or Refs.
+func references(m map[string]interface{}, path string) []string {
+	var refs []string
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := strings.TrimPrefix(path+"."+k, ".")
+		isRef := strings.Contains(strings.ToLower(k), "providerconfig") || strings.HasSuffix(k, "Ref") || strings.HasSuffix(k, "Refs")
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			if name, ok := v["name"].(string); ok && isRef {
+				refs = append(refs, fmt.Sprintf("%s: %s", p, name))
+				continue
+			}
+			refs = append(refs, references(v, p)...)
+		case []interface{}:
+			for i, item := range v {
+				im, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				ip := fmt.Sprintf("%s[%d]", p, i)
+				if name, ok := im["name"].(string); ok && isRef {
+					refs = append(refs, fmt.Sprintf("%s: %s", ip, name))
+					continue
+				}
+				refs = append(refs, references(im, ip)...)
+			}
+		case string:
+			if isRef && v != "" {
+				refs = append(refs, fmt.Sprintf("%s: %s", p, v))
+			}
+		}
+	}
+	return refs
+}
+
+// priceRange returns the lowest and highest hourly offering price of the
+// InstanceTypes generated for profile, or "" when none has a price.
+func priceRange(instanceTypes []unstructured.Unstructured, profile string) string {
+	var prices []float64
+	for _, it := range instanceTypes {
+		if !strings.HasPrefix(it.GetName(), profile+"-") {
+			continue
+		}
+		zones, _, _ := unstructured.NestedSlice(it.Object, "spec", "offerings")
+		for _, z := range zones {
+			zm, ok := z.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			offerings, _, _ := unstructured.NestedSlice(zm, "zoneOfferings")
+			for _, o := range offerings {
+				om, ok := o.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if p, err := strconv.ParseFloat(strings.TrimPrefix(fmt.Sprint(om["price"]), "$"), 64); err == nil {
+					prices = append(prices, p)
+				}
+			}
+		}
+	}
+	if len(prices) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.4f - %.4f (%d offerings)", slices.Min(prices), slices.Max(prices), len(prices))
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func boolField(m map[string]interface{}, key string) string {
+	b, ok := m[key].(bool)
+	if !ok {
+		return "-"
+	}
+	return strconv.FormatBool(b)
+}
+
+func orDash(s string) string {
+	if s == "" || s == "<nil>" {
+		return "-"
+	}
+	return s
+}
+
+func indent(s, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -2,14 +2,14 @@ package profile
 
 import (
 	"github.com/spf13/cobra"
-)
 
-var debug bool
+	"github.com/etesami/skycluster-cli/internal/resource"
+)
 
 func init() {
-	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(resource.NewListCmd(resource.ProviderProfile))
 	profileCmd.AddCommand(profileCreateCmd)
-	profileCmd.AddCommand(profileDeleteCmd)
+	profileCmd.AddCommand(resource.NewDeleteCmd(resource.ProviderProfile, nil))
 }
 
 var profileCmd = &cobra.Command{
@@ -26,8 +26,3 @@ var profileCmd = &cobra.Command{
 func GetProfileCmd() *cobra.Command {
 	return profileCmd
 }
-
-// SetDebug sets package-level debug flag after CLI flags are parsed.
-func SetDebug(d bool) {
-	debug = d
-}
\ No newline at end of file
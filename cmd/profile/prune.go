@@ -0,0 +1,81 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var pruneDryRun bool
+
+func init() {
+	profilePruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Only list the unused ProviderProfiles")
+	profileCmd.AddCommand(profilePruneCmd)
+}
+
+var profilePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the ProviderProfiles no XProvider, XKube or XInstance uses",
+	Long: `Find the ProviderProfiles whose platform and region no XProvider, XKube or
+XInstance refers to through its providerRef, and delete them once confirmed.
+With --dry-run they are only listed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		profiles, err := resource.ProviderProfile.List(ctx, dyn)
+		if err != nil {
+			return err
+		}
+
+		// used holds the platform/region pairs referenced by providerRef.
+		used := map[string]bool{}
+		for _, t := range []*resource.Type{resource.XProvider, resource.XKube, resource.XInstance} {
+			items, err := t.List(ctx, dyn)
+			if err != nil {
+				return err
+			}
+			for i := range items {
+				platform, _, _ := unstructured.NestedString(items[i].Object, "spec", "providerRef", "platform")
+				region, _, _ := unstructured.NestedString(items[i].Object, "spec", "providerRef", "region")
+				used[platform+"/"+region] = true
+			}
+		}
+
+		var unused []string
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		for i := range profiles {
+			platform, _, _ := unstructured.NestedString(profiles[i].Object, "spec", "platform")
+			region, _, _ := unstructured.NestedString(profiles[i].Object, "spec", "region")
+			if used[platform+"/"+region] {
+				debugf("profile %s (%s/%s) is in use", profiles[i].GetName(), platform, region)
+				continue
+			}
+			if len(unused) == 0 {
+				fmt.Fprintln(writer, "NAME\tPLATFORM\tREGION")
+			}
+			unused = append(unused, profiles[i].GetName())
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", profiles[i].GetName(), platform, region)
+		}
+		if len(unused) == 0 {
+			fmt.Println("No unused ProviderProfiles found.")
+			return nil
+		}
+		fmt.Println("Unused ProviderProfiles:")
+		writer.Flush()
+		if pruneDryRun {
+			return nil
+		}
+		fmt.Println()
+		return resource.ProviderProfile.Delete(ctx, dyn, unused, nil)
+	},
+}
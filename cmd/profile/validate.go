@@ -0,0 +1,321 @@
+package profile
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+//go:embed schema/providerprofile.yaml
+var bundledSchema []byte
+
+var (
+	validateFile    string
+	validateOffline bool
+)
+
+// regionPattern is the form of the region names of the public clouds.
+var regionPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+func init() {
+	profileValidateCmd.Flags().StringVarP(&validateFile, "spec-file", "f", "", "Path to the ProviderProfile spec, as passed to create (required)")
+	profileValidateCmd.Flags().BoolVar(&validateOffline, "offline", false, "Use the bundled schema instead of the CRD of the cluster")
+	_ = profileValidateCmd.MarkFlagRequired("spec-file")
+	profileCmd.AddCommand(profileValidateCmd)
+}
+
+var profileValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a ProviderProfile spec before creating it",
+	Long: `Check a ProviderProfile spec file against the schema of the ProviderProfile
+CRD of the cluster, or the schema bundled with the CLI when the cluster cannot
+be reached or with --offline. Unknown fields, missing required keys and values
+of the wrong type are reported, as well as malformed regions and zones:
+duplicate zone names, several default zones, no enabled zone, and AWS or GCP
+zones outside the region.
+
+The file may hold several specs separated by ---, as for create. Nothing is
+changed on the cluster; the exit code is 1 when a spec is invalid.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		docs, err := resource.ProviderProfile.ReadSpecs(validateFile, "spec")
+		if err != nil {
+			return err
+		}
+		schema, source, err := profileSchema(cmd.Context())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "validating against the %s\n", source)
+		// Invalid specs are reported above, not with the usage.
+		cmd.SilenceUsage = true
+
+		invalid := 0
+		for _, d := range docs {
+			problems := validateSchema(schema, d.Spec, "spec")
+			problems = append(problems, checkZones(d.Spec)...)
+			if len(problems) == 0 {
+				fmt.Printf("%s: valid\n", d.Name)
+				continue
+			}
+			invalid++
+			fmt.Printf("%s: %d problem(s)\n", d.Name, len(problems))
+			for _, p := range problems {
+				fmt.Printf("  - %s\n", p)
+			}
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d specs are invalid", invalid, len(docs))
+		}
+		return nil
+	},
+}
+
+// profileSchema returns the schema of the ProviderProfile spec from the CRD
+// of the cluster, falling back to the bundled one, and where it came from.
+func profileSchema(ctx context.Context) (*apiextensionsv1.JSONSchemaProps, string, error) {
+	if !validateOffline {
+		s, err := liveSchema(ctx)
+		if err == nil {
+			return s, "ProviderProfile CRD of the cluster", nil
+		}
+		debugf("reading the ProviderProfile CRD: %v", err)
+		fmt.Fprintf(os.Stderr, "warning: cannot read the ProviderProfile CRD (%v); using the bundled schema\n", err)
+	}
+	var s apiextensionsv1.JSONSchemaProps
+	if err := yaml.Unmarshal(bundledSchema, &s); err != nil {
+		return nil, "", fmt.Errorf("parsing the bundled schema: %w", err)
+	}
+	return &s, "bundled schema", nil
+}
+
+func liveSchema(ctx context.Context) (*apiextensionsv1.JSONSchemaProps, error) {
+	cs, err := utils.GetClientsetExtended(viper.GetString("kubeconfig"))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	gvr := resource.ProviderProfile.GVR
+	crd, err := cs.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, gvr.Resource+"."+gvr.Group, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range crd.Spec.Versions {
+		if v.Name != gvr.Version || v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		spec, ok := v.Schema.OpenAPIV3Schema.Properties["spec"]
+		if !ok {
+			break
+		}
+		return &spec, nil
+	}
+	return nil, fmt.Errorf("CRD %s has no schema for the spec of %s", crd.Name, gvr.Version)
+}
+
+// validateSchema checks v against the structural schema s and returns the
+// problems found, prefixed with the path of the field.
+func validateSchema(s *apiextensionsv1.JSONSchemaProps, v interface{}, path string) []string {
+	if s == nil || s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields && len(s.Properties) == 0 {
+		return nil
+	}
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: must not be null", path)}
+	}
+	if s.XIntOrString {
+		switch v.(type) {
+		case string, int64, float64:
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: must be an integer or a string, got %s", path, typeName(v))}
+	}
+
+	var problems []string
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: must be an object, got %s", path, typeName(v))}
+		}
+		for _, r := range s.Required {
+			if _, ok := m[r]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: required field is missing", path, r))
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ps, ok := s.Properties[k]; ok {
+				problems = append(problems, validateSchema(&ps, m[k], path+"."+k)...)
+				continue
+			}
+			if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				problems = append(problems, validateSchema(s.AdditionalProperties.Schema, m[k], path+"."+k)...)
+				continue
+			}
+			if (s.AdditionalProperties != nil && s.AdditionalProperties.Allows) || (s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields) {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s.%s: unknown field%s", path, k, suggestField(k, s.Properties)))
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: must be a list, got %s", path, typeName(v))}
+		}
+		if s.MinItems != nil && int64(len(items)) < *s.MinItems {
+			problems = append(problems, fmt.Sprintf("%s: must have at least %d item(s)", path, *s.MinItems))
+		}
+		if s.MaxItems != nil && int64(len(items)) > *s.MaxItems {
+			problems = append(problems, fmt.Sprintf("%s: must have at most %d item(s)", path, *s.MaxItems))
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, item := range items {
+				problems = append(problems, validateSchema(s.Items.Schema, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: must be a string, got %s", path, typeName(v))}
+		}
+		if s.MinLength != nil && int64(len(str)) < *s.MinLength {
+			problems = append(problems, fmt.Sprintf("%s: must not be shorter than %d", path, *s.MinLength))
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(str) {
+				problems = append(problems, fmt.Sprintf("%s: %q does not match %s", path, str, s.Pattern))
+			}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%s: must be true or false, got %s", path, typeName(v))}
+		}
+	case "integer":
+		switch n := v.(type) {
+		case int64:
+		case float64:
+			if n != float64(int64(n)) {
+				return []string{fmt.Sprintf("%s: must be an integer, got %v", path, n)}
+			}
+		default:
+			return []string{fmt.Sprintf("%s: must be an integer, got %s", path, typeName(v))}
+		}
+	case "number":
+		switch v.(type) {
+		case int64, float64:
+		default:
+			return []string{fmt.Sprintf("%s: must be a number, got %s", path, typeName(v))}
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		got, _ := json.Marshal(v)
+		allowed := make([]string, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			allowed = append(allowed, string(e.Raw))
+		}
+		if !slices.Contains(allowed, string(got)) {
+			problems = append(problems, fmt.Sprintf("%s: %s is not one of %s", path, got, strings.Join(allowed, ", ")))
+		}
+	}
+	return problems
+}
+
+// checkZones reports region and zone problems the schema cannot express.
+func checkZones(spec map[string]interface{}) []string {
+	var problems []string
+	platform, _ := spec["platform"].(string)
+	region, _ := spec["region"].(string)
+	cloud := platform == "aws" || platform == "gcp" || platform == "azure"
+	if cloud && region != "" && !regionPattern.MatchString(region) {
+		problems = append(problems, fmt.Sprintf("spec.region: %q is not a valid %s region name", region, platform))
+	}
+
+	zones, _ := spec["zones"].([]interface{})
+	seen := map[string]bool{}
+	var defaults, enabled int
+	for i, z := range zones {
+		zm, ok := z.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := zm["name"].(string)
+		path := fmt.Sprintf("spec.zones[%d]", i)
+		if name != "" && seen[name] {
+			problems = append(problems, fmt.Sprintf("%s.name: duplicate zone %q", path, name))
+		}
+		seen[name] = true
+		// AWS zones are the region plus a letter, GCP zones the region, a
+		// dash and a letter.
+		if (platform == "aws" || platform == "gcp") && region != "" && name != "" && !strings.HasPrefix(name, region) {
+			problems = append(problems, fmt.Sprintf("%s.name: zone %q is not in region %q", path, name, region))
+		}
+		if d, _ := zm["defaultZone"].(bool); d {
+			defaults++
+		}
+		if e, ok := zm["enabled"].(bool); !ok || e {
+			enabled++
+		}
+	}
+	if len(zones) > 0 {
+		if defaults > 1 {
+			problems = append(problems, fmt.Sprintf("spec.zones: %d zones are marked defaultZone, at most one may be", defaults))
+		}
+		if enabled == 0 {
+			problems = append(problems, "spec.zones: no zone is enabled")
+		}
+	}
+	return problems
+}
+
+// suggestField returns a hint naming the known fields closest to k.
+func suggestField(k string, props map[string]apiextensionsv1.JSONSchemaProps) string {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	if s := utils.SuggestNames(k, names, 1); len(s) > 0 {
+		return fmt.Sprintf(" (did you mean %q?)", s[0])
+	}
+	return ""
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "a list"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int64, float64:
+		return "a number"
+	}
+	return fmt.Sprintf("%T", v)
+}
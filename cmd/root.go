@@ -1,25 +1,67 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	al "github.com/etesami/skycluster-cli/cmd/alert"
+	ap "github.com/etesami/skycluster-cli/cmd/apply"
+	bk "github.com/etesami/skycluster-cli/cmd/backup"
+	bg "github.com/etesami/skycluster-cli/cmd/budget"
+	ch "github.com/etesami/skycluster-cli/cmd/chaos"
+	ci "github.com/etesami/skycluster-cli/cmd/ci"
 	cl "github.com/etesami/skycluster-cli/cmd/cleanup"
+	cf "github.com/etesami/skycluster-cli/cmd/config"
+	ctl "github.com/etesami/skycluster-cli/cmd/controller"
+	cx "github.com/etesami/skycluster-cli/cmd/ctx"
+	dr "github.com/etesami/skycluster-cli/cmd/doctor"
+	ev "github.com/etesami/skycluster-cli/cmd/events"
+	ex "github.com/etesami/skycluster-cli/cmd/examples"
+	exp "github.com/etesami/skycluster-cli/cmd/export"
+	inv "github.com/etesami/skycluster-cli/cmd/inventory"
+	of "github.com/etesami/skycluster-cli/cmd/offerings"
+	ovl "github.com/etesami/skycluster-cli/cmd/overlay"
+	pa "github.com/etesami/skycluster-cli/cmd/patch"
+	pl "github.com/etesami/skycluster-cli/cmd/plugin"
 	pp "github.com/etesami/skycluster-cli/cmd/profile"
+	sc "github.com/etesami/skycluster-cli/cmd/scaffold"
+	srv "github.com/etesami/skycluster-cli/cmd/serve"
 	st "github.com/etesami/skycluster-cli/cmd/setup"
+	ss "github.com/etesami/skycluster-cli/cmd/state"
 	sub "github.com/etesami/skycluster-cli/cmd/subnet"
+	tn "github.com/etesami/skycluster-cli/cmd/tenant"
+	tl "github.com/etesami/skycluster-cli/cmd/timeline"
+	ui "github.com/etesami/skycluster-cli/cmd/ui"
+	us "github.com/etesami/skycluster-cli/cmd/unstick"
+	vr "github.com/etesami/skycluster-cli/cmd/version"
+	wh "github.com/etesami/skycluster-cli/cmd/whoami"
 	in "github.com/etesami/skycluster-cli/cmd/xinstance"
 	k8 "github.com/etesami/skycluster-cli/cmd/xkube"
 	pv "github.com/etesami/skycluster-cli/cmd/xprovider"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
 
 	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 var ns string
 var debug bool
+var verbosity int
+var logFormat string
+var assumeYes, force bool
 
 var rootCmd = &cobra.Command{
 	Short: "SkyCluster Cli is a tool to interact with SkyCluster API",
@@ -30,32 +72,169 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// The context of every command is cancelled on the first Ctrl-C or
+	// SIGTERM, so that watches and waits stop and print what they have; a
+	// second one kills the CLI as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := checkShadowedFlags(rootCmd); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	rootCmd.SetArgs(addPlugin(rootCmd, rewriteLegacyArgs(rootCmd, os.Args[1:], os.Stderr)))
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// checkShadowedFlags returns an error if a subcommand defines a flag with the
+// name of a global one, which cobra would silently let win over the global
+// flag.
+func checkShadowedFlags(root *cobra.Command) error {
+	var err error
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		for _, sub := range c.Commands() {
+			sub.LocalFlags().VisitAll(func(f *pflag.Flag) {
+				if err == nil && root.PersistentFlags().Lookup(f.Name) != nil {
+					err = fmt.Errorf("flag --%s of %q shadows the global --%s", f.Name, strings.TrimSpace(sub.CommandPath()), f.Name)
+				}
+			})
+			walk(sub)
+		}
+	}
+	walk(root)
+	return err
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file")
-	rootCmd.PersistentFlags().StringVar(&ns, "namespace", "", "namespace")
-	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&ns, "namespace", "", "Namespace (config: namespace)")
+	_ = viper.BindPFlag("namespace", rootCmd.PersistentFlags().Lookup("namespace"))
+	rootCmd.PersistentFlags().String("kubeconfig", "", "Kubeconfig of the management cluster (config: kubeconfig, default $KUBECONFIG)")
+	rootCmd.PersistentFlags().String("context", "", "Context of the kubeconfig to use instead of its current one (config: context)")
+	_ = viper.BindPFlag("kubeconfig", rootCmd.PersistentFlags().Lookup("kubeconfig"))
+	_ = viper.BindPFlag("context", rootCmd.PersistentFlags().Lookup("context"))
+	rootCmd.PersistentFlags().Bool("legacy-api-group", false, "Use the API group "+resource.LegacyGroup+" of management clusters set up by earlier releases (config: legacyAPIGroup)")
+	_ = viper.BindPFlag("legacyAPIGroup", rootCmd.PersistentFlags().Lookup("legacy-api-group"))
+	rootCmd.PersistentFlags().String("cluster", "", "Management cluster of the config file to use instead of the current one (see: skycluster ctx)")
+	_ = viper.BindPFlag("cluster", rootCmd.PersistentFlags().Lookup("cluster"))
+	rootCmd.PersistentFlags().Float32("kube-qps", 0, "Queries per second to the management cluster, 0 for the client-go default (config: client.qps)")
+	rootCmd.PersistentFlags().Int("kube-burst", 0, "Burst of queries to the management cluster, 0 for the client-go default (config: client.burst)")
+	_ = viper.BindPFlag("client.qps", rootCmd.PersistentFlags().Lookup("kube-qps"))
+	_ = viper.BindPFlag("client.burst", rootCmd.PersistentFlags().Lookup("kube-burst"))
+	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging (same as -vv)")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Log more: -v for info, -vv for debug messages")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", log.FormatText, "Format of the logs on stderr: text or json")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation before deleting")
+	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "Same as --yes")
+	rootCmd.PersistentFlags().String("progress", utils.ProgressAuto, "Progress output of waits: auto, tui, plain, or json lines on stdout (config: progress)")
+	_ = viper.BindPFlag("progress", rootCmd.PersistentFlags().Lookup("progress"))
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable colored output (config: output.noColor)")
+	_ = viper.BindPFlag("output.noColor", rootCmd.PersistentFlags().Lookup("no-color"))
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	// rootCmd.AddCommand(dp.GetDependencyCmd())
-	// rootCmd.AddCommand(ovl.GetOverlayCmd())
 
 	rootCmd.AddCommand(st.GetSetupCmd())
 	rootCmd.AddCommand(pp.GetProfileCmd())
 	rootCmd.AddCommand(pv.GetXProviderCmd())
 	rootCmd.AddCommand(in.GetXInstanceCmd())
+	rootCmd.AddCommand(of.GetOfferingsCmd())
 	rootCmd.AddCommand(k8.GetXKubeCmd())
 	rootCmd.AddCommand(sub.GetSubnetCmd())
+	rootCmd.AddCommand(ovl.GetOverlayCmd())
 	rootCmd.AddCommand(cl.GetCleanupCmd())
+	rootCmd.AddCommand(ex.GetExamplesCmd())
+	rootCmd.AddCommand(sc.GetScaffoldCmd())
+	rootCmd.AddCommand(wh.GetWhoAmICmd())
+	rootCmd.AddCommand(vr.GetVersionCmd())
+	rootCmd.AddCommand(vr.GetUpgradeCmd())
+	rootCmd.AddCommand(bk.GetBackupCmd())
+	rootCmd.AddCommand(bk.GetRestoreCmd())
+	rootCmd.AddCommand(exp.GetExportCmd())
+	rootCmd.AddCommand(ap.GetApplyCmd())
+	rootCmd.AddCommand(ap.GetDiffCmd())
+	rootCmd.AddCommand(bg.GetBudgetCmd())
+	rootCmd.AddCommand(ui.GetUICmd())
+	rootCmd.AddCommand(srv.GetServeCmd())
+	rootCmd.AddCommand(al.GetAlertCmd())
+	rootCmd.AddCommand(ci.GetCICmd())
+	rootCmd.AddCommand(tn.GetTenantCmd())
+	rootCmd.AddCommand(inv.GetInventoryCmd())
+	rootCmd.AddCommand(pa.GetPatchCmd())
+	rootCmd.AddCommand(tl.GetTimelineCmd())
+	rootCmd.AddCommand(ctl.GetControllerCmd())
+	rootCmd.AddCommand(ch.GetChaosCmd())
+	rootCmd.AddCommand(ss.GetStateCmd())
+	rootCmd.AddCommand(us.GetUnstickCmd())
+	rootCmd.AddCommand(ev.GetEventsCmd())
+	rootCmd.AddCommand(dr.GetDoctorCmd())
+	rootCmd.AddCommand(cx.GetCtxCmd())
+	rootCmd.AddCommand(cf.GetConfigCmd())
+	rootCmd.AddCommand(pl.GetPluginCmd())
+
+	// Registered resources without a dedicated command get the generic one.
+	for _, t := range resource.All() {
+		if !hasCommand(rootCmd, t.Name) {
+			rootCmd.AddCommand(resource.NewCommand(t))
+		}
+	}
+}
+
+func hasCommand(parent *cobra.Command, name string) bool {
+	for _, c := range parent.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
 }
 
 func initConfig() {
-	// Don't forget to read config either from cfgFile or from home directory!
+	// A config file that cannot be used does not stop the commands: it is
+	// recorded and only those that reach the management cluster fail with it,
+	// so that e.g. --help, subnet and 'config init' still work.
+	utils.SetConfigError(readConfig())
+	utils.SetClientRateLimits(float32(viper.GetFloat64("client.qps")), viper.GetInt("client.burst"))
+	if viper.GetBool("legacyAPIGroup") {
+		resource.UseLegacyGroup()
+	}
+	// The kubeconfig of the flag or the config file wins over KUBECONFIG; of
+	// a list of files, the first one is used.
+	if viper.GetString("kubeconfig") == "" {
+		if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 {
+			viper.Set("kubeconfig", paths[0])
+		}
+	}
+	if viper.GetBool("output.noColor") || os.Getenv("NO_COLOR") != "" {
+		pterm.DisableColor()
+	}
+
+	if debug && verbosity < 2 {
+		verbosity = 2
+	}
+	if err := log.Setup(os.Stderr, verbosity, logFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	utils.SetAssumeYes(assumeYes || force)
+	if err := utils.SetProgressMode(viper.GetString("progress")); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// readConfig reads the config file of --config or ~/.skycluster/config and
+// selects the management cluster in it. A missing ~/.skycluster/config is
+// not an error: the commands then run on the flags and KUBECONFIG. A missing
+// file given with --config is, rather than silently running without it.
+func readConfig() error {
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -63,8 +242,7 @@ func initConfig() {
 		// Find home directory.
 		home, err := homedir.Dir()
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			return fmt.Errorf("finding the config file: %w", err)
 		}
 
 		// Search config in home directory with name ".skycluster" (without extension).
@@ -73,16 +251,61 @@ func initConfig() {
 		viper.SetConfigType("yaml")
 	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		fmt.Println("Can't read config:", err)
-		os.Exit(1)
+	err := viper.ReadInConfig()
+	if cfgFile != "" && errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("config file %s not found; create it with: skycluster --config %s config init", cfgFile, cfgFile)
+	}
+	if err != nil && !isNotExist(err) {
+		return fmt.Errorf("can't read config: %w; fix it or recreate it with: skycluster config init --overwrite", err)
 	}
+	migrateKubeconfig()
+	return selectCluster()
+}
 
-	pp.SetDebug(debug)
-	st.SetDebug(debug)
-	in.SetDebug(debug)
-	pv.SetDebug(debug)
-	k8.SetDebug(debug)
-	cl.SetDebug(debug)
-	// sub.SetDebug(debug)
+// migrateKubeconfig reads the kubeconfig of config files of earlier
+// releases, a map with the one of the management cluster under sky-manager,
+// as the flat kubeconfig setting.
+func migrateKubeconfig() {
+	m, ok := viper.Get("kubeconfig").(map[string]interface{})
+	if !ok {
+		return
+	}
+	path, _ := m["sky-manager"].(string)
+	fmt.Fprintf(os.Stderr, "warning: kubeconfig.sky-manager in the config file is deprecated, set it with: skycluster config set kubeconfig %s\n", path)
+	if !rootCmd.PersistentFlags().Changed("kubeconfig") {
+		viper.Set("kubeconfig", path)
+	}
+}
+
+// isNotExist reports whether err of reading the config is no file found
+// through the search path.
+func isNotExist(err error) bool {
+	var notFound viper.ConfigFileNotFoundError
+	return errors.As(err, &notFound)
+}
+
+// selectCluster points the kubeconfig and context at the selected management
+// cluster of the config file, if any, unless --kubeconfig or --context were
+// given. An unknown currentCluster only warns, so that 'ctx use' can fix it.
+func selectCluster() error {
+	name := cx.Selected()
+	if name == "" {
+		return nil
+	}
+	c, ok := cx.Lookup(name)
+	if !ok {
+		if viper.GetString("cluster") != "" {
+			return fmt.Errorf("unknown cluster %q (see: skycluster ctx list)", name)
+		}
+		fmt.Fprintf(os.Stderr, "warning: unknown currentCluster %q, see: skycluster ctx list\n", name)
+		return nil
+	}
+	flags := rootCmd.PersistentFlags()
+	if !flags.Changed("kubeconfig") {
+		viper.Set("kubeconfig", c.Kubeconfig)
+	}
+	if !flags.Changed("context") {
+		viper.Set("context", c.Context)
+	}
+	return nil
 }
@@ -0,0 +1,103 @@
+package scaffold
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+
+	"github.com/etesami/skycluster-cli/cmd/subnet"
+)
+
+//go:embed templates/*.yaml
+var templates embed.FS
+
+// defaultVPC is the VPC CIDR the scaffolds are filled in with.
+const defaultVPC = "10.30.0.0/16"
+
+var platform string
+
+// platformData holds example values of each platform.
+type platformData struct {
+	Platform string
+	Region   string
+	Alias    string
+	Zone     string
+	VPC      string
+	Pods     string
+	Services string
+}
+
+var platforms = map[string]platformData{
+	"aws":       {Region: "us-east-1", Alias: "us-east", Zone: "us-east-1a"},
+	"gcp":       {Region: "us-east1", Alias: "us-east", Zone: "us-east1-b"},
+	"azure":     {Region: "eastus", Alias: "us-east", Zone: "1"},
+	"openstack": {Region: "RegionOne", Alias: "on-prem", Zone: "nova"},
+}
+
+func init() {
+	scaffoldCmd.Flags().StringVarP(&platform, "platform", "p", "aws", fmt.Sprintf("Platform to fill the spec in for (%s)", strings.Join(platformNames(), ", ")))
+}
+
+var scaffoldCmd = &cobra.Command{
+	Use:   "scaffold <xprovider|xkube|xinstance|profile>",
+	Short: "Print a commented example spec to start a spec file from",
+	Long: `Print an example spec of an XProvider, XKube, XInstance or ProviderProfile,
+with a comment on each field, filled in for the platform given with -p, e.g.:
+
+  skycluster scaffold xprovider -p gcp > xprovider.yaml
+
+Edit the file, then pass it to the create command of the resource with -f.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: kinds(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !slices.Contains(kinds(), args[0]) {
+			return fmt.Errorf("unknown resource %q (supported: %s)", args[0], strings.Join(kinds(), ", "))
+		}
+		d, ok := platforms[platform]
+		if !ok {
+			return fmt.Errorf("unknown platform %q (supported: %s)", platform, strings.Join(platformNames(), ", "))
+		}
+		d.Platform = platform
+		d.VPC = defaultVPC
+		r, err := subnet.Suggest(platform, defaultVPC)
+		if err != nil {
+			return err
+		}
+		d.Pods, d.Services = r.Pods, r.Services
+
+		t, err := template.ParseFS(templates, "templates/"+args[0]+".yaml")
+		if err != nil {
+			return err
+		}
+		return t.Execute(os.Stdout, d)
+	},
+}
+
+// kinds returns the resources a scaffold exists for, from the templates.
+func kinds() []string {
+	entries, _ := templates.ReadDir("templates")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	return names
+}
+
+func platformNames() []string {
+	names := make([]string, 0, len(platforms))
+	for n := range platforms {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func GetScaffoldCmd() *cobra.Command {
+	return scaffoldCmd
+}
@@ -0,0 +1,262 @@
+package serve
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	"github.com/etesami/skycluster-cli/internal/policy"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+// maxBody is the largest request body accepted.
+const maxBody = 1 << 20
+
+// beforeDelete are the hooks the delete commands run before deleting a
+// resource of a type.
+var beforeDelete = map[*resource.Type]resource.BeforeDelete{
+	resource.XKube: xk.PruneBeforeDelete,
+}
+
+// api serves the SkyCluster operations.
+type api struct {
+	lib *skycluster.Client
+}
+
+// typeInfo describes a resource type in /api/v1/resources.
+type typeInfo struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Resource   string `json:"resource"`
+	APIVersion string `json:"apiVersion"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// kindStatus is the status of a kind in /api/v1/status.
+type kindStatus struct {
+	Kind  string `json:"kind"`
+	Total int    `json:"total"`
+	Ready int    `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+func (a *api) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /api/v1/status", a.status)
+	mux.HandleFunc("GET /api/v1/resources", a.types)
+	mux.HandleFunc("GET /api/v1/resources/{type}", a.list)
+	mux.HandleFunc("POST /api/v1/resources/{type}", a.apply)
+	mux.HandleFunc("GET /api/v1/resources/{type}/{name}", a.get)
+	mux.HandleFunc("DELETE /api/v1/resources/{type}/{name}", a.delete)
+	mux.HandleFunc("GET /api/v1/xkubes/{name}/kubeconfig", a.kubeconfig)
+	return mux
+}
+
+// authenticate passes on the requests under /api/ that carry token as a
+// bearer token, and all others.
+func authenticate(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		debugf("%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				fail(w, http.StatusUnauthorized, "missing or wrong bearer token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *api) status(w http.ResponseWriter, r *http.Request) {
+	var out []kindStatus
+	for _, t := range resource.All() {
+		s := kindStatus{Kind: t.Kind}
+		items, err := a.lib.List(r.Context(), t.Kind, "")
+		if err != nil {
+			s.Error = err.Error()
+		}
+		for i := range items {
+			s.Total++
+			if utils.GetConditionStatus(&items[i], "Ready") == "True" {
+				s.Ready++
+			}
+		}
+		out = append(out, s)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *api) types(w http.ResponseWriter, r *http.Request) {
+	var out []typeInfo
+	for _, t := range resource.All() {
+		out = append(out, typeInfo{Name: t.Name, Kind: t.Kind, Resource: t.GVR.Resource, APIVersion: t.APIVersion(), Namespace: t.CurrentNamespace()})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a *api) list(w http.ResponseWriter, r *http.Request) {
+	t, ok := lookup(w, r)
+	if !ok {
+		return
+	}
+	items, err := a.lib.List(r.Context(), t.Kind, r.URL.Query().Get("selector"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (a *api) get(w http.ResponseWriter, r *http.Request) {
+	t, ok := lookup(w, r)
+	if !ok {
+		return
+	}
+	obj, err := a.lib.Get(r.Context(), t.Kind, r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, obj)
+}
+
+// apply creates or updates the object of the body as create does: it must
+// be of the type of the path, and the policies and checks of the type must
+// accept it.
+func (a *api) apply(w http.ResponseWriter, r *http.Request) {
+	t, ok := lookup(w, r)
+	if !ok {
+		return
+	}
+	u := &unstructured.Unstructured{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&u.Object); err != nil {
+		fail(w, http.StatusBadRequest, fmt.Sprintf("decoding the body: %v", err))
+		return
+	}
+	if u.GetKind() == "" {
+		u.SetKind(t.Kind)
+	}
+	if u.GetAPIVersion() == "" {
+		u.SetAPIVersion(t.APIVersion())
+	}
+	if u.GetKind() != t.Kind {
+		fail(w, http.StatusBadRequest, fmt.Sprintf("the body is a %s, not a %s", u.GetKind(), t.Kind))
+		return
+	}
+	if _, err := resource.Validate(u); err != nil {
+		fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := policy.Enforce(r.Context(), u, debugf); err != nil {
+		fail(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if t.Check != nil {
+		if err := t.Check(r.Context(), u); err != nil {
+			fail(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+	}
+	res, err := a.lib.Apply(r.Context(), u, r.URL.Query().Get("serverSide") == "true", r.URL.Query().Get("forceConflicts") == "true")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	debugf("%s %s %s", t.Kind, u.GetName(), res)
+	code := http.StatusOK
+	if res == skycluster.Created {
+		code = http.StatusCreated
+	}
+	writeJSON(w, code, map[string]string{"kind": t.Kind, "name": u.GetName(), "result": res})
+}
+
+func (a *api) delete(w http.ResponseWriter, r *http.Request) {
+	t, ok := lookup(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("name")
+	obj, err := a.lib.Get(r.Context(), t.Kind, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if before := beforeDelete[t]; before != nil {
+		if err := before(r.Context(), a.lib.Dynamic, obj); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if err := a.lib.Delete(r.Context(), t.Kind, name); err != nil {
+		writeError(w, err)
+		return
+	}
+	debugf("deleted %s %s", t.Kind, name)
+	writeJSON(w, http.StatusOK, map[string]string{"kind": t.Kind, "name": name, "result": "deleted"})
+}
+
+// kubeconfig returns the kubeconfig of an xkube as xkube config does.
+func (a *api) kubeconfig(w http.ResponseWriter, r *http.Request) {
+	kubeconfig, err := xk.GetConfig(r.Context(), r.PathValue("name"), "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	fmt.Fprint(w, kubeconfig)
+}
+
+// lookup returns the type the path names by name or plural, or fails the
+// request.
+func lookup(w http.ResponseWriter, r *http.Request) (*resource.Type, bool) {
+	name := strings.ToLower(r.PathValue("type"))
+	if t, ok := resource.ForName(name); ok {
+		return t, true
+	}
+	var names []string
+	for _, t := range resource.All() {
+		if t.GVR.Resource == name {
+			return t, true
+		}
+		names = append(names, t.Name)
+	}
+	fail(w, http.StatusNotFound, fmt.Sprintf("unknown resource type %q (supported: %s)", name, strings.Join(names, ", ")))
+	return nil, false
+}
+
+// writeError fails the request with the status of a Kubernetes API error,
+// or 500 for any other.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		code = int(status.Status().Code)
+	}
+	fail(w, code, err.Error())
+}
+
+func fail(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		debugf("writing the response: %v", err)
+	}
+}
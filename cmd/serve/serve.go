@@ -0,0 +1,160 @@
+package serve
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+// tokenEnv holds the API token when --token-file is not given.
+const tokenEnv = "SKYCLUSTER_API_TOKEN"
+
+var (
+	listenAddr string
+	tokenFile  string
+	tlsCert    string
+	tlsKey     string
+)
+
+func init() {
+	serveCmd.Flags().StringVar(&listenAddr, "listen", "127.0.0.1:8080", "Address to serve the API on")
+	serveCmd.Flags().StringVar(&tokenFile, "token-file", "", "File holding the bearer token clients must send (default: $"+tokenEnv+", or a new random token)")
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate to serve HTTPS with")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "", "Private key of --tls-cert")
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the SkyCluster operations over an authenticated HTTP API",
+	Long: `Serve the SkyCluster operations of the CLI over HTTP until interrupted, so
+that web UIs and other tools can drive SkyCluster without running the CLI:
+
+  GET    /healthz                               liveness, without a token
+  GET    /api/v1/status                         count and ready count per kind
+  GET    /api/v1/resources                      the resource types
+  GET    /api/v1/resources/<type>               list, ?selector= filters by labels
+  GET    /api/v1/resources/<type>/<name>        one resource
+  POST   /api/v1/resources/<type>               create or update the JSON object
+                                                of the body, ?serverSide=true to
+                                                use server-side apply and
+                                                &forceConflicts=true to take
+                                                over fields managed by others
+  DELETE /api/v1/resources/<type>/<name>        delete
+  GET    /api/v1/xkubes/<name>/kubeconfig       kubeconfig of an xkube
+
+<type> is a resource name (xkube, xinstance, profile, ...) or its plural
+(xkubes). Every request under /api/ must send Authorization: Bearer <token>,
+with the token of --token-file or $` + tokenEnv + `; without either a random
+token is generated and printed. Objects are created as the CLI does, with the
+policies enforced and the audit annotations set to the identity running serve.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (tlsCert == "") != (tlsKey == "") {
+			return errors.New("--tls-cert and --tls-key must be given together")
+		}
+		token, err := apiToken()
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		kubeconfig := viper.GetString("kubeconfig")
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		cs, err := utils.GetClientset(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build clientset: %w", err)
+		}
+		a := &api{lib: &skycluster.Client{Dynamic: dyn, Kube: cs, Logf: debugf}}
+
+		ln, err := net.Listen("tcp", listenAddr)
+		if err != nil {
+			return err
+		}
+		if tlsCert == "" && !loopback(ln.Addr()) {
+			fmt.Fprintf(os.Stderr, "warning: serving on %s without TLS; the token and kubeconfigs are sent in the clear\n", ln.Addr())
+		}
+		srv := &http.Server{
+			Handler:           authenticate(token, a.routes()),
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return cmd.Context() },
+		}
+		go func() {
+			<-cmd.Context().Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(ctx)
+		}()
+
+		scheme := "http"
+		if tlsCert != "" {
+			scheme = "https"
+		}
+		fmt.Printf("Serving the SkyCluster API on %s://%s\n", scheme, ln.Addr())
+		if tlsCert != "" {
+			err = srv.ServeTLS(ln, tlsCert, tlsKey)
+		} else {
+			err = srv.Serve(ln)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	},
+}
+
+// apiToken returns the token of --token-file or tokenEnv, or a new random one
+// it prints.
+func apiToken() (string, error) {
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading the token: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("%s is empty", tokenFile)
+		}
+		return token, nil
+	}
+	if token := strings.TrimSpace(os.Getenv(tokenEnv)); token != "" {
+		return token, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	fmt.Fprintf(os.Stderr, "No token given; clients must send: Authorization: Bearer %s\n", token)
+	return token, nil
+}
+
+// loopback reports whether addr only accepts local connections.
+func loopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// debugf logs a debug message of the serve command.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("serve", format, args...)
+}
+
+func GetServeCmd() *cobra.Command {
+	return serveCmd
+}
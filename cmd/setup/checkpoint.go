@@ -0,0 +1,154 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	// checkpointConfigMap records setup progress in skycluster-system so that
+	// `setup --resume` can continue after a failed run.
+	checkpointConfigMap = "skycluster-setup-progress"
+	checkpointKey       = "progress.json"
+)
+
+// setupCheckpoint is the persisted setup progress. Resources are keyed by their
+// KindDescription, which is also what the setup watch list uses in DependsOn.
+type setupCheckpoint struct {
+	Ready       map[string]string `json:"ready"` // id -> RFC3339 time it became ready
+	FailedStep  string            `json:"failedStep,omitempty"`
+	FailedError string            `json:"failedError,omitempty"`
+	UpdatedAt   string            `json:"updatedAt"`
+
+	ns string
+	cs *kubernetes.Clientset
+}
+
+// loadCheckpoint reads the checkpoint ConfigMap; a missing ConfigMap yields an empty checkpoint.
+func loadCheckpoint(ctx context.Context, cs *kubernetes.Clientset, ns string) (*setupCheckpoint, error) {
+	cp := &setupCheckpoint{Ready: map[string]string{}, ns: ns, cs: cs}
+	cm, err := cs.CoreV1().ConfigMaps(ns).Get(ctx, checkpointConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting configmap %s/%s: %w", ns, checkpointConfigMap, err)
+	}
+	if raw := cm.Data[checkpointKey]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), cp); err != nil {
+			return nil, fmt.Errorf("parsing setup checkpoint: %w", err)
+		}
+		if cp.Ready == nil {
+			cp.Ready = map[string]string{}
+		}
+	}
+	return cp, nil
+}
+
+// save writes the checkpoint back to its ConfigMap.
+func (c *setupCheckpoint) save(ctx context.Context) error {
+	c.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.RetryOnConflict(ctx, func(ctx context.Context) error {
+		cms := c.cs.CoreV1().ConfigMaps(c.ns)
+		existing, err := cms.Get(ctx, checkpointConfigMap, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = cms.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: c.ns,
+					Name:      checkpointConfigMap,
+					Labels:    map[string]string{"skycluster.io/managed-by": "skycluster"},
+				},
+				Data: map[string]string{checkpointKey: string(raw)},
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if existing.Data == nil {
+			existing.Data = map[string]string{}
+		}
+		existing.Data[checkpointKey] = string(raw)
+		_, err = cms.Update(ctx, existing, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// reset clears recorded progress, used when setup starts from scratch.
+func (c *setupCheckpoint) reset() {
+	c.Ready = map[string]string{}
+	c.FailedStep = ""
+	c.FailedError = ""
+}
+
+// skipReady drops specs already recorded as ready and removes them from the
+// DependsOn lists of the remaining specs.
+func (c *setupCheckpoint) skipReady(watchList []utils.WaitResourceSpec) []utils.WaitResourceSpec {
+	var out []utils.WaitResourceSpec
+	for _, spec := range watchList {
+		if _, ok := c.Ready[spec.KindDescription]; ok {
+			fmt.Fprintf(messages(), "Skipping %s (ready in a previous run)\n", spec.KindDescription)
+			continue
+		}
+		out = append(out, spec)
+	}
+	for i := range out {
+		var deps []string
+		for _, d := range out[i].DependsOn {
+			if _, ok := c.Ready[d]; !ok {
+				deps = append(deps, d)
+			}
+		}
+		out[i].DependsOn = deps
+	}
+	return out
+}
+
+// recordingSink wraps sink and persists every completion and failure. Sink
+// calls are serialized by the waiter, so no extra locking is needed.
+func (c *setupCheckpoint) recordingSink(ctx context.Context, sink utils.ProgressSink) utils.ProgressSink {
+	return func(ev utils.ProgressEvent) {
+		changed := false
+		switch {
+		case ev.Err != nil:
+			c.FailedStep = ev.KindDescription
+			c.FailedError = ev.Err.Error()
+			changed = true
+		case ev.ResourceCompleted:
+			c.Ready[ev.KindDescription] = time.Now().UTC().Format(time.RFC3339)
+			if c.FailedStep == ev.KindDescription {
+				c.FailedStep, c.FailedError = "", ""
+			}
+			changed = true
+		}
+		if changed {
+			if err := c.save(ctx); err != nil {
+				debugf("saving setup checkpoint failed: %v", err)
+			}
+		}
+		sink(ev)
+	}
+}
+
+// reportResume prints where a resumed setup picks up.
+func (c *setupCheckpoint) reportResume() {
+	if c.FailedStep != "" {
+		fmt.Fprintf(os.Stderr, "Resuming setup; previous run failed at %s: %s\n", c.FailedStep, c.FailedError)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Resuming setup; %d resource(s) already ready\n", len(c.Ready))
+}
@@ -0,0 +1,229 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// setupConfig is the declarative alternative to the setup flags, loaded with --file.
+//
+//	keys:
+//	  public: ~/.ssh/id_rsa.pub
+//	  private: ~/.ssh/id_rsa
+//	  generate: false      # true writes a new keypair to private instead
+//	  type: ed25519        # of the generated keypair: ed25519 or rsa
+//	apiServer: 203.0.113.10:6443
+//	submariner:
+//	  enabled: true
+//	watch:
+//	  timeout: 5m          # applies to every watched resource
+//	  pollInterval: 10s
+//	  timeouts:            # per resource, keyed by manifest name
+//	    headscale-server: 10m
+//	extraSecrets:
+//	  - name: my-registry
+//	    labels: {skycluster.io/secret-type: registry}
+//	    stringData: {username: bob}
+//	    files: {password: ~/.registry-pass}
+//	registries:          # private mirrors, see registryConfig
+//	  mirror: registry.example.org/mirror
+//	  pullSecret: my-registry
+type setupConfig struct {
+	Keys struct {
+		Public   string `json:"public"`
+		Private  string `json:"private"`
+		Generate bool   `json:"generate,omitempty"`
+		Type     string `json:"type,omitempty"`
+	} `json:"keys"`
+	APIServer  string `json:"apiServer"`
+	Submariner *struct {
+		Enabled bool `json:"enabled"`
+	} `json:"submariner,omitempty"`
+	Watch struct {
+		Timeout      string            `json:"timeout,omitempty"`
+		PollInterval string            `json:"pollInterval,omitempty"`
+		Timeouts     map[string]string `json:"timeouts,omitempty"`
+	} `json:"watch"`
+	ExtraSecrets []extraSecretSpec `json:"extraSecrets,omitempty"`
+	Registries   registryConfig    `json:"registries,omitempty"`
+
+	// parsed values, filled by validate
+	timeout      time.Duration
+	pollInterval time.Duration
+	timeouts     map[string]time.Duration
+	secrets      []*corev1.Secret
+}
+
+type extraSecretSpec struct {
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	StringData map[string]string `json:"stringData,omitempty"`
+	Files      map[string]string `json:"files,omitempty"` // key -> local file path
+}
+
+// loadSetupConfig reads and validates a setup file. Unknown fields are rejected so
+// typos surface before anything is applied to the cluster.
+func loadSetupConfig(path string) (*setupConfig, error) {
+	raw, err := os.ReadFile(expandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("reading setup file: %w", err)
+	}
+	var cfg setupConfig
+	if err := yaml.UnmarshalStrict(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing setup file %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid setup file %s: %w", path, err)
+	}
+	debugf("loaded setup file %s: apiServer=%q extraSecrets=%d", path, cfg.APIServer, len(cfg.secrets))
+	return &cfg, nil
+}
+
+func (c *setupConfig) validate() error {
+	var errs []string
+	if c.Keys.Generate {
+		if c.Keys.Type != "" && c.Keys.Type != "ed25519" && c.Keys.Type != "rsa" {
+			errs = append(errs, fmt.Sprintf("keys.type: unknown key type %q (supported: ed25519, rsa)", c.Keys.Type))
+		}
+	} else {
+		if strings.TrimSpace(c.Keys.Public) == "" {
+			errs = append(errs, "keys.public is required")
+		}
+		if strings.TrimSpace(c.Keys.Private) == "" {
+			errs = append(errs, "keys.private is required")
+		}
+	}
+	if strings.TrimSpace(c.APIServer) == "" {
+		errs = append(errs, "apiServer is required")
+	}
+
+	parse := func(field, v string) time.Duration {
+		if v == "" {
+			return 0
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			errs = append(errs, fmt.Sprintf("%s: invalid duration %q", field, v))
+			return 0
+		}
+		return d
+	}
+	c.timeout = parse("watch.timeout", c.Watch.Timeout)
+	c.pollInterval = parse("watch.pollInterval", c.Watch.PollInterval)
+	c.timeouts = map[string]time.Duration{}
+	for name, v := range c.Watch.Timeouts {
+		c.timeouts[name] = parse("watch.timeouts."+name, v)
+	}
+
+	seen := map[string]bool{}
+	for i, s := range c.ExtraSecrets {
+		field := fmt.Sprintf("extraSecrets[%d]", i)
+		ns := s.Namespace
+		if ns == "" {
+			ns = utils.SystemNamespace
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(s.Name) {
+			errs = append(errs, fmt.Sprintf("%s.name %q: %s", field, s.Name, msg))
+		}
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, fmt.Sprintf("%s.namespace %q: %s", field, ns, msg))
+		}
+		if seen[ns+"/"+s.Name] {
+			errs = append(errs, fmt.Sprintf("%s: duplicate secret %s/%s", field, ns, s.Name))
+		}
+		seen[ns+"/"+s.Name] = true
+		if len(s.StringData) == 0 && len(s.Files) == 0 {
+			errs = append(errs, fmt.Sprintf("%s: one of stringData or files is required", field))
+		}
+
+		data := map[string][]byte{}
+		for k, p := range s.Files {
+			b, err := os.ReadFile(expandPath(p))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s.files.%s: %v", field, k, err))
+				continue
+			}
+			data[k] = b
+		}
+		labels := map[string]string{"skycluster.io/managed-by": "skycluster"}
+		for k, v := range s.Labels {
+			labels[k] = v
+		}
+		secretType := corev1.SecretTypeOpaque
+		if s.Type != "" {
+			secretType = corev1.SecretType(s.Type)
+		}
+		c.secrets = append(c.secrets, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: s.Name, Labels: labels},
+			Type:       secretType,
+			StringData: s.StringData,
+			Data:       data,
+		})
+	}
+
+	errs = append(errs, c.Registries.validate()...)
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyFlags copies file values into the setup flags; flags given explicitly on the
+// command line take precedence over the file.
+func (c *setupConfig) applyFlags(cmd *cobra.Command) {
+	if !cmd.Flags().Changed("public") {
+		publicKeyPath = c.Keys.Public
+	}
+	if !cmd.Flags().Changed("private") {
+		privateKeyPath = c.Keys.Private
+	}
+	if !cmd.Flags().Changed("generate-keys") {
+		generateKeys = c.Keys.Generate
+	}
+	if !cmd.Flags().Changed("key-type") && c.Keys.Type != "" {
+		keyType = c.Keys.Type
+	}
+	if !cmd.Flags().Changed("apiserver") {
+		xsetupAPIServer = c.APIServer
+	}
+	if !cmd.Flags().Changed("submariner") && c.Submariner != nil {
+		xsetupSubmariner = c.Submariner.Enabled
+	}
+}
+
+// applyWatchOverrides adjusts timeouts and poll intervals of the watch list.
+func (c *setupConfig) applyWatchOverrides(watchList []utils.WaitResourceSpec) {
+	known := map[string]bool{}
+	for i := range watchList {
+		spec := &watchList[i]
+		known[spec.ManifestMetadataName] = true
+		if c.timeout > 0 {
+			spec.Timeout = c.timeout
+		}
+		if d, ok := c.timeouts[spec.ManifestMetadataName]; ok && d > 0 {
+			spec.Timeout = d
+		}
+		if c.pollInterval > 0 {
+			spec.PollInterval = c.pollInterval
+		}
+	}
+	for name := range c.timeouts {
+		if !known[name] {
+			fmt.Fprintf(os.Stderr, "warning: watch.timeouts.%s does not match any watched resource\n", name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package setup
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+)
+
+// defaultKeyPath is where --generate-keys writes the private key unless
+// --private is given; the public key goes next to it with a .pub suffix.
+const defaultKeyPath = "~/.ssh/skycluster_"
+
+// generateKeyPair creates a keypair of keyType, ed25519 or rsa, writes the
+// private key to privPath with mode 0600 and the public key to
+// privPath+".pub", and returns the path of the latter. Existing files are
+// not overwritten.
+func generateKeyPair(keyType, privPath string) (string, error) {
+	pubPath := privPath + ".pub"
+	for _, p := range []string{privPath, pubPath} {
+		if _, err := os.Stat(p); err == nil {
+			return "", fmt.Errorf("%s already exists; pass --private with a new path, or --public and --private without --generate-keys to use the existing keys", p)
+		}
+	}
+
+	var priv, pub []byte
+	switch keyType {
+	case "ed25519":
+		pk, sk, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		wire := sshWire([]byte("ssh-ed25519"), pk)
+		pub = authorizedKey("ssh-ed25519", wire)
+		priv, err = openSSHPrivateKey(wire, sk)
+		if err != nil {
+			return "", err
+		}
+	case "rsa":
+		sk, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			return "", err
+		}
+		wire := sshWire([]byte("ssh-rsa"), mpint(big.NewInt(int64(sk.E))), mpint(sk.N))
+		pub = authorizedKey("ssh-rsa", wire)
+		priv = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(sk)})
+	default:
+		return "", fmt.Errorf("unknown key type %q (supported: ed25519, rsa)", keyType)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(privPath), 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(privPath, priv, 0o600); err != nil {
+		return "", fmt.Errorf("writing the private key: %w", err)
+	}
+	if err := os.WriteFile(pubPath, pub, 0o644); err != nil {
+		return "", fmt.Errorf("writing the public key: %w", err)
+	}
+	debugf("generated %s keypair %s", keyType, privPath)
+	return pubPath, nil
+}
+
+// authorizedKey returns the public key line of the key with the SSH wire
+// encoding wire, as ssh-keygen writes it.
+func authorizedKey(keyType string, wire []byte) []byte {
+	return []byte(keyType + " " + base64.StdEncoding.EncodeToString(wire) + " skycluster\n")
+}
+
+// openSSHPrivateKey encodes the ed25519 key sk, whose public key has the
+// wire encoding pubWire, in the unencrypted openssh-key-v1 format.
+func openSSHPrivateKey(pubWire []byte, sk ed25519.PrivateKey) ([]byte, error) {
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+	private := append(check[:], check[:]...)
+	private = append(private, sshWire([]byte("ssh-ed25519"), sk.Public().(ed25519.PublicKey), sk, []byte("skycluster"))...)
+	// pad to the cipher block size of 8
+	for i := byte(1); len(private)%8 != 0; i++ {
+		private = append(private, i)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("openssh-key-v1\x00")
+	b.Write(sshWire([]byte("none"), []byte("none"), nil))
+	binary.Write(&b, binary.BigEndian, uint32(1))
+	b.Write(sshWire(pubWire, private))
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: b.Bytes()}), nil
+}
+
+// sshWire encodes fields as SSH strings, each prefixed with its length.
+func sshWire(fields ...[]byte) []byte {
+	var b bytes.Buffer
+	for _, f := range fields {
+		binary.Write(&b, binary.BigEndian, uint32(len(f)))
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+// mpint returns the bytes of the SSH mpint n, which is positive.
+func mpint(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
@@ -0,0 +1,167 @@
+package setup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	planFile   string
+	planOutput string
+)
+
+func init() {
+	setupPlanCmd.Flags().StringVarP(&planFile, "file", "f", "", "Setup file whose watch overrides to apply, as passed to setup --file")
+	setupPlanCmd.Flags().StringVarP(&planOutput, "output", "o", "table", "Output format: table, yaml or json")
+	setupCmd.AddCommand(setupPlanCmd)
+}
+
+// planEntry is one resource setup waits on, as printed by setup plan.
+type planEntry struct {
+	ID           string   `json:"id"`
+	Description  string   `json:"description"`
+	Group        string   `json:"group"`
+	Version      string   `json:"version"`
+	Resource     string   `json:"resource"`
+	Namespace    string   `json:"namespace,omitempty"`
+	ManifestName string   `json:"manifestName,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Condition    string   `json:"condition"`
+	Status       string   `json:"status,omitempty"`
+	Timeout      string   `json:"timeout"`
+	PollInterval string   `json:"pollInterval"`
+	DependsOn    []string `json:"dependsOn,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+var setupPlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Print the resources setup waits on, resolved to their Crossplane objects",
+	Long: `Print the resources setup waits on after creating the XSetup, with the
+name of the Crossplane object each one resolved to, its GVR, the condition
+setup waits for and its current status, and the timeouts, so that the exact
+objects can be inspected with kubectl when setup hangs, e.g.:
+
+  skycluster setup plan
+  kubectl get releases.helm.crossplane.io <NAME> -o yaml
+
+Resources that cannot be resolved yet are listed with the error. With --file
+the watch timeouts of the setup file are applied. Nothing is changed on the
+cluster.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if planOutput != "table" && planOutput != "yaml" && planOutput != "json" {
+			return fmt.Errorf("unknown output format %q (supported: table, yaml, json)", planOutput)
+		}
+		ctx := cmd.Context()
+		watchList := setupWatchList()
+		if planFile != "" {
+			c, err := loadSetupConfig(planFile)
+			if err != nil {
+				return err
+			}
+			c.applyWatchOverrides(watchList)
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+
+		entries := make([]planEntry, 0, len(watchList))
+		for i := range watchList {
+			spec := &watchList[i]
+			e := planEntry{
+				ID:           spec.ID,
+				Description:  spec.KindDescription,
+				Group:        spec.GVR.Group,
+				Version:      spec.GVR.Version,
+				Resource:     spec.GVR.Resource,
+				Namespace:    spec.Namespace,
+				ManifestName: spec.ManifestMetadataName,
+				Condition:    spec.ConditionType,
+				Timeout:      spec.Timeout.String(),
+				PollInterval: spec.PollInterval.String(),
+				DependsOn:    spec.DependsOn,
+			}
+			if e.ID == "" {
+				e.ID = spec.KindDescription
+			}
+			// Resolve one at a time so that one missing object does not hide
+			// the others.
+			if err := utils.ResolveResourceNamesFromManifest(ctx, dyn, watchList[i:i+1], debugf); err != nil {
+				e.Error = err.Error()
+				entries = append(entries, e)
+				continue
+			}
+			e.Name = spec.Name
+			var ri dynamic.ResourceInterface = dyn.Resource(spec.GVR)
+			if spec.Namespace != "" {
+				ri = dyn.Resource(spec.GVR).Namespace(spec.Namespace)
+			}
+			obj, err := ri.Get(ctx, spec.Name, metav1.GetOptions{})
+			if err != nil {
+				e.Error = err.Error()
+			} else {
+				e.Status = utils.GetConditionStatus(obj, spec.ConditionType)
+			}
+			entries = append(entries, e)
+		}
+
+		switch planOutput {
+		case "yaml":
+			out, err := yaml.Marshal(entries)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(out)
+			return err
+		case "json":
+			out, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(out))
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tRESOURCE\tNAME\tCONDITION\tSTATUS\tTIMEOUT\tDEPENDS ON")
+		for _, e := range entries {
+			name := e.Name
+			if name == "" {
+				name = "<unresolved: " + e.ManifestName + ">"
+			}
+			if e.Namespace != "" {
+				name = e.Namespace + "/" + name
+			}
+			status := e.Status
+			if status == "" {
+				status = "-"
+			}
+			deps := strings.Join(e.DependsOn, ", ")
+			if deps == "" {
+				deps = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s.%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.Resource, e.Group, name, e.Condition, status, e.Timeout, deps)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Error != "" {
+				fmt.Fprintf(os.Stderr, "warning: %s: %s\n", e.ID, e.Error)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,318 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// cloudProvider is how the credentials of a platform are read and handed to
+// its Crossplane provider.
+type cloudProvider struct {
+	platform string
+	// gvr is the ProviderConfig resource of the Crossplane provider.
+	gvr schema.GroupVersionResource
+	// env lists the variables read when there is no --credentials-file.
+	env []string
+	// credentials returns the content of the credentials secret from the
+	// file, or from env when file is empty.
+	credentials func(file string) ([]byte, error)
+	// spec adds platform specific fields to the ProviderConfig spec.
+	spec func(creds []byte, spec map[string]interface{}) error
+}
+
+var cloudProviders = []cloudProvider{
+	{
+		platform:    "aws",
+		gvr:         schema.GroupVersionResource{Group: "aws.upbound.io", Version: "v1beta1", Resource: "providerconfigs"},
+		env:         []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"},
+		credentials: awsCredentials,
+	},
+	{
+		platform:    "gcp",
+		gvr:         schema.GroupVersionResource{Group: "gcp.upbound.io", Version: "v1beta1", Resource: "providerconfigs"},
+		env:         []string{"GOOGLE_APPLICATION_CREDENTIALS"},
+		credentials: gcpCredentials,
+		spec:        gcpSpec,
+	},
+	{
+		platform:    "azure",
+		gvr:         schema.GroupVersionResource{Group: "azure.upbound.io", Version: "v1beta1", Resource: "providerconfigs"},
+		env:         []string{"AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET", "AZURE_SUBSCRIPTION_ID", "AZURE_TENANT_ID"},
+		credentials: jsonFromEnv(map[string]string{"clientId": "AZURE_CLIENT_ID", "clientSecret": "AZURE_CLIENT_SECRET", "subscriptionId": "AZURE_SUBSCRIPTION_ID", "tenantId": "AZURE_TENANT_ID"}),
+	},
+	{
+		platform: "openstack",
+		gvr:      schema.GroupVersionResource{Group: "openstack.crossplane.io", Version: "v1beta1", Resource: "providerconfigs"},
+		env:      []string{"OS_AUTH_URL", "OS_USERNAME", "OS_PASSWORD", "OS_PROJECT_NAME", "OS_REGION_NAME", "OS_USER_DOMAIN_NAME", "OS_PROJECT_DOMAIN_NAME"},
+		credentials: jsonFromEnv(map[string]string{"auth_url": "OS_AUTH_URL", "user_name": "OS_USERNAME", "password": "OS_PASSWORD",
+			"tenant_name": "OS_PROJECT_NAME", "region": "OS_REGION_NAME", "user_domain_name": "OS_USER_DOMAIN_NAME", "project_domain_name": "OS_PROJECT_DOMAIN_NAME"}),
+	},
+}
+
+var (
+	providerCredsFile  string
+	providerConfigName string
+	providerProject    string
+	providerWait       time.Duration
+)
+
+func init() {
+	setupProviderCmd.PersistentFlags().StringVar(&providerCredsFile, "credentials-file", "", "File with the credentials; without it they are read from the environment")
+	setupProviderCmd.PersistentFlags().StringVar(&providerConfigName, "name", "default", "Name of the ProviderConfig")
+	setupProviderCmd.PersistentFlags().DurationVar(&providerWait, "wait", 2*time.Minute, "How long to wait for the ProviderConfig to become Ready; 0 does not wait")
+	for _, p := range cloudProviders {
+		cmd := newProviderCmd(p)
+		if p.platform == "gcp" {
+			cmd.Flags().StringVar(&providerProject, "project", "", "GCP project (default: project_id of the service account key)")
+		}
+		setupProviderCmd.AddCommand(cmd)
+	}
+	setupCmd.AddCommand(setupProviderCmd)
+}
+
+var setupProviderCmd = &cobra.Command{
+	Use:   "provider",
+	Short: "Add the cloud credentials of a platform to the management cluster",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func newProviderCmd(p cloudProvider) *cobra.Command {
+	return &cobra.Command{
+		Use:   p.platform,
+		Short: "Store the " + p.platform + " credentials and create the ProviderConfig using them",
+		Long: `Store the ` + p.platform + ` credentials in a secret of skycluster-system and create
+the ` + p.gvr.GroupResource().String() + ` object that points at it, then wait
+for it to become Ready. The credentials are read from --credentials-file, or
+else from ` + strings.Join(p.env, ", ") + `.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			creds, err := p.credentials(expandPath(providerCredsFile))
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return addProvider(cmd.Context(), p, creds)
+		},
+	}
+}
+
+// addProvider creates or updates the credentials secret and the
+// ProviderConfig of p, and waits for the latter to become Ready.
+func addProvider(ctx context.Context, p cloudProvider, creds []byte) error {
+	ns := utils.SystemNamespace
+	kubeconfig := viper.GetString("kubeconfig")
+	clientset, err := utils.GetClientset(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("build kubernetes clientset: %w", err)
+	}
+	dyn, err := utils.GetDynamicClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("build dynamic client: %w", err)
+	}
+	if err := createOrUpdateNamespace(ctx, clientset, ns); err != nil {
+		return err
+	}
+
+	labels := map[string]string{
+		"skycluster.io/managed-by":    "skycluster",
+		"skycluster.io/secret-type":   "provider-credentials",
+		"skycluster.io/provider-name": p.platform,
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: p.platform + "-" + providerConfigName + "-credentials", Labels: labels},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"credentials": creds},
+	}
+	if err := createOrUpdateSecret(ctx, clientset, secret); err != nil {
+		return fmt.Errorf("create/update secret %s: %w", secret.Name, err)
+	}
+	fmt.Printf("Secret %s/%s stored\n", ns, secret.Name)
+
+	spec := map[string]interface{}{
+		"credentials": map[string]interface{}{
+			"source": "Secret",
+			"secretRef": map[string]interface{}{
+				"namespace": ns,
+				"name":      secret.Name,
+				"key":       "credentials",
+			},
+		},
+	}
+	if p.spec != nil {
+		if err := p.spec(creds, spec); err != nil {
+			return err
+		}
+	}
+	pc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": p.gvr.GroupVersion().String(),
+		"kind":       "ProviderConfig",
+		"metadata": map[string]interface{}{
+			"name":   providerConfigName,
+			"labels": map[string]interface{}{"skycluster.io/managed-by": "skycluster", "skycluster.io/provider-name": p.platform},
+		},
+		"spec": spec,
+	}}
+	if err := createOrUpdateProviderConfig(ctx, dyn, p.gvr, pc); err != nil {
+		return fmt.Errorf("create/update ProviderConfig %s: %w", providerConfigName, err)
+	}
+	fmt.Printf("ProviderConfig %s (%s) applied\n", providerConfigName, p.gvr.Group)
+
+	if providerWait == 0 {
+		return nil
+	}
+	err = utils.RunWithSpinnerContext(ctx, fmt.Sprintf("Waiting for ProviderConfig %s to become Ready", providerConfigName), func(ctx context.Context) error {
+		return utils.WaitForResourceReady(ctx, dyn, utils.WaitResourceSpec{
+			KindDescription: p.platform + " ProviderConfig",
+			GVR:             p.gvr,
+			Name:            providerConfigName,
+			ConditionType:   "Ready",
+			Timeout:         providerWait,
+			PollInterval:    5 * time.Second,
+		}, debugf)
+	})
+	if err != nil {
+		return fmt.Errorf("ProviderConfig %s is not Ready: %w (see: kubectl describe %s %s)", providerConfigName, err, p.gvr.GroupResource(), providerConfigName)
+	}
+	fmt.Printf("ProviderConfig %s is Ready\n", providerConfigName)
+	return nil
+}
+
+// createOrUpdateProviderConfig creates the cluster-scoped ProviderConfig u,
+// or replaces the spec and labels of the existing one.
+func createOrUpdateProviderConfig(ctx context.Context, dyn dynamic.Interface, gvr schema.GroupVersionResource, u *unstructured.Unstructured) error {
+	ri := dyn.Resource(gvr)
+	return utils.RetryOnConflict(ctx, func(ctx context.Context) error {
+		existing, err := ri.Get(ctx, u.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			debugf("ProviderConfig %s not found, creating", u.GetName())
+			_, err = ri.Create(ctx, u, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		debugf("ProviderConfig %s exists, updating", u.GetName())
+		existing.Object["spec"] = u.Object["spec"]
+		existing.SetLabels(u.GetLabels())
+		_, err = ri.Update(ctx, existing, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// awsCredentials returns an AWS shared credentials file: the given file as
+// it is, or a default profile built from the environment.
+func awsCredentials(file string) ([]byte, error) {
+	if file != "" {
+		return readCredentials(file)
+	}
+	id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return nil, fmt.Errorf("no AWS credentials: pass --credentials-file or set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	creds := "[default]\naws_access_key_id = " + id + "\naws_secret_access_key = " + secret + "\n"
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		creds += "aws_session_token = " + token + "\n"
+	}
+	return []byte(creds), nil
+}
+
+// gcpCredentials returns the JSON key of a service account, from the file or
+// GOOGLE_APPLICATION_CREDENTIALS.
+func gcpCredentials(file string) ([]byte, error) {
+	if file == "" {
+		file = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if file == "" {
+		return nil, fmt.Errorf("no GCP credentials: pass --credentials-file or set GOOGLE_APPLICATION_CREDENTIALS to a service account key file")
+	}
+	creds, err := readCredentials(file)
+	if err != nil {
+		return nil, err
+	}
+	var key struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(creds, &key); err != nil || key.Type != "service_account" {
+		return nil, fmt.Errorf("%s is not a service account key file", file)
+	}
+	return creds, nil
+}
+
+// gcpSpec sets the project of the ProviderConfig, which the GCP provider
+// requires.
+func gcpSpec(creds []byte, spec map[string]interface{}) error {
+	project := providerProject
+	if project == "" {
+		var key struct {
+			ProjectID string `json:"project_id"`
+		}
+		_ = json.Unmarshal(creds, &key)
+		project = key.ProjectID
+	}
+	if project == "" {
+		return fmt.Errorf("no GCP project: the key has no project_id, pass --project")
+	}
+	spec["projectID"] = project
+	return nil
+}
+
+// jsonFromEnv returns a credentials func reading a JSON file, or building the
+// JSON object of vars, mapping its keys to environment variables, which must
+// all be set.
+func jsonFromEnv(vars map[string]string) func(file string) ([]byte, error) {
+	return func(file string) ([]byte, error) {
+		if file != "" {
+			creds, err := readCredentials(file)
+			if err != nil {
+				return nil, err
+			}
+			if !json.Valid(creds) {
+				return nil, fmt.Errorf("%s is not a JSON file", file)
+			}
+			return creds, nil
+		}
+		obj := map[string]string{}
+		var missing []string
+		for k, env := range vars {
+			v := os.Getenv(env)
+			if v == "" {
+				missing = append(missing, env)
+			}
+			obj[k] = v
+		}
+		sort.Strings(missing)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("no credentials: pass --credentials-file or set %s", strings.Join(missing, ", "))
+		}
+		return json.Marshal(obj)
+	}
+}
+
+func readCredentials(file string) ([]byte, error) {
+	creds, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading the credentials: %w", err)
+	}
+	if len(strings.TrimSpace(string(creds))) == 0 {
+		return nil, fmt.Errorf("credentials file %s is empty", file)
+	}
+	return creds, nil
+}
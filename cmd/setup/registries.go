@@ -0,0 +1,344 @@
+package setup
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// registryConfig redirects what setup installs to private mirrors, for
+// environments that cannot pull from the public registries and chart
+// repositories the compositions use.
+//
+//	registries:
+//	  mirror: registry.example.org/mirror   # replaces the registry of every image of the Objects
+//	  pullSecret: skycluster-registry       # imagePullSecret added to the Objects and Releases
+//	  credentialHelper: ecr-login           # optional, fills pullSecret from docker-credential-ecr-login
+//	  charts:                               # by chart name; "*" applies to all others
+//	    submariner-operator:
+//	      repository: oci://registry.example.org/charts
+//	      version: 0.18.0
+//	      values: {operator: {image: {repository: registry.example.org/submariner-operator}}}
+type registryConfig struct {
+	Mirror           string                   `json:"mirror,omitempty"`
+	PullSecret       string                   `json:"pullSecret,omitempty"`
+	CredentialHelper string                   `json:"credentialHelper,omitempty"`
+	Charts           map[string]chartOverride `json:"charts,omitempty"`
+}
+
+type chartOverride struct {
+	Repository string                 `json:"repository,omitempty"`
+	Version    string                 `json:"version,omitempty"`
+	Values     map[string]interface{} `json:"values,omitempty"`
+}
+
+func (r *registryConfig) empty() bool {
+	return r.Mirror == "" && r.PullSecret == "" && len(r.Charts) == 0
+}
+
+func (r *registryConfig) validate() []string {
+	var errs []string
+	if r.Mirror != "" {
+		if strings.Contains(r.Mirror, "://") || strings.HasSuffix(r.Mirror, "/") {
+			errs = append(errs, fmt.Sprintf("registries.mirror %q: expected host[:port][/path] without scheme", r.Mirror))
+		}
+	}
+	if r.PullSecret != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(r.PullSecret) {
+			errs = append(errs, fmt.Sprintf("registries.pullSecret %q: %s", r.PullSecret, msg))
+		}
+	}
+	if r.CredentialHelper != "" && (r.Mirror == "" || r.PullSecret == "") {
+		errs = append(errs, "registries.credentialHelper needs registries.mirror and registries.pullSecret")
+	}
+	for name, c := range r.Charts {
+		if c.Repository == "" {
+			continue
+		}
+		u, err := url.Parse(c.Repository)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "oci") {
+			errs = append(errs, fmt.Sprintf("registries.charts.%s.repository %q: expected an http, https or oci URL", name, c.Repository))
+		}
+	}
+	return errs
+}
+
+// pullSecretFromHelper runs docker-credential-<helper> for the mirror and
+// returns the credentials as a dockerconfigjson secret, or nil when no
+// helper is configured and the pull secret is expected to exist already.
+func (r *registryConfig) pullSecretFromHelper(ctx context.Context, ns string) (*corev1.Secret, error) {
+	if r.CredentialHelper == "" {
+		return nil, nil
+	}
+	host, _, _ := strings.Cut(r.Mirror, "/")
+	helper := "docker-credential-" + r.CredentialHelper
+	debugf("running %s get for %s", helper, host)
+	c := exec.CommandContext(ctx, helper, "get")
+	c.Stdin = strings.NewReader(host)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %v: %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return nil, fmt.Errorf("parsing output of %s: %w", helper, err)
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Secret))
+	cfg, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			host: map[string]string{"username": creds.Username, "password": creds.Secret, "auth": auth},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      r.PullSecret,
+			Labels: map[string]string{
+				"skycluster.io/managed-by":  "skycluster",
+				"skycluster.io/secret-type": "registry",
+			},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: cfg},
+	}, nil
+}
+
+// injectRegistries applies the overrides to the resolved Objects and
+// Releases of the watch list, and keeps re-applying them every interval
+// until the returned stop function is called, since Crossplane may
+// reconcile the composed resources back to what the composition says.
+func injectRegistries(ctx context.Context, dyn dynamic.Interface, cs *kubernetes.Clientset, r *registryConfig, watchList []utils.WaitResourceSpec, interval time.Duration) (stop func()) {
+	if r == nil || r.empty() {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		copied := map[string]bool{}
+		for {
+			for _, spec := range watchList {
+				if spec.Name == "" {
+					continue
+				}
+				if err := r.inject(ctx, dyn, cs, spec, copied); err != nil {
+					debugf("registry overrides for %s: %v", spec.KindDescription, err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// inject updates one composed resource when the overrides change it.
+func (r *registryConfig) inject(ctx context.Context, dyn dynamic.Interface, cs *kubernetes.Clientset, spec utils.WaitResourceSpec, copied map[string]bool) error {
+	ri := dyn.Resource(spec.GVR)
+	var (
+		obj *unstructured.Unstructured
+		err error
+	)
+	if spec.Namespace == "" {
+		obj, err = ri.Get(ctx, spec.Name, metav1.GetOptions{})
+	} else {
+		obj, err = ri.Namespace(spec.Namespace).Get(ctx, spec.Name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	updated := obj.DeepCopy()
+	switch spec.GVR.Resource {
+	case "releases":
+		r.overrideRelease(updated)
+	case "objects":
+		ns := r.overrideObject(updated)
+		if ns != "" && ns != utils.SystemNamespace && r.PullSecret != "" && !copied[ns] {
+			if err := copyPullSecret(ctx, cs, r.PullSecret, ns); err != nil {
+				return err
+			}
+			copied[ns] = true
+		}
+	}
+	if reflect.DeepEqual(obj.Object, updated.Object) {
+		return nil
+	}
+	debugf("applying registry overrides to %s %s", spec.GVR.Resource, obj.GetName())
+	if spec.Namespace == "" {
+		_, err = ri.Update(ctx, updated, metav1.UpdateOptions{})
+	} else {
+		_, err = ri.Namespace(spec.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// overrideRelease points a Helm Release at the configured chart repository
+// and adds the pull secret for both the chart and, through the common
+// global.imagePullSecrets value, its images.
+func (r *registryConfig) overrideRelease(u *unstructured.Unstructured) {
+	chart, _, _ := unstructured.NestedString(u.Object, "spec", "forProvider", "chart", "name")
+	c, ok := r.Charts[chart]
+	if !ok {
+		c = r.Charts["*"]
+	}
+	if c.Repository != "" {
+		_ = unstructured.SetNestedField(u.Object, c.Repository, "spec", "forProvider", "chart", "repository")
+	}
+	if c.Version != "" {
+		_ = unstructured.SetNestedField(u.Object, c.Version, "spec", "forProvider", "chart", "version")
+	}
+	values, _, _ := unstructured.NestedMap(u.Object, "spec", "forProvider", "values")
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	if r.PullSecret != "" {
+		_ = unstructured.SetNestedMap(u.Object, map[string]interface{}{
+			"name":      r.PullSecret,
+			"namespace": utils.SystemNamespace,
+		}, "spec", "forProvider", "chart", "pullSecretRef")
+		secrets, _, _ := unstructured.NestedSlice(values, "global", "imagePullSecrets")
+		if !containsValue(secrets, r.PullSecret) {
+			_ = unstructured.SetNestedSlice(values, append(secrets, r.PullSecret), "global", "imagePullSecrets")
+		}
+	}
+	if len(c.Values) > 0 {
+		values = mergeMaps(values, runtime.DeepCopyJSON(c.Values))
+	}
+	if len(values) > 0 {
+		_ = unstructured.SetNestedMap(u.Object, values, "spec", "forProvider", "values")
+	}
+}
+
+// overrideObject rewrites the images of a workload manifest to the mirror
+// and adds the pull secret to its pods. It returns the namespace of the
+// manifest.
+func (r *registryConfig) overrideObject(u *unstructured.Unstructured) string {
+	manifest, _, _ := unstructured.NestedMap(u.Object, "spec", "forProvider", "manifest")
+	if manifest == nil {
+		return ""
+	}
+	ns, _, _ := unstructured.NestedString(manifest, "metadata", "namespace")
+	kind, _, _ := unstructured.NestedString(manifest, "kind")
+	var path []string
+	switch kind {
+	case "Pod":
+		path = []string{"spec"}
+	case "Deployment", "DaemonSet", "StatefulSet", "ReplicaSet", "Job":
+		path = []string{"spec", "template", "spec"}
+	case "CronJob":
+		path = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return ns
+	}
+	podSpec, _, _ := unstructured.NestedMap(manifest, path...)
+	if podSpec == nil {
+		return ns
+	}
+	if r.Mirror != "" {
+		for _, field := range []string{"initContainers", "containers"} {
+			containers, _, _ := unstructured.NestedSlice(podSpec, field)
+			for _, c := range containers {
+				if m, ok := c.(map[string]interface{}); ok {
+					if image, ok := m["image"].(string); ok {
+						m["image"] = mirrorImage(image, r.Mirror)
+					}
+				}
+			}
+			if containers != nil {
+				_ = unstructured.SetNestedSlice(podSpec, containers, field)
+			}
+		}
+	}
+	if r.PullSecret != "" {
+		secrets, _, _ := unstructured.NestedSlice(podSpec, "imagePullSecrets")
+		found := false
+		for _, s := range secrets {
+			if m, ok := s.(map[string]interface{}); ok && m["name"] == r.PullSecret {
+				found = true
+			}
+		}
+		if !found {
+			_ = unstructured.SetNestedSlice(podSpec, append(secrets, map[string]interface{}{"name": r.PullSecret}), "imagePullSecrets")
+		}
+	}
+	_ = unstructured.SetNestedMap(manifest, podSpec, path...)
+	_ = unstructured.SetNestedMap(u.Object, manifest, "spec", "forProvider", "manifest")
+	return ns
+}
+
+// mirrorImage replaces the registry of image with mirror, e.g.
+// quay.io/submariner/lighthouse-agent:0.18 becomes
+// registry.example.org/mirror/submariner/lighthouse-agent:0.18 and busybox
+// becomes registry.example.org/mirror/library/busybox.
+func mirrorImage(image, mirror string) string {
+	if strings.HasPrefix(image, mirror+"/") {
+		return image
+	}
+	first, rest, found := strings.Cut(image, "/")
+	switch {
+	case !found:
+		return mirror + "/library/" + image
+	case strings.ContainsAny(first, ".:") || first == "localhost":
+		return mirror + "/" + rest
+	default:
+		return mirror + "/" + image
+	}
+}
+
+// copyPullSecret copies the pull secret from skycluster-system into ns, so
+// pods of Objects in other namespaces can use it.
+func copyPullSecret(ctx context.Context, cs *kubernetes.Clientset, name, ns string) error {
+	src, err := cs.CoreV1().Secrets(utils.SystemNamespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting pull secret skycluster-system/%s: %w", name, err)
+	}
+	if err := createOrUpdateNamespace(ctx, cs, ns); err != nil {
+		return err
+	}
+	debugf("copying pull secret %s to namespace %s", name, ns)
+	return createOrUpdateSecret(ctx, cs, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: src.Labels},
+		Type:       src.Type,
+		Data:       src.Data,
+	})
+}
+
+func containsValue(list []interface{}, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
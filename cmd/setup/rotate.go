@@ -0,0 +1,176 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// rotatedAtAnnotation is set on the XSetups after a rotation, so that their
+// composition is reconciled with the new secrets right away.
+const rotatedAtAnnotation = "skycluster.io/rotated-at"
+
+var (
+	rotatePublic     string
+	rotatePrivate    string
+	rotateGenerate   bool
+	rotateKeyType    string
+	rotateKubeconfig string
+	rotateWait       bool
+)
+
+func init() {
+	rotateKeysCmd.Flags().StringVar(&rotatePublic, "public", "", "Path to the new public key")
+	rotateKeysCmd.Flags().StringVar(&rotatePrivate, "private", "", "Path to the new private key; with --generate-keys where to write it")
+	rotateKeysCmd.Flags().BoolVar(&rotateGenerate, "generate-keys", false, "Generate the new keypair, writing the private key to --private (default "+defaultKeyPath+"<type>)")
+	rotateKeysCmd.Flags().StringVar(&rotateKeyType, "key-type", "ed25519", "Type of the keypair --generate-keys creates: ed25519 or rsa")
+	rotateKubeconfigCmd.Flags().StringVar(&rotateKubeconfig, "from", "", "Kubeconfig to store (default: the kubeconfig of the CLI, at its --context)")
+	for _, c := range []*cobra.Command{rotateKeysCmd, rotateKubeconfigCmd} {
+		c.Flags().BoolVar(&rotateWait, "wait", true, "Wait for the resources setup created to be Ready again")
+		setupCmd.AddCommand(c)
+	}
+}
+
+var rotateKeysCmd = &cobra.Command{
+	Use:   "rotate-keys",
+	Short: "Replace the keypair of the skycluster-keys secret",
+	Long: `Replace the default keypair in the skycluster-keys secret, with the keys of
+--public and --private or a keypair made with --generate-keys, then annotate
+the XSetups so they reconcile and wait for the resources setup created to be
+Ready again. Machines created before keep the old public key.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rotateGenerate {
+			if rotatePrivate == "" {
+				rotatePrivate = defaultKeyPath + rotateKeyType
+			}
+		} else if rotatePublic == "" || rotatePrivate == "" {
+			return fmt.Errorf("pass --public and --private, or --generate-keys")
+		}
+		cmd.SilenceUsage = true
+		if rotateGenerate {
+			pubPath, err := generateKeyPair(rotateKeyType, expandPath(rotatePrivate))
+			if err != nil {
+				return fmt.Errorf("generating keys: %w", err)
+			}
+			rotatePublic = pubPath
+			fmt.Fprintf(messages(), "Generated a %s keypair: %s (private) and %s\n", rotateKeyType, expandPath(rotatePrivate), pubPath)
+		}
+		pub, err := os.ReadFile(expandPath(rotatePublic))
+		if err != nil {
+			return fmt.Errorf("reading public key: %w", err)
+		}
+		priv, err := os.ReadFile(expandPath(rotatePrivate))
+		if err != nil {
+			return fmt.Errorf("reading private key: %w", err)
+		}
+		secret, err := keysSecret(utils.SystemNamespace, pub, priv)
+		if err != nil {
+			return err
+		}
+		return rotate(cmd.Context(), secret)
+	},
+}
+
+var rotateKubeconfigCmd = &cobra.Command{
+	Use:   "rotate-kubeconfig",
+	Short: "Replace the kubeconfig of the skycluster-management secret",
+	Long: `Replace the kubeconfig of the management cluster in the skycluster-management
+secret, e.g. after its credentials were renewed, then annotate the XSetups
+so they reconcile and wait for the resources setup created to be Ready
+again. The new kubeconfig must reach its API server, or nothing is changed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		path := rotateKubeconfig
+		if path == "" {
+			path = viper.GetString("kubeconfig")
+		}
+		kube, err := os.ReadFile(expandPath(path))
+		if err != nil {
+			return fmt.Errorf("reading kubeconfig: %w", err)
+		}
+		if c := viper.GetString("context"); c != "" && rotateKubeconfig == "" {
+			if kube, err = withCurrentContext(kube, c); err != nil {
+				return err
+			}
+		}
+		// A kubeconfig the controllers cannot use would break every
+		// reconciliation, so it must reach its cluster first.
+		cs, err := utils.GetClientsetFromString(string(kube))
+		if err != nil {
+			return fmt.Errorf("parsing kubeconfig %s: %w", path, err)
+		}
+		if _, err := cs.Discovery().ServerVersion(); err != nil {
+			return fmt.Errorf("the kubeconfig %s does not reach its cluster: %w", path, err)
+		}
+		return rotate(cmd.Context(), managementSecret(utils.SystemNamespace, kube))
+	},
+}
+
+// rotate stores secret, annotates the XSetups so they reconcile, and waits
+// for the resources setup created unless --wait=false.
+func rotate(ctx context.Context, secret *corev1.Secret) error {
+	kubeconfig := viper.GetString("kubeconfig")
+	clientset, err := utils.GetClientset(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("build kubernetes clientset: %w", err)
+	}
+	dyn, err := utils.GetDynamicClient(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("build dynamic client: %w", err)
+	}
+	if err := createOrUpdateSecret(ctx, clientset, secret); err != nil {
+		return fmt.Errorf("create/update secret %s: %w", secret.Name, err)
+	}
+	fmt.Fprintf(messages(), "Secret %s/%s updated\n", secret.Namespace, secret.Name)
+
+	xsetups, err := resource.XSetup.List(ctx, dyn)
+	if err != nil {
+		return err
+	}
+	patch := fmt.Appendf(nil, `{"metadata":{"annotations":{%q:%q}}}`, rotatedAtAnnotation, time.Now().UTC().Format(time.RFC3339))
+	for _, x := range xsetups {
+		err := utils.Retry(ctx, func(ctx context.Context) error {
+			_, err := resource.XSetup.Client(dyn).Patch(ctx, x.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("annotating XSetup %s: %w", x.GetName(), err)
+		}
+		debugf("annotated XSetup %s", x.GetName())
+	}
+	if len(xsetups) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no XSetup found; run skycluster setup to create one")
+		return nil
+	}
+	if !rotateWait {
+		return nil
+	}
+
+	// Give the controllers a moment to pick up the change before waiting.
+	select {
+	case <-time.After(3 * time.Second):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	watchList := setupWatchList()
+	if err := utils.ResolveResourceNamesFromManifest(ctx, dyn, watchList, debugf); err != nil {
+		return fmt.Errorf("pre-watch resolution failed: %w", err)
+	}
+	if err := utils.WaitWithProgress(ctx, dyn, watchList, os.Stdout, debugf); err != nil {
+		return fmt.Errorf("waiting for resources ready: %w", err)
+	}
+	fmt.Fprintln(messages(), "Rotation done; the resources of setup are Ready.")
+	return nil
+}
@@ -21,12 +21,16 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/etesami/skycluster-cli/internal/log"
 	"github.com/etesami/skycluster-cli/internal/utils"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
 )
 
 var (
@@ -34,49 +38,67 @@ var (
 	privateKeyPath   string
 	xsetupAPIServer  string
 	xsetupSubmariner bool
+	setupFile        string
+	setupResume      bool
+	generateKeys     bool
+	keyType          string
 
 	// debug flag controls debug output (can be set by package that uses this, or tests)
-	debug bool
 )
 
-// debugf prints debug messages to stderr when debug is enabled.
+// debugf logs a debug message of the setup commands.
 func debugf(format string, args ...interface{}) {
-	if debug {
-		_, _ = fmt.Fprintf(os.Stderr, "DEBUG: "+format+"\n", args...)
-	}
+	log.Debugf("setup", format, args...)
 }
 
 func init() {
 	// Use Cobra flags (also support go test / `go run` style flags fallback)
 	setupCmd.Flags().StringVar(&publicKeyPath, "public", "", "Path to public key (e.g. ~/.ssh/id_rsa.pub)")
 	setupCmd.Flags().StringVar(&privateKeyPath, "private", "", "Path to private key (e.g. ~/.ssh/id_rsa)")
+	setupCmd.Flags().BoolVar(&generateKeys, "generate-keys", false, "Generate a new keypair, writing the private key to --private (default "+defaultKeyPath+"<type>) and the public key next to it")
+	setupCmd.Flags().StringVar(&keyType, "key-type", "ed25519", "Type of the keypair --generate-keys creates: ed25519 or rsa")
 	// flags for XSetup resource
 	setupCmd.Flags().StringVar(&xsetupAPIServer, "apiserver", "", "API server address to put in XSetup.spec.apiServer (host[:port])")
 	setupCmd.Flags().BoolVar(&xsetupSubmariner, "submariner", true, "Whether to enable submariner in XSetup.spec.submariner.enabled")
+	setupCmd.Flags().BoolVar(&setupResume, "resume", false, "Resume a previous setup run, skipping resources that already became ready")
+	setupCmd.Flags().StringVarP(&setupFile, "file", "f", "", "Declarative setup file (keys, apiServer, submariner, watch timeouts, extra secrets, registries); explicit flags override it")
 
 	// make flags available to library using standard flag package (optional)
 	_ = flag.CommandLine.Parse([]string{})
 }
 
-// SetDebug sets package-level debug flag after CLI flags are parsed.
-func SetDebug(d bool) {
-	debug = d
-}
-
 var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Setup commands",
 	Run: func(cmd *cobra.Command, args []string) {
 		debugf("setup command started")
+		var fileCfg *setupConfig
+		if setupFile != "" {
+			c, err := loadSetupConfig(setupFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			c.applyFlags(cmd)
+			fileCfg = c
+		}
 		// Validate required flags
-		if publicKeyPath == "" || privateKeyPath == "" {
+		if generateKeys {
+			if keyType != "ed25519" && keyType != "rsa" {
+				fmt.Fprintf(os.Stderr, "error: unknown key type %q (supported: ed25519, rsa)\n", keyType)
+				os.Exit(1)
+			}
+			if privateKeyPath == "" {
+				privateKeyPath = defaultKeyPath + keyType
+			}
+		} else if publicKeyPath == "" || privateKeyPath == "" {
 			debugf("missing required key paths: public=%q private=%q", publicKeyPath, privateKeyPath)
-			fmt.Fprintln(os.Stderr, "error: flags --public and --private are required")
+			fmt.Fprintln(os.Stderr, "error: flags --public and --private (or keys in --file), or --generate-keys, are required")
 			os.Exit(1)
 		}
 		if strings.TrimSpace(xsetupAPIServer) == "" {
 			debugf("missing required apiserver flag")
-			fmt.Fprintln(os.Stderr, "error: flag --apiserver is required")
+			fmt.Fprintln(os.Stderr, "error: flag --apiserver (or apiServer in --file) is required")
 			os.Exit(1)
 		}
 
@@ -94,6 +116,16 @@ var setupCmd = &cobra.Command{
 			debugf("API server probe used strict TLS verification")
 		}
 
+		if generateKeys {
+			pubPath, err := generateKeyPair(keyType, expandPath(privateKeyPath))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: generating keys: %v\n", err)
+				os.Exit(1)
+			}
+			publicKeyPath = pubPath
+			fmt.Fprintf(messages(), "Generated a %s keypair: %s (private) and %s\n", keyType, expandPath(privateKeyPath), pubPath)
+		}
+
 		// check files exist and read them
 		debugf("reading public key from %q", publicKeyPath)
 		pubBytes, err := os.ReadFile(expandPath(publicKeyPath))
@@ -122,57 +154,22 @@ var setupCmd = &cobra.Command{
 			os.Exit(1)
 		}
 		debugf("read %d bytes from kubeconfig", len(kubeBytes))
-
-		// Prepare values
-		pubStr := strings.TrimSpace(string(pubBytes))
-		privB64 := base64.StdEncoding.EncodeToString(privBytes)
-		debugf("prepared public key string length %d and base64 private key length %d", len(pubStr), len(privB64))
-
-		// JSON config for first secret
-		cfg := map[string]string{
-			"publicKey":  pubStr,
-			"privateKey": privB64,
-		}
-		cfgBytes, err := json.Marshal(cfg)
-		if err != nil {
-			debugf("failed to marshal keypair json: %v", err)
-			fmt.Fprintf(os.Stderr, "error: marshal keypair json: %v\n", err)
-			os.Exit(1)
+		if c := viper.GetString("context"); c != "" {
+			// The secret must point at the cluster the CLI was told to use.
+			if kubeBytes, err = withCurrentContext(kubeBytes, c); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
 		}
-		debugf("marshalled keypair json (%d bytes)", len(cfgBytes))
 
 		// Build secrets
-		ns := "skycluster-system"
-		secret1 := &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: ns,
-				Name:      "skycluster-keys",
-				Labels: map[string]string{
-					"skycluster.io/managed-by":  "skycluster",
-					"skycluster.io/secret-type": "default-keypair",
-				},
-			},
-			Type: corev1.SecretTypeOpaque,
-			StringData: map[string]string{
-				"config": string(cfgBytes),
-			},
-		}
-
-		secret2 := &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: ns,
-				Name:      "skycluster-management",
-				Labels: map[string]string{
-					"skycluster.io/managed-by":   "skycluster",
-					"skycluster.io/secret-type":  "k8s-connection-data",
-					"skycluster.io/cluster-name": "skycluster-management",
-				},
-			},
-			Type: corev1.SecretTypeOpaque,
-			Data: map[string][]byte{
-				"kubeconfig": kubeBytes,
-			},
+		ns := utils.SystemNamespace
+		secret1, err := keysSecret(ns, pubBytes, privBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
+		secret2 := managementSecret(ns, kubeBytes)
 
 		// Create client using kubeconfig
 		debugf("building kubernetes clientset with kubeconfig %q", kubeconfigPath)
@@ -184,7 +181,7 @@ var setupCmd = &cobra.Command{
 		}
 		debugf("kubernetes clientset initialized")
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		// Ensure namespaces exist (best effort; ignore AlreadyExists)
 		debugf("ensuring namespace %s exists", ns)
@@ -200,6 +197,20 @@ var setupCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		checkpoint, err := loadCheckpoint(ctx, clientset, ns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if setupResume {
+			checkpoint.reportResume()
+		} else {
+			checkpoint.reset()
+			if err := checkpoint.save(ctx); err != nil {
+				debugf("saving setup checkpoint failed: %v", err)
+			}
+		}
+
 		debugf("creating/updating secret %s/%s", secret1.Namespace, secret1.Name)
 		if err := createOrUpdateSecret(ctx, clientset, secret1); err != nil {
 			debugf("createOrUpdateSecret failed for %s: %v", secret1.Name, err)
@@ -216,6 +227,34 @@ var setupCmd = &cobra.Command{
 		}
 		debugf("created/updated secret %s/%s", secret2.Namespace, secret2.Name)
 
+		if fileCfg != nil {
+			for _, s := range fileCfg.secrets {
+				if s.Namespace != ns {
+					if err := createOrUpdateNamespace(ctx, clientset, s.Namespace); err != nil {
+						fmt.Fprintf(os.Stderr, "error: ensure namespace %s: %v\n", s.Namespace, err)
+						os.Exit(1)
+					}
+				}
+				debugf("creating/updating extra secret %s/%s", s.Namespace, s.Name)
+				if err := createOrUpdateSecret(ctx, clientset, s); err != nil {
+					fmt.Fprintf(os.Stderr, "error: create/update secret %s: %v\n", s.Name, err)
+					os.Exit(1)
+				}
+			}
+			pullSecret, err := fileCfg.Registries.pullSecretFromHelper(ctx, ns)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: registry credentials: %v\n", err)
+				os.Exit(1)
+			}
+			if pullSecret != nil {
+				debugf("creating/updating pull secret %s/%s", pullSecret.Namespace, pullSecret.Name)
+				if err := createOrUpdateSecret(ctx, clientset, pullSecret); err != nil {
+					fmt.Fprintf(os.Stderr, "error: create/update secret %s: %v\n", pullSecret.Name, err)
+					os.Exit(1)
+				}
+			}
+		}
+
 		// Now create/update the XSetup resource (cluster-scoped)
 		debugf("building dynamic client with kubeconfig %q", kubeconfigPath)
 		dyn, err := utils.GetDynamicClient(kubeconfigPath)
@@ -239,134 +278,31 @@ var setupCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		fmt.Println("Setup initiated successfully. Waiting for resources to become ready...")
+		fmt.Fprintln(messages(), "Setup initiated successfully. Waiting for resources to become ready...")
 
 		// --------------------------------------------------------------------
 		// PRE-WATCH PHASE + WATCHING PROCESS FOR STATICALLY DEFINED RESOURCES
 		// --------------------------------------------------------------------
 		debugf("Resolving resources to watch (pre-watch phase)...")
-		time.Sleep(3 * time.Second) // brief pause before starting watch
-
-		// These specs use the *underlying* manifest name (spec.forProvider.manifest.metadata.name),
-		// which we know, but not the Crossplane object name itself.
-		// So Name is left empty and ManifestMetadataName is used to resolve it.
-		watchList := []utils.WaitResourceSpec{
-			{
-				KindDescription: "Istio root CA certs generator",
-				GVR: schema.GroupVersionResource{
-					Group:    "kubernetes.crossplane.io",
-					Version:  "v1alpha2",
-					Resource: "objects",
-				},
-				ManifestMetadataName: "istio-root-ca-certs-generator", // == spec.forProvider.manifest.metadata.name
-				ConditionType:        "Ready",
-				Timeout:              1 * time.Minute,
-				PollInterval:         5 * time.Second,
-			},
-			{
-				KindDescription: "Headscale cert generator",
-				GVR: schema.GroupVersionResource{
-					Group:    "kubernetes.crossplane.io",
-					Version:  "v1alpha2",
-					Resource: "objects",
-				},
-				ManifestMetadataName: "headscale-cert-gen",
-				ConditionType:        "Ready",
-				Timeout:              3 * time.Minute,
-				PollInterval:         10 * time.Second,
-			},
-			{
-				KindDescription: "Headscale server",
-				GVR: schema.GroupVersionResource{
-					Group:    "kubernetes.crossplane.io",
-					Version:  "v1alpha2",
-					Resource: "objects",
-				},
-				ManifestMetadataName: "headscale-server",
-				ConditionType:        "Ready",
-				Timeout:              5 * time.Minute,
-				PollInterval:         10 * time.Second,
-			},
-			{
-				KindDescription: "Headscale connection secret",
-				GVR: schema.GroupVersionResource{
-					Group:    "kubernetes.crossplane.io",
-					Version:  "v1alpha2",
-					Resource: "objects",
-				},
-				ManifestMetadataName: "headscale-connection-secret",
-				ConditionType:        "Ready",
-				Timeout:              2 * time.Minute,
-				PollInterval:         5 * time.Second,
-			},
-			// For these Helm releases we *do* know the name directly.
-			{
-				KindDescription: "Submariner Operator Release",
-				GVR: schema.GroupVersionResource{
-					Group:    "helm.crossplane.io",
-					Version:  "v1beta1",
-					Resource: "releases",
-				},
-				ManifestMetadataName: "submariner-k8s-broker",
-				ConditionType: "Ready",
-				Timeout:       4 * time.Minute,
-				PollInterval:  10 * time.Second,
-			},
-			{
-				KindDescription: "Submariner operator",
-				GVR: schema.GroupVersionResource{
-					Group:    "helm.crossplane.io",
-					Version:  "v1beta1",
-					Resource: "releases",
-				},
-				ManifestMetadataName: "submariner-operator",
-				ConditionType: "Ready",
-				Timeout:       4 * time.Minute,
-				PollInterval:  10 * time.Second,
-			},
+		// brief pause before starting watch
+		select {
+		case <-time.After(3 * time.Second):
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr, "Interrupted; run 'skycluster setup --resume' to wait for the resources.")
+			os.Exit(130)
 		}
 
-		// Create and start TUI renderer
-		renderer := utils.NewTUIRenderer()
-		if err := renderer.Start(); err != nil {
-			// fallback to plain output if TUI fails
-			fmt.Printf("Failed to start TUI renderer: %v\n", err)
-			// simple fallback ProgressSink
-			plainSink := func(ev utils.ProgressEvent) {
-        if ev.Err != nil {
-            fmt.Printf("[ERROR] %s (%s/%s %s): %v\n",
-                ev.KindDescription,
-                ev.Namespace,
-                ev.Name,
-                ev.GVR.Resource,
-                ev.Err,
-            )
-            return
-        }
-        status := "waiting"
-        if ev.ResourceCompleted {
-            status = "ready"
-        }
-        fmt.Printf("[%.0f%%] (%d/%d) %-30s %-6s %s/%s %s\n",
-            ev.OverallPercent,
-            ev.CurrentIndex,
-            ev.Total,
-            ev.KindDescription,
-            status,
-            ev.Namespace,
-            ev.Name,
-            ev.GVR.Resource,
-        )
-			}
-			// Pre-watch phase: resolve names via spec.forProvider.manifest.metadata.name
-			if err := utils.ResolveResourceNamesFromManifest(ctx, dyn, watchList, debugf); err != nil {
-				fmt.Fprintf(os.Stderr, "error: pre-watch resolution failed: %v\n", err)
-				os.Exit(1)
-			}
-
-			if err := utils.WaitForResourcesReadySequential(ctx, dyn, watchList, plainSink, debugf); err != nil {
-				fmt.Fprintf(os.Stderr, "error: waiting for resources ready: %v\n", err)
-				os.Exit(1)
+		watchList := setupWatchList()
+		var registries *registryConfig
+		if fileCfg != nil {
+			fileCfg.applyWatchOverrides(watchList)
+			registries = &fileCfg.Registries
+		}
+		if setupResume {
+			watchList = checkpoint.skipReady(watchList)
+			if len(watchList) == 0 {
+				fmt.Fprintln(messages(), "All resources were already ready; nothing to resume.")
+				return
 			}
 		}
 
@@ -375,12 +311,21 @@ var setupCmd = &cobra.Command{
 			fmt.Fprintf(os.Stderr, "error: pre-watch resolution failed: %v\n", err)
 			os.Exit(1)
 		}
-		
-		// Use the TUI renderer as the ProgressSink
-		err = utils.WaitForResourcesReadySequential(ctx, dyn, watchList, renderer.Sink, debugf)
-		renderer.Stop(err)
+
+		// Independent resources are waited on in parallel, DependsOn chains
+		// are respected.
+		sink, stop := utils.StartProgress(os.Stdout, debugf)
+		stopInjecting := injectRegistries(ctx, dyn, clientset, registries, watchList, 15*time.Second)
+		err = utils.WaitForResourcesReadyConcurrent(ctx, dyn, watchList, checkpoint.recordingSink(ctx, sink), debugf)
+		stopInjecting()
+		stop(err)
+		if err != nil && ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "Interrupted; run 'skycluster setup --resume' to continue.")
+			os.Exit(130)
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: waiting for resources ready: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Fix the issue and run 'skycluster setup --resume' to continue.")
 			os.Exit(1)
 		}
 	},
@@ -388,62 +333,214 @@ var setupCmd = &cobra.Command{
 
 func GetSetupCmd() *cobra.Command { return setupCmd }
 
+// messages returns where setup writes its own lines: stderr with --progress
+// json, so that stdout only carries the JSON lines of the progress.
+func messages() io.Writer {
+	if utils.ProgressMode() == utils.ProgressJSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// setupWatchList returns the resources setup waits on after creating the
+// XSetup. These specs use the *underlying* manifest name
+// (spec.forProvider.manifest.metadata.name), which we know, but not the
+// Crossplane object name itself. So Name is left empty and
+// ManifestMetadataName is used to resolve it.
+func setupWatchList() []utils.WaitResourceSpec {
+	return []utils.WaitResourceSpec{
+		{
+			KindDescription: "Istio root CA certs generator",
+			GVR: schema.GroupVersionResource{
+				Group:    "kubernetes.crossplane.io",
+				Version:  "v1alpha2",
+				Resource: "objects",
+			},
+			ManifestMetadataName: "istio-root-ca-certs-generator", // == spec.forProvider.manifest.metadata.name
+			ConditionType:        "Ready",
+			Timeout:              1 * time.Minute,
+			PollInterval:         5 * time.Second,
+		},
+		{
+			KindDescription: "Headscale cert generator",
+			GVR: schema.GroupVersionResource{
+				Group:    "kubernetes.crossplane.io",
+				Version:  "v1alpha2",
+				Resource: "objects",
+			},
+			ManifestMetadataName: "headscale-cert-gen",
+			ConditionType:        "Ready",
+			Timeout:              3 * time.Minute,
+			PollInterval:         10 * time.Second,
+		},
+		{
+			KindDescription: "Headscale server",
+			GVR: schema.GroupVersionResource{
+				Group:    "kubernetes.crossplane.io",
+				Version:  "v1alpha2",
+				Resource: "objects",
+			},
+			ManifestMetadataName: "headscale-server",
+			DependsOn:            []string{"Headscale cert generator"},
+			ConditionType:        "Ready",
+			Timeout:              5 * time.Minute,
+			PollInterval:         10 * time.Second,
+		},
+		{
+			KindDescription: "Headscale connection secret",
+			GVR: schema.GroupVersionResource{
+				Group:    "kubernetes.crossplane.io",
+				Version:  "v1alpha2",
+				Resource: "objects",
+			},
+			ManifestMetadataName: "headscale-connection-secret",
+			DependsOn:            []string{"Headscale server"},
+			ConditionType:        "Ready",
+			Timeout:              2 * time.Minute,
+			PollInterval:         5 * time.Second,
+		},
+		// For these Helm releases we *do* know the name directly.
+		{
+			KindDescription: "Submariner Operator Release",
+			GVR: schema.GroupVersionResource{
+				Group:    "helm.crossplane.io",
+				Version:  "v1beta1",
+				Resource: "releases",
+			},
+			ManifestMetadataName: "submariner-k8s-broker",
+			ConditionType: "Ready",
+			Timeout:       4 * time.Minute,
+			PollInterval:  10 * time.Second,
+		},
+		{
+			KindDescription: "Submariner operator",
+			GVR: schema.GroupVersionResource{
+				Group:    "helm.crossplane.io",
+				Version:  "v1beta1",
+				Resource: "releases",
+			},
+			ManifestMetadataName: "submariner-operator",
+			DependsOn:     []string{"Submariner Operator Release"},
+			ConditionType: "Ready",
+			Timeout:       4 * time.Minute,
+			PollInterval:  10 * time.Second,
+		},
+	}
+}
+
+// keysSecret builds the skycluster-keys secret with the default keypair.
+func keysSecret(ns string, pubBytes, privBytes []byte) (*corev1.Secret, error) {
+	pubStr := strings.TrimSpace(string(pubBytes))
+	privB64 := base64.StdEncoding.EncodeToString(privBytes)
+	debugf("prepared public key string length %d and base64 private key length %d", len(pubStr), len(privB64))
+
+	// JSON config of the secret
+	cfg := map[string]string{
+		"publicKey":  pubStr,
+		"privateKey": privB64,
+	}
+	cfgBytes, err := json.Marshal(cfg)
+	if err != nil {
+		debugf("failed to marshal keypair json: %v", err)
+		return nil, fmt.Errorf("marshal keypair json: %w", err)
+	}
+	debugf("marshalled keypair json (%d bytes)", len(cfgBytes))
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      "skycluster-keys",
+			Labels: map[string]string{
+				"skycluster.io/managed-by":  "skycluster",
+				"skycluster.io/secret-type": "default-keypair",
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"config": string(cfgBytes),
+		},
+	}, nil
+}
+
+// managementSecret builds the skycluster-management secret with the
+// kubeconfig of the management cluster.
+func managementSecret(ns string, kubeBytes []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      "skycluster-management",
+			Labels: map[string]string{
+				"skycluster.io/managed-by":   "skycluster",
+				"skycluster.io/secret-type":  "k8s-connection-data",
+				"skycluster.io/cluster-name": "skycluster-management",
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"kubeconfig": kubeBytes,
+		},
+	}
+}
+
 // createOrUpdateSecret will create the secret or update it if already exists.
 func createOrUpdateSecret(ctx context.Context, c *kubernetes.Clientset, s *corev1.Secret) error {
 	svc := c.CoreV1().Secrets(s.Namespace)
-	debugf("attempting to GET secret %s/%s", s.Namespace, s.Name)
-	existing, err := svc.Get(ctx, s.Name, metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		debugf("secret %s/%s not found, creating", s.Namespace, s.Name)
-		_, err := svc.Create(ctx, s, metav1.CreateOptions{})
+	return utils.RetryOnConflict(ctx, func(ctx context.Context) error {
+		debugf("attempting to GET secret %s/%s", s.Namespace, s.Name)
+		existing, err := svc.Get(ctx, s.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			debugf("secret %s/%s not found, creating", s.Namespace, s.Name)
+			_, err := svc.Create(ctx, s, metav1.CreateOptions{})
+			if err != nil {
+				debugf("create secret %s/%s failed: %v", s.Namespace, s.Name, err)
+			} else {
+				debugf("created secret %s/%s", s.Namespace, s.Name)
+			}
+			return err
+		}
 		if err != nil {
-			debugf("create secret %s/%s failed: %v", s.Namespace, s.Name, err)
-		} else {
-			debugf("created secret %s/%s", s.Namespace, s.Name)
+			debugf("error getting secret %s/%s: %v", s.Namespace, s.Name, err)
+			return err
 		}
-		return err
-	}
-	if err != nil {
-		debugf("error getting secret %s/%s: %v", s.Namespace, s.Name, err)
-		return err
-	}
 
-	debugf("secret %s/%s exists, updating", s.Namespace, s.Name)
-	// preserve resource version and update fields
-	existing.ObjectMeta.Labels = s.ObjectMeta.Labels
-	existing.StringData = s.StringData
-	existing.Data = s.Data
-	existing.Type = s.Type
+		debugf("secret %s/%s exists, updating", s.Namespace, s.Name)
+		// preserve resource version and update fields
+		existing.ObjectMeta.Labels = s.ObjectMeta.Labels
+		existing.StringData = s.StringData
+		existing.Data = s.Data
+		existing.Type = s.Type
 
-	_, err = svc.Update(ctx, existing, metav1.UpdateOptions{})
-	if err != nil {
-		debugf("update secret %s/%s failed: %v", s.Namespace, s.Name, err)
-	} else {
-		debugf("updated secret %s/%s", s.Namespace, s.Name)
-	}
-	return err
+		_, err = svc.Update(ctx, existing, metav1.UpdateOptions{})
+		if err != nil {
+			debugf("update secret %s/%s failed: %v", s.Namespace, s.Name, err)
+		} else {
+			debugf("updated secret %s/%s", s.Namespace, s.Name)
+		}
+		return err
+	})
 }
 
 func createOrUpdateNamespace(ctx context.Context, c *kubernetes.Clientset, ns string) error {
-	debugf("checking namespace %s", ns)
-	_, err := c.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		debugf("namespace %s not found, creating", ns)
-		_, err = c.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
-			ObjectMeta: metav1.ObjectMeta{Name: ns},
-		}, metav1.CreateOptions{})
-		if err != nil {
-			debugf("create namespace %s failed: %v", ns, err)
-			return fmt.Errorf("create namespace %s: %w", ns, err)
+	return utils.Retry(ctx, func(ctx context.Context) error {
+		debugf("checking namespace %s", ns)
+		_, err := c.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			debugf("namespace %s not found, creating", ns)
+			_, err = c.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: ns},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				debugf("create namespace %s failed: %v", ns, err)
+				return fmt.Errorf("create namespace %s: %w", ns, err)
+			}
+			debugf("created namespace %s", ns)
+		} else if err != nil {
+			debugf("error checking namespace %s: %v", ns, err)
+			return fmt.Errorf("check namespace %s: %w", ns, err)
+		} else {
+			debugf("namespace %s already exists", ns)
 		}
-		debugf("created namespace %s", ns)
-	} else if err != nil {
-		debugf("error checking namespace %s: %v", ns, err)
-		return fmt.Errorf("check namespace %s: %w", ns, err)
-	} else {
-		debugf("namespace %s already exists", ns)
-	}
-	return nil
+		return nil
+	})
 }
 
 // buildXSetupUnstructured builds an unstructured.Unstructured representing the XSetup CR.
@@ -479,41 +576,45 @@ func createOrUpdateXSetup(ctx context.Context, dyn dynamic.Interface, u *unstruc
 	name := u.GetName()
 	debugf("ensuring XSetup %s (cluster-scoped)", name)
 
-	// Try to get existing (cluster-scoped)
-	debugf("attempting to GET existing XSetup %s", name)
-	existing, err := dyn.Resource(gvr).Get(ctx, name, metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		debugf("XSetup %s not found, creating", name)
-		_, err := dyn.Resource(gvr).Create(ctx, u, metav1.CreateOptions{})
+	return utils.RetryOnConflict(ctx, func(ctx context.Context) error {
+		// Try to get existing (cluster-scoped)
+		debugf("attempting to GET existing XSetup %s", name)
+		existing, err := dyn.Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			debugf("XSetup %s not found, creating", name)
+			audit.Stamp(u)
+			_, err := dyn.Resource(gvr).Create(ctx, u, metav1.CreateOptions{})
+			if err != nil {
+				debugf("create XSetup %s failed: %v", name, err)
+			} else {
+				debugf("created XSetup %s", name)
+			}
+			return err
+		}
 		if err != nil {
-			debugf("create XSetup %s failed: %v", name, err)
-		} else {
-			debugf("created XSetup %s", name)
+			debugf("error getting XSetup %s: %v", name, err)
+			return err
 		}
-		return err
-	}
-	if err != nil {
-		debugf("error getting XSetup %s: %v", name, err)
-		return err
-	}
 
-	debugf("XSetup %s exists, preparing to merge", name)
-	// Merge existing and new objects: overlay u onto existing so unspecified fields are preserved.
-	merged := existing.DeepCopy()
-	merged.Object = mergeMaps(merged.Object, u.Object)
-	if j, err := json.MarshalIndent(merged.Object, "", "  "); err == nil {
-		debugf("merged XSetup object: %s", string(j))
-	} else {
-		debugf("could not marshal merged XSetup for debug: %v", err)
-	}
+		debugf("XSetup %s exists, preparing to merge", name)
+		// Merge existing and new objects: overlay u onto existing so unspecified fields are preserved.
+		merged := existing.DeepCopy()
+		merged.Object = mergeMaps(merged.Object, u.Object)
+		if j, err := json.MarshalIndent(merged.Object, "", "  "); err == nil {
+			debugf("merged XSetup object: %s", string(j))
+		} else {
+			debugf("could not marshal merged XSetup for debug: %v", err)
+		}
 
-	_, err = dyn.Resource(gvr).Update(ctx, merged, metav1.UpdateOptions{})
-	if err != nil {
-		debugf("update XSetup %s failed: %v", name, err)
-	} else {
-		debugf("updated XSetup %s", name)
-	}
-	return err
+		audit.StampUpdate(merged, existing)
+		_, err = dyn.Resource(gvr).Update(ctx, merged, metav1.UpdateOptions{})
+		if err != nil {
+			debugf("update XSetup %s failed: %v", name, err)
+		} else {
+			debugf("updated XSetup %s", name)
+		}
+		return err
+	})
 }
 
 // mergeMaps overlays src onto dst recursively.
@@ -667,6 +768,19 @@ func probeKubernetesVersionURL(url string, insecure bool) (bool, bool, error) {
 	return true, insecure, nil
 }
 
+// withCurrentContext returns kubeconfig with its current context set to name.
+func withCurrentContext(kubeconfig []byte, name string) ([]byte, error) {
+	cfg, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	if _, ok := cfg.Contexts[name]; !ok {
+		return nil, fmt.Errorf("context %q does not exist in the kubeconfig", name)
+	}
+	cfg.CurrentContext = name
+	return clientcmd.Write(*cfg)
+}
+
 // expandPath expands ~ to home directory (simple implementation)
 func expandPath(p string) string {
 	if p == "" {
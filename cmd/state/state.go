@@ -0,0 +1,116 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/etesami/skycluster-cli/internal/state"
+)
+
+var (
+	showOutput  string
+	cleanDryRun bool
+)
+
+func init() {
+	stateShowCmd.Flags().StringVarP(&showOutput, "output", "o", "table", "Output format: table or json")
+	stateCleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "Only print the state files that would be removed")
+	stateCmd.AddCommand(stateShowCmd)
+	stateCmd.AddCommand(stateCleanCmd)
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect and clean the local state of the CLI",
+	Long: `Inspect and clean the local state of the CLI, kept in ~/.skycluster/state,
+e.g. the gateway addresses xprovider watch-ips last saw. The config file and
+the policies in ~/.skycluster are not part of it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "List the local state files with their schema version and age",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		infos, err := state.List()
+		if err != nil {
+			return err
+		}
+		switch showOutput {
+		case "json":
+			out, err := json.MarshalIndent(infos, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(out))
+			return err
+		case "table":
+		default:
+			return fmt.Errorf("unknown output format %q (supported: table, json)", showOutput)
+		}
+
+		fmt.Printf("Directory: %s\n", state.Dir())
+		if pid := state.Holder(); pid != 0 {
+			fmt.Printf("Locked by: pid %d\n", pid)
+		}
+		if len(infos) == 0 {
+			fmt.Println("No state files")
+			return nil
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tUPDATED\tSIZE")
+		for _, i := range infos {
+			updated := "-"
+			if !i.UpdatedAt.IsZero() {
+				updated = i.UpdatedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", i.Name, i.Version, updated, i.Size)
+		}
+		return w.Flush()
+	},
+}
+
+var stateCleanCmd = &cobra.Command{
+	Use:   "clean [name...]",
+	Short: "Remove local state files, all of them by default",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := args
+		if len(names) == 0 {
+			infos, err := state.List()
+			if err != nil {
+				return err
+			}
+			for _, i := range infos {
+				names = append(names, i.Name)
+			}
+		}
+		if len(names) == 0 {
+			fmt.Println("No state files")
+			return nil
+		}
+		if cleanDryRun {
+			for _, n := range names {
+				fmt.Printf("would remove %s\n", n)
+			}
+			return nil
+		}
+		if err := state.Remove(names...); err != nil {
+			return err
+		}
+		fmt.Printf("removed %d state file(s)\n", len(names))
+		return nil
+	},
+}
+
+func GetStateCmd() *cobra.Command {
+	return stateCmd
+}
@@ -0,0 +1,123 @@
+package subnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var checkAll bool
+
+func init() {
+	subnetCheckCmd.Flags().BoolVarP(&checkAll, "all", "a", false, "List all the ranges in use, not only the overlapping ones")
+	subnetCmd.AddCommand(subnetCheckCmd)
+}
+
+var subnetCheckCmd = &cobra.Command{
+	Use:   "check <cidr>",
+	Short: "Check a CIDR against the ranges of the XProviders and XKubes",
+	Long: `Check a proposed CIDR against the VPC CIDRs of the XProviders and the pod
+and service CIDRs of the XKubes of the management cluster, and list the ones
+it overlaps. Overlapping ranges break the routing of the mesh, so check a
+CIDR before creating an XProvider or XKube with it. Fails when there is an
+overlap.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, proposed, err := net.ParseCIDR(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q: %w", args[0], err)
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		cmd.SilenceUsage = true
+		used, err := rangesInUse(cmd.Context(), dyn)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "KIND\tNAME\tFIELD\tCIDR\tOVERLAPS")
+		conflicts := 0
+		for _, u := range used {
+			_, n, err := net.ParseCIDR(u.cidr)
+			if err != nil {
+				debugf("skipping invalid %s of %s %s: %q", u.field, u.kind, u.name, u.cidr)
+				continue
+			}
+			overlap := overlaps(proposed, n)
+			if overlap {
+				conflicts++
+			} else if !checkAll {
+				continue
+			}
+			mark := "no"
+			if overlap {
+				mark = "yes"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", u.kind, u.name, u.field, u.cidr, mark)
+		}
+		if conflicts > 0 || checkAll {
+			_ = tw.Flush()
+			fmt.Println()
+		}
+		if conflicts > 0 {
+			return fmt.Errorf("%s overlaps %d of the %d ranges in use", proposed, conflicts, len(used))
+		}
+		fmt.Printf("%s overlaps none of the %d ranges in use\n", proposed, len(used))
+		return nil
+	},
+}
+
+// usedRange is a CIDR set in a field of a resource.
+type usedRange struct {
+	kind, name, field, cidr string
+}
+
+// rangesInUse returns the vpcCidr of the XProviders and the podCidr and
+// serviceCidr of the XKubes, from their spec or else their status.
+func rangesInUse(ctx context.Context, dyn dynamic.Interface) ([]usedRange, error) {
+	var out []usedRange
+	for _, k := range []struct {
+		t      *resource.Type
+		fields []string
+	}{
+		{resource.XProvider, []string{"vpcCidr"}},
+		{resource.XKube, []string{"podCidr", "serviceCidr"}},
+	} {
+		items, err := k.t.List(ctx, dyn)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", k.t.Plural(), err)
+		}
+		for _, it := range items {
+			for _, f := range k.fields {
+				c, _, _ := unstructured.NestedString(it.Object, "spec", f)
+				if c == "" {
+					c, _, _ = unstructured.NestedString(it.Object, "status", f)
+				}
+				if c != "" {
+					out = append(out, usedRange{kind: k.t.Kind, name: it.GetName(), field: f, cidr: c})
+				}
+			}
+		}
+	}
+	debugf("%d ranges in use", len(out))
+	return out, nil
+}
+
+// debugf logs a debug message of the subnet commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("subnet", format, args...)
+}
@@ -76,3 +76,63 @@ func printTree(w io.Writer, n *node, prefix string, isLast bool) {
 		printTree(w, c, nextPrefix, i == len(n.children)-1)
 	}
 }
+
+// Ranges are the ranges the calculator suggests within a VPC CIDR. Nodes is
+// only set for GCP, whose GKE nodes need a range of their own.
+type Ranges struct {
+	Subnet   string
+	Nodes    string
+	Pods     string
+	Services string
+}
+
+// Suggest returns the ranges the calculator prints for vpcCIDR on platform.
+// On GCP the pod/service range is split in two.
+func Suggest(platform, vpcCIDR string) (Ranges, error) {
+	halves, err := subnetSplit(vpcCIDR, 1)
+	if err != nil {
+		return Ranges{}, err
+	}
+	outside, err := buildSubnet(vpcCIDR, 172)
+	if err != nil {
+		return Ranges{}, err
+	}
+	r := Ranges{Subnet: halves[0].String()}
+	if platform != "gcp" {
+		r.Pods = halves[1].String()
+		r.Services = outside.String()
+		return r, nil
+	}
+	r.Nodes = halves[1].String()
+	podSvc, err := subnetSplit(outside.String(), 1)
+	if err != nil {
+		return Ranges{}, err
+	}
+	r.Pods, r.Services = podSvc[0].String(), podSvc[1].String()
+	return r, nil
+}
+
+// NextFree returns the first 10.N.0.0/16, from 10.10.0.0/16 on, that overlaps
+// none of the used CIDRs, or "" if there is none.
+func NextFree(used []string) string {
+	var nets []*net.IPNet
+	for _, u := range used {
+		if _, n, err := net.ParseCIDR(u); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	for i := 10; i < 256; i++ {
+		_, candidate, _ := net.ParseCIDR(fmt.Sprintf("10.%d.0.0/16", i))
+		free := true
+		for _, n := range nets {
+			if n.Contains(candidate.IP) || candidate.Contains(n.IP) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return candidate.String()
+		}
+	}
+	return ""
+}
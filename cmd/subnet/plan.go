@@ -0,0 +1,280 @@
+package subnet
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// platforms are the platforms the planner knows the ranges of.
+var platforms = []string{"aws", "azure", "gcp", "openstack"}
+
+var (
+	planProviders []string
+	planCount     int
+	planExclude   []string
+	planOutputDir string
+)
+
+func init() {
+	subnetPlanCmd.Flags().StringSliceVar(&planProviders, "providers", nil, "Providers to plan, each platform[:region[:zone]], e.g. aws:us-east-1,gcp:us-east1:us-east1-b")
+	subnetPlanCmd.Flags().IntVar(&planCount, "count", 0, "Plan this many providers of the platform of -p instead of --providers")
+	subnetPlanCmd.Flags().StringSliceVar(&planExclude, "exclude", nil, "CIDRs already in use that no VPC may overlap")
+	subnetPlanCmd.Flags().StringVar(&planOutputDir, "output-dir", "", "Write xproviders.yaml and xkubes.yaml for the create commands to this directory")
+	subnetCmd.AddCommand(subnetPlanCmd)
+}
+
+var subnetPlanCmd = &cobra.Command{
+	Use:   "plan <supernet>",
+	Short: "Plan the VPC, pod and service ranges of a whole deployment",
+	Long: `Plan the ranges of a deployment of several XProviders, each with one XKube:
+a /16 VPC per provider out of the supernet, overlapping none of --exclude, and
+the subnet, GKE node, pod and service ranges the calculator suggests for it.
+No two ranges of the plan overlap.
+
+  skycluster subnet plan 10.40.0.0/14 --providers aws:us-east-1,gcp:us-east1:us-east1-b
+  skycluster subnet plan 10.0.0.0/8 --count 3 -p azure
+
+With --output-dir, the XProvider and XKube specs are written to xproviders.yaml
+and xkubes.yaml, named platform-region, ready for:
+
+  skycluster xprovider create -f xproviders.yaml --server-side
+  skycluster xkube create -f xkubes.yaml --server-side`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := planEntries()
+		if err != nil {
+			return err
+		}
+		plan, err := Plan(args[0], entries, planExclude)
+		if err != nil {
+			return err
+		}
+		printPlan(plan)
+		if planOutputDir == "" {
+			return nil
+		}
+		return writePlan(planOutputDir, plan)
+	},
+}
+
+// PlanEntry is a provider of a plan: an XProvider and its XKube.
+type PlanEntry struct {
+	Name     string
+	Platform string
+	Region   string
+	Zone     string
+}
+
+// Allocation is the ranges planned for an entry.
+type Allocation struct {
+	PlanEntry
+	VPC string
+	Ranges
+}
+
+// planEntries returns the entries of --providers, or --count entries of the
+// platform of -p.
+func planEntries() ([]PlanEntry, error) {
+	switch {
+	case len(planProviders) > 0 && planCount > 0:
+		return nil, fmt.Errorf("--providers and --count cannot be used together")
+	case planCount > 0:
+		entries := make([]PlanEntry, planCount)
+		for i := range entries {
+			entries[i] = PlanEntry{Name: fmt.Sprintf("%s-%d", provider, i+1), Platform: provider}
+		}
+		return entries, nil
+	case len(planProviders) == 0:
+		return nil, fmt.Errorf("pass the providers to plan with --providers or --count")
+	}
+	var entries []PlanEntry
+	for i, p := range planProviders {
+		parts := strings.Split(strings.TrimSpace(p), ":")
+		if len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid provider %q, want platform[:region[:zone]]", p)
+		}
+		e := PlanEntry{Platform: parts[0], Name: fmt.Sprintf("%s-%d", parts[0], i+1)}
+		if len(parts) > 1 && parts[1] != "" {
+			e.Region = parts[1]
+			e.Name = e.Platform + "-" + e.Region
+		}
+		if len(parts) > 2 {
+			e.Zone = parts[2]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Plan gives each entry a /16 VPC of supernet, in order, that overlaps none of
+// the exclude CIDRs, with the ranges Suggest returns for it. The VPCs are /16
+// because the service range is derived from the second octet of the VPC.
+func Plan(supernet string, entries []PlanEntry, exclude []string) ([]Allocation, error) {
+	if err := checkCIDR(supernet); err != nil {
+		return nil, fmt.Errorf("the supernet must be in 10.0.0.0/8, got %s", supernet)
+	}
+	_, super, err := net.ParseCIDR(supernet)
+	if err != nil {
+		return nil, err
+	}
+	ones, _ := super.Mask.Size()
+	if ones > 16 {
+		return nil, fmt.Errorf("supernet %s is smaller than the /16 of a VPC", supernet)
+	}
+	var excluded []*net.IPNet
+	for _, c := range exclude {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --exclude %q: %w", c, err)
+		}
+		excluded = append(excluded, n)
+	}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if !slices.Contains(platforms, e.Platform) {
+			return nil, fmt.Errorf("unsupported platform %q (supported: %s)", e.Platform, strings.Join(platforms, ", "))
+		}
+		if seen[e.Name] {
+			return nil, fmt.Errorf("%s is planned twice", e.Name)
+		}
+		seen[e.Name] = true
+	}
+
+	vpcs, err := subnetSplit(super.String(), 16-ones)
+	if err != nil {
+		return nil, err
+	}
+	var plan []Allocation
+	next := 0
+	for _, e := range entries {
+		for next < len(vpcs) && overlapsAny(vpcs[next], excluded) {
+			next++
+		}
+		if next == len(vpcs) {
+			return nil, fmt.Errorf("supernet %s has room for %d of the %d VPCs", supernet, len(plan), len(entries))
+		}
+		vpc := vpcs[next].String()
+		next++
+		r, err := Suggest(e.Platform, vpc)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, Allocation{PlanEntry: e, VPC: vpc, Ranges: r})
+	}
+	if err := checkPlan(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// checkPlan returns an error if two ranges of different providers overlap.
+func checkPlan(plan []Allocation) error {
+	type owned struct {
+		owner string
+		net   *net.IPNet
+	}
+	var all []owned
+	for _, a := range plan {
+		for _, c := range []string{a.VPC, a.Pods, a.Services} {
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				return err
+			}
+			for _, o := range all {
+				if o.owner != a.Name && overlaps(o.net, n) {
+					return fmt.Errorf("range %s of %s overlaps %s of %s", n, a.Name, o.net, o.owner)
+				}
+			}
+			all = append(all, owned{a.Name, n})
+		}
+	}
+	return nil
+}
+
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func overlapsAny(n *net.IPNet, nets []*net.IPNet) bool {
+	for _, o := range nets {
+		if overlaps(n, o) {
+			return true
+		}
+	}
+	return false
+}
+
+func printPlan(plan []Allocation) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPLATFORM\tVPC\tSUBNET\tNODES (GKE)\tPODS\tSERVICES")
+	for _, a := range plan {
+		nodes := a.Nodes
+		if nodes == "" {
+			nodes = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.Name, a.Platform, a.VPC, a.Subnet, nodes, a.Pods, a.Services)
+	}
+	_ = tw.Flush()
+}
+
+// writePlan writes the XProvider and XKube specs of plan to dir, one YAML
+// document per provider named by metadata.name.
+func writePlan(dir string, plan []Allocation) error {
+	var providers, kubes []map[string]interface{}
+	for _, a := range plan {
+		if a.Region == "" {
+			return fmt.Errorf("%s has no region; pass platform:region in --providers to write the specs", a.Name)
+		}
+		ref := map[string]interface{}{"platform": a.Platform, "region": a.Region}
+		if a.Zone != "" {
+			ref["zones"] = map[string]interface{}{"primary": a.Zone}
+		}
+		meta := map[string]interface{}{"name": a.Name}
+		providers = append(providers, map[string]interface{}{
+			"metadata":    meta,
+			"providerRef": ref,
+			"vpcCidr":     a.VPC,
+		})
+		kubes = append(kubes, map[string]interface{}{
+			"metadata":    meta,
+			"providerRef": ref,
+			"podCidr":     a.Pods,
+			"serviceCidr": a.Services,
+		})
+	}
+	for _, f := range []struct {
+		file, cmd string
+		docs      []map[string]interface{}
+	}{
+		{"xproviders.yaml", "xprovider", providers},
+		{"xkubes.yaml", "xkube", kubes},
+	} {
+		var b strings.Builder
+		fmt.Fprintf(&b, "# Planned with: skycluster %s\n", strings.Join(os.Args[1:], " "))
+		fmt.Fprintf(&b, "# Create them with: skycluster %s create -f %s --server-side\n", f.cmd, f.file)
+		for _, d := range f.docs {
+			out, err := yaml.Marshal(d)
+			if err != nil {
+				return err
+			}
+			b.WriteString("---\n")
+			b.Write(out)
+		}
+		path := filepath.Join(dir, f.file)
+		if err := utils.WriteFileAtomic(path, []byte(b.String()), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"text/tabwriter"
 
@@ -22,14 +23,17 @@ func init() {
 var subnetCmd = &cobra.Command{
 	Use:   "subnet <subnet-cidr>",
 	Short: "Subnet calculates the subnet information for a given CIDR for you cluster.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			cmd.Help()
-			return
+			return cmd.Help()
+		}
+		if !slices.Contains(platforms, provider) {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("unsupported provider %q (supported: %s)", provider, strings.Join(platforms, ", "))
 		}
 		err := checkCIDR(args[0]); if err != nil {
 			fmt.Println("This tool only supports CIDR in 10.0.0.0/8. Use other CIDRs at your own discretion.")
-			return
+			return nil
 		}
 		switch provider {
 		case "aws":
@@ -38,16 +42,21 @@ var subnetCmd = &cobra.Command{
 			calculateGCPSubnets(args[0])	
 			fmt.Printf("\n%s\t%s\n",
 			"Note:", "For GCP GKE service, you need to specify a subnet range for nodes (XKube Nodes)")
-		default:
-			fmt.Println("Unsupported provider")
-			return
+		case "azure":
+			calculateAzureSubnets(args[0])
+			fmt.Printf("\n%s\t%s\n",
+				"Note:", "AKS needs a Service Range outside the VNet; its DNS service uses the 10th address of it")
+		case "openstack":
+			calculateOpenStackSubnets(args[0])
+			fmt.Printf("\n%s\t%s\n",
+				"Note:", "The CNI routes the Pod Range over the network; allow it in the port security (allowed address pairs)")
 		}
 		
 		fmt.Printf("\n%s\t%s\n",
 			"Note:", "You can use any CIDR within the Subnet Ranges for your XProvider configuration.")
 		// fmt.Printf("\n%s\t%s\n",
 		// 	"Note:", "This tool provides a basic subnet calculation for SkyCluster environment.")
-
+		return nil
 	},
 }
 
@@ -170,6 +179,66 @@ func calculateAWSSubnets(cidr string) {
 	}
 }
 
+/*
+ Azure Subnet Calculation
+*/
+func calculateAzureSubnets(cidr string) {
+	r, err := Suggest("azure", cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	// Build hierarchy
+	root := &node{
+		name: "VNet",
+		cidr: cidr,
+		children: []*node{
+			{name: "Subnet Range", cidr: r.Subnet},
+			{name: "XKube Pod Range (AKS)", cidr: r.Pods},
+		},
+	}
+	svcRoot := &node{name: "XKube Service Range (AKS)", cidr: r.Services}
+
+	// Render with alignment
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCIDR")
+	printTree(tw, root, "", true)
+	printTree(tw, svcRoot, "", true)
+	if err := tw.Flush(); err != nil {
+		panic(err)
+	}
+}
+
+/*
+ OpenStack Subnet Calculation
+*/
+func calculateOpenStackSubnets(cidr string) {
+	r, err := Suggest("openstack", cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	// Build hierarchy
+	root := &node{
+		name: "Network",
+		cidr: cidr,
+		children: []*node{
+			{name: "Subnet Range", cidr: r.Subnet},
+			{name: "XKube Pod Range", cidr: r.Pods},
+		},
+	}
+	svcRoot := &node{name: "XKube Service Range", cidr: r.Services}
+
+	// Render with alignment
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCIDR")
+	printTree(tw, root, "", true)
+	printTree(tw, svcRoot, "", true)
+	if err := tw.Flush(); err != nil {
+		panic(err)
+	}
+}
+
 // Helper function
 func buildSubnet(cidr string, octets ...int) (*net.IPNet, error) {
 	_, ipnet, err := net.ParseCIDR(cidr)
@@ -0,0 +1,319 @@
+package tenant
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	rbacv1ac "k8s.io/client-go/applyconfigurations/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	tenantLabel    = "skycluster.io/tenant"
+	managedByLabel = "skycluster.io/managed-by"
+	xkubeNamespace = utils.SystemNamespace
+)
+
+var (
+	clusters       []string
+	cpu            string
+	memory         string
+	pods           int
+	group          string
+	role           string
+	defaultRequest map[string]string
+	defaultLimit   map[string]string
+	listTable      utils.TableOptions
+)
+
+var tenantHeaders = []string{"TENANT", "CLUSTER", "CPU", "MEMORY", "PODS", "STATUS"}
+
+func init() {
+	tenantCmd.PersistentFlags().StringSliceVar(&clusters, "clusters", []string{"all"}, "XKubes to act on, separated by comma, or all")
+
+	tenantCreateCmd.Flags().StringVar(&cpu, "cpu", "", "CPU quota of the tenant on each cluster, e.g. 20 (required)")
+	tenantCreateCmd.Flags().StringVar(&memory, "memory", "", "Memory quota of the tenant on each cluster, e.g. 64Gi (required)")
+	tenantCreateCmd.Flags().IntVar(&pods, "pods", 0, "Maximum number of pods on each cluster; 0 for no limit")
+	tenantCreateCmd.Flags().StringVar(&group, "group", "", "Group bound to the tenant namespace (defaults to the tenant name)")
+	tenantCreateCmd.Flags().StringVar(&role, "role", "edit", "ClusterRole granted to the group in the tenant namespace")
+	tenantCreateCmd.Flags().StringToStringVar(&defaultRequest, "default-request", map[string]string{"cpu": "100m", "memory": "128Mi"}, "Container requests applied when a pod sets none")
+	tenantCreateCmd.Flags().StringToStringVar(&defaultLimit, "default-limit", map[string]string{"cpu": "500m", "memory": "512Mi"}, "Container limits applied when a pod sets none")
+	_ = tenantCreateCmd.MarkFlagRequired("cpu")
+	_ = tenantCreateCmd.MarkFlagRequired("memory")
+
+	tenantCmd.AddCommand(tenantCreateCmd)
+	utils.AddTableFlags(tenantListCmd, &listTable, "")
+	tenantCmd.AddCommand(tenantListCmd)
+	tenantCmd.AddCommand(tenantDeleteCmd)
+}
+
+var tenantCmd = &cobra.Command{
+	Use:   "tenant",
+	Short: "Manage tenant namespaces across the xkubes",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var tenantCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create or update a tenant namespace with quotas and access on every xkube",
+	Long: `Create or update the namespace <name> on every selected xkube, with:
+
+  - a ResourceQuota capping the requests and limits of the tenant to --cpu
+    and --memory (and the pod count to --pods),
+  - a LimitRange giving containers --default-request and --default-limit,
+  - a RoleBinding granting --role to the --group in the namespace.
+
+Running it again updates the quotas in place.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		quota, err := quotaList()
+		if err != nil {
+			return err
+		}
+		request, err := resourceList(defaultRequest, "--default-request")
+		if err != nil {
+			return err
+		}
+		limit, err := resourceList(defaultLimit, "--default-limit")
+		if err != nil {
+			return err
+		}
+		if group == "" {
+			group = name
+		}
+		return forEachCluster(cmd.Context(), func(ctx context.Context, cluster string, cs *kubernetes.Clientset) error {
+			if err := applyTenant(ctx, cs, name, quota, request, limit); err != nil {
+				return err
+			}
+			fmt.Printf("Tenant %s ensured on %s\n", name, cluster)
+			return nil
+		})
+	},
+}
+
+var tenantListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tenants and their quota usage on every xkube",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tbl := utils.NewTable(tenantHeaders...)
+		if err := tbl.Check(listTable); err != nil {
+			return err
+		}
+		err := forEachCluster(cmd.Context(), func(ctx context.Context, cluster string, cs *kubernetes.Clientset) error {
+			nss, err := cs.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: tenantLabel})
+			if err != nil {
+				return err
+			}
+			for _, ns := range nss.Items {
+				cpuUsage, memUsage, podUsage := "-", "-", "-"
+				q, err := cs.CoreV1().ResourceQuotas(ns.Name).Get(ctx, quotaName(ns.Labels[tenantLabel]), metav1.GetOptions{})
+				if err == nil {
+					cpuUsage = usage(q, corev1.ResourceLimitsCPU)
+					memUsage = usage(q, corev1.ResourceLimitsMemory)
+					podUsage = usage(q, corev1.ResourcePods)
+				}
+				tbl.Append(ns.Labels[tenantLabel], cluster, cpuUsage, memUsage, podUsage, string(ns.Status.Phase))
+			}
+			return nil
+		})
+		if len(tbl.Rows) == 0 {
+			fmt.Println("No tenants found.")
+		} else if werr := tbl.Write(os.Stdout, listTable); werr != nil {
+			return werr
+		}
+		return err
+	},
+}
+
+var tenantDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a tenant namespace, and everything in it, from every xkube",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if !utils.AssumeYes() {
+			if err := utils.CanPrompt(os.Stdin); err != nil {
+				return err
+			}
+			fmt.Printf("Deleting namespace %s and all its workloads on %s? (y/N): ", name, strings.Join(clusters, ", "))
+			reader := bufio.NewReader(os.Stdin)
+			resp, _ := reader.ReadString('\n')
+			if r := strings.ToLower(strings.TrimSpace(resp)); r != "y" && r != "yes" {
+				fmt.Println("Aborted.")
+				return nil
+			}
+		}
+		return forEachCluster(cmd.Context(), func(ctx context.Context, cluster string, cs *kubernetes.Clientset) error {
+			ns, err := cs.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				fmt.Printf("Tenant %s not found on %s\n", name, cluster)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// Never delete a namespace the tenant commands did not create.
+			if ns.Labels[tenantLabel] != name {
+				return fmt.Errorf("namespace %s is not a tenant namespace", name)
+			}
+			if err := cs.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			fmt.Printf("Tenant %s deleted from %s\n", name, cluster)
+			return nil
+		})
+	},
+}
+
+// forEachCluster runs fn with a client for every selected xkube. A failing
+// cluster is reported and skipped; an error is returned if any failed.
+func forEachCluster(ctx context.Context, fn func(ctx context.Context, cluster string, cs *kubernetes.Clientset) error) error {
+	names := clusters
+	if slices.Contains(names, "all") {
+		names = xk.ListXKubesNames(ctx, "")
+	}
+	if len(names) == 0 {
+		return errors.New("no xkubes found")
+	}
+	failed := 0
+	for _, name := range names {
+		cs, err := remoteClient(ctx, name)
+		if err == nil {
+			err = fn(ctx, name, cs)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", name, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cluster(s) failed", failed, len(names))
+	}
+	return nil
+}
+
+func remoteClient(ctx context.Context, xkubeName string) (*kubernetes.Clientset, error) {
+	kubeconfig, err := xk.GetConfig(ctx, xkubeName, xkubeNamespace)
+	if err != nil {
+		return nil, err
+	}
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig of xkube %s: %w", xkubeName, err)
+	}
+	return kubernetes.NewForConfig(restCfg)
+}
+
+// applyTenant server-side applies the namespace, quota, limit range and role
+// binding of the tenant, so repeated runs converge on the flags given.
+func applyTenant(ctx context.Context, cs *kubernetes.Clientset, name string, quota, request, limit corev1.ResourceList) error {
+	labels := map[string]string{tenantLabel: name, managedByLabel: "skycluster"}
+	opts := metav1.ApplyOptions{FieldManager: utils.FieldManager, Force: true}
+
+	ns := corev1ac.Namespace(name).WithLabels(labels)
+	if _, err := cs.CoreV1().Namespaces().Apply(ctx, ns, opts); err != nil {
+		return fmt.Errorf("applying namespace: %w", err)
+	}
+	debugf("applied namespace %s", name)
+
+	rq := corev1ac.ResourceQuota(quotaName(name), name).WithLabels(labels).
+		WithSpec(corev1ac.ResourceQuotaSpec().WithHard(quota))
+	if _, err := cs.CoreV1().ResourceQuotas(name).Apply(ctx, rq, opts); err != nil {
+		return fmt.Errorf("applying resource quota: %w", err)
+	}
+
+	lr := corev1ac.LimitRange(name+"-defaults", name).WithLabels(labels).
+		WithSpec(corev1ac.LimitRangeSpec().WithLimits(corev1ac.LimitRangeItem().
+			WithType(corev1.LimitTypeContainer).
+			WithDefaultRequest(request).
+			WithDefault(limit)))
+	if _, err := cs.CoreV1().LimitRanges(name).Apply(ctx, lr, opts); err != nil {
+		return fmt.Errorf("applying limit range: %w", err)
+	}
+
+	rb := rbacv1ac.RoleBinding(name+"-tenant", name).WithLabels(labels).
+		WithRoleRef(rbacv1ac.RoleRef().WithAPIGroup("rbac.authorization.k8s.io").WithKind("ClusterRole").WithName(role)).
+		WithSubjects(rbacv1ac.Subject().WithAPIGroup("rbac.authorization.k8s.io").WithKind("Group").WithName(group))
+	if _, err := cs.RbacV1().RoleBindings(name).Apply(ctx, rb, opts); err != nil {
+		return fmt.Errorf("applying role binding: %w", err)
+	}
+	return nil
+}
+
+func quotaName(tenant string) string {
+	return tenant + "-quota"
+}
+
+// quotaList returns the hard quota the flags describe. Requests and limits
+// are capped alike.
+func quotaList() (corev1.ResourceList, error) {
+	c, err := resource.ParseQuantity(cpu)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --cpu %q: %w", cpu, err)
+	}
+	m, err := resource.ParseQuantity(memory)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --memory %q: %w", memory, err)
+	}
+	quota := corev1.ResourceList{
+		corev1.ResourceRequestsCPU:    c,
+		corev1.ResourceLimitsCPU:      c,
+		corev1.ResourceRequestsMemory: m,
+		corev1.ResourceLimitsMemory:   m,
+	}
+	if pods > 0 {
+		quota[corev1.ResourcePods] = *resource.NewQuantity(int64(pods), resource.DecimalSI)
+	}
+	return quota, nil
+}
+
+func resourceList(values map[string]string, flag string) (corev1.ResourceList, error) {
+	out := corev1.ResourceList{}
+	for k, v := range values {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s=%q: %w", flag, k, v, err)
+		}
+		out[corev1.ResourceName(k)] = q
+	}
+	return out, nil
+}
+
+// usage renders the used and hard amounts of a quota resource.
+func usage(q *corev1.ResourceQuota, name corev1.ResourceName) string {
+	hard, ok := q.Status.Hard[name]
+	if !ok {
+		return "-"
+	}
+	used := q.Status.Used[name]
+	return used.String() + "/" + hard.String()
+}
+
+// debugf logs a debug message of the tenant commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("tenant", format, args...)
+}
+
+func GetTenantCmd() *cobra.Command {
+	return tenantCmd
+}
+
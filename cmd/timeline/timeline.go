@@ -0,0 +1,236 @@
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	depth    int
+	noEvents bool
+)
+
+func init() {
+	timelineCmd.Flags().IntVar(&depth, "depth", 3, "Levels of composed resources to follow")
+	timelineCmd.Flags().BoolVar(&noEvents, "no-events", false, "Only show condition transitions, not Kubernetes events")
+}
+
+var timelineCmd = &cobra.Command{
+	Use:   "timeline <kind> <name>",
+	Short: "Show how long each provisioning stage of a resource took",
+	Long: `Assemble a timeline of a SkyCluster resource from its conditions, the
+conditions of the resources composed for it and the Kubernetes events of all
+of them, e.g.:
+
+  skycluster timeline xkube gcp-us-east1
+
+Every resource is a branch of the tree, with its steps in order. A step shows
+when it happened relative to the creation of the top resource and, in
+brackets, how long it took since the previous step of the same resource.`,
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: typeNames(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		t, ok := resource.ForName(strings.ToLower(args[0]))
+		if !ok {
+			return fmt.Errorf("unknown kind %q (supported: %s)", args[0], strings.Join(typeNames(), ", "))
+		}
+		kubeconfig := viper.GetString("kubeconfig")
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		cs, err := utils.GetClientset(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build clientset: %w", err)
+		}
+		obj, err := utils.GetWithSuggestions(ctx, t.Client(dyn), t.Name, args[1])
+		if err != nil {
+			return err
+		}
+		groups, err := restmapper.GetAPIGroupResources(cs.Discovery())
+		if err != nil {
+			return fmt.Errorf("discovering API resources: %w", err)
+		}
+		b := builder{dyn: dyn, cs: cs, mapper: restmapper.NewDiscoveryRESTMapper(groups)}
+		root := b.node(ctx, obj, 0)
+		render(os.Stdout, root)
+		return nil
+	},
+}
+
+// step is one point of a resource's timeline.
+type step struct {
+	at   time.Time
+	what string
+}
+
+// node is the timeline of one resource and of the resources composed for it.
+type node struct {
+	title    string
+	created  time.Time
+	steps    []step
+	children []*node
+}
+
+type builder struct {
+	dyn    dynamic.Interface
+	cs     *kubernetes.Clientset
+	mapper meta.RESTMapper
+}
+
+// node builds the timeline of obj, following its composed resources while
+// level is below --depth.
+func (b builder) node(ctx context.Context, obj *unstructured.Unstructured, level int) *node {
+	n := &node{
+		title:   obj.GetKind() + " " + obj.GetName(),
+		created: obj.GetCreationTimestamp().Time,
+	}
+	n.steps = append(n.steps, step{at: n.created, what: "created"})
+	n.steps = append(n.steps, conditionSteps(obj)...)
+	if !noEvents {
+		n.steps = append(n.steps, b.eventSteps(ctx, obj)...)
+	}
+	sort.SliceStable(n.steps, func(i, j int) bool { return n.steps[i].at.Before(n.steps[j].at) })
+
+	if level >= depth {
+		return n
+	}
+	for _, ref := range utils.ResourceRefs(obj) {
+		child, err := utils.GetRef(ctx, b.dyn, b.mapper, ref)
+		if err != nil {
+			debugf("skipping composed %s %s: %v", ref.GetKind(), ref.GetName(), err)
+			n.children = append(n.children, &node{title: fmt.Sprintf("%s %s (%v)", ref.GetKind(), ref.GetName(), err)})
+			continue
+		}
+		n.children = append(n.children, b.node(ctx, child, level+1))
+	}
+	sort.SliceStable(n.children, func(i, j int) bool { return n.children[i].created.Before(n.children[j].created) })
+	return n
+}
+
+// conditionSteps returns the last transition of every condition of obj.
+func conditionSteps(obj *unstructured.Unstructured) []step {
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	var steps []step
+	for _, c := range conds {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ts, _ := m["lastTransitionTime"].(string)
+		at, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			continue
+		}
+		what := fmt.Sprintf("%v=%v", m["type"], m["status"])
+		if reason, _ := m["reason"].(string); reason != "" {
+			what += " " + reason
+		}
+		if m["status"] != "True" {
+			if msg, _ := m["message"].(string); msg != "" {
+				what += ": " + msg
+			}
+		}
+		steps = append(steps, step{at: at, what: what})
+	}
+	return steps
+}
+
+// eventSteps returns the Kubernetes events recorded for obj.
+func (b builder) eventSteps(ctx context.Context, obj *unstructured.Unstructured) []step {
+	events, err := b.cs.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.uid=" + string(obj.GetUID()),
+	})
+	if err != nil {
+		debugf("listing events of %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		return nil
+	}
+	var steps []step
+	for _, ev := range events.Items {
+		what := fmt.Sprintf("event %s %s: %s", ev.Type, ev.Reason, strings.TrimSpace(ev.Message))
+		if ev.Count > 1 {
+			what += fmt.Sprintf(" (x%d)", ev.Count)
+		}
+		steps = append(steps, step{at: utils.EventTime(&ev), what: what})
+	}
+	return steps
+}
+
+// render writes the tree of root. Offsets are relative to the creation of
+// root; the duration in brackets is the time since the previous step.
+func render(w io.Writer, root *node) {
+	fmt.Fprintf(w, "%s (created %s)\n", root.title, root.created.Local().Format(time.RFC3339))
+	renderChildren(w, root, root.created, "")
+}
+
+func renderChildren(w io.Writer, n *node, origin time.Time, indent string) {
+	total := len(n.steps) + len(n.children)
+	i := 0
+	branch := func() (string, string) {
+		i++
+		if i == total {
+			return indent + "└── ", indent + "    "
+		}
+		return indent + "├── ", indent + "│   "
+	}
+	var prev time.Time
+	for _, s := range n.steps {
+		head, _ := branch()
+		took := ""
+		if !prev.IsZero() {
+			took = " [" + formatDuration(s.at.Sub(prev)) + "]"
+		}
+		fmt.Fprintf(w, "%s+%s%s  %s\n", head, formatDuration(s.at.Sub(origin)), took, s.what)
+		prev = s.at
+	}
+	for _, c := range n.children {
+		head, next := branch()
+		fmt.Fprintf(w, "%s%s\n", head, c.title)
+		renderChildren(w, c, origin, next)
+	}
+}
+
+// formatDuration renders d rounded to the second, e.g. 19m29s.
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + formatDuration(-d)
+	}
+	return d.Round(time.Second).String()
+}
+
+func typeNames() []string {
+	var names []string
+	for _, t := range resource.All() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// debugf logs a debug message of the timeline commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("timeline", format, args...)
+}
+
+func GetTimelineCmd() *cobra.Command {
+	return timelineCmd
+}
+
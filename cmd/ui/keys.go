@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// keyCode identifies the keys the UI reacts to.
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyTab
+	keyShiftTab
+	keyEnter
+	keyEsc
+	keyCtrlC
+	keyDelete
+	keyPgUp
+	keyPgDown
+	keyUnknown
+)
+
+type key struct {
+	code keyCode
+	// r is the typed character of keyRune keys.
+	r rune
+}
+
+// escapes maps the terminal escape sequences to their keys.
+var escapes = map[string]keyCode{
+	"\x1b[A":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1b[C":  keyRight,
+	"\x1b[D":  keyLeft,
+	"\x1bOA":  keyUp,
+	"\x1bOB":  keyDown,
+	"\x1bOC":  keyRight,
+	"\x1bOD":  keyLeft,
+	"\x1b[Z":  keyShiftTab,
+	"\x1b[3~": keyDelete,
+	"\x1b[5~": keyPgUp,
+	"\x1b[6~": keyPgDown,
+}
+
+// listenKeys puts the terminal in raw mode and calls fn for every key press
+// until fn returns true or stdin fails.
+func listenKeys(fn func(key) bool) error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	buf := make([]byte, 32)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		if fn(parseKey(buf[:n])) {
+			return nil
+		}
+	}
+}
+
+func parseKey(b []byte) key {
+	switch {
+	case len(b) == 0:
+		return key{code: keyUnknown}
+	case b[0] == 0x1b:
+		if len(b) == 1 {
+			return key{code: keyEsc}
+		}
+		if code, ok := escapes[string(b)]; ok {
+			return key{code: code}
+		}
+		return key{code: keyUnknown}
+	case b[0] == 0x03:
+		return key{code: keyCtrlC}
+	case b[0] == '\t':
+		return key{code: keyTab}
+	case b[0] == '\r', b[0] == '\n':
+		return key{code: keyEnter}
+	}
+	r, _ := utf8.DecodeRune(b)
+	if r == utf8.RuneError || r < ' ' {
+		return key{code: keyUnknown}
+	}
+	return key{code: keyRune, r: r}
+}
@@ -0,0 +1,466 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// eventsType lists the Kubernetes events of SkyCluster objects. It is not
+// registered, so it gets no commands of its own.
+var eventsType = &resource.Type{
+	Name: "event",
+	Kind: "Event",
+	GVR:  schema.GroupVersionResource{Version: "v1", Resource: "events"},
+}
+
+// tab is one page of the UI.
+type tab struct {
+	title   string
+	t       *resource.Type
+	columns []resource.Column
+	// keep filters the objects shown; nil keeps all.
+	keep func(obj *unstructured.Unstructured) bool
+	// less orders the rows; nil sorts by name.
+	less func(a, b *unstructured.Unstructured) bool
+	// prune runs before an object is deleted from this tab. Its error does
+	// not stop the deletion and is shown on the status line instead, as
+	// the terminal is owned by the UI.
+	prune func(ctx context.Context, obj *unstructured.Unstructured) error
+	// readOnly tabs have no delete action.
+	readOnly bool
+
+	// objects are kept current by follow, keyed by namespace/name.
+	objects  map[string]*unstructured.Unstructured
+	synced   bool
+	selected int
+	err      string
+}
+
+// view modes.
+const (
+	modeList = iota
+	modeDescribe
+	modeConfirm
+)
+
+// model is the UI state. Watch events and key presses both change it,
+// so every access goes through mu.
+type model struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	dyn    dynamic.Interface
+	area   *pterm.AreaPrinter
+	tabs   []*tab
+	active int
+	mode   int
+	status string
+
+	describe []string
+	scroll   int
+	target   *unstructured.Unstructured
+}
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse and manage SkyCluster resources in a full-screen terminal UI",
+	Long: `Browse and manage SkyCluster resources in a full-screen terminal UI.
+
+The tabs show XProviders, XKubes, XInstances, the XKubeMesh and the events of
+SkyCluster objects, and refresh live as the resources change.
+
+Keys:
+  ←/→, tab, 1-5   switch tabs
+  ↑/↓, j/k        move the selection
+  enter, d        describe the selected resource (esc to go back)
+  x, delete       delete the selected resource, after confirmation
+  q, ctrl+c       quit`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !utils.IsInteractive() {
+			return errors.New("skycluster ui needs an interactive terminal")
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		return run(cmd.Context(), dyn)
+	},
+}
+
+func newTabs() []*tab {
+	isSkyCluster := func(obj *unstructured.Unstructured) bool {
+		apiVersion, _, _ := unstructured.NestedString(obj.Object, "involvedObject", "apiVersion")
+		return strings.Contains(apiVersion, "skycluster.io/")
+	}
+	return []*tab{
+		{title: "Providers", t: resource.XProvider},
+		{title: "Kubes", t: resource.XKube, prune: xk.PruneAccess},
+		{title: "Instances", t: resource.XInstance},
+		{title: "Mesh", t: resource.XKubeMesh},
+		{
+			title: "Events",
+			t:     eventsType,
+			columns: []resource.Column{
+				{Header: "LAST SEEN", Value: func(obj *unstructured.Unstructured) string {
+					return duration.HumanDuration(time.Since(eventTime(obj)))
+				}},
+				{Header: "TYPE", Value: eventField("type")},
+				{Header: "REASON", Value: eventField("reason")},
+				{Header: "OBJECT", Value: func(obj *unstructured.Unstructured) string {
+					kind, _, _ := unstructured.NestedString(obj.Object, "involvedObject", "kind")
+					name, _, _ := unstructured.NestedString(obj.Object, "involvedObject", "name")
+					return strings.ToLower(kind) + "/" + name
+				}},
+				{Header: "MESSAGE", Value: eventField("message")},
+			},
+			keep: isSkyCluster,
+			less: func(a, b *unstructured.Unstructured) bool {
+				return eventTime(a).After(eventTime(b))
+			},
+			readOnly: true,
+		},
+	}
+}
+
+func run(ctx context.Context, dyn dynamic.Interface) error {
+	area, err := pterm.DefaultArea.WithFullscreen().WithRemoveWhenDone().Start()
+	if err != nil {
+		return err
+	}
+	defer area.Stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m := &model{ctx: ctx, dyn: dyn, area: area, tabs: newTabs()}
+	m.redraw()
+	for _, tb := range m.tabs {
+		go m.follow(tb)
+	}
+
+	// Ages in the events tab move even when nothing changes.
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.redraw()
+			}
+		}
+	}()
+
+	return listenKeys(func(k key) bool {
+		stop := m.handleKey(k)
+		m.redraw()
+		return stop
+	})
+}
+
+// items returns the objects shown in tb, filtered and sorted.
+func (tb *tab) items() []*unstructured.Unstructured {
+	var out []*unstructured.Unstructured
+	for _, obj := range tb.objects {
+		if tb.t.Namespace != "" && obj.GetNamespace() != tb.t.Namespace {
+			continue
+		}
+		if tb.keep != nil && !tb.keep(obj) {
+			continue
+		}
+		out = append(out, obj)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if tb.less != nil {
+			return tb.less(out[i], out[j])
+		}
+		return out[i].GetName() < out[j].GetName()
+	})
+	return out
+}
+
+func (tb *tab) cols() []resource.Column {
+	if tb.columns != nil {
+		return tb.columns
+	}
+	name := resource.Column{Header: "NAME", Value: func(obj *unstructured.Unstructured) string { return obj.GetName() }}
+	return append([]resource.Column{name}, tb.t.Columns...)
+}
+
+// handleKey applies a key press and reports whether the UI should exit.
+func (m *model) handleKey(k key) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k.code == keyCtrlC {
+		return true
+	}
+	switch m.mode {
+	case modeDescribe:
+		m.describeKey(k)
+		return false
+	case modeConfirm:
+		if k.code == keyRune && k.r == 'y' {
+			m.deleteTarget()
+		} else {
+			m.status = "Deletion cancelled."
+		}
+		m.mode = modeList
+		m.target = nil
+		return false
+	}
+
+	tb := m.tabs[m.active]
+	items := tb.items()
+	switch k.code {
+	case keyRight, keyTab:
+		m.active = (m.active + 1) % len(m.tabs)
+	case keyLeft, keyShiftTab:
+		m.active = (m.active + len(m.tabs) - 1) % len(m.tabs)
+	case keyDown:
+		tb.selected++
+	case keyUp:
+		tb.selected--
+	case keyEnter:
+		m.openDescribe(items)
+	case keyDelete:
+		m.askDelete(tb, items)
+	case keyRune:
+		switch k.r {
+		case 'q':
+			return true
+		case 'j':
+			tb.selected++
+		case 'k':
+			tb.selected--
+		case 'd':
+			m.openDescribe(items)
+		case 'x':
+			m.askDelete(tb, items)
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			if i := int(k.r - '1'); i < len(m.tabs) {
+				m.active = i
+			}
+		}
+	}
+	return false
+}
+
+func (m *model) describeKey(k key) {
+	page := max(pterm.GetTerminalHeight()-4, 1)
+	switch {
+	case k.code == keyEsc, k.code == keyRune && k.r == 'q':
+		m.mode = modeList
+	case k.code == keyDown, k.code == keyRune && k.r == 'j':
+		m.scroll++
+	case k.code == keyUp, k.code == keyRune && k.r == 'k':
+		m.scroll--
+	case k.code == keyPgDown, k.code == keyRune && k.r == ' ':
+		m.scroll += page
+	case k.code == keyPgUp:
+		m.scroll -= page
+	}
+	m.scroll = max(0, min(m.scroll, len(m.describe)-page))
+}
+
+func (m *model) selectedItem(items []*unstructured.Unstructured) *unstructured.Unstructured {
+	tb := m.tabs[m.active]
+	if len(items) == 0 {
+		return nil
+	}
+	tb.selected = max(0, min(tb.selected, len(items)-1))
+	return items[tb.selected]
+}
+
+func (m *model) openDescribe(items []*unstructured.Unstructured) {
+	obj := m.selectedItem(items)
+	if obj == nil {
+		return
+	}
+	c := obj.DeepCopy()
+	unstructured.RemoveNestedField(c.Object, "metadata", "managedFields")
+	out, err := yaml.Marshal(c.Object)
+	if err != nil {
+		m.status = fmt.Sprintf("describe %s: %v", obj.GetName(), err)
+		return
+	}
+	m.describe = strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	m.scroll = 0
+	m.target = obj
+	m.mode = modeDescribe
+}
+
+func (m *model) askDelete(tb *tab, items []*unstructured.Unstructured) {
+	if tb.readOnly {
+		m.status = tb.title + " cannot be deleted."
+		return
+	}
+	obj := m.selectedItem(items)
+	if obj == nil {
+		return
+	}
+	m.target = obj
+	m.mode = modeConfirm
+}
+
+// deleteTarget deletes the confirmed object in the background; the informer
+// removes it from the list once the API server has.
+func (m *model) deleteTarget() {
+	tb, obj := m.tabs[m.active], m.target
+	m.status = fmt.Sprintf("Deleting %s %s...", tb.t.Kind, obj.GetName())
+	go func() {
+		var warning error
+		if tb.prune != nil {
+			warning = tb.prune(m.ctx, obj)
+		}
+		err := tb.t.Client(m.dyn).Delete(m.ctx, obj.GetName(), metav1.DeleteOptions{})
+		m.mu.Lock()
+		switch {
+		case err != nil:
+			m.status = fmt.Sprintf("Deleting %s %s failed: %v", tb.t.Kind, obj.GetName(), err)
+		case warning != nil:
+			m.status = pterm.Yellow(fmt.Sprintf("Deleted %s %s; warning: %v", tb.t.Kind, obj.GetName(), warning))
+		default:
+			m.status = fmt.Sprintf("Deleted %s %s", tb.t.Kind, obj.GetName())
+		}
+		m.mu.Unlock()
+		m.redraw()
+	}()
+}
+
+// redraw renders the current state to the full-screen area.
+func (m *model) redraw() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.area == nil {
+		return
+	}
+	height := pterm.GetTerminalHeight()
+	var b strings.Builder
+
+	// Tab bar
+	for i, tb := range m.tabs {
+		label := fmt.Sprintf(" %d %s ", i+1, tb.title)
+		if i == m.active {
+			label = pterm.NewStyle(pterm.BgCyan, pterm.FgBlack, pterm.Bold).Sprint(label)
+		}
+		b.WriteString(label)
+	}
+	b.WriteString("\n\n")
+
+	tb := m.tabs[m.active]
+	switch m.mode {
+	case modeDescribe:
+		rows := max(height-4, 1)
+		// The terminal may have shrunk since the last scroll.
+		m.scroll = max(min(m.scroll, len(m.describe)-rows), 0)
+		end := min(len(m.describe), m.scroll+rows)
+		fmt.Fprintf(&b, "%s %s\n", pterm.Bold.Sprint(m.target.GetKind()), m.target.GetName())
+		b.WriteString(strings.Join(m.describe[m.scroll:end], "\n"))
+		b.WriteString("\n" + pterm.Gray("↑/↓ pgup/pgdn scroll · esc back"))
+	default:
+		b.WriteString(m.renderTable(tb, height-5))
+		b.WriteString("\n")
+		switch {
+		case m.mode == modeConfirm:
+			b.WriteString(pterm.Yellow(fmt.Sprintf("Delete %s %s? (y/N)", tb.t.Kind, m.target.GetName())))
+		case tb.err != "":
+			b.WriteString(pterm.Red("watch: " + tb.err))
+		case m.status != "":
+			b.WriteString(m.status)
+		default:
+			b.WriteString(pterm.Gray("←/→ tabs · ↑/↓ select · enter describe · x delete · q quit"))
+		}
+	}
+	// The terminal is in raw mode, which does not return the carriage on
+	// line feeds.
+	m.area.Update(strings.ReplaceAll(b.String(), "\n", "\r\n"))
+}
+
+// renderTable renders the rows of tb that fit in rows lines, scrolled so the
+// selection stays visible.
+func (m *model) renderTable(tb *tab, rows int) string {
+	items := tb.items()
+	if len(items) == 0 {
+		if !tb.synced {
+			return "Loading..."
+		}
+		return fmt.Sprintf("No %s found.", tb.t.Plural())
+	}
+	tb.selected = max(0, min(tb.selected, len(items)-1))
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 3, ' ', 0)
+	cols := tb.cols()
+	headers := make([]string, len(cols))
+	for i, c := range cols {
+		headers[i] = c.Header
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, obj := range items {
+		cells := make([]string, len(cols))
+		for i, c := range cols {
+			cells[i] = c.Value(obj)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	// lines[0] is the header; keep it and scroll the rest.
+	rows = max(rows-1, 1)
+	first := max(0, tb.selected-rows+1)
+	last := min(len(items), first+rows)
+	out := []string{pterm.Bold.Sprint(lines[0])}
+	for i := first; i < last; i++ {
+		line := lines[i+1]
+		if i == tb.selected {
+			line = pterm.NewStyle(pterm.BgGray, pterm.FgWhite).Sprint(line)
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func eventField(field string) func(*unstructured.Unstructured) string {
+	return func(obj *unstructured.Unstructured) string {
+		v, _, _ := unstructured.NestedString(obj.Object, field)
+		return v
+	}
+}
+
+// eventTime returns when an event was last seen, falling back to the newer
+// eventTime field and the creation time.
+func eventTime(obj *unstructured.Unstructured) time.Time {
+	for _, f := range []string{"lastTimestamp", "eventTime"} {
+		if v, _, _ := unstructured.NestedString(obj.Object, f); v != "" {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t
+			}
+		}
+	}
+	return obj.GetCreationTimestamp().Time
+}
+
+func GetUICmd() *cobra.Command {
+	return uiCmd
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+)
+
+// follow keeps the objects of tb current until m.ctx is done.
+func (m *model) follow(tb *tab) {
+	tb.t.Follow(m.ctx, m.dyn, resource.Handler{
+		Synced: func(items []unstructured.Unstructured) {
+			objects := make(map[string]*unstructured.Unstructured, len(items))
+			for i := range items {
+				objects[objectKey(&items[i])] = &items[i]
+			}
+			m.mu.Lock()
+			tb.objects, tb.synced, tb.err = objects, true, ""
+			m.mu.Unlock()
+			m.redraw()
+		},
+		Changed: func(typ watch.EventType, obj *unstructured.Unstructured) {
+			m.mu.Lock()
+			if typ == watch.Deleted {
+				delete(tb.objects, objectKey(obj))
+			} else {
+				tb.objects[objectKey(obj)] = obj
+			}
+			m.mu.Unlock()
+			m.redraw()
+		},
+		Failed: func(err error) {
+			m.mu.Lock()
+			tb.err = err.Error()
+			m.mu.Unlock()
+			m.redraw()
+		},
+	})
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -0,0 +1,169 @@
+package unstick
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// skyGroup is the API group suffix of the resources unstick works on.
+const skyGroup = "skycluster.io"
+
+var (
+	dryRun bool
+	grace  time.Duration
+)
+
+func init() {
+	unstickCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show the finalizers and what would be done")
+	unstickCmd.Flags().DurationVar(&grace, "wait", utils.DefaultFinalizerGrace, "How long to wait for the resource to go after the delete before removing its finalizers")
+}
+
+var unstickCmd = &cobra.Command{
+	Use:   "unstick <resource> <name>",
+	Short: "Remove the finalizers of a stuck SkyCluster resource and delete it",
+	Long: `Delete a SkyCluster resource that does not go away, e.g. because the
+controller holding its finalizers is gone:
+
+  skycluster unstick xkube gcp-us-east1
+  skycluster unstick xnetworks.v1alpha1.skycluster.io net-1
+
+The resource is a registered kind (xkube, xinstance, ...) or a resource of an
+skycluster.io group as resource.group or resource.version.group. After a
+confirmation (skipped with --yes), it is deleted; if it is still there after
+--wait, its finalizers are removed and it is deleted again, with a zero grace
+period as a last resort. Removing finalizers skips the cleanup they guard, so
+cloud resources may be left behind.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		kubeconfig := viper.GetString("kubeconfig")
+		disc, err := utils.GetDiscoveryClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build discovery client: %w", err)
+		}
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		gvr, ns, err := resolve(disc, args[0])
+		if err != nil {
+			return err
+		}
+		ns = utils.Namespace(ns)
+		namespaced, err := utils.IsNamespaced(disc, gvr)
+		if err != nil {
+			return err
+		}
+		if namespaced && ns == "" {
+			ns = utils.SystemNamespace
+		}
+		debugf("resolved %s to %s (namespace %q)", args[0], gvr, ns)
+		res, err := utils.ResourceFor(dyn, disc, gvr, ns)
+		if err != nil {
+			return err
+		}
+
+		name := args[1]
+		obj, err := res.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%s %s not found", gvr.Resource, name)
+		}
+		if err != nil {
+			return fmt.Errorf("getting %s %s: %w", gvr.Resource, name, err)
+		}
+		finalizers := "<none>"
+		if f := obj.GetFinalizers(); len(f) > 0 {
+			finalizers = strings.Join(f, ", ")
+		}
+		deleting := "no"
+		if ts := obj.GetDeletionTimestamp(); ts != nil {
+			deleting = "since " + ts.UTC().Format("2006-01-02 15:04:05 UTC")
+		}
+		fmt.Printf("%s %s\n  Finalizers: %s\n  Deleting:   %s\n", gvr.Resource, name, finalizers, deleting)
+		if dryRun {
+			fmt.Printf("Dry run: it would be deleted, its finalizers removed if it remains after %s, and force deleted as a last resort.\n", grace)
+			return nil
+		}
+		ok, err := resource.AskDelete(os.Stdin, os.Stdout, gvr.Resource, 1)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Deletion cancelled.")
+			return nil
+		}
+
+		forced, err := utils.ForceDelete(ctx, res, name, grace, debugf)
+		if err != nil {
+			return err
+		}
+		if forced {
+			fmt.Printf("%s %s: finalizers removed and deleted\n", gvr.Resource, name)
+		} else {
+			fmt.Printf("%s %s deleted\n", gvr.Resource, name)
+		}
+		return nil
+	},
+}
+
+// resolve returns the GVR of arg, a registered type name or kind, or a
+// resource.group or resource.version.group of an skycluster.io group, and
+// the namespace of registered types.
+func resolve(disc discovery.DiscoveryInterface, arg string) (schema.GroupVersionResource, string, error) {
+	if t, ok := resource.ForName(strings.ToLower(arg)); ok {
+		return t.GVR, t.Namespace, nil
+	}
+	if t, ok := resource.ForKind(arg); ok {
+		return t.GVR, t.Namespace, nil
+	}
+
+	full, gr := schema.ParseResourceArg(arg)
+	if full != nil && isSkyGroup(full.Group) {
+		if _, err := utils.IsNamespaced(disc, *full); err == nil {
+			return *full, "", nil
+		}
+	}
+	if !strings.Contains(arg, ".") {
+		return schema.GroupVersionResource{}, "", fmt.Errorf("unknown resource %q (a registered kind or resource.group, e.g. xkubes.%s)", arg, skyGroup)
+	}
+	if !isSkyGroup(gr.Group) {
+		return schema.GroupVersionResource{}, "", fmt.Errorf("%s is not a %s resource", arg, skyGroup)
+	}
+	groups, err := disc.ServerGroups()
+	if err != nil {
+		return schema.GroupVersionResource{}, "", fmt.Errorf("discovering API groups: %w", err)
+	}
+	for _, g := range groups.Groups {
+		if g.Name == gr.Group {
+			return gr.WithVersion(g.PreferredVersion.Version), "", nil
+		}
+	}
+	return schema.GroupVersionResource{}, "", fmt.Errorf("the server has no API group %s", gr.Group)
+}
+
+func isSkyGroup(group string) bool {
+	return group == skyGroup || strings.HasSuffix(group, "."+skyGroup)
+}
+
+// debugf logs a debug message of the unstick commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("unstick", format, args...)
+}
+
+func GetUnstickCmd() *cobra.Command {
+	return unstickCmd
+}
+
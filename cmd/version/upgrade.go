@@ -0,0 +1,168 @@
+package version
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+	cliversion "github.com/etesami/skycluster-cli/internal/version"
+)
+
+var (
+	upgradeFile      string
+	upgradeDryRun    bool
+	upgradeDowngrade bool
+	upgradeWait      time.Duration
+)
+
+func init() {
+	upgradeCmd.Flags().StringVarP(&upgradeFile, "file", "f", "", "Versions manifest to upgrade to instead of the one built into the CLI")
+	upgradeCmd.Flags().BoolVar(&upgradeDryRun, "dry-run", false, "Only list the Releases that would change")
+	upgradeCmd.Flags().BoolVar(&upgradeDowngrade, "allow-downgrade", false, "Also move Releases newer than the manifest back to it")
+	upgradeCmd.Flags().DurationVar(&upgradeWait, "wait", 10*time.Minute, "How long to wait for the upgraded Releases to be Ready; 0 does not wait")
+}
+
+// upgrade is a Release to move to another chart version.
+type upgrade struct {
+	release, chart, from, to string
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Move the Helm Releases of the cluster to the chart versions the CLI pins",
+	Long: `Move the Helm Releases of istio and submariner to the chart versions of the
+versions manifest built into the CLI, or of --file, and wait for them to be
+Ready. The manifest lists charts as:
+
+  charts:
+    - name: istiod
+      repository: https://istio-release.storage.googleapis.com/charts
+      version: 1.27.0
+
+Releases already newer than the manifest are kept unless --allow-downgrade.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifest := cliversion.Pinned()
+		if upgradeFile != "" {
+			m, err := cliversion.LoadManifest(upgradeFile)
+			if err != nil {
+				return err
+			}
+			manifest = m
+		}
+		cmd.SilenceUsage = true
+		ctx := cmd.Context()
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		releases, err := chartReleases(ctx, dyn)
+		if err != nil {
+			return err
+		}
+
+		var upgrades []upgrade
+		for _, r := range releases {
+			chart, _, _ := unstructured.NestedString(r.Object, "spec", "forProvider", "chart", "name")
+			from, _, _ := unstructured.NestedString(r.Object, "spec", "forProvider", "chart", "version")
+			p, ok := manifest.Chart(chart)
+			if !ok || p.Version == from {
+				continue
+			}
+			if newer(from, p.Version) && !upgradeDowngrade {
+				fmt.Fprintf(os.Stderr, "warning: Release %s has chart %s %s, newer than %s; keeping it (see --allow-downgrade)\n", r.GetName(), chart, from, p.Version)
+				continue
+			}
+			upgrades = append(upgrades, upgrade{release: r.GetName(), chart: chart, from: from, to: p.Version})
+		}
+		if len(upgrades) == 0 {
+			fmt.Println("All Releases are at the pinned chart versions.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RELEASE\tCHART\tFROM\tTO")
+		for _, u := range upgrades {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.release, u.chart, dash(u.from), u.to)
+		}
+		w.Flush()
+		if upgradeDryRun {
+			return nil
+		}
+		if ok, err := confirm(len(upgrades)); err != nil || !ok {
+			return err
+		}
+
+		var wait []utils.WaitResourceSpec
+		for _, u := range upgrades {
+			patch := fmt.Appendf(nil, `{"spec":{"forProvider":{"chart":{"version":%q}}}}`, u.to)
+			err := utils.Retry(ctx, func(ctx context.Context) error {
+				_, err := dyn.Resource(releaseGVR).Patch(ctx, u.release, types.MergePatchType, patch, metav1.PatchOptions{})
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("upgrading Release %s: %w", u.release, err)
+			}
+			fmt.Printf("Release %s: chart %s %s -> %s\n", u.release, u.chart, dash(u.from), u.to)
+			wait = append(wait, utils.WaitResourceSpec{
+				KindDescription: "Release " + u.release,
+				GVR:             releaseGVR,
+				Name:            u.release,
+				ConditionType:   "Ready",
+				Timeout:         upgradeWait,
+				PollInterval:    10 * time.Second,
+			})
+		}
+		if upgradeWait == 0 {
+			return nil
+		}
+		if err := utils.WaitWithProgress(ctx, dyn, wait, os.Stdout, debugf); err != nil {
+			return fmt.Errorf("waiting for the Releases: %w", err)
+		}
+		fmt.Println("Upgrade done.")
+		return nil
+	},
+}
+
+// newer reports whether the chart version a is newer than b. Versions that
+// do not parse are not.
+func newer(a, b string) bool {
+	va, err := utilversion.ParseGeneric(a)
+	if err != nil {
+		return false
+	}
+	vb, err := utilversion.ParseGeneric(b)
+	if err != nil {
+		return false
+	}
+	return vb.LessThan(va)
+}
+
+// confirm asks whether to upgrade n Releases, unless --yes.
+func confirm(n int) (bool, error) {
+	if utils.AssumeYes() {
+		return true, nil
+	}
+	if err := utils.CanPrompt(os.Stdin); err != nil {
+		return false, err
+	}
+	fmt.Printf("Upgrading these %d Releases? (y/N): ", n)
+	resp, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if r := strings.ToLower(strings.TrimSpace(resp)); r != "y" && r != "yes" {
+		fmt.Println("Aborted.")
+		return false, nil
+	}
+	return true, nil
+}
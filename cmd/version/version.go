@@ -0,0 +1,255 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/utils"
+	cliversion "github.com/etesami/skycluster-cli/internal/version"
+)
+
+var (
+	releaseGVR = schema.GroupVersionResource{Group: "helm.crossplane.io", Version: "v1beta1", Resource: "releases"}
+	crdGVR     = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	xrdGVR     = schema.GroupVersionResource{Group: "apiextensions.crossplane.io", Version: "v1", Resource: "compositeresourcedefinitions"}
+)
+
+var (
+	versionOutput string
+	clientOnly    bool
+)
+
+func init() {
+	versionCmd.Flags().StringVarP(&versionOutput, "output", "o", "table", "Output format: table or json")
+	versionCmd.Flags().BoolVar(&clientOnly, "client", false, "Only show the CLI version, without asking the cluster")
+}
+
+// component is an installed part of SkyCluster and its version.
+type component struct {
+	Name      string `json:"name"`
+	Pinned    string `json:"pinned,omitempty"`
+	Installed string `json:"installed,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Source    string `json:"source"`
+}
+
+// versionInfo is what version prints.
+type versionInfo struct {
+	CLI        string      `json:"cli"`
+	Server     string      `json:"server,omitempty"`
+	Components []component `json:"components,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show the CLI version and the versions of the charts and CRDs installed on the cluster",
+	Long: `Show the version of the CLI, and of the management cluster the Helm
+Releases of istio and submariner, their CRDs and the SkyCluster XRDs, next to
+the chart versions this CLI pins. Move the Releases to the pinned versions
+with skycluster upgrade.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if versionOutput != "table" && versionOutput != "json" {
+			return fmt.Errorf("unknown output format %q (supported: table, json)", versionOutput)
+		}
+		info := versionInfo{CLI: cliversion.Get()}
+		if !clientOnly {
+			if err := discover(cmd.Context(), &info); err != nil {
+				debugf("discovering the installed versions: %v", err)
+				info.Error = err.Error()
+			}
+		}
+
+		if versionOutput == "json" {
+			out, err := json.MarshalIndent(info, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(out))
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "CLI version:\t%s\n", info.CLI)
+		if info.Server != "" {
+			fmt.Fprintf(w, "Kubernetes:\t%s\n", info.Server)
+		}
+		w.Flush()
+		if clientOnly {
+			return nil
+		}
+		if info.Error != "" {
+			fmt.Fprintf(os.Stderr, "warning: cannot read the installed versions: %s\n", info.Error)
+			return nil
+		}
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "COMPONENT\tPINNED\tINSTALLED\tSTATUS\tSOURCE")
+		for _, c := range info.Components {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Name, dash(c.Pinned), dash(c.Installed), dash(c.Status), c.Source)
+		}
+		return w.Flush()
+	},
+}
+
+// discover fills the server version and the components of info from the
+// cluster.
+func discover(ctx context.Context, info *versionInfo) error {
+	kubeconfig := viper.GetString("kubeconfig")
+	dc, err := utils.GetDiscoveryClient(kubeconfig)
+	if err != nil {
+		return err
+	}
+	sv, err := dc.ServerVersion()
+	if err != nil {
+		return err
+	}
+	info.Server = sv.GitVersion
+	dyn, err := utils.GetDynamicClient(kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	pinned := cliversion.Pinned()
+	releases, err := chartReleases(ctx, dyn)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, r := range releases {
+		chart, _, _ := unstructured.NestedString(r.Object, "spec", "forProvider", "chart", "name")
+		installed, _, _ := unstructured.NestedString(r.Object, "spec", "forProvider", "chart", "version")
+		state, _, _ := unstructured.NestedString(r.Object, "status", "atProvider", "state")
+		c := component{Name: chart, Installed: installed, Status: state, Source: "Release " + r.GetName()}
+		if p, ok := pinned.Chart(chart); ok {
+			c.Pinned = p.Version
+			seen[chart] = true
+		}
+		info.Components = append(info.Components, c)
+	}
+	// pinned charts without a Release are not installed
+	for _, p := range pinned.Charts {
+		if !seen[p.Name] {
+			info.Components = append(info.Components, component{Name: p.Name, Pinned: p.Version, Status: "not installed", Source: "Release"})
+		}
+	}
+
+	crds, err := dyn.Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, g := range []struct{ name, suffix string }{{"istio CRDs", "istio.io"}, {"submariner CRDs", "submariner.io"}} {
+		info.Components = append(info.Components, crdComponent(g.name, g.suffix, crds.Items))
+	}
+
+	xrds, err := dyn.Resource(xrdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		debugf("listing the XRDs: %v", err)
+		info.Components = append(info.Components, component{Name: "skycluster XRDs", Status: "unknown", Source: "CompositeResourceDefinitions"})
+		return nil
+	}
+	versions := map[string]bool{}
+	n, ready := 0, 0
+	for i := range xrds.Items {
+		x := &xrds.Items[i]
+		group, _, _ := unstructured.NestedString(x.Object, "spec", "group")
+		if !strings.HasSuffix(group, "skycluster.io") {
+			continue
+		}
+		n++
+		if utils.IsConditionTrue(x, "Established") {
+			ready++
+		}
+		vs, _, _ := unstructured.NestedSlice(x.Object, "spec", "versions")
+		for _, v := range vs {
+			if vm, ok := v.(map[string]interface{}); ok && vm["referenceable"] == true {
+				versions[fmt.Sprint(vm["name"])] = true
+			}
+		}
+	}
+	c := component{Name: "skycluster XRDs", Installed: joinKeys(versions), Source: fmt.Sprintf("%d CompositeResourceDefinitions", n)}
+	if n == 0 {
+		c.Status = "not installed"
+	} else {
+		c.Status = fmt.Sprintf("%d/%d established", ready, n)
+	}
+	info.Components = append(info.Components, c)
+	return nil
+}
+
+// crdComponent summarizes the CRDs of the groups ending in suffix, with the
+// chart versions their labels record.
+func crdComponent(name, suffix string, crds []unstructured.Unstructured) component {
+	versions := map[string]bool{}
+	n := 0
+	for i := range crds {
+		group, _, _ := unstructured.NestedString(crds[i].Object, "spec", "group")
+		if !strings.HasSuffix(group, suffix) {
+			continue
+		}
+		n++
+		labels := crds[i].GetLabels()
+		if v := labels["app.kubernetes.io/version"]; v != "" {
+			versions[v] = true
+		} else if chart := labels["helm.sh/chart"]; chart != "" {
+			versions[chart[strings.LastIndex(chart, "-")+1:]] = true
+		}
+	}
+	c := component{Name: name, Installed: joinKeys(versions), Source: fmt.Sprintf("%d CustomResourceDefinitions", n)}
+	if n == 0 {
+		c.Status = "not installed"
+	}
+	return c
+}
+
+// chartReleases returns the Helm Releases of the cluster, sorted by name.
+func chartReleases(ctx context.Context, dyn dynamic.Interface) ([]unstructured.Unstructured, error) {
+	list, err := dyn.Resource(releaseGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing the Helm Releases: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].GetName() < list.Items[j].GetName() })
+	return list.Items, nil
+}
+
+func joinKeys(m map[string]bool) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// debugf logs a debug message of the version and upgrade commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("version", format, args...)
+}
+
+func GetVersionCmd() *cobra.Command {
+	return versionCmd
+}
+
+func GetUpgradeCmd() *cobra.Command {
+	return upgradeCmd
+}
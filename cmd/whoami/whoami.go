@@ -0,0 +1,48 @@
+package whoami
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
+	"github.com/etesami/skycluster-cli/internal/version"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the identity stamped on resources created by this CLI",
+	Run: func(cmd *cobra.Command, args []string) {
+		id := audit.WhoAmI()
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(writer, "Local user:\t%s\n", id.LocalUser)
+		fmt.Fprintf(writer, "Host:\t%s\n", id.Host)
+		fmt.Fprintf(writer, "Kube context:\t%s\n", valueOrNone(id.KubeContext))
+		fmt.Fprintf(writer, "Kube user:\t%s\n", valueOrNone(id.KubeUser))
+		fmt.Fprintf(writer, "CLI version:\t%s\n", version.Get())
+		writer.Flush()
+
+		fmt.Println("\nAnnotations stamped on created resources:")
+		writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(writer, "  %s\t%s\n", audit.AnnotationCreatedBy, id.String())
+		fmt.Fprintf(writer, "  %s\t<time of creation>\n", audit.AnnotationCreatedAt)
+		fmt.Fprintf(writer, "  %s\t%s\n", audit.AnnotationCLIVersion, version.Get())
+		fmt.Fprintf(writer, "  %s\t<random, one per invocation>\n", audit.AnnotationOperationID)
+		fmt.Fprintf(writer, "  %s\t<time the invocation started>\n", audit.AnnotationOperationStartedAt)
+		fmt.Fprintf(writer, "  %s\t<time taken, including --wait>\n", audit.AnnotationOperationDuration)
+		writer.Flush()
+	},
+}
+
+func GetWhoAmICmd() *cobra.Command {
+	return whoamiCmd
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,295 @@
+package xinstance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// composedDepth is how many levels of composed resources --resources follows.
+const composedDepth = 3
+
+var (
+	describeOutput    string
+	describeResources bool
+)
+
+func init() {
+	xInstanceDescribeCmd.Flags().StringVarP(&describeOutput, "output", "o", "", "Output format: yaml or json; the default is a readable summary")
+	xInstanceDescribeCmd.Flags().BoolVar(&describeResources, "resources", false, "Also show the Crossplane resources composed for the XInstance, with their cloud names and conditions")
+	xInstanceCmd.AddCommand(xInstanceDescribeCmd)
+}
+
+var xInstanceDescribeCmd = &cobra.Command{
+	Use:     "describe <name>",
+	Aliases: []string{"get"},
+	Short:   "Show the flavor, image, network, volumes and conditions of an XInstance",
+	Long: `Show an XInstance in full: its provider, flavor and image, whether it is a
+spot instance, its addresses and the other network details, its volumes, and
+the history of its conditions and events.
+
+With --resources the Crossplane resources composed for it are listed as well,
+down to the managed resources of the cloud, with their external names and
+conditions, to find the one that is stuck. With -o yaml or -o json the
+XInstance itself is printed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		kubeconfig := viper.GetString("kubeconfig")
+		dyn, err := utils.GetDynamicClient(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		obj, err := utils.GetWithSuggestions(ctx, resource.XInstance.Client(dyn), resource.XInstance.Name, args[0])
+		if err != nil {
+			return err
+		}
+		obj = obj.DeepCopy()
+		unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+
+		switch describeOutput {
+		case "yaml":
+			out, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(out)
+			return err
+		case "json":
+			out, err := json.MarshalIndent(obj.Object, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(out))
+			return err
+		case "":
+		default:
+			return fmt.Errorf("unknown output format %q (supported: yaml, json)", describeOutput)
+		}
+
+		cs, err := utils.GetClientset(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build clientset: %w", err)
+		}
+		if err := describe(ctx, os.Stdout, cs, obj); err != nil {
+			return err
+		}
+		if !describeResources {
+			return nil
+		}
+		groups, err := restmapper.GetAPIGroupResources(cs.Discovery())
+		if err != nil {
+			return fmt.Errorf("discovering API resources: %w", err)
+		}
+		return describeComposed(ctx, os.Stdout, dyn, restmapper.NewDiscoveryRESTMapper(groups), obj)
+	},
+}
+
+// describe writes a readable summary of the XInstance obj to w.
+func describe(ctx context.Context, w io.Writer, cs *kubernetes.Clientset, obj *unstructured.Unstructured) error {
+	str := func(path ...string) string {
+		s, _, _ := unstructured.NestedString(obj.Object, path...)
+		return orDash(s)
+	}
+	flag := func(path ...string) string {
+		b, found, _ := unstructured.NestedBool(obj.Object, path...)
+		if !found {
+			return "-"
+		}
+		return strconv.FormatBool(b)
+	}
+	flavor, _, _ := unstructured.NestedString(obj.Object, "spec", "flavor")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", obj.GetName())
+	fmt.Fprintf(tw, "Provider:\t%s\n", str("status", "providerName"))
+	fmt.Fprintf(tw, "Platform:\t%s\n", str("spec", "providerRef", "platform"))
+	fmt.Fprintf(tw, "Region:\t%s\n", str("spec", "providerRef", "region"))
+	fmt.Fprintf(tw, "Zone:\t%s\n", str("spec", "providerRef", "zones", "primary"))
+	fmt.Fprintf(tw, "Flavor:\t%s\n", orDash(flavor))
+	if flavor != "" {
+		if gpu := utils.GPUFromFlavorName(flavor); gpu.Enabled {
+			fmt.Fprintf(tw, "GPU:\t%s\n", gpu)
+		}
+	}
+	fmt.Fprintf(tw, "Image:\t%s\n", str("spec", "image"))
+	fmt.Fprintf(tw, "Spot instance:\t%s\n", flag("spec", "spotInstance"))
+	fmt.Fprintf(tw, "Public IP requested:\t%s\n", flag("spec", "publicIp"))
+	fmt.Fprintf(tw, "Ready:\t%s\n", orDash(utils.GetConditionStatus(obj, "Ready")))
+	fmt.Fprintf(tw, "Age:\t%s\n", duration.HumanDuration(time.Since(obj.GetCreationTimestamp().Time)))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nNetwork:")
+	network, _, _ := unstructured.NestedMap(obj.Object, "status", "network")
+	if len(network) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else if err := writeYAML(w, network); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nVolumes:")
+	volumes := map[string]interface{}{}
+	for _, part := range []string{"spec", "status"} {
+		m, _, _ := unstructured.NestedMap(obj.Object, part)
+		for k, v := range m {
+			if l := strings.ToLower(k); strings.Contains(l, "volume") || strings.Contains(l, "disk") {
+				volumes[part+"."+k] = v
+			}
+		}
+	}
+	if len(volumes) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else if err := writeYAML(w, volumes); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nConditions:")
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	sort.SliceStable(conds, func(i, j int) bool {
+		return conditionTime(conds[i]).Before(conditionTime(conds[j]))
+	})
+	if len(conds) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  TYPE\tSTATUS\tREASON\tSINCE\tMESSAGE")
+		for _, c := range conds {
+			cm, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", orDash(fmt.Sprint(cm["type"])), orDash(fmt.Sprint(cm["status"])),
+				orDash(fmt.Sprint(cm["reason"])), age(conditionTime(c)), orDash(fmt.Sprint(cm["message"])))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "\nEvents:")
+	events, err := cs.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.uid=" + string(obj.GetUID()),
+	})
+	if err != nil {
+		fmt.Fprintf(w, "  %v\n", err)
+		return nil
+	}
+	if len(events.Items) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return nil
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return utils.EventTime(&events.Items[i]).Before(utils.EventTime(&events.Items[j]))
+	})
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TYPE\tREASON\tAGE\tCOUNT\tMESSAGE")
+	for i := range events.Items {
+		ev := &events.Items[i]
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%d\t%s\n", ev.Type, ev.Reason, age(utils.EventTime(ev)), max(ev.Count, 1), strings.TrimSpace(ev.Message))
+	}
+	return tw.Flush()
+}
+
+// describeComposed writes the tree of the resources composed for obj, with
+// the external name and the conditions of each.
+func describeComposed(ctx context.Context, w io.Writer, dyn dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) error {
+	fmt.Fprintln(w, "\nComposed resources:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  RESOURCE\tSYNCED\tREADY\tEXTERNAL NAME\tMESSAGE")
+	var walk func(obj *unstructured.Unstructured, indent string, level int)
+	walk = func(obj *unstructured.Unstructured, indent string, level int) {
+		for _, ref := range utils.ResourceRefs(obj) {
+			title := indent + ref.GetKind() + "/" + ref.GetName()
+			child, err := utils.GetRef(ctx, dyn, mapper, ref)
+			if err != nil {
+				fmt.Fprintf(tw, "  %s\t-\t-\t-\t%v\n", title, err)
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", title, orDash(utils.GetConditionStatus(child, "Synced")),
+				orDash(utils.GetConditionStatus(child, "Ready")), orDash(child.GetAnnotations()["crossplane.io/external-name"]), orDash(notReadyMessage(child)))
+			if level+1 < composedDepth {
+				walk(child, indent+"  ", level+1)
+			}
+		}
+	}
+	walk(obj, "", 0)
+	return tw.Flush()
+}
+
+// notReadyMessage returns the message, or else the reason, of the first
+// Synced or Ready condition of obj that is not True.
+func notReadyMessage(obj *unstructured.Unstructured) string {
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, want := range []string{"Synced", "Ready"} {
+		for _, c := range conds {
+			cm, ok := c.(map[string]interface{})
+			if !ok || cm["type"] != want || cm["status"] == "True" {
+				continue
+			}
+			msg, _ := cm["message"].(string)
+			if msg == "" {
+				msg, _ = cm["reason"].(string)
+			}
+			return msg
+		}
+	}
+	return ""
+}
+
+func conditionTime(c interface{}) time.Time {
+	cm, _ := c.(map[string]interface{})
+	ts, _ := cm["lastTransitionTime"].(string)
+	t, _ := time.Parse(time.RFC3339, ts)
+	return t
+}
+
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return duration.HumanDuration(time.Since(t))
+}
+
+// writeYAML writes v as indented YAML.
+func writeYAML(w io.Writer, v interface{}) error {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	for _, l := range strings.SplitAfter(string(out), "\n") {
+		if l != "" {
+			fmt.Fprint(w, "  "+l)
+		}
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" || s == "<nil>" {
+		return "-"
+	}
+	return s
+}
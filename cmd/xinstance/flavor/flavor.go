@@ -2,26 +2,50 @@ package flavor
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"maps"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-	"text/tabwriter"
 
-	vars "github.com/etesami/skycluster-cli/internal"
 	utils "github.com/etesami/skycluster-cli/internal/utils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 var pNames []string
+var gpuOnly bool
+var listTable utils.TableOptions
+var listOutput string
+
+// sortKeys are the --sort-by names kept for the columns they stand for.
+var sortKeys = map[string]string{"name": "FLAVOR", "price": "PRICE/H", "vcpu": "VCPU", "ram": "RAM"}
+
+var (
+	flavorHeaders = []string{"FLAVOR", "PROVIDER", "PROVIDER_FLAVOR", "VCPU", "RAM", "ARCH", "GPU", "PRICE/H"}
+	gpuHeaders    = []string{"FLAVOR", "PROVIDER", "PROVIDER_FLAVOR", "GPU_MODEL", "GPU_COUNT", "GPU_MEMORY"}
+)
 
 func init() {
 	flavorCmd.AddCommand(flavorListCmd)
 	flavorListCmd.PersistentFlags().StringSliceVarP(&pNames, "provider-name", "p", nil, "Provider Names, seperated by comma")
+	flavorListCmd.PersistentFlags().BoolVar(&gpuOnly, "gpu", false, "Only list accelerator-bearing flavors, with GPU model and count per provider")
+	utils.AddTableFlags(flavorListCmd, &listTable, "")
+	flavorListCmd.Flags().Lookup("sort-by").Usage = "Sort the flavors by name, price, vcpu, ram or another column, prefixed with - for descending order"
+	flavorListCmd.Flags().StringVarP(&listOutput, "output", "o", "table", "Output format: table or json")
+	// --provider is accepted for --provider-name
+	flavorListCmd.SetGlobalNormalizationFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "provider" {
+			name = "provider-name"
+		}
+		return pflag.NormalizedName(name)
+	})
 }
 
 var flavorCmd = &cobra.Command{
@@ -35,20 +59,141 @@ var flavorCmd = &cobra.Command{
 var flavorListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List avaialble flavors across providers",
-	Run: func(cmd *cobra.Command, args []string) {
-		listFlavors()
+	Long: `List the flavors of the provider-mappings ConfigMaps, one row per flavor and
+provider, with the vCPUs, RAM, architecture and hourly price the ConfigMap
+values give. vCPUs and RAM missing from a value are read from the flavor name,
+e.g. 4vCPU-16GB.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := listTable
+		desc := strings.HasPrefix(opts.SortBy, "-")
+		if col, ok := sortKeys[strings.ToLower(strings.TrimPrefix(opts.SortBy, "-"))]; ok {
+			opts.SortBy = col
+			if desc {
+				opts.SortBy = "-" + col
+			}
+		}
+		if gpuOnly {
+			if err := utils.NewTable(gpuHeaders...).Check(opts); err != nil {
+				return err
+			}
+			return listGPUFlavors(opts)
+		}
+		if err := utils.NewTable(flavorHeaders...).Check(opts); err != nil {
+			return err
+		}
+		return listFlavors(opts)
 	},
 }
 
-func listFlavors() {
-	kconfig := viper.GetStringMapString("kubeconfig")
-	kubeconfig := kconfig["sky-manager"]
+// flavorOffer is a flavor as one provider offers it.
+type flavorOffer struct {
+	Flavor   string `json:"flavor"`
+	Provider string `json:"provider"`
+	utils.FlavorSpec
+}
+
+func listFlavors(opts utils.TableOptions) error {
+	if listOutput != "table" && listOutput != "json" {
+		return fmt.Errorf("unknown output format %q (supported: table, json)", listOutput)
+	}
+
+	offers := []flavorOffer{}
+	for pID, entries := range getProviderFlavors() {
+		for key, value := range entries {
+			offers = append(offers, flavorOffer{Flavor: key, Provider: pID, FlavorSpec: utils.ParseFlavor(key, value)})
+		}
+	}
+	sort.SliceStable(offers, func(i, j int) bool {
+		if offers[i].Flavor != offers[j].Flavor {
+			return offers[i].Flavor < offers[j].Flavor
+		}
+		return offers[i].Provider < offers[j].Provider
+	})
+	tbl := utils.NewTable(flavorHeaders...)
+	if listOutput == "json" {
+		// order as the table would be
+		if less, _ := tbl.Less(opts.SortBy); less != nil {
+			sort.SliceStable(offers, func(i, j int) bool { return less(offers[i].cells(), offers[j].cells()) })
+		}
+		out, err := json.MarshalIndent(offers, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(out))
+		return err
+	}
+	if len(offers) == 0 {
+		fmt.Println("No flavors available")
+		return nil
+	}
+	for _, o := range offers {
+		tbl.Append(o.cells()...)
+	}
+	return tbl.Write(os.Stdout, opts)
+}
+
+// cells returns the table row of o.
+func (o flavorOffer) cells() []string {
+	vcpus, price := "-", "-"
+	if o.VCPUs > 0 {
+		vcpus = strconv.Itoa(o.VCPUs)
+	}
+	if o.Price > 0 {
+		price = strconv.FormatFloat(o.Price, 'f', -1, 64)
+	}
+	return []string{o.Flavor, o.Provider, dash(o.Name), vcpus, dash(o.RAM), dash(o.Arch), o.GPU.String(), price}
+}
+
+// listGPUFlavors prints one row per provider offering an accelerator-bearing
+// flavor, followed by a hint on how to schedule an XInstance onto it.
+func listGPUFlavors(opts utils.TableOptions) error {
+	type row struct {
+		flavor, provider, name string
+		gpu                    utils.GPUSpec
+	}
+	var rows []row
+	for pID, entries := range getProviderFlavors() {
+		for key, value := range entries {
+			f := utils.ParseFlavor(key, value)
+			if !f.GPU.Enabled {
+				continue
+			}
+			rows = append(rows, row{flavor: key, provider: pID, name: f.Name, gpu: f.GPU})
+		}
+	}
+	if len(rows) == 0 {
+		fmt.Println("No GPU flavors available")
+		return nil
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].flavor != rows[j].flavor {
+			return rows[i].flavor < rows[j].flavor
+		}
+		return rows[i].provider < rows[j].provider
+	})
+
+	tbl := utils.NewTable(gpuHeaders...)
+	for _, r := range rows {
+		tbl.Append(r.flavor, r.provider, dash(r.name), dash(r.gpu.Model), strconv.Itoa(r.gpu.Count), dash(r.gpu.Memory))
+	}
+	if err := tbl.Write(os.Stdout, opts); err != nil {
+		return err
+	}
+	fmt.Println("\nHint: set spec.flavor to a FLAVOR above and spec.providerRef to one of its providers " +
+		"(<platform>_<region>_<zone>) so the XInstance is scheduled where the GPU is offered.")
+	return nil
+}
+
+// getProviderFlavors returns the flavor entries of the provider-mappings
+// ConfigMaps, keyed by "<provider>_<region>_<zone>" and restricted to -p if set.
+func getProviderFlavors() map[string]map[string]string {
+	kubeconfig := viper.GetString("kubeconfig")
 	clientset, err := utils.GetClientset(kubeconfig)
 	if err != nil {
 		log.Fatalf("Error getting clientset: %v", err)
-		return
 	}
-	flavorList := make(map[string][]string, 0)
+	flavorList := make(map[string]map[string]string, 0)
 	baseFilters := "skycluster.io/managed-by=skycluster, skycluster.io/config-type=provider-mappings"
 	for _, n := range pNames {
 		filters := baseFilters + ", skycluster.io/provider-name=" + n
@@ -61,24 +206,12 @@ func listFlavors() {
 		filteredFlavors := getFlavorData(clientset, baseFilters)
 		maps.Copy(flavorList, filteredFlavors)
 	}
-
-	availableFlavors := utils.IntersectionOfMapValues(flavorList, utils.GetMapStringKeys(flavorList))
-	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', tabwriter.AlignRight)
-	if len(availableFlavors) == 0 {
-		fmt.Println("No flavors available")
-	} else {
-		fmt.Fprintln(writer, "NAME\tOFFERED BY")
-	}
-	for _, f := range availableFlavors {
-		fmt.Fprintf(writer, "%s\t%d\n", f, len(flavorList))
-	}
-	writer.Flush()
-
+	return flavorList
 }
 
-func getFlavorData(clientset *kubernetes.Clientset, filters string) map[string][]string {
-	flavorList := make(map[string][]string, 0)
-	confgis, err := clientset.CoreV1().ConfigMaps(vars.SkyClusterName).List(context.Background(), metav1.ListOptions{
+func getFlavorData(clientset *kubernetes.Clientset, filters string) map[string]map[string]string {
+	flavorList := make(map[string]map[string]string, 0)
+	confgis, err := clientset.CoreV1().ConfigMaps(utils.SystemNamespace).List(context.Background(), metav1.ListOptions{
 		LabelSelector: filters,
 	})
 	if err != nil {
@@ -86,14 +219,14 @@ func getFlavorData(clientset *kubernetes.Clientset, filters string) map[string][
 	}
 
 	for _, cm := range confgis.Items {
-		fList := make([]string, 0)
+		fList := make(map[string]string, 0)
 		pName := cm.Labels["skycluster.io/provider-name"]
 		pRegion := cm.Labels["skycluster.io/provider-region"]
 		pZone := cm.Labels["skycluster.io/provider-zone"]
 		pID := pName + "_" + pRegion + "_" + pZone
-		for d, _ := range cm.Data {
+		for d, v := range cm.Data {
 			if strings.Contains(d, "flavor") {
-				fList = append(fList, d)
+				fList[d] = v
 			}
 		}
 		if len(fList) > 0 {
@@ -103,6 +236,13 @@ func getFlavorData(clientset *kubernetes.Clientset, filters string) map[string][
 	return flavorList
 }
 
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func GetFlavorCmd() *cobra.Command {
 	return flavorCmd
 }
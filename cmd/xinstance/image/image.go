@@ -4,12 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"maps"
 	"os"
+	"slices"
+	"sort"
 	"strings"
-	"text/tabwriter"
 
-	vars "github.com/etesami/skycluster-cli/internal"
 	utils "github.com/etesami/skycluster-cli/internal/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,10 +17,17 @@ import (
 )
 
 var pNames []string
+var region, zone string
+var listTable utils.TableOptions
+
+var imageHeaders = []string{"IMAGE", "PROVIDER", "REGION", "ZONE", "PROVIDER_IMAGE"}
 
 func init() {
 	imageCmd.AddCommand(imageListCmd)
 	imageListCmd.PersistentFlags().StringSliceVarP(&pNames, "provider-name", "p", nil, "Provider Names, seperated by comma")
+	imageListCmd.Flags().StringVar(&region, "region", "", "Only list the images of this region")
+	imageListCmd.Flags().StringVar(&zone, "zone", "", "Only list the images of this zone")
+	utils.AddTableFlags(imageListCmd, &listTable, "")
 }
 
 var imageCmd = &cobra.Command{
@@ -33,50 +39,81 @@ var imageCmd = &cobra.Command{
 }
 
 var imageListCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [image]...",
 	Short: "List avaialble images across providers",
-	Run: func(cmd *cobra.Command, args []string) {
-		listImages()
+	Long: `List the generic images of the provider-mappings ConfigMaps and what each
+resolves to per provider, region and zone, e.g. the AMI of ubuntu-22.04 in
+every AWS region. Name images to only list those.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := utils.NewTable(imageHeaders...).Check(listTable); err != nil {
+			return err
+		}
+		return listImages(args)
 	},
 }
 
-func listImages() {
-	kconfig := viper.GetStringMapString("kubeconfig")
-	kubeconfig := kconfig["sky-manager"]
+// imageMapping is the image a provider, in a region and zone, runs for a
+// generic image name.
+type imageMapping struct {
+	image, provider, region, zone, id string
+}
+
+func listImages(names []string) error {
+	kubeconfig := viper.GetString("kubeconfig")
 	clientset, err := utils.GetClientset(kubeconfig)
 	if err != nil {
 		log.Fatalf("Error getting clientset: %v", err)
-		return
 	}
-	imageList := make(map[string][]string, 0)
 	baseFilters := "skycluster.io/managed-by=skycluster, skycluster.io/config-type=provider-mappings"
+	if region != "" {
+		baseFilters += ", skycluster.io/provider-region=" + region
+	}
+	if zone != "" {
+		baseFilters += ", skycluster.io/provider-zone=" + zone
+	}
+	var mappings []imageMapping
 	for _, n := range pNames {
 		filters := baseFilters + ", skycluster.io/provider-name=" + n
-		filteredImages := getImageData(clientset, filters)
-		maps.Copy(imageList, filteredImages)
+		mappings = append(mappings, getImageData(clientset, filters)...)
 	}
 
 	// no provider names provided, get all images
 	if len(pNames) == 0 {
-		filteredImages := getImageData(clientset, baseFilters)
-		maps.Copy(imageList, filteredImages)
+		mappings = getImageData(clientset, baseFilters)
 	}
-	availableImages := utils.IntersectionOfMapValues(imageList, utils.GetMapStringKeys(imageList))
-	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', tabwriter.AlignRight)
-	if len(availableImages) == 0 {
+	if len(names) > 0 {
+		mappings = slices.DeleteFunc(mappings, func(m imageMapping) bool {
+			return !slices.Contains(names, m.image)
+		})
+	}
+	if len(mappings) == 0 {
 		fmt.Println("No images available")
-	} else {
-		fmt.Fprintln(writer, "NAME\tOFFERED BY")
+		return nil
 	}
-	for _, f := range availableImages {
-		fmt.Fprintf(writer, "%s\t%d\n", f, len(imageList))
+	sort.Slice(mappings, func(i, j int) bool {
+		a, b := mappings[i], mappings[j]
+		if a.image != b.image {
+			return a.image < b.image
+		}
+		if a.provider != b.provider {
+			return a.provider < b.provider
+		}
+		if a.region != b.region {
+			return a.region < b.region
+		}
+		return a.zone < b.zone
+	})
+
+	tbl := utils.NewTable(imageHeaders...)
+	for _, m := range mappings {
+		tbl.Append(m.image, m.provider, dash(m.region), dash(m.zone), dash(m.id))
 	}
-	writer.Flush()
+	return tbl.Write(os.Stdout, listTable)
 }
 
-func getImageData(clientset *kubernetes.Clientset, filters string) map[string][]string {
-	imageList := make(map[string][]string, 0)
-	confgis, err := clientset.CoreV1().ConfigMaps(vars.SkyClusterName).List(context.Background(), metav1.ListOptions{
+func getImageData(clientset *kubernetes.Clientset, filters string) []imageMapping {
+	var imageList []imageMapping
+	confgis, err := clientset.CoreV1().ConfigMaps(utils.SystemNamespace).List(context.Background(), metav1.ListOptions{
 		LabelSelector: filters,
 	})
 	if err != nil {
@@ -84,18 +121,17 @@ func getImageData(clientset *kubernetes.Clientset, filters string) map[string][]
 	}
 
 	for _, cm := range confgis.Items {
-		fList := make([]string, 0)
-		pName := cm.Labels["skycluster.io/provider-name"]
-		pRegion := cm.Labels["skycluster.io/provider-region"]
-		pZone := cm.Labels["skycluster.io/provider-zone"]
-		pID := pName + "_" + pRegion + "_" + pZone
-		for d, _ := range cm.Data {
-			if strings.Contains(d, "image") {
-				fList = append(fList, d)
+		for d, v := range cm.Data {
+			if !strings.Contains(d, "image") {
+				continue
 			}
-		}
-		if len(fList) > 0 {
-			imageList[pID] = fList
+			imageList = append(imageList, imageMapping{
+				image:    utils.MappingName(d, "image"),
+				provider: cm.Labels["skycluster.io/provider-name"],
+				region:   cm.Labels["skycluster.io/provider-region"],
+				zone:     cm.Labels["skycluster.io/provider-zone"],
+				id:       strings.TrimSpace(v),
+			})
 		}
 	}
 	return imageList
@@ -104,3 +140,10 @@ func getImageData(clientset *kubernetes.Clientset, filters string) map[string][]
 func GetImageCmd() *cobra.Command {
 	return imageCmd
 }
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
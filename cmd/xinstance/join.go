@@ -0,0 +1,341 @@
+package xinstance
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	joinXKube    string
+	joinMethod   string
+	joinUser     string
+	joinTimeout  time.Duration
+	joinTokenTTL time.Duration
+)
+
+func init() {
+	xInstanceJoinCmd.Flags().StringVarP(&joinXKube, "xkube", "k", "", "Name of the xkube to join (required)")
+	xInstanceJoinCmd.Flags().StringVar(&joinMethod, "method", "kubeadm", "Join method: kubeadm or k3s")
+	xInstanceJoinCmd.Flags().StringVar(&joinUser, "user", "ubuntu", "SSH user on the instance")
+	xInstanceJoinCmd.Flags().DurationVar(&joinTimeout, "timeout", 10*time.Minute, "How long to wait for the node to become Ready")
+	xInstanceJoinCmd.Flags().DurationVar(&joinTokenTTL, "token-ttl", time.Hour, "Lifetime of the bootstrap token created on the xkube")
+	_ = xInstanceJoinCmd.MarkFlagRequired("xkube")
+}
+
+var xInstanceJoinCmd = &cobra.Command{
+	Use:   "join-cluster <instance>",
+	Short: "Join an XInstance to an xkube as a worker node",
+	Long: `Join an XInstance to an xkube as a worker node.
+
+A bootstrap token is created on the target xkube, the instance is reached over
+SSH with the SkyCluster keypair (through its provider gateway when it has no
+public IP), the kubeadm or k3s join is run there, and the command waits for the
+node to appear Ready.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if joinMethod != "kubeadm" && joinMethod != "k3s" {
+			return fmt.Errorf("unsupported --method %q (use kubeadm or k3s)", joinMethod)
+		}
+		return joinCluster(cmd.Context(), args[0], joinXKube)
+	},
+}
+
+func joinCluster(ctx context.Context, instanceName, xkubeName string) error {
+	kubeconfigPath := viper.GetString("kubeconfig")
+	dyn, err := utils.GetDynamicClient(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("build dynamic client: %w", err)
+	}
+	mgmt, err := utils.GetClientset(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("build clientset: %w", err)
+	}
+
+	inst, err := utils.GetWithSuggestions(ctx, resource.XInstance.Client(dyn), resource.XInstance.Name, instanceName)
+	if err != nil {
+		return err
+	}
+	r, err := sshRoute(ctx, dyn, inst)
+	if err != nil {
+		return err
+	}
+
+	kubeconfig, err := xk.GetConfig(ctx, xkubeName, utils.SystemNamespace)
+	if err != nil {
+		return err
+	}
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return fmt.Errorf("parsing kubeconfig of xkube %s: %w", xkubeName, err)
+	}
+	remote, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return fmt.Errorf("build clientset for xkube %s: %w", xkubeName, err)
+	}
+	caHash, err := caCertHash(restCfg.CAData)
+	if err != nil {
+		return err
+	}
+
+	token, err := createBootstrapToken(ctx, remote, joinTokenTTL)
+	if err != nil {
+		return fmt.Errorf("creating bootstrap token on xkube %s: %w", xkubeName, err)
+	}
+	debugf("created bootstrap token %s on xkube %s", strings.SplitN(token, ".", 2)[0], xkubeName)
+
+	keyFile, err := writeSkyClusterKey(ctx, mgmt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(keyFile)
+
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(restCfg.Host, "https://"), "http://")
+	var joinCmd string
+	switch joinMethod {
+	case "kubeadm":
+		joinCmd = fmt.Sprintf("sudo kubeadm join %s --token %s --discovery-token-ca-cert-hash %s", endpoint, token, caHash)
+	case "k3s":
+		joinCmd = fmt.Sprintf("curl -sfL https://get.k3s.io | K3S_URL=https://%s K3S_TOKEN=%s sh -s - agent", endpoint, token)
+	}
+
+	hostname, err := runSSH(keyFile, r, "hostname", "")
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", instanceName, err)
+	}
+	nodeName := strings.ToLower(strings.TrimSpace(hostname))
+
+	fmt.Printf("Joining %s (%s) to xkube %s using %s...\n", instanceName, r.target, xkubeName, joinMethod)
+	if out, err := runSSH(keyFile, r, joinCmd, token); err != nil {
+		fmt.Fprint(os.Stderr, redact(out, token))
+		return fmt.Errorf("join command failed on %s: %w", instanceName, err)
+	}
+
+	fmt.Printf("Waiting for node %s to become Ready...\n", nodeName)
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Second, joinTimeout, true, func(ctx context.Context) (bool, error) {
+		node, err := remote.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			debugf("node %s not visible yet: %v", nodeName, err)
+			return false, nil
+		}
+		for _, c := range node.Status.Conditions {
+			if c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("node %s did not become Ready on xkube %s: %w", nodeName, xkubeName, err)
+	}
+	fmt.Printf("Node %s joined xkube %s and is Ready\n", nodeName, xkubeName)
+	return nil
+}
+
+// route is how an xinstance is reached over SSH.
+type route struct {
+	target string
+	// gateway is the provider gateway to jump through; empty when the
+	// instance has a public IP.
+	gateway string
+	// bastions are the ssh.bastions jump hosts in front of gateway, or of
+	// target when there is no gateway.
+	bastions []string
+}
+
+// sshRoute returns the route to inst. Instances without a public IP are
+// reached through their provider gateway, and both through the bastion chain
+// configured for the provider, if any.
+func sshRoute(ctx context.Context, dyn dynamic.Interface, inst *unstructured.Unstructured) (route, error) {
+	platform, _, _ := unstructured.NestedString(inst.Object, "spec", "providerRef", "platform")
+	providerName, _, _ := unstructured.NestedString(inst.Object, "status", "providerName")
+	bastions, err := utils.BastionChain(platform, providerName)
+	if err != nil {
+		return route{}, err
+	}
+
+	pubIp, _, _ := unstructured.NestedString(inst.Object, "status", "network", "publicIp")
+	if pubIp != "" {
+		return route{target: pubIp, bastions: bastions}, nil
+	}
+	privIp, _, _ := unstructured.NestedString(inst.Object, "status", "network", "privateIp")
+	if privIp == "" {
+		return route{}, fmt.Errorf("xinstance %s has no IP address yet", inst.GetName())
+	}
+	if providerName == "" {
+		return route{}, fmt.Errorf("xinstance %s has no public IP and no status.providerName to reach it through", inst.GetName())
+	}
+	gvr := schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xproviders"}
+	provider, err := utils.GetWithSuggestions(ctx, dyn.Resource(gvr), "xprovider", providerName)
+	if err != nil {
+		return route{}, err
+	}
+	gw, _, _ := unstructured.NestedString(provider.Object, "status", "gateway", "publicIp")
+	if gw == "" && len(bastions) > 0 {
+		// Behind a bastion, the gateway is reachable on its private address.
+		gw, _, _ = unstructured.NestedString(provider.Object, "status", "gateway", "privateIp")
+	}
+	if gw == "" {
+		return route{}, fmt.Errorf("gateway of xprovider %s has no public IP", providerName)
+	}
+	return route{target: privIp, gateway: gw, bastions: bastions}, nil
+}
+
+// createBootstrapToken creates a kubeadm-style bootstrap token secret on the
+// target cluster and returns the "<id>.<secret>" token. k3s agents accept the
+// same token format.
+func createBootstrapToken(ctx context.Context, cs *kubernetes.Clientset, ttl time.Duration) (string, error) {
+	id, err := randomToken(6)
+	if err != nil {
+		return "", err
+	}
+	secret, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bootstrap-token-" + id,
+			Namespace: "kube-system",
+			Labels:    map[string]string{"skycluster.io/managed-by": "skycluster"},
+		},
+		Type: corev1.SecretType("bootstrap.kubernetes.io/token"),
+		StringData: map[string]string{
+			"description":                    "created by skycluster xinstance join-cluster",
+			"token-id":                       id,
+			"token-secret":                   secret,
+			"expiration":                     time.Now().Add(ttl).UTC().Format(time.RFC3339),
+			"usage-bootstrap-authentication": "true",
+			"usage-bootstrap-signing":        "true",
+			"auth-extra-groups":              "system:bootstrappers:kubeadm:default-node-token",
+		},
+	}
+	if _, err := cs.CoreV1().Secrets("kube-system").Create(ctx, s, metav1.CreateOptions{}); err != nil {
+		return "", err
+	}
+	return id + "." + secret, nil
+}
+
+// randomToken returns n characters from [a-z0-9], as required for bootstrap tokens.
+func randomToken(n int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b), nil
+}
+
+// caCertHash computes the kubeadm discovery hash (sha256 of the CA public key).
+func caCertHash(caData []byte) (string, error) {
+	block, _ := pem.Decode(caData)
+	if block == nil {
+		return "", errors.New("xkube kubeconfig has no certificate-authority-data")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("parsing xkube CA certificate: %w", err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// writeSkyClusterKey extracts the private key stored by `skycluster setup`
+// into a temporary file usable with ssh -i. The caller removes the file.
+func writeSkyClusterKey(ctx context.Context, cs *kubernetes.Clientset) (string, error) {
+	sec, err := cs.CoreV1().Secrets(utils.SystemNamespace).Get(ctx, "skycluster-keys", metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("getting skycluster keypair: %w", err)
+	}
+	var kp struct {
+		PrivateKey string `json:"privateKey"`
+	}
+	if err := json.Unmarshal(sec.Data["config"], &kp); err != nil {
+		return "", fmt.Errorf("parsing skycluster keypair: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(kp.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("decoding skycluster private key: %w", err)
+	}
+	f, err := os.CreateTemp("", "skycluster-key-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := f.Chmod(0o600); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if _, err := f.Write(key); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// runSSH runs command on the target of r with the system ssh client and
+// returns the combined output. The bastions authenticate with the user's own
+// ssh setup; the gateway and target with keyFile. secret, if any, is left
+// out of the debug output.
+func runSSH(keyFile string, r route, command, secret string) (string, error) {
+	opts := []string{
+		"-i", keyFile,
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=15",
+	}
+	args := append([]string{}, opts...)
+	switch {
+	case r.gateway != "":
+		proxy := append([]string{"ssh"}, opts...)
+		if len(r.bastions) > 0 {
+			proxy = append(proxy, "-J", strings.Join(r.bastions, ","))
+		}
+		proxy = append(proxy, "-W", "%h:%p", joinUser+"@"+r.gateway)
+		args = append(args, "-o", "ProxyCommand="+strings.Join(proxy, " "))
+	case len(r.bastions) > 0:
+		args = append(args, "-J", strings.Join(r.bastions, ","))
+	}
+	args = append(args, joinUser+"@"+r.target, command)
+	debugf("running ssh %s", redact(strings.Join(args, " "), secret))
+	out, err := exec.Command("ssh", args...).CombinedOutput()
+	return string(out), err
+}
+
+// redact replaces secret in s.
+func redact(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, secret, "<redacted>")
+}
@@ -0,0 +1,95 @@
+package xinstance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// mappingsSelector selects the provider-mappings ConfigMaps, which list the
+// flavors and images each provider offers.
+const mappingsSelector = "skycluster.io/managed-by=skycluster, skycluster.io/config-type=provider-mappings"
+
+func init() {
+	resource.XInstance.Check = checkOfferings
+}
+
+// checkOfferings returns an error if the flavor or the image of the XInstance
+// u is not offered by its provider, per the provider-mappings ConfigMaps of
+// its platform, region and zone. Without such ConfigMaps nothing is checked.
+func checkOfferings(ctx context.Context, u *unstructured.Unstructured) error {
+	platform, _, _ := unstructured.NestedString(u.Object, "spec", "providerRef", "platform")
+	region, _, _ := unstructured.NestedString(u.Object, "spec", "providerRef", "region")
+	zone, _, _ := unstructured.NestedString(u.Object, "spec", "providerRef", "zones", "primary")
+	flavor, _, _ := unstructured.NestedString(u.Object, "spec", "flavor")
+	image, _, _ := unstructured.NestedString(u.Object, "spec", "image")
+	if platform == "" || (flavor == "" && image == "") {
+		return nil
+	}
+
+	cs, err := utils.GetClientset(viper.GetString("kubeconfig"))
+	if err != nil {
+		return err
+	}
+	selector := mappingsSelector + ", skycluster.io/provider-name=" + platform
+	if region != "" {
+		selector += ", skycluster.io/provider-region=" + region
+	}
+	if zone != "" {
+		selector += ", skycluster.io/provider-zone=" + zone
+	}
+	cms, err := cs.CoreV1().ConfigMaps(utils.SystemNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("listing the provider mappings: %w", err)
+	}
+	if len(cms.Items) == 0 {
+		debugf("no provider mappings for %s %s %s, not checking the flavor and image of %s", platform, region, zone, u.GetName())
+		return nil
+	}
+
+	var flavors, images []string
+	for _, cm := range cms.Items {
+		for k := range cm.Data {
+			switch {
+			case strings.Contains(k, "flavor"):
+				flavors = append(flavors, k)
+			case strings.Contains(k, "image"):
+				images = append(images, k)
+			}
+		}
+	}
+	where := strings.Join(strings.Fields(strings.Join([]string{platform, region, zone}, " ")), " ")
+	if flavor != "" && len(flavors) > 0 && !offered(flavors, "flavor", flavor) {
+		return fmt.Errorf("flavor %q is not offered in %s (offered: %s; see: skycluster xinstance flavor list -p %s)", flavor, where, joinSorted(flavors), platform)
+	}
+	if image != "" && len(images) > 0 && !offered(images, "image", image) {
+		return fmt.Errorf("image %q is not offered in %s (offered: %s)", image, where, joinSorted(images))
+	}
+	return nil
+}
+
+// offered reports whether one of the ConfigMap keys offers name, either as
+// the key itself or after a leading kind such as "flavor-" or "image.".
+func offered(keys []string, kind, name string) bool {
+	for _, k := range keys {
+		if k == name || utils.MappingName(k, kind) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func joinSorted(keys []string) string {
+	keys = slices.Clone(keys)
+	sort.Strings(keys)
+	return strings.Join(slices.Compact(keys), ", ")
+}
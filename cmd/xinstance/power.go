@@ -0,0 +1,142 @@
+package xinstance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// Power states of an XInstance, as set in spec.powerState and reported in
+// status.powerState.
+const (
+	PowerRunning = "Running"
+	PowerStopped = "Stopped"
+)
+
+var (
+	powerWait    bool
+	powerTimeout time.Duration
+)
+
+func init() {
+	for _, c := range []*cobra.Command{
+		newPowerCmd("start", "Start a stopped XInstance", PowerRunning),
+		newPowerCmd("stop", "Stop an XInstance, keeping its disks and addresses", PowerStopped),
+		newPowerCmd("reboot", "Reboot a running XInstance", ""),
+	} {
+		c.Flags().BoolVar(&powerWait, "wait", true, "Wait until the XInstance reports the new state")
+		c.Flags().DurationVar(&powerTimeout, "timeout", 10*time.Minute, "How long to wait for the new state")
+		xInstanceCmd.AddCommand(c)
+	}
+}
+
+// newPowerCmd returns the command setting spec.powerState to state, or
+// requesting a reboot when state is empty.
+func newPowerCmd(use, short, state string) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <name>",
+		Short: short,
+		Long: short + `.
+
+Stop and start set spec.powerState of the XInstance to Stopped or Running;
+reboot sets spec.rebootRequest to the current time. The command then waits
+until status.powerState reports the change and, for a reboot, until
+status.rebootRequest echoes the request. With --wait=false it returns once the
+change is saved.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+			if err != nil {
+				return fmt.Errorf("build dynamic client: %w", err)
+			}
+			live, err := utils.GetWithSuggestions(ctx, resource.XInstance.Client(dyn), resource.XInstance.Name, args[0])
+			if err != nil {
+				return err
+			}
+			name := live.GetName()
+			want, _, _ := unstructured.NestedString(live.Object, "spec", "powerState")
+			current, _, _ := unstructured.NestedString(live.Object, "status", "powerState")
+
+			updated := live.DeepCopy()
+			var request string
+			if state == "" {
+				if want == PowerStopped {
+					return fmt.Errorf("XInstance %s is stopped; start it instead", name)
+				}
+				request = time.Now().UTC().Format(time.RFC3339)
+				err = unstructured.SetNestedField(updated.Object, request, "spec", "rebootRequest")
+			} else {
+				if want == state && current == state {
+					fmt.Printf("XInstance %s is already %s\n", name, state)
+					return nil
+				}
+				err = unstructured.SetNestedField(updated.Object, state, "spec", "powerState")
+			}
+			if err != nil {
+				return err
+			}
+			audit.StampUpdate(updated, live)
+			err = utils.Retry(ctx, func(ctx context.Context) error {
+				_, err := resource.XInstance.Client(dyn).Update(ctx, updated, metav1.UpdateOptions{})
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("updating XInstance %s: %w", name, err)
+			}
+			if !powerWait {
+				fmt.Printf("XInstance %s: %s requested\n", name, use)
+				return nil
+			}
+			cmd.SilenceUsage = true
+			if err := waitForPower(ctx, dyn, name, state, request); err != nil {
+				return err
+			}
+			fmt.Printf("XInstance %s: %s done\n", name, use)
+			return nil
+		},
+	}
+}
+
+// waitForPower polls the XInstance name until status.powerState is state or,
+// for a reboot (empty state), until status.rebootRequest is request and the
+// instance is running again.
+func waitForPower(ctx context.Context, dyn dynamic.Interface, name, state, request string) error {
+	want := state
+	if want == "" {
+		want = PowerRunning
+	}
+	fmt.Printf("Waiting for XInstance %s to be %s (timeout %s)...\n", name, want, powerTimeout)
+	var current string
+	err := wait.PollUntilContextTimeout(ctx, 5*time.Second, powerTimeout, true, func(ctx context.Context) (bool, error) {
+		obj, err := resource.XInstance.Client(dyn).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			debugf("get xinstance %s failed: %v", name, err)
+			return false, nil
+		}
+		current, _, _ = unstructured.NestedString(obj.Object, "status", "powerState")
+		if request != "" {
+			if seen, _, _ := unstructured.NestedString(obj.Object, "status", "rebootRequest"); seen != request {
+				debugf("xinstance %s: reboot %s not observed yet (status.rebootRequest=%q)", name, request, seen)
+				return false, nil
+			}
+		}
+		debugf("xinstance %s: powerState=%q", name, current)
+		return current == want, nil
+	})
+	if err != nil {
+		return fmt.Errorf("XInstance %s not %s after %s (powerState=%q): %w", name, want, powerTimeout, current, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package xinstance
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	instanceTypeGVR = schema.GroupVersionResource{Group: "core.skycluster.io", Version: "v1alpha1", Resource: "instancetypes"}
+	imageGVR        = schema.GroupVersionResource{Group: "core.skycluster.io", Version: "v1alpha1", Resource: "images"}
+)
+
+// createWizard asks for the provider, then for a flavor and an image among
+// those generated for its ProviderProfile in the chosen zone.
+func createWizard(ctx context.Context, dyn dynamic.Interface) (map[string]interface{}, string, error) {
+	ref, profile, err := resource.PickProviderRef(ctx, dyn)
+	if err != nil {
+		return nil, "", err
+	}
+	zone, _, _ := unstructured.NestedString(ref, "zones", "primary")
+
+	flavor, err := pickOffering(ctx, dyn, instanceTypeGVR, profile, zone, "Flavor", "zoneOfferings")
+	if err != nil {
+		return nil, "", err
+	}
+	image, err := pickOffering(ctx, dyn, imageGVR, profile, zone, "Image", "images")
+	if err != nil {
+		return nil, "", err
+	}
+	publicIp, err := utils.Confirm("Assign a public IP?", false)
+	if err != nil {
+		return nil, "", err
+	}
+	spot, err := utils.Confirm("Use a spot instance?", false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	spec := map[string]interface{}{
+		"providerRef":  ref,
+		"flavor":       flavor,
+		"image":        image,
+		"publicIp":     publicIp,
+		"spotInstance": spot,
+	}
+	return spec, fmt.Sprintf("%s-vm", ref["platform"]), nil
+}
+
+// pickOffering lets the user pick one of the names (nameLabel, else name) of
+// the entries under key of the objects of gvr generated for profile, keeping
+// those of zone. Without any, the name is asked for.
+func pickOffering(ctx context.Context, dyn dynamic.Interface, gvr schema.GroupVersionResource, profile *unstructured.Unstructured, zone, title, key string) (string, error) {
+	list, err := dyn.Resource(gvr).Namespace(profile.GetNamespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		debugf("listing %s: %v", gvr.Resource, err)
+	}
+	seen := map[string]bool{}
+	var names []string
+	if list != nil {
+		for _, obj := range list.Items {
+			if !strings.HasPrefix(obj.GetName(), profile.GetName()+"-") {
+				continue
+			}
+			for _, n := range offeringNames(obj.Object["spec"], key, zone) {
+				if !seen[n] {
+					seen[n] = true
+					names = append(names, n)
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		return utils.Ask(fmt.Sprintf("No %s offered by ProviderProfile %s in %s. %s", strings.ToLower(title), profile.GetName(), zone, title), "")
+	}
+	sort.Strings(names)
+	opts := make([]utils.PickOption, 0, len(names))
+	for _, n := range names {
+		opts = append(opts, utils.PickOption{Name: n, Ready: true})
+	}
+	return utils.PickOne(title, opts)
+}
+
+// offeringNames walks v and returns the names of the entries of the lists
+// under key, skipping the ones of a zone other than zone.
+func offeringNames(v interface{}, key, zone string) []string {
+	var names []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if z, ok := v["zone"].(string); ok && zone != "" && z != zone {
+			return nil
+		}
+		for k, child := range v {
+			items, ok := child.([]interface{})
+			if k != key || !ok {
+				names = append(names, offeringNames(child, key, zone)...)
+				continue
+			}
+			for _, item := range items {
+				im, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if z, ok := im["zone"].(string); ok && zone != "" && z != zone {
+					continue
+				}
+				for _, f := range []string{"nameLabel", "name"} {
+					if n, _ := im[f].(string); n != "" {
+						names = append(names, n)
+						break
+					}
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			names = append(names, offeringNames(item, key, zone)...)
+		}
+	}
+	return names
+}
@@ -2,17 +2,20 @@ package xinstance
 
 import (
 	"github.com/spf13/cobra"
-)
 
-// debug controls debug output. Tests or a caller can set this to true.
-var debug bool
+	"github.com/etesami/skycluster-cli/cmd/xinstance/flavor"
+	"github.com/etesami/skycluster-cli/cmd/xinstance/image"
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+)
 
 func init() {
-	// xInstanceCmd.AddCommand(flavor.GetFlavorCmd())
-	// xInstanceCmd.AddCommand(image.GetImageCmd())
-	xInstanceCmd.AddCommand(xInstanceListCmd)
-	xInstanceCmd.AddCommand(xInstanceCreateCmd)
-	xInstanceCmd.AddCommand(xInstanceDeleteCmd)
+	xInstanceCmd.AddCommand(flavor.GetFlavorCmd())
+	xInstanceCmd.AddCommand(image.GetImageCmd())
+	xInstanceCmd.AddCommand(resource.NewListCmd(resource.XInstance))
+	xInstanceCmd.AddCommand(resource.WithWizard(resource.NewCreateCmd(resource.XInstance, nil), resource.XInstance, createWizard))
+	xInstanceCmd.AddCommand(resource.NewDeleteCmd(resource.XInstance, nil))
+	xInstanceCmd.AddCommand(xInstanceJoinCmd)
 }
 
 var xInstanceCmd = &cobra.Command{
@@ -23,11 +26,12 @@ var xInstanceCmd = &cobra.Command{
 	},
 }
 
+// debugf logs a debug message of the xinstance commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("xinstance", format, args...)
+}
+
 func GetXInstanceCmd() *cobra.Command {
 	return xInstanceCmd
 }
 
-// SetDebug sets package-level debug flag after CLI flags are parsed.
-func SetDebug(d bool) {
-	debug = d
-}
@@ -0,0 +1,287 @@
+package xkube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+const (
+	staticSAPrefix      = skycluster.StaticSAPrefix
+	staticAccessNS      = skycluster.StaticNamespace
+	staticSecretLabel   = "skycluster.io/secret-type=static-kubeconfig"
+	staticClusterIDKey  = skycluster.LabelClusterID
+	saTokenOwnerAnnoKey = "kubernetes.io/service-account.name"
+)
+
+var (
+	pruneCluster string
+	pruneAll     bool
+)
+
+func init() {
+	accessPruneCmd.Flags().StringVar(&pruneCluster, "xkube", "", "Prune the access of this xkube only, registered or not")
+	accessPruneCmd.Flags().BoolVar(&pruneAll, "all", false, "Prune the access of every xkube, including registered ones")
+	accessPruneCmd.MarkFlagsMutuallyExclusive("xkube", "all")
+	accessCmd.AddCommand(accessPruneCmd)
+	xKubeCmd.AddCommand(accessCmd)
+}
+
+var accessCmd = &cobra.Command{
+	Use:   "access",
+	Short: "Manage the credentials the CLI keeps on xkubes",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var accessPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the ServiceAccounts and bindings 'xkube config' created on remote clusters",
+	Long: `Delete the skycluster-static-sa-<xkube> ServiceAccount and its cluster-admin
+binding that 'xkube config' creates on every remote cluster, together with the
+static kubeconfig cached on the management cluster. The cache of a cluster
+that cannot be reached is kept, so that pruning it can be retried.
+
+Without flags, only clusters that are no longer registered as xkubes are
+pruned; they are reached with the cached kubeconfig, so their cache must not
+have expired yet. --xkube prunes one xkube and --all prunes every xkube.
+The next 'xkube config' recreates the access of registered xkubes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		local, err := localClients()
+		if err != nil {
+			return err
+		}
+		secrets, err := local.clientSet.CoreV1().Secrets(staticAccessNS).List(ctx, metav1.ListOptions{LabelSelector: staticSecretLabel})
+		if err != nil {
+			return fmt.Errorf("listing static kubeconfigs: %w", err)
+		}
+		xkubes, err := resource.XKube.List(ctx, local.dynamicClient)
+		if err != nil {
+			return fmt.Errorf("listing xkubes: %w", err)
+		}
+		registered := map[string]*unstructured.Unstructured{}
+		for i := range xkubes {
+			registered[xkubes[i].GetName()] = &xkubes[i]
+		}
+
+		// Collect the targets: registered xkubes are reached with their admin
+		// kubeconfig, the others with the cached static one.
+		var targets []string
+		cached := map[string][]byte{}
+		for _, s := range secrets.Items {
+			id := s.Labels[staticClusterIDKey]
+			if id == "" {
+				continue
+			}
+			cached[id] = s.Data["kubeconfig"]
+			if _, ok := registered[id]; !ok || pruneAll {
+				targets = append(targets, id)
+			}
+		}
+		if pruneAll {
+			for name := range registered {
+				if !slices.Contains(targets, name) {
+					targets = append(targets, name)
+				}
+			}
+		}
+		if pruneCluster != "" {
+			if _, ok := registered[pruneCluster]; !ok && cached[pruneCluster] == nil {
+				return fmt.Errorf("xkube %s is neither registered nor has a cached kubeconfig", pruneCluster)
+			}
+			targets = []string{pruneCluster}
+		}
+		if len(targets) == 0 {
+			fmt.Println("Nothing to prune.")
+			return nil
+		}
+		slices.Sort(targets)
+
+		failed := 0
+		for _, id := range targets {
+			kubeconfig, viaCache := cached[id], true
+			if obj, ok := registered[id]; ok {
+				if kubeconfig, err = local.lib().AdminKubeconfig(ctx, obj); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %s: %v\n", id, err)
+					failed++
+					continue
+				}
+				viaCache = false
+			}
+			if err := pruneClusterAccess(ctx, *local, id, kubeconfig, viaCache); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %s: %v\n", id, err)
+				failed++
+				continue
+			}
+			fmt.Printf("Pruned access on %s\n", id)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d cluster(s) could not be pruned", failed, len(targets))
+		}
+		return nil
+	},
+}
+
+// PruneBeforeDelete removes the CLI's access from an xkube that is being
+// deregistered, while its credentials are still reachable. Failures only
+// warn so that an unreachable cluster does not block the deletion.
+func PruneBeforeDelete(ctx context.Context, dyn dynamic.Interface, obj *unstructured.Unstructured) error {
+	if err := PruneAccess(ctx, obj); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	return nil
+}
+
+// PruneAccess is PruneBeforeDelete for callers that report the failure
+// themselves; the error includes the command to retry with.
+func PruneAccess(ctx context.Context, obj *unstructured.Unstructured) error {
+	local, err := localClients()
+	if err == nil {
+		var kubeconfig []byte
+		if kubeconfig, err = local.lib().AdminKubeconfig(ctx, obj); err == nil {
+			err = pruneClusterAccess(ctx, *local, obj.GetName(), kubeconfig, false)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not prune access on xkube %s: %w; retry with: skycluster xkube access prune --xkube %s", obj.GetName(), err, obj.GetName())
+	}
+	return nil
+}
+
+// PruneUnregistered prunes the access of every cluster that has a cached
+// static kubeconfig but is no longer registered as an xkube. It is used by
+// the uninstall cleanup: failing clusters are reported and skipped, and
+// counted in the returned error.
+func PruneUnregistered(ctx context.Context) error {
+	local, err := localClients()
+	if err != nil {
+		return err
+	}
+	secrets, err := local.clientSet.CoreV1().Secrets(staticAccessNS).List(ctx, metav1.ListOptions{LabelSelector: staticSecretLabel})
+	if err != nil {
+		return fmt.Errorf("listing static kubeconfigs: %w", err)
+	}
+	names, err := resource.XKube.Names(ctx, local.dynamicClient)
+	if err != nil {
+		return fmt.Errorf("listing xkubes: %w", err)
+	}
+	tried, failed := 0, 0
+	for _, s := range secrets.Items {
+		id := s.Labels[staticClusterIDKey]
+		if id == "" || slices.Contains(names, id) {
+			continue
+		}
+		tried++
+		if err := pruneClusterAccess(ctx, *local, id, s.Data["kubeconfig"], true); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not prune access on %s: %v\n", id, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cluster(s) could not be pruned", failed, tried)
+	}
+	return nil
+}
+
+// pruneClusterAccess deletes the static ServiceAccount of clusterID, its
+// binding and any legacy token secrets from the remote cluster, then the
+// cached static kubeconfig from the management cluster. When the remote
+// cluster cannot be reached the cache is kept, so that a later prune can
+// still reach it, and the error is returned. viaCache tells that kubeconfig
+// is the cached one, i.e. authenticates as the ServiceAccount being pruned.
+func pruneClusterAccess(ctx context.Context, local clientSets, clusterID string, kubeconfig []byte, viaCache bool) error {
+	if len(kubeconfig) == 0 && !viaCache {
+		return errors.New("no kubeconfig to reach the cluster")
+	}
+	// An empty cache cannot reach the cluster either; it is only dropped.
+	if len(kubeconfig) > 0 {
+		if err := deleteStaticAccess(ctx, withTLSOverrides(ctx, local, clusterID, kubeconfig), clusterID, viaCache); err != nil {
+			return fmt.Errorf("%w; the cached static kubeconfig is kept to retry", err)
+		}
+	}
+
+	secretName := clusterID + "-static-kubeconfig"
+	err := local.clientSet.CoreV1().Secrets(staticAccessNS).Delete(ctx, secretName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting secret %s/%s: %w", staticAccessNS, secretName, err)
+	}
+	debugf("deleted cached static kubeconfig %s/%s", staticAccessNS, secretName)
+	forgetAccess(clusterID, "cluster-admin")
+	return nil
+}
+
+func deleteStaticAccess(ctx context.Context, kubeconfig []byte, clusterID string, viaCache bool) error {
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("building rest config: %w", err)
+	}
+	cs, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return fmt.Errorf("creating kubernetes client: %w", err)
+	}
+	saName := staticSAPrefix + clusterID
+	crbName := saName + "-crb"
+
+	// Tokens minted through TokenRequest die with the ServiceAccount; only
+	// legacy token secrets need to go explicitly.
+	secrets, err := cs.CoreV1().Secrets(staticAccessNS).List(ctx, metav1.ListOptions{FieldSelector: "type=" + string(corev1.SecretTypeServiceAccountToken)})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("listing token secrets: %w", err)
+	}
+	if secrets != nil {
+		for _, s := range secrets.Items {
+			if s.Annotations[saTokenOwnerAnnoKey] != saName {
+				continue
+			}
+			if err := cs.CoreV1().Secrets(staticAccessNS).Delete(ctx, s.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("deleting token secret %s: %w", s.Name, err)
+			}
+		}
+	}
+	if err := cs.RbacV1().ClusterRoleBindings().Delete(ctx, crbName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting clusterrolebinding %s: %w", crbName, err)
+	}
+	// Acting as the ServiceAccount itself, its rights are gone with the
+	// binding; it is then left behind without any permissions.
+	err = cs.CoreV1().ServiceAccounts(staticAccessNS).Delete(ctx, saName, metav1.DeleteOptions{})
+	if viaCache && (apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err)) {
+		debugf("cannot delete %s with its own token after unbinding it: %v", saName, err)
+		err = nil
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("deleting serviceaccount %s/%s: %w", staticAccessNS, saName, err)
+	}
+	debugf("deleted %s and %s on %s", saName, crbName, clusterID)
+	return nil
+}
+
+func localClients() (*clientSets, error) {
+	kubeconfigPath := viper.GetString("kubeconfig")
+	dyn, err := utils.GetDynamicClient(kubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("build dynamic client: %w", err)
+	}
+	cs, err := utils.GetClientset(kubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("build clientset: %w", err)
+	}
+	return &clientSets{dynamicClient: dyn, clientSet: cs}, nil
+}
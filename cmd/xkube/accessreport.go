@@ -0,0 +1,276 @@
+package xkube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/state"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+const (
+	issuedAtAnnoKey = skycluster.AnnotationIssuedAt
+	lastUsedAnnoKey = skycluster.AnnotationLastUsed
+	scopeAnnoKey    = skycluster.AnnotationScope
+)
+
+var reportUnusedFor time.Duration
+
+func init() {
+	accessReportCmd.Flags().DurationVar(&reportUnusedFor, "unused-for", 0, "Only show credentials not used for at least this long, e.g. 720h")
+	accessCmd.AddCommand(accessReportCmd)
+}
+
+var accessReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "List the credentials the CLI issued on xkubes by age, last use and scope",
+	Long: `List the static kubeconfigs 'xkube config' caches on the management cluster,
+oldest first, with their scope, age, expiry and when the CLI last used them.
+
+The CLI also records in its local state every token it mints, including the
+viewer tokens of 'xkube config share' that are not stored anywhere else, and
+when this machine last used them; the report then includes them as well. The
+file of the access.stateFile config key of earlier releases is read in once.
+Stale credentials are revoked with
+'xkube access prune --xkube <xkube>'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		local, err := localClients()
+		if err != nil {
+			return err
+		}
+		secrets, err := local.clientSet.CoreV1().Secrets(staticAccessNS).List(ctx, metav1.ListOptions{LabelSelector: staticSecretLabel})
+		if err != nil {
+			return fmt.Errorf("listing static kubeconfigs: %w", err)
+		}
+		names, err := resource.XKube.Names(ctx, local.dynamicClient)
+		if err != nil {
+			return fmt.Errorf("listing xkubes: %w", err)
+		}
+		recorded, err := loadAccessState()
+		if err != nil {
+			return err
+		}
+
+		var rows []accessRecord
+		for _, s := range secrets.Items {
+			id := s.Labels[staticClusterIDKey]
+			if id == "" {
+				continue
+			}
+			r := accessRecord{Cluster: id, Scope: s.Annotations[scopeAnnoKey], Source: "management"}
+			if r.Scope == "" {
+				r.Scope = "cluster-admin"
+			}
+			r.Issued = annotationTime(s.Annotations[issuedAtAnnoKey])
+			if r.Issued.IsZero() {
+				r.Issued = s.CreationTimestamp.Time
+			}
+			r.Expiry = annotationTime(s.Annotations[expiryAnnoKey])
+			r.LastUsed = annotationTime(s.Annotations[lastUsedAnnoKey])
+			if l, ok := recorded.find(r.Cluster, r.Scope); ok && l.LastUsed.After(r.LastUsed) {
+				r.LastUsed = l.LastUsed
+			}
+			rows = append(rows, r)
+		}
+		for _, l := range recorded.Records {
+			if !slices.ContainsFunc(rows, func(r accessRecord) bool { return r.Cluster == l.Cluster && r.Scope == l.Scope }) {
+				l.Source = "local"
+				rows = append(rows, l)
+			}
+		}
+		if reportUnusedFor > 0 {
+			rows = slices.DeleteFunc(rows, func(r accessRecord) bool {
+				return !r.LastUsed.IsZero() && time.Since(r.LastUsed) < reportUnusedFor
+			})
+		}
+		if len(rows) == 0 {
+			fmt.Println("No credentials found.")
+			return nil
+		}
+		slices.SortFunc(rows, func(a, b accessRecord) int { return a.Issued.Compare(b.Issued) })
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "CLUSTER\tSCOPE\tSOURCE\tAGE\tEXPIRES\tLAST USED\tXKUBE")
+		for _, r := range rows {
+			xkube := "registered"
+			if !slices.Contains(names, r.Cluster) {
+				xkube = "gone"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Cluster, r.Scope, r.Source, since(r.Issued), expires(r.Expiry), lastUsed(r.LastUsed), xkube)
+		}
+		return w.Flush()
+	},
+}
+
+// recordStaticUse records the use of the static kubeconfig of clusterID in
+// the local state.
+func recordStaticUse(clusterID, scope string) {
+	updateAccessState(func(s *accessState) {
+		r := s.upsert(clusterID, scope)
+		r.LastUsed = time.Now().UTC()
+	})
+}
+
+// recordIssued stores a newly minted token in the local state.
+func recordIssued(clusterID, scope string, expiry time.Time) {
+	updateAccessState(func(s *accessState) {
+		r := s.upsert(clusterID, scope)
+		r.Issued = time.Now().UTC()
+		r.Expiry = expiry.UTC()
+	})
+}
+
+// forgetAccess drops the token of clusterID with scope from the local state.
+func forgetAccess(clusterID, scope string) {
+	updateAccessState(func(s *accessState) {
+		s.Records = slices.DeleteFunc(s.Records, func(r accessRecord) bool { return r.Cluster == clusterID && r.Scope == scope })
+	})
+}
+
+// accessRecord is one credential issued by the CLI.
+type accessRecord struct {
+	Cluster  string    `json:"cluster"`
+	Scope    string    `json:"scope"`
+	Issued   time.Time `json:"issued"`
+	Expiry   time.Time `json:"expiry"`
+	LastUsed time.Time `json:"lastUsed,omitempty"`
+	Source   string    `json:"-"`
+}
+
+// accessState is the local state keeping the latest token per cluster and
+// scope.
+type accessState struct {
+	Records []accessRecord `json:"records"`
+	// Migrated is set once the file of the access.stateFile config key of
+	// earlier releases has been read in.
+	Migrated bool `json:"migrated,omitempty"`
+}
+
+// accessStateName is the local state recording the tokens the CLI minted.
+const (
+	accessStateName    = "access"
+	accessStateVersion = 1
+)
+
+func (s *accessState) find(cluster, scope string) (accessRecord, bool) {
+	i := slices.IndexFunc(s.Records, func(r accessRecord) bool { return r.Cluster == cluster && r.Scope == scope })
+	if i < 0 {
+		return accessRecord{}, false
+	}
+	return s.Records[i], true
+}
+
+func (s *accessState) upsert(cluster, scope string) *accessRecord {
+	i := slices.IndexFunc(s.Records, func(r accessRecord) bool { return r.Cluster == cluster && r.Scope == scope })
+	if i < 0 {
+		s.Records = append(s.Records, accessRecord{Cluster: cluster, Scope: scope})
+		i = len(s.Records) - 1
+	}
+	return &s.Records[i]
+}
+
+// migrate merges in the records of the access.stateFile of earlier
+// releases, once. The records already in s win.
+func (s *accessState) migrate() error {
+	if s.Migrated {
+		return nil
+	}
+	s.Migrated = true
+	path := resource.ExpandPath(strings.TrimSpace(viper.GetString("access.stateFile")))
+	if path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading access state: %w", err)
+	}
+	var legacy accessState
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return fmt.Errorf("parsing access state %s: %w", path, err)
+	}
+	debugf("migrating %d access record(s) from %s", len(legacy.Records), path)
+	for _, r := range legacy.Records {
+		if _, ok := s.find(r.Cluster, r.Scope); !ok {
+			s.Records = append(s.Records, r)
+		}
+	}
+	return nil
+}
+
+// loadAccessState reads the local state.
+func loadAccessState() (*accessState, error) {
+	s := &accessState{}
+	if err := state.Load(accessStateName, accessStateVersion, s); err != nil {
+		return nil, err
+	}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// updateAccessState applies change to the local state. Failures are only
+// logged, so that recording the access never fails the command.
+func updateAccessState(change func(*accessState)) {
+	s := &accessState{}
+	err := state.Update(accessStateName, accessStateVersion, s, func() error {
+		if err := s.migrate(); err != nil {
+			return err
+		}
+		change(s)
+		return nil
+	})
+	if err != nil {
+		debugf("recording access: %v", err)
+	}
+}
+
+func annotationTime(v string) time.Time {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func since(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return duration.HumanDuration(time.Since(t))
+}
+
+func expires(t time.Time) string {
+	switch {
+	case t.IsZero():
+		return "-"
+	case time.Now().After(t):
+		return "expired"
+	default:
+		return "in " + duration.HumanDuration(time.Until(t))
+	}
+}
+
+func lastUsed(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return since(t) + " ago"
+}
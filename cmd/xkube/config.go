@@ -2,33 +2,34 @@ package xkube
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	authenticationv1 "k8s.io/api/authentication/v1"
-	corev1 "k8s.io/api/core/v1"
-	rbacv1 "k8s.io/api/rbac/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
-	"k8s.io/utils/ptr"
 
+	"github.com/etesami/skycluster-cli/internal/resource"
 	utils "github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
 )
 
 var kubeNames []string
 var outPath string
+var allXKubes bool
+var skipUnready bool
+var strictFetch bool
+var mergeInto string
+var contextPrefix string
 
 type clientSets struct {
 	dynamicClient dynamic.Interface
@@ -37,426 +38,275 @@ type clientSets struct {
 
 func init() {
 	configShowCmd.PersistentFlags().StringSliceVarP(&kubeNames, "xkube", "k", nil, "Kube Names, separated by comma")
-	configShowCmd.PersistentFlags().StringVarP(&outPath, "out", "o", "", "Output file path (required)")
-	if err := configShowCmd.MarkPersistentFlagRequired("out"); err != nil {
-		log.Fatalf("failed to mark 'out' flag required: %v", err)
-	}
+	configShowCmd.PersistentFlags().StringVarP(&outPath, "out", "o", "", "Output file path (required unless --merge-into is set)")
+	configShowCmd.PersistentFlags().BoolVar(&allXKubes, "all", false, "Fetch kubeconfigs of all xkubes without prompting")
+	configShowCmd.PersistentFlags().BoolVar(&skipUnready, "skip-unready", false, "Skip xkubes that are not Ready instead of trying to fetch their kubeconfig")
+	configShowCmd.PersistentFlags().BoolVar(&strictFetch, "strict", false, "Exit non-zero if any xkube failed or was skipped, or none produced a kubeconfig (good kubeconfigs are still written)")
+	configShowCmd.Flags().StringVar(&mergeInto, "merge-into", "", "Merge the contexts into this kubeconfig, "+clientcmd.RecommendedHomeFile+" when given without a value (--merge-into=<path>)")
+	configShowCmd.Flags().Lookup("merge-into").NoOptDefVal = clientcmd.RecommendedHomeFile
+	configShowCmd.Flags().StringVar(&contextPrefix, "context-prefix", "", "Prefix the context, cluster and user names with this string, e.g. \"sky-\"")
 }
 
 var configShowCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Show current kubeconfig of the xkube (writes to file)",
 	Run: func(cmd *cobra.Command, args []string) {
-		ns := "skycluster-system"
-		utils.RunWithSpinner("Fetching kubeconfigs", func() error {
-			showConfigs(kubeNames, ns, outPath)
-			return nil 
+		ns := utils.SystemNamespace
+		if len(kubeNames) == 0 && !allXKubes && utils.IsInteractive() {
+			picked, err := pickXKubes(cmd.Context(), ns)
+			if err != nil {
+				log.Fatalf("Error selecting xkubes: %v", err)
+			}
+			if len(picked) == 0 {
+				fmt.Println("No xkube selected.")
+				return
+			}
+			kubeNames = picked
+		}
+		if outPath == "" && mergeInto == "" {
+			log.Fatalf("One of --out or --merge-into is required")
+		}
+		// fail before contacting any cluster if the output cannot be written
+		for _, p := range []string{outPath, mergeInto} {
+			if p == "" {
+				continue
+			}
+			if err := utils.MkdirAndPreflight(p); err != nil {
+				log.Fatalf("Cannot write kubeconfig to %s: %v", p, err)
+			}
+		}
+		var results []fetchResult
+		err := utils.RunWithSpinner("Fetching kubeconfigs", func() error {
+			var err error
+			results, err = showConfigs(cmd.Context(), kubeNames, ns, outPath)
+			return err
 		})
+		printFetchSummary(results)
+		if errors.Is(err, errNoKubeconfigs) && !strictFetch {
+			// the summary tells why; only --strict makes it a failure
+			return
+		}
+		if err != nil {
+			// the spinner already reported err
+			os.Exit(1)
+		}
+		if outPath != "" {
+			fmt.Fprintf(os.Stderr, "Wrote kubeconfig to %s\n", outPath)
+		}
+		if strictFetch {
+			for _, r := range results {
+				if r.err != nil || r.skipped {
+					os.Exit(1)
+				}
+			}
+		}
 	},
 }
 
-func showConfigs(kubeNames []string, ns string, outPath string) {
+// errNoKubeconfigs is returned by showConfigs when no xkube produced a
+// kubeconfig, so nothing was written.
+var errNoKubeconfigs = errors.New("no kubeconfigs produced; nothing to write")
+
+// fetchResult is the per-xkube outcome reported in the final summary.
+type fetchResult struct {
+	name    string
+	skipped bool
+	err     error
+}
+
+// showConfigs fetches the kubeconfig of every xkube, merges the ones that
+// succeeded and writes them to outPath. A failing xkube does not stop the
+// others; an error is only returned when nothing could be written, which
+// is errNoKubeconfigs when no xkube produced a kubeconfig.
+func showConfigs(ctx context.Context, kubeNames []string, ns string, outPath string) ([]fetchResult, error) {
 	kubeconfigPath := viper.GetString("kubeconfig")
 	dynamicClient, err1 := utils.GetDynamicClient(kubeconfigPath)
 	clientSet, err2 := utils.GetClientset(kubeconfigPath)
 	if err1 != nil || err2 != nil {
-		log.Fatalf("Error getting dynamic client: %v", err1)
-		return
+		return nil, fmt.Errorf("error getting clients: %v", errors.Join(err1, err2))
 	}
 	localClients := clientSets{
 		dynamicClient: dynamicClient,
 		clientSet:     clientSet,
 	}
 
-	if len(kubeNames) == 0 {kubeNames = ListXKubesNames(ns)}
+	if len(kubeNames) == 0 {kubeNames = ListXKubesNames(ctx, ns)}
+
+	var notReady map[string]bool
+	if skipUnready {
+		notReady = unreadyXKubes(ctx, dynamicClient, ns)
+	}
 
 	var kubeconfigs []string
+	var results []fetchResult
 	for _, c := range kubeNames {
-
-		staticKubeconfig, err := fetchKubeconfig(c, localClients)
+		if ctx.Err() != nil {
+			// interrupted: report what was fetched, write nothing
+			return results, ctx.Err()
+		}
+		if notReady[c] {
+			results = append(results, fetchResult{name: c, skipped: true})
+			continue
+		}
+		staticKubeconfig, err := fetchKubeconfig(ctx, c, localClients)
 		if err != nil {
-			log.Printf("Error generating kubeconfig for [%s]: %v", c, err)
+			results = append(results, fetchResult{name: c, err: err})
 			continue
 		}
 		kubeconfigs = append(kubeconfigs, staticKubeconfig)
+		results = append(results, fetchResult{name: c})
 	}
 
 	if len(kubeconfigs) == 0 {
-		log.Fatalf("no kubeconfigs produced; nothing to write")
+		return results, errNoKubeconfigs
 	}
 
 	// Prepare output bytes
-	var outBytes []byte
 	mergedBytes, err := mergeKubeconfigs(kubeconfigs)
 	if err != nil {
-		log.Fatalf("Error merging kubeconfigs: %v", err)
+		return results, fmt.Errorf("error merging kubeconfigs: %v", err)
+	}
+	if contextPrefix != "" {
+		if mergedBytes, err = prefixKubeconfig(mergedBytes, contextPrefix); err != nil {
+			return results, err
+		}
 	}
-	outBytes = mergedBytes
 
 	if outPath != "" {
 		// Write to the required output path (do not print to screen)
-		if err := os.WriteFile(outPath, outBytes, 0o600); err != nil {
-			log.Fatalf("Error writing kubeconfig to file %s: %v", outPath, err)
+		if err := utils.WriteFileAtomic(outPath, mergedBytes, 0o600); err != nil {
+			return results, fmt.Errorf("error writing kubeconfig to file %s: %v", outPath, err)
 		}
 	}
-
-	// Optionally, you can print a small success message to stderr (not stdout), or omit entirely.
-	fmt.Fprintf(os.Stderr, "Wrote kubeconfig to %s\n", outPath)
-}
-
-func GetConfig(kubeName string, ns string) (string, error) {
-	kubeconfigPath := viper.GetString("kubeconfig")
-	dynamicClient, err1 := utils.GetDynamicClient(kubeconfigPath)
-	clientSet, err2 := utils.GetClientset(kubeconfigPath)
-	if err1 != nil || err2 != nil {
-		return "", err1
-	}
-
-	localClients := clientSets{
-		dynamicClient: dynamicClient,
-		clientSet:     clientSet,
-	}
-
-	staticKubeconfig, err := fetchKubeconfig(kubeName, localClients)
-	if err != nil {
-		return "", fmt.Errorf("error generating kubeconfig for [%s]: %v", kubeName, err)
+	if mergeInto != "" {
+		if err := mergeIntoKubeconfig(mergeInto, mergedBytes); err != nil {
+			return results, fmt.Errorf("error merging kubeconfig into %s: %v", mergeInto, err)
+		}
 	}
-	
-	return staticKubeconfig, nil
+	return results, nil
 }
 
-func fetchKubeconfig(xkubeName string, clientSets clientSets) (string, error) {
-	dynamicClient := clientSets.dynamicClient
+// unreadyXKubes returns the names of xkubes whose Ready condition is not True.
+func unreadyXKubes(ctx context.Context, dynamicClient dynamic.Interface, ns string) map[string]bool {
 	gvr := schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xkubes"}
-	ri := dynamicClient.Resource(gvr)
-
-	obj, err := ri.Get(context.Background(), xkubeName, metav1.GetOptions{})
+	disc, err := utils.GetDiscoveryClient(viper.GetString("kubeconfig"))
 	if err != nil {
-		log.Printf("Error fetching config [%s]: %v", xkubeName, err)
-		return "", err
+		log.Printf("Error creating discovery client, not skipping any: %v", err)
+		return nil
 	}
-	
-	clusterName, _, _ := unstructured.NestedString(obj.Object, "status", "externalClusterName")
-	if clusterName == "" {return "", fmt.Errorf("externalClusterName not present for GCP platform")}
-
-	// Check for existing static kubeconfig secret and its validity
-	ns := ""
-	existingSecret, err := fetchStaticKubeconfigSecret(clusterName, ns, clientSets.clientSet)
-	if err == nil && len(existingSecret) > 0 {
-		// found existing valid static kubeconfig secret
-		return string(existingSecret), nil
-	}
-
-	// Determine platform from spec.providerRef.platform
-	platform, _, _ := unstructured.NestedString(obj.Object, "spec", "providerRef", "platform")
-
-	// If platform is gcp, use gcloud to obtain credentials (temporary kubeconfig)
-	if platform == "gcp" {
-		// Extract location from spec.providerRef.zones.primary
-		provCfgZones, foundZones, err := unstructured.NestedStringMap(obj.Object, "spec", "providerRef", "zones")
-		if err != nil {return "", err}
-		if !foundZones {return "", fmt.Errorf("providerRef.zones not found")}
-		
-		location := provCfgZones["primary"]
-		if location == "" {return "", fmt.Errorf("primary zone not set in providerRef.zones")}
-
-		// Create a temporary kubeconfig file for gcloud to write into
-		tmpFile, err := os.CreateTemp("", "gke-kubeconfig-*")
-		if err != nil {
-			return "", fmt.Errorf("failed to create temporary kubeconfig file for [%s]: %v", xkubeName, err)
-		}
-		tmpName := tmpFile.Name()
-		tmpFile.Close()
-
-		// Run gcloud with KUBECONFIG env pointing to tmpName
-		gcCmd := exec.Command("gcloud", "container", "clusters", "get-credentials", clusterName, "--location", location)
-		gcCmd.Env = append(os.Environ(), "KUBECONFIG="+tmpName)
-		out, err := gcCmd.CombinedOutput()
-		if err != nil {
-			// Per your request, on gcloud errors we print and terminate.
-			log.Fatalf("gcloud failed to get credentials for cluster %s (location=%s): %v\nOutput: %s", clusterName, location, err, string(out))
-		}
-
-		kubeconfigBytes, err := os.ReadFile(tmpName)
-		// Attempt to remove temp file immediately after reading (ignore removal error)
-		_ = os.Remove(tmpName)
-		if err != nil {
-			log.Fatalf("failed to read kubeconfig written by gcloud for [%s]: %v", xkubeName, err)
-		}
-
-		// Store/retrieve static kubeconfig in secret (and respect expiry)
-		staticKubeconfig, err := ensureStaticKubeconfig(kubeconfigBytes, xkubeName, "skycluster-system", clientSets)
-		if err != nil {return "", err}
-
-		return staticKubeconfig, nil
+	ri, err := utils.ResourceFor(dynamicClient, disc, gvr, ns)
+	if err != nil {
+		log.Printf("Error listing xkubes for readiness, not skipping any: %v", err)
+		return nil
 	}
-
-	// Non-GCP path: look for secret reference in status.clusterSecretName
-	secretName, found, err := unstructured.NestedString(obj.Object, "status", "clusterSecretName")
-	if err != nil {return "", err}
-	if !found {return "", fmt.Errorf("secret name not found for config [%s]", xkubeName)}
-
-	// Secrets for xkube objects with kubeconfig are stored in skycluster-system
-	skyclusterNamespace := "skycluster-system"
-	// Fetch referenced secret
-	gvr = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
-	secret, err := dynamicClient.Resource(gvr).Namespace(skyclusterNamespace).
-		Get(context.Background(), secretName, metav1.GetOptions{})
+	list, err := ri.List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return "", fmt.Errorf("error fetching secret %s for config [%s]: %v", secretName, xkubeName, err)
+		log.Printf("Error listing xkubes for readiness, not skipping any: %v", err)
+		return nil
 	}
-	// Process the secret as needed
-	kubeconfig_b64, found, err := unstructured.NestedString(secret.Object, "data", "kubeconfig")
-	if err != nil {return "", fmt.Errorf("error fetching secret data for config [%s]: %v", xkubeName, err)}
-	if !found {return "", fmt.Errorf("secret data not found for config [%s]", xkubeName)}
-
-	kubeconfigBytes, err := base64.StdEncoding.DecodeString(kubeconfig_b64)
-	if err != nil {return "", fmt.Errorf("error decoding kubeconfig for config [%s]: %v", xkubeName, err)}
-
-	// Create or reuse static credentials: store the static kubeconfig in a secret (with expiry)
-	staticKubeconfig, err := ensureStaticKubeconfig(kubeconfigBytes, xkubeName, skyclusterNamespace, clientSets)
-	if err != nil {return "", fmt.Errorf("error creating static kubeconfig for [%s]: %v", xkubeName, err)}
-
-	return staticKubeconfig, nil
-}
-
-// ensureStaticKubeconfig ensures a ServiceAccount and ClusterRoleBinding exist 
-// in the target cluster, creates (or reuses) a service-account-token via 
-// TokenRequest API and returns a kubeconfig that uses that static token.
-// The resulting kubeconfig is persisted into a secret in the targetNamespace 
-// named "<clusterID>-static-kubeconfig".
-// The secret includes an expiry annotation that corresponds to the token expiration. 
-// If the secret already exists and the stored expiry is still in the future, 
-// the stored kubeconfig is returned instead of generating a new token.
-func ensureStaticKubeconfig(kubeconfigBytes []byte, clusterID string, targetNamespace string, localClientSets clientSets) (string, error) {
-	// use for secret creation/checks
-	localClientSet := localClientSets.clientSet
-
-	// Build client from given kubeconfig bytes
-	restCfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfigBytes)
-	if err != nil {return "", fmt.Errorf("building rest config from kubeconfig: %w", err)}
-
-	clientset, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {return "", fmt.Errorf("creating kubernetes client: %w", err)}
-
-	// Parse kubeconfig to discover server and CA data and current context
-	parsedCfg, err := clientcmd.Load(kubeconfigBytes)
-	if err != nil {return "", fmt.Errorf("parsing kubeconfig: %w", err)}
-
-	// Pick current context if available, otherwise first context
-	var ctxName string
-	if parsedCfg.CurrentContext != "" {
-		ctxName = parsedCfg.CurrentContext
-	} else {
-		for k := range parsedCfg.Contexts {
-			ctxName = k
-			break
+	out := map[string]bool{}
+	for i := range list.Items {
+		if utils.GetConditionStatus(&list.Items[i], "Ready") != "True" {
+			out[list.Items[i].GetName()] = true
 		}
 	}
-	if ctxName == "" {return "", fmt.Errorf("no context found in kubeconfig")}
-	
-	ctx := parsedCfg.Contexts[ctxName]
-	clusterRef := ctx.Cluster
-	clusterObj, ok := parsedCfg.Clusters[clusterRef]
-	if !ok {return "", fmt.Errorf("cluster %q not found in kubeconfig", clusterRef)}
+	return out
+}
 
-	// ensure target namespace
-	_, err = clientset.CoreV1().Namespaces().Get(context.Background(), targetNamespace, metav1.GetOptions{})
-	if err != nil {
-		_, err = clientset.CoreV1().Namespaces().Create(context.Background(), &corev1.Namespace{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: targetNamespace,
-			},
-		}, metav1.CreateOptions{})
-		if err != nil {
-			return "", fmt.Errorf("creating namespace %s: %w", targetNamespace, err)
+// printFetchSummary prints one line per xkube to stderr so stdout stays clean.
+func printFetchSummary(results []fetchResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr)
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "XKUBE\tRESULT\tDETAIL")
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			fmt.Fprintf(writer, "%s\tskipped\tnot ready\n", r.name)
+		case r.err != nil:
+			fmt.Fprintf(writer, "%s\tfailed\t%v\n", r.name, r.err)
+		default:
+			fmt.Fprintf(writer, "%s\tok\t\n", r.name)
 		}
-	}	
+	}
+	writer.Flush()
+}
 
-	// Create ServiceAccount if not exists (remote cluster)
-	// Names for SA, CRB
-	saName := "skycluster-static-sa-" + clusterID
-	crbName := saName + "-crb"
-	_, err = clientset.CoreV1().ServiceAccounts(targetNamespace).Get(context.Background(), saName, metav1.GetOptions{})
+// pickXKubes lets the user choose xkubes from a list annotated with their readiness.
+func pickXKubes(ctx context.Context, ns string) ([]string, error) {
+	opts, err := xkubeOptions(ctx, ns)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			_, err = clientset.CoreV1().ServiceAccounts(targetNamespace).Create(context.Background(), &corev1.ServiceAccount{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      saName,
-					Namespace: targetNamespace,
-					Labels: map[string]string{
-						"skycluster.io/managed-by": "skycluster",
-					},
-				},
-			}, metav1.CreateOptions{})
-			if err != nil {
-				return "", fmt.Errorf("creating serviceaccount %s/%s: %w", targetNamespace, saName, err)
-			}
-		} else {
-			return "", fmt.Errorf("error checking serviceaccount %s/%s: %w", targetNamespace, saName, err)
-		}
+		return nil, err
 	}
+	return utils.PickMany("Select xkubes", opts)
+}
 
-	// Ensure ClusterRoleBinding exists granting cluster-admin to that SA (adjust role as needed)
-	// (remote cluster)
-	_, err = clientset.RbacV1().ClusterRoleBindings().Get(context.Background(), crbName, metav1.GetOptions{})
+// pickXKube lets the user choose one xkube, like pickXKubes.
+func pickXKube(ctx context.Context, ns string) (string, error) {
+	opts, err := xkubeOptions(ctx, ns)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			crb := &rbacv1.ClusterRoleBinding{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: crbName,
-				},
-				Subjects: []rbacv1.Subject{
-					{
-						Kind:      "ServiceAccount",
-						Name:      saName,
-						Namespace: targetNamespace,
-					},
-				},
-				RoleRef: rbacv1.RoleRef{
-					APIGroup: "rbac.authorization.k8s.io",
-					Kind:     "ClusterRole",
-					Name:     "cluster-admin",
-				},
-			}
-			_, err = clientset.RbacV1().ClusterRoleBindings().Create(context.Background(), crb, metav1.CreateOptions{})
-			if err != nil {
-				return "", fmt.Errorf("creating clusterrolebinding %s: %w", crbName, err)
-			}
-		} else {
-			return "", fmt.Errorf("error checking clusterrolebinding %s: %w", crbName, err)
-		}
+		return "", err
 	}
+	return utils.PickOne("Select an xkube", opts)
+}
 
-	// Generate token using TokenRequest API (Kubernetes v1.24+ compatible)
-	tokenRequest := &authenticationv1.TokenRequest{
-		Spec: authenticationv1.TokenRequestSpec{
-			ExpirationSeconds: ptr.To[int64](86400),
-		},
+func xkubeOptions(ctx context.Context, ns string) ([]utils.PickOption, error) {
+	gvr := schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xkubes"}
+	ri, err := utils.GetResourceClient(viper.GetString("kubeconfig"), gvr, ns)
+	if err != nil {
+		return nil, err
 	}
-	tokenResponse, err := clientset.CoreV1().ServiceAccounts(targetNamespace).CreateToken(context.Background(), saName, tokenRequest, metav1.CreateOptions{})
-	if err != nil {return "", fmt.Errorf("creating service account token: %w", err)}
-	
-	token := []byte(tokenResponse.Status.Token)
-	// Build a kubeconfig that uses this token and the cluster info
-	outBytes, err := buildNewKubeconfig(clusterObj, clusterID, token)
-	if err != nil {return "", fmt.Errorf("writing new kubeconfig: %w", err)}
-	
-	// Persist the kubeconfig into a secret with expiry set to token expiration	
-	var expiryTime time.Time
-	if tokenResponse.Status.ExpirationTimestamp.IsZero() {
-		// fallback if unavailable: set expiry to now + requested duration (ExpirationSeconds)
-	expiryTime = time.Now().UTC().Add(10 * time.Hour)
-	} else {
-		expiryTime = tokenResponse.Status.ExpirationTimestamp.Time.UTC()
-	}
-
-	// Check for existing secret and its expiry
-	// secret name where we'll store the static kubeconfig + expiry
-	secretName := clusterID + "-static-kubeconfig"
-	secretObj := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
-			Namespace: targetNamespace,
-			Labels: map[string]string{
-				"skycluster.io/managed-by": "skycluster",
-				"skycluster.io/secret-type": "static-kubeconfig",
-				"skycluster.io/cluster-id":   clusterID,
-			},
-			Annotations: map[string]string{
-				"skycluster.io/expiry": expiryTime.Format(time.RFC3339),
-			},
-		},
-		Data: map[string][]byte{
-			"kubeconfig": outBytes,
-		},
-		Type: corev1.SecretTypeOpaque,	
-	}
-
-	// Create or update secret
-	_, err = localClientSet.CoreV1().Secrets(targetNamespace).Create(context.Background(), secretObj, metav1.CreateOptions{})
+	list, err := ri.List(ctx, metav1.ListOptions{})
 	if err != nil {
-		// If create failed because it already exists (race), try update
-		if apierrors.IsAlreadyExists(err) {
-			// attempt to update
-			_, err = localClientSet.CoreV1().Secrets(targetNamespace).Update(context.Background(), secretObj, metav1.UpdateOptions{})
-			if err != nil {
-				return "", fmt.Errorf("creating/updating secret %s/%s: %w", targetNamespace, secretName, err)
-			}
-		} else {
-			return "", fmt.Errorf("creating secret %s/%s: %w", targetNamespace, secretName, err)
-		}
+		return nil, err
 	}
-
-	return string(outBytes), nil
+	return utils.ReadinessOptions(list.Items), nil
 }
 
-// return static kubeconfig (byte) from secret if exists and not expired
-func fetchStaticKubeconfigSecret(clusterID string, targetNamespace string, localClientSet *kubernetes.Clientset) ([]byte, error) {
-	// secret name where we'll store the static kubeconfig + expiry
-	secretName := clusterID + "-static-kubeconfig"
-	expiryAnnotation := "skycluster.io/expiry"
-
-	// Check for existing secret and its expiry
-	existingSecret, err := localClientSet.CoreV1().Secrets(targetNamespace).Get(context.Background(), secretName, metav1.GetOptions{})
-	if err == nil {
-		// Secret exists; check expiry annotation and kubeconfig presence
-		if existingSecret.Data != nil {
-			if kcBytes, ok := existingSecret.Data["kubeconfig"]; ok && len(kcBytes) > 0 {
-				if ann := existingSecret.Annotations[expiryAnnotation]; ann != "" {
-					expiryTime, perr := time.Parse(time.RFC3339, ann)
-					if perr == nil {
-						if time.Now().UTC().Before(expiryTime) {
-							// Not expired: return stored kubeconfig
-							return kcBytes, nil
-						}
-						// expired -> proceed to create a new token and update secret
-					}
-				}
-			}
-		}
-	} else {
-		return nil, fmt.Errorf("error checking existing secret %s/%s: %w", targetNamespace, secretName, err)
+func GetConfig(ctx context.Context, kubeName string, ns string) (string, error) {
+	kubeconfigPath := viper.GetString("kubeconfig")
+	dynamicClient, err1 := utils.GetDynamicClient(kubeconfigPath)
+	clientSet, err2 := utils.GetClientset(kubeconfigPath)
+	if err1 != nil || err2 != nil {
+		return "", err1
 	}
-	return nil, fmt.Errorf("static kubeconfig secret %s/%s not found or expired", targetNamespace, secretName)
-}
-
-func buildNewKubeconfig(clusterObj *api.Cluster, clusterID string, token []byte) ([]byte, error) {
-
-	// Build a kubeconfig that uses this token and the cluster info
-	newCfg := api.NewConfig()
 
-	// choose unique names to avoid collision when merging multiple
-	clusterOutName := clusterID + "-cluster"
-	userOutName := clusterID
-	contextOutName := clusterID
-
-	newCfg.Clusters[clusterOutName] = &api.Cluster{
-		Server:                   clusterObj.Server,
-		CertificateAuthorityData: clusterObj.CertificateAuthorityData,
-		InsecureSkipTLSVerify:    clusterObj.InsecureSkipTLSVerify,
+	localClients := clientSets{
+		dynamicClient: dynamicClient,
+		clientSet:     clientSet,
 	}
 
-	newCfg.AuthInfos[userOutName] = &api.AuthInfo{
-		Token: string(token),
+	staticKubeconfig, err := fetchKubeconfig(ctx, kubeName, localClients)
+	if err != nil {
+		return "", fmt.Errorf("error generating kubeconfig for [%s]: %v", kubeName, err)
 	}
+	
+	return staticKubeconfig, nil
+}
 
-	newCfg.Contexts[contextOutName] = &api.Context{
-		Cluster:  clusterOutName,
-		AuthInfo: userOutName,
+// lib returns the library client working with cs, reporting to the debug
+// output and the local access state.
+func (cs clientSets) lib() *skycluster.Client {
+	return &skycluster.Client{
+		Dynamic:     cs.dynamicClient,
+		Kube:        cs.clientSet,
+		Credentials: credentialProviders,
+		Logf:        debugf,
+		OnIssued:    recordIssued,
+		OnUsed:      recordStaticUse,
 	}
+}
 
-	newCfg.CurrentContext = contextOutName
-
-	outBytes, err := clientcmd.Write(*newCfg)
+func fetchKubeconfig(ctx context.Context, xkubeName string, clientSets clientSets) (string, error) {
+	obj, err := utils.GetWithSuggestions(ctx, resource.XKube.Client(clientSets.dynamicClient), resource.XKube.Name, xkubeName)
 	if err != nil {
-		return nil, fmt.Errorf("writing new kubeconfig: %w", err)
+		return "", err
 	}
-
-	return outBytes, nil
+	return clientSets.lib().KubeconfigFor(ctx, obj)
 }
 
-// Merge kubeconfig strings into one single kubeconfig YAML
 func mergeKubeconfigs(kubeconfigs []string) ([]byte, error) {
 	merged := api.NewConfig()
 
@@ -490,4 +340,91 @@ func mergeKubeconfigs(kubeconfigs []string) ([]byte, error) {
 
 	// Serialize
 	return clientcmd.Write(*merged)
-}
\ No newline at end of file
+}
+// prefixKubeconfig prepends prefix to the names of every cluster, user and
+// context of raw.
+func prefixKubeconfig(raw []byte, prefix string) ([]byte, error) {
+	cfg, err := clientcmd.Load(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	out := api.NewConfig()
+	for name, cluster := range cfg.Clusters {
+		out.Clusters[prefix+name] = cluster
+	}
+	for name, user := range cfg.AuthInfos {
+		out.AuthInfos[prefix+name] = user
+	}
+	for name, ctx := range cfg.Contexts {
+		ctx.Cluster = prefix + ctx.Cluster
+		ctx.AuthInfo = prefix + ctx.AuthInfo
+		out.Contexts[prefix+name] = ctx
+	}
+	if cfg.CurrentContext != "" {
+		out.CurrentContext = prefix + cfg.CurrentContext
+	}
+	return clientcmd.Write(*out)
+}
+
+// mergeIntoKubeconfig adds the clusters, users and contexts of generated to
+// the kubeconfig at path, replacing entries of the same name. The file is
+// locked while it is rewritten and its previous content is kept next to it
+// as <path>.bak-<timestamp>. The current context is only set when the file
+// has none.
+func mergeIntoKubeconfig(path string, generated []byte) error {
+	gen, err := clientcmd.Load(generated)
+	if err != nil {
+		return fmt.Errorf("parsing generated kubeconfig: %w", err)
+	}
+	unlock, err := utils.LockFile(path, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg := api.NewConfig()
+	perm := os.FileMode(0o600)
+	original, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if cfg, err = clientcmd.Load(original); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		if fi, err := os.Stat(path); err == nil {
+			perm = fi.Mode().Perm()
+		}
+		backup := fmt.Sprintf("%s.bak-%s", path, time.Now().Format("20060102-150405"))
+		if err := utils.WriteFileAtomic(backup, original, perm); err != nil {
+			return fmt.Errorf("backing up %s: %w", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "Backed up %s to %s\n", path, backup)
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	for name, cluster := range gen.Clusters {
+		cfg.Clusters[name] = cluster
+	}
+	for name, user := range gen.AuthInfos {
+		cfg.AuthInfos[name] = user
+	}
+	for name, ctx := range gen.Contexts {
+		if _, ok := cfg.Contexts[name]; ok {
+			fmt.Fprintf(os.Stderr, "Replacing context %s in %s\n", name, path)
+		}
+		cfg.Contexts[name] = ctx
+	}
+	if cfg.CurrentContext == "" {
+		cfg.CurrentContext = gen.CurrentContext
+	}
+
+	out, err := clientcmd.Write(*cfg)
+	if err != nil {
+		return fmt.Errorf("serializing kubeconfig: %w", err)
+	}
+	if err := utils.WriteFileAtomic(path, out, perm); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Merged %d context(s) into %s\n", len(gen.Contexts), path)
+	return nil
+}
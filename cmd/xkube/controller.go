@@ -2,6 +2,7 @@ package xkube
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -13,8 +14,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/yaml"
 
 	"github.com/etesami/skycluster-cli/internal/utils"
@@ -27,21 +32,35 @@ type Controller struct {
 	dyn    dynamic.Interface
 	ns     string
 
-	secretLabelSelector string // e.g. "skycluster.io/secret-type=cluster-cacert"
-	remoteSecretKey     string // e.g. "remote-secret.yaml"
+	// rules select the secrets to propagate; see PropagationRule.
+	rules []PropagationRule
 
 	// readyXkubes maps clusterName -> kubeconfig
 	readyMu sync.Mutex
 	ready   map[string]string
 
-	// deployedTracks[source][target] == true when secret from source has been applied to target.
+	// deployed[secret][target] == true when the secret, identified by
+	// namespace/name@resourceVersion, has been applied to target.
 	deployedMu sync.Mutex
 	deployed   map[string]map[string]bool
 
 	// for constructing fetchKubeconfig call (matches your original)
 	clientSets clientSets
+
+	// queue holds the names of xkubes to propagate secrets to.
+	queue        workqueue.TypedRateLimitingInterface[string]
+	resyncPeriod time.Duration
+	// progress is signalled after every successful reconcile.
+	progress chan struct{}
+
+	syncedMu sync.Mutex
+	synced   map[string]bool // xkube name -> last reconcile succeeded
+
+	metrics *controllerMetrics
 }
 
+var xkubesGVR = schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xkubes"}
+
 // NewController creates and initializes a Controller.
 // kubeconfigPath is used to create clientset/dynamic client for the management cluster.
 // ns is the namespace where secrets are watched/listed.
@@ -58,223 +77,324 @@ func NewController(kubeconfigPath, ns string) (*Controller, error) {
 		debugf("GetDynamicClient failed: %v", err2)
 		return nil, fmt.Errorf("creating dynamic client: %w", err2)
 	}
+	return newController(cs, dyn, ns), nil
+}
 
+// NewControllerForConfig creates a Controller talking to the management
+// cluster described by cfg, e.g. the in-cluster config of a pod.
+func NewControllerForConfig(cfg *rest.Config, ns string) (*Controller, error) {
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes clientset: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating dynamic client: %w", err)
+	}
+	return newController(cs, dyn, ns), nil
+}
+
+func newController(cs *kubernetes.Clientset, dyn dynamic.Interface, ns string) *Controller {
 	c := &Controller{
 		cs:                  cs,
 		dyn:                 dyn,
 		ns:                  ns,
-		secretLabelSelector: "skycluster.io/secret-type=cluster-cacert",
-		remoteSecretKey:     "remote-secret.yaml",
-		ready:               make(map[string]string),
-		deployed:            make(map[string]map[string]bool),
+		rules:    []PropagationRule{defaultPropagationRule},
+		ready:    make(map[string]string),
+		deployed: make(map[string]map[string]bool),
 		clientSets: clientSets{
 			dynamicClient: dyn,
 			clientSet:     cs,
 		},
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[string](2*time.Second, 2*time.Minute),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "xkube-secrets"},
+		),
+		resyncPeriod: 30 * time.Second,
+		progress:     make(chan struct{}, 1),
+		synced:       make(map[string]bool),
+		metrics:      newControllerMetrics(),
 	}
 	debugf("NewController initialized successfully")
-	return c, nil
+	return c
 }
 
-// Run starts watchers and blocks until ctx is cancelled. It returns when the context is done.
-func (c *Controller) Run(ctx context.Context) error {
-	debugf("Controller.Run starting (ns=%q)", c.ns)
-	gvr := schema.GroupVersionResource{
-		Group:    "skycluster.io",
-		Version:  "v1alpha1",
-		Resource: "xkubes",
+// SetPropagationRules replaces the rules selecting the secrets to propagate,
+// which default to the remote CA secrets.
+func (c *Controller) SetPropagationRules(rules []PropagationRule) {
+	if len(rules) > 0 {
+		c.rules = rules
 	}
+}
 
-	// create cancellable child context so we can stop early
-	childCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// SetResyncPeriod sets how often every ready xkube is queued again.
+func (c *Controller) SetResyncPeriod(d time.Duration) {
+	c.resyncPeriod = d
+}
 
-	// get initial list to populate counts/map
-	list, err := c.dyn.Resource(gvr).List(childCtx, metav1.ListOptions{})
-	if err != nil {
-		debugf("listing xkubes failed: %v", err)
-		return fmt.Errorf("listing xkubemeshes: %w", err)
-	}
-	debugf("initial xkubes list count=%d", len(list.Items))
+// Run propagates the selected secrets between ready xkubes and blocks until
+// all xkubes are ready and each has received the secrets of the others, or
+// until ctx is cancelled. xkubes are queued when the watch reports them
+// ready and again every resync period; failed ones are retried with
+// exponential backoff.
+func (c *Controller) Run(ctx context.Context) error {
+	return c.run(ctx, false)
+}
 
-	mu := &sync.Mutex{}
-	readyMap := make(map[string]bool)
-	total, ready := len(list.Items), 0
+// RunForever propagates the secrets like Run, but keeps going until ctx is
+// cancelled so that xkubes joining later receive them as well. When the
+// watch ends it is established again.
+func (c *Controller) RunForever(ctx context.Context) error {
+	return c.run(ctx, true)
+}
 
-	// Watch xkubes
-	xkubeWatcher, err := c.dyn.Resource(gvr).Watch(ctx, metav1.ListOptions{})
+func (c *Controller) run(ctx context.Context, forever bool) error {
+	debugf("Controller.Run starting (ns=%q forever=%v)", c.ns, forever)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer c.queue.ShutDown()
+
+	xkubeWatcher, err := c.dyn.Resource(xkubesGVR).Watch(ctx, metav1.ListOptions{})
 	if err != nil {
 		debugf("watch creation failed: %v", err)
-		return fmt.Errorf("watching xkubemeshes: %w", err)
+		return fmt.Errorf("watching xkubes: %w", err)
 	}
-	defer xkubeWatcher.Stop()
 	debugf("watcher established for xkubes")
 
-	// Event loop goroutines
-	var wg sync.WaitGroup
-	stopCh := make(chan struct{})
-	wg.Add(1)
-
-	// xkube events
+	go c.watchReady(ctx, xkubeWatcher, forever)
 	go func() {
-		defer wg.Done()
-		ch := xkubeWatcher.ResultChan()
-		for {
-			select {
-			case ev, ok := <-ch:
-				if !ok {
-					debugf("watch result channel closed")
-					return
-				}
-				if ev.Object == nil {
-					debugf("watch event with nil object received; skipping")
-					continue
-				}
-
-				obj, ok := ev.Object.(*unstructured.Unstructured)
-				if !ok {
-					log.Printf("unexpected type from xkube watch: %T", ev.Object)
-					continue
-				}
-
-				// Determine ready status once
-				isReady := utils.GetConditionStatus(obj, "Ready") == "True"
-				debugf("event for %s/%s ready=%v", obj.GetNamespace(), obj.GetName(), isReady)
-
-				// update ready map and counts
-				key := obj.GetNamespace() + "/" + obj.GetName()
-				mu.Lock()
-				prev, exists := readyMap[key]
-				if !exists { // new entry
-					readyMap[key] = isReady
-					if isReady {
-						ready++
-					}
-					debugf("new xkube entry %s ready=%v (readyCount=%d total=%d)", key, isReady, ready, total)
-				} else { // existing entry
-					if prev != isReady {
-						if isReady {
-							ready++
-						} else {
-							ready--
-						}
-						readyMap[key] = isReady
-						debugf("updated xkube entry %s prevReady=%v nowReady=%v (readyCount=%d)", key, prev, isReady, ready)
-					}
-				}
-
-				// If the object is Ready, call the handler
-				if isReady {
-					debugf("calling handleReadyXkube for %s", key)
-					c.handleReadyXkube(obj)
-				}
+		for c.processNextItem(ctx) {
+		}
+	}()
 
-				// stop when all are ready (and there is at least one)
-				if total > 0 && ready == total {
-					debugf("all xkubes ready (ready=%d total=%d) - cancelling child context", ready, total)
-					mu.Unlock()
-					cancel() // stops watchers and main wait
-					return
-				}
-				mu.Unlock()
+	ticker := time.NewTicker(c.resyncPeriod)
+	defer ticker.Stop()
+	for {
+		done, err := c.resync(ctx)
+		if err != nil {
+			log.Printf("warning: resync failed, retrying in %s: %v", c.resyncPeriod, err)
+		}
+		if done && !forever {
+			debugf("all xkubes ready and synced")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			debugf("context done; shutting down")
+			return nil
+		case <-ticker.C:
+		case <-c.progress:
+		}
+	}
+}
 
-			case <-stopCh:
-				debugf("stopCh received - terminating watch goroutine")
+// watchReady queues the xkubes w reports ready until ctx is done. The
+// periodic resync keeps things moving when the watch ends; with forever the
+// watch is also established again.
+func (c *Controller) watchReady(ctx context.Context, w watch.Interface, forever bool) {
+	for {
+		stop := context.AfterFunc(ctx, w.Stop)
+		c.queueReady(w)
+		stop()
+		w.Stop()
+		debugf("watch result channel closed")
+		for {
+			if !forever {
 				return
 			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.resyncPeriod):
+			}
+			var err error
+			if w, err = c.dyn.Resource(xkubesGVR).Watch(ctx, metav1.ListOptions{}); err == nil {
+				c.metrics.watchRestarts.Inc()
+				break
+			}
+			log.Printf("warning: re-establishing the xkube watch: %v", err)
 		}
-	}()
+	}
+}
+
+// queueReady queues the xkubes the watcher reports ready until it ends.
+func (c *Controller) queueReady(w watch.Interface) {
+	for ev := range w.ResultChan() {
+		obj, ok := ev.Object.(*unstructured.Unstructured)
+		if !ok {
+			debugf("unexpected type from xkube watch: %T", ev.Object)
+			continue
+		}
+		if utils.GetConditionStatus(obj, "Ready") == "True" {
+			debugf("watch: xkube %s is ready, queueing", obj.GetName())
+			c.queue.Add(obj.GetName())
+		}
+	}
+}
+
+// resync queues every ready xkube and reports whether all xkubes are ready
+// and have been synced.
+func (c *Controller) resync(ctx context.Context) (bool, error) {
+	list, err := c.dyn.Resource(xkubesGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("listing xkubes: %w", err)
+	}
+	done := len(list.Items) > 0
+	names := make(map[string]bool, len(list.Items))
+	for i := range list.Items {
+		name := list.Items[i].GetName()
+		names[name] = true
+		ready := utils.GetConditionStatus(&list.Items[i], "Ready") == "True"
+		c.metrics.ready.SetBool(ready, name)
+		if !ready {
+			done = false
+			continue
+		}
+		c.queue.Add(name)
+		if !c.isSynced(name) {
+			done = false
+		}
+	}
+	// forget the xkubes that are gone
+	c.metrics.ready.Retain(func(name string) bool { return names[name] })
+	c.metrics.synced.Retain(func(name string) bool { return names[name] })
+	debugf("resync: %d xkubes, done=%v", len(list.Items), done)
+	return done, nil
+}
 
-	// Block until context cancelled
-	<-childCtx.Done()
-	debugf("childCtx done; shutting down")
-	close(stopCh)
-	wg.Wait()
-	debugf("Run completed")
-	return nil
+// processNextItem reconciles the next queued xkube, requeueing it with
+// backoff when that fails. It returns false once the queue is shut down or
+// ctx is done.
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	name, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(name)
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err := c.reconcile(ctx, name); err != nil {
+		c.setSynced(name, false)
+		c.metrics.failures.Inc(name)
+		log.Printf("warning: propagating secrets to xkube %s failed (attempt %d), will retry: %v", name, c.queue.NumRequeues(name)+1, err)
+		c.queue.AddRateLimited(name)
+		return true
+	}
+	c.queue.Forget(name)
+	c.setSynced(name, true)
+	select {
+	case c.progress <- struct{}{}:
+	default:
+	}
+	return true
 }
 
-// handleReadyXkube is called when an xkubemesh shows Ready=true.
-// It fetches its kubeconfig, stores it in ready map, and applies existing secrets to it.
-func (c *Controller) handleReadyXkube(obj *unstructured.Unstructured) {
+// reconcile fetches the kubeconfig of a ready xkube, stores it in the ready
+// map and applies the secrets of the other clusters to it.
+func (c *Controller) reconcile(ctx context.Context, name string) error {
+	obj, err := c.dyn.Resource(xkubesGVR).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		debugf("xkube %s is gone; nothing to do", name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting xkube: %w", err)
+	}
+	if utils.GetConditionStatus(obj, "Ready") != "True" {
+		debugf("xkube %s is not ready; waiting for the watch or resync", name)
+		return nil
+	}
 	targetClusterName := c.getClusterNameFromXkube(obj)
-	log.Printf("handling ready xkube: cluster=%s name=%s", targetClusterName, obj.GetName())
-	debugf("handleReadyXkube: obj=%s/%s clusterName=%q", obj.GetNamespace(), obj.GetName(), targetClusterName)
+	debugf("reconcile: xkube=%s clusterName=%q", name, targetClusterName)
 	if targetClusterName == "" {
-		debugf("no clusterName found for xkube %s/%s - skipping", obj.GetNamespace(), obj.GetName())
-		return // cannot proceed without cluster name
+		return fmt.Errorf("status.clusterName is not set yet")
 	}
 
-	// fetch kubeconfig for this xkube (assumes fetchKubeconfig exists in your codebase)
-	kc, err := fetchKubeconfig(obj.GetName(), c.clientSets)
-	if err != nil || strings.TrimSpace(kc) == "" {
-		log.Printf("warning: kubeconfig for mesh %s is empty or fetch failed; will retry later: err=%v", obj.GetName(), err)
-		debugf("fetchKubeconfig failed or returned empty for %s: err=%v", obj.GetName(), err)
-		return
+	kc, err := fetchKubeconfig(ctx, name, c.clientSets)
+	if err != nil {
+		return fmt.Errorf("fetching kubeconfig: %w", err)
 	}
-	debugf("fetched kubeconfig for xkube %s (len=%d)", obj.GetName(), len(kc))
-
+	if strings.TrimSpace(kc) == "" {
+		return fmt.Errorf("fetching kubeconfig: empty kubeconfig")
+	}
+	debugf("fetched kubeconfig for xkube %s (len=%d)", name, len(kc))
 	c.setReady(targetClusterName, kc)
-	debugf("setReady for cluster %s", targetClusterName)
-	log.Printf("xkube ready: cluster=%s name=%s", targetClusterName, obj.GetName())
 
-	// apply all existing relevant secrets into this target (except those from the same source)
-	secrets, err := c.listSecrets(context.Background())
+	// apply all existing relevant secrets into this target (except those from
+	// the same source); secrets without a source cluster come from the
+	// management cluster and go to every target.
+	secrets, err := c.listSecrets(ctx)
 	if err != nil {
-		log.Printf("error listing secrets for propagation to %s: %v", targetClusterName, err)
-		debugf("listSecrets failed: %v", err)
-		return
+		return fmt.Errorf("listing secrets: %w", err)
 	}
-	debugf("listSecrets returned %d secrets", len(secrets))
-
+	var errs []error
 	for i := range secrets {
-		secret := secrets[i] // avoid pointer to loop var
+		secret := secrets[i].secret // avoid pointer to loop var
+		key := secrets[i].key
 		sourceClusterName := secret.Labels["skycluster.io/cluster-name"]
-		if sourceClusterName == "" || sourceClusterName == targetClusterName {
+		if sourceClusterName == targetClusterName {
 			debugf("skipping secret %s/%s source=%q target=%q", secret.Namespace, secret.Name, sourceClusterName, targetClusterName)
 			continue
 		}
-
-		if c.isDeployed(sourceClusterName, targetClusterName) {
-			debugf("secret from source=%s already deployed to target=%s - skipping", sourceClusterName, targetClusterName)
+		if sourceClusterName == "" {
+			sourceClusterName = "management"
+		}
+		id := fmt.Sprintf("%s/%s@%s", secret.Namespace, secret.Name, secret.ResourceVersion)
+		if c.isDeployed(id, targetClusterName) {
+			debugf("secret %s already deployed to target=%s - skipping", id, targetClusterName)
 			continue
 		}
 
 		debugf("applying secret %s/%s from %s to target=%s", secret.Namespace, secret.Name, sourceClusterName, targetClusterName)
-		if err := c.applySecretToRemote(context.Background(), kc, &secret); err != nil {
-			log.Printf("error applying secret %s/%s from %s to %s: %v", secret.Namespace, secret.Name, sourceClusterName, targetClusterName, err)
-			debugf("applySecretToRemote failed: %v", err)
+		if err := c.applySecretToRemote(ctx, kc, &secret, key); err != nil {
+			errs = append(errs, fmt.Errorf("applying secret %s/%s from %s: %w", secret.Namespace, secret.Name, sourceClusterName, err))
 			continue
 		}
-		c.markDeployed(sourceClusterName, targetClusterName)
-		debugf("marked deployed source=%s target=%s", sourceClusterName, targetClusterName)
-		log.Printf("propagated secret (source=%s) to target=%s", sourceClusterName, targetClusterName)
+		c.markDeployed(id, targetClusterName)
+		c.metrics.propagated.Inc(targetClusterName)
+		log.Printf("propagated secret %s/%s (source=%s) to target=%s", secret.Namespace, secret.Name, sourceClusterName, targetClusterName)
 	}
+	return errors.Join(errs...)
 }
 
-// applySecretToRemote creates or updates the given secret on the remote cluster described by kubeconfig (kc).
-// It applies the secret into the same namespace and name as originSecret.
-func (c *Controller) applySecretToRemote(ctx context.Context, kc string, originSecret *corev1.Secret) error {
-	debugf("applySecretToRemote: origin=%s/%s targetKubeconfigLen=%d", originSecret.Namespace, originSecret.Name, len(kc))
+// applySecretToRemote creates or updates a secret on the remote cluster described by kubeconfig (kc):
+// the secret embedded as YAML under key of originSecret, or originSecret itself when key is empty.
+func (c *Controller) applySecretToRemote(ctx context.Context, kc string, originSecret *corev1.Secret, key string) error {
+	debugf("applySecretToRemote: origin=%s/%s key=%q targetKubeconfigLen=%d", originSecret.Namespace, originSecret.Name, key, len(kc))
 	if strings.TrimSpace(kc) == "" {
 		debugf("empty kubeconfig provided")
 		return fmt.Errorf("empty kubeconfig for target cluster")
 	}
 
-	// Get embedded YAML from origin secret
-	raw, ok := originSecret.Data[c.remoteSecretKey]
-	if !ok || len(raw) == 0 {
-		debugf("origin secret missing embedded key %q", c.remoteSecretKey)
-		return fmt.Errorf("secret %s/%s missing key %q", originSecret.Namespace, originSecret.Name, c.remoteSecretKey)
-	}
-
-	// Unmarshal YAML into a corev1.Secret
 	var remoteSecret corev1.Secret
-	if err := yaml.Unmarshal(raw, &remoteSecret); err != nil {
-		debugf("unmarshal embedded secret YAML failed: %v", err)
-		return fmt.Errorf("failed to unmarshal embedded secret YAML from %s/%s: %w", originSecret.Namespace, originSecret.Name, err)
+	if key == "" {
+		remoteSecret = corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        originSecret.Name,
+				Namespace:   originSecret.Namespace,
+				Labels:      originSecret.Labels,
+				Annotations: originSecret.Annotations,
+			},
+			Type: originSecret.Type,
+			Data: originSecret.Data,
+		}
+	} else {
+		// Get embedded YAML from origin secret
+		raw, ok := originSecret.Data[key]
+		if !ok || len(raw) == 0 {
+			debugf("origin secret missing embedded key %q", key)
+			return fmt.Errorf("secret %s/%s missing key %q", originSecret.Namespace, originSecret.Name, key)
+		}
+
+		// Unmarshal YAML into a corev1.Secret
+		if err := yaml.Unmarshal(raw, &remoteSecret); err != nil {
+			debugf("unmarshal embedded secret YAML failed: %v", err)
+			return fmt.Errorf("failed to unmarshal embedded secret YAML from %s/%s: %w", originSecret.Namespace, originSecret.Name, err)
+		}
+		debugf("unmarshalled embedded secret YAML: name=%q namespace=%q", remoteSecret.Name, remoteSecret.Namespace)
 	}
-	debugf("unmarshalled embedded secret YAML: name=%q namespace=%q", remoteSecret.Name, remoteSecret.Namespace)
 
 	// Ensure name and namespace are present
 	name := remoteSecret.Name
@@ -296,47 +416,67 @@ func (c *Controller) applySecretToRemote(ctx context.Context, kc string, originS
 	ctx2, cancel := context.WithTimeout(ctx, 20*time.Second)
 	defer cancel()
 
-	// Try to get existing secret on remote cluster
-	existing, err := remoteClient.CoreV1().Secrets(namespace).Get(ctx2, name, metav1.GetOptions{})
-	if err != nil {
-		if k8serrors.IsNotFound(err) {
-			debugf("remote secret %s/%s not found - creating", namespace, name)
-			// Create
-			_, err = remoteClient.CoreV1().Secrets(namespace).Create(ctx2, &remoteSecret, metav1.CreateOptions{})
-			if err != nil {
-				debugf("creating remote secret failed: %v", err)
-				return fmt.Errorf("creating secret %s/%s on remote cluster: %w", namespace, name, err)
+	return utils.RetryOnConflict(ctx2, func(ctx2 context.Context) error {
+		// Try to get existing secret on remote cluster
+		existing, err := remoteClient.CoreV1().Secrets(namespace).Get(ctx2, name, metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				debugf("remote secret %s/%s not found - creating", namespace, name)
+				// Create; an earlier attempt may have set the resourceVersion.
+				remoteSecret.ResourceVersion = ""
+				_, err = remoteClient.CoreV1().Secrets(namespace).Create(ctx2, &remoteSecret, metav1.CreateOptions{})
+				if err != nil {
+					debugf("creating remote secret failed: %v", err)
+					return fmt.Errorf("creating secret %s/%s on remote cluster: %w", namespace, name, err)
+				}
+				debugf("created secret %s/%s on remote", namespace, name)
+				return nil
 			}
-			debugf("created secret %s/%s on remote", namespace, name)
-			return nil
+			debugf("getting remote secret failed: %v", err)
+			return fmt.Errorf("getting remote secret %s/%s: %w", namespace, name, err)
 		}
-		debugf("getting remote secret failed: %v", err)
-		return fmt.Errorf("getting remote secret %s/%s: %w", namespace, name, err)
-	}
 
-	// Exists -> update. Preserve resourceVersion for optimistic concurrency.
-	remoteSecret.ResourceVersion = existing.ResourceVersion
-	debugf("updating existing remote secret %s/%s (resourceVersion=%s)", namespace, name, remoteSecret.ResourceVersion)
-	_, err = remoteClient.CoreV1().Secrets(namespace).Update(ctx2, &remoteSecret, metav1.UpdateOptions{})
-	if err != nil {
-		debugf("updating remote secret failed: %v", err)
-		return fmt.Errorf("updating secret %s/%s on remote cluster: %w", namespace, name, err)
-	}
-	debugf("updated remote secret %s/%s successfully", namespace, name)
-	return nil
+		// Exists -> update. Preserve resourceVersion for optimistic concurrency.
+		remoteSecret.ResourceVersion = existing.ResourceVersion
+		debugf("updating existing remote secret %s/%s (resourceVersion=%s)", namespace, name, remoteSecret.ResourceVersion)
+		_, err = remoteClient.CoreV1().Secrets(namespace).Update(ctx2, &remoteSecret, metav1.UpdateOptions{})
+		if err != nil {
+			debugf("updating remote secret failed: %v", err)
+			return fmt.Errorf("updating secret %s/%s on remote cluster: %w", namespace, name, err)
+		}
+		debugf("updated remote secret %s/%s successfully", namespace, name)
+		return nil
+	})
 }
 
-// listSecrets returns secrets in controller namespace that match the label selector.
-func (c *Controller) listSecrets(ctx context.Context) ([]corev1.Secret, error) {
-	debugf("listSecrets: ns=%q selector=%q", c.ns, c.secretLabelSelector)
-	opts := metav1.ListOptions{LabelSelector: c.secretLabelSelector}
-	ls, err := c.cs.CoreV1().Secrets(c.ns).List(ctx, opts)
-	if err != nil {
-		debugf("list secrets failed: %v", err)
-		return nil, err
+// selectedSecret is a secret matched by a rule, with the key of the rule.
+type selectedSecret struct {
+	secret corev1.Secret
+	key    string
+}
+
+// listSecrets returns secrets in controller namespace that match the label selectors of the rules.
+// A secret matched by several rules is returned once, with the key of the first one.
+func (c *Controller) listSecrets(ctx context.Context) ([]selectedSecret, error) {
+	var out []selectedSecret
+	seen := map[types.UID]bool{}
+	for _, r := range c.rules {
+		debugf("listSecrets: ns=%q selector=%q", c.ns, r.Selector)
+		opts := metav1.ListOptions{LabelSelector: r.Selector}
+		ls, err := c.cs.CoreV1().Secrets(c.ns).List(ctx, opts)
+		if err != nil {
+			debugf("list secrets failed: %v", err)
+			return nil, err
+		}
+		debugf("listSecrets returned %d items", len(ls.Items))
+		for _, s := range ls.Items {
+			if !seen[s.UID] {
+				seen[s.UID] = true
+				out = append(out, selectedSecret{secret: s, key: r.Key})
+			}
+		}
 	}
-	debugf("listSecrets returned %d items", len(ls.Items))
-	return ls.Items, nil
+	return out, nil
 }
 
 // getClusterNameFromXkube extracts the clusterName from xkubemesh unstructured object,
@@ -351,24 +491,24 @@ func (c *Controller) getClusterNameFromXkube(obj *unstructured.Unstructured) str
 }
 
 // --- deployed bookkeeping helpers ---
-func (c *Controller) markDeployed(source, target string) {
-	debugf("markDeployed: source=%s target=%s", source, target)
+func (c *Controller) markDeployed(secret, target string) {
+	debugf("markDeployed: secret=%s target=%s", secret, target)
 	c.deployedMu.Lock()
 	defer c.deployedMu.Unlock()
-	if _, ok := c.deployed[source]; !ok {
-		c.deployed[source] = make(map[string]bool)
+	if _, ok := c.deployed[secret]; !ok {
+		c.deployed[secret] = make(map[string]bool)
 	}
-	c.deployed[source][target] = true
+	c.deployed[secret][target] = true
 }
 
-func (c *Controller) isDeployed(source, target string) bool {
+func (c *Controller) isDeployed(secret, target string) bool {
 	c.deployedMu.Lock()
 	defer c.deployedMu.Unlock()
-	if m, ok := c.deployed[source]; ok {
-		debugf("isDeployed: source=%s target=%s -> %v", source, target, m[target])
+	if m, ok := c.deployed[secret]; ok {
+		debugf("isDeployed: secret=%s target=%s -> %v", secret, target, m[target])
 		return m[target]
 	}
-	debugf("isDeployed: no entries for source=%s", source)
+	debugf("isDeployed: no entries for secret=%s", secret)
 	return false
 }
 
@@ -379,6 +519,19 @@ func (c *Controller) clearDeployedForSource(source string) {
 	delete(c.deployed, source)
 }
 
+func (c *Controller) setSynced(name string, ok bool) {
+	c.syncedMu.Lock()
+	defer c.syncedMu.Unlock()
+	c.synced[name] = ok
+	c.metrics.synced.SetBool(ok, name)
+}
+
+func (c *Controller) isSynced(name string) bool {
+	c.syncedMu.Lock()
+	defer c.syncedMu.Unlock()
+	return c.synced[name]
+}
+
 // ready map helpers
 func (c *Controller) setReady(clusterName, kc string) {
 	debugf("setReady: cluster=%s", clusterName)
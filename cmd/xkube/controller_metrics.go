@@ -0,0 +1,33 @@
+package xkube
+
+import (
+	"github.com/etesami/skycluster-cli/internal/metrics"
+)
+
+// controllerMetrics are the metrics of the Controller.
+type controllerMetrics struct {
+	registry      *metrics.Registry
+	propagated    *metrics.Counter
+	failures      *metrics.Counter
+	watchRestarts *metrics.Counter
+	ready         *metrics.Gauge
+	synced        *metrics.Gauge
+}
+
+func newControllerMetrics() *controllerMetrics {
+	r := metrics.NewRegistry()
+	return &controllerMetrics{
+		registry:      r,
+		propagated:    r.NewCounter("skycluster_controller_secrets_propagated_total", "Secrets applied to an xkube, by target cluster.", "target"),
+		failures:      r.NewCounter("skycluster_controller_propagation_failures_total", "Failed attempts to propagate the secrets to an xkube, by xkube.", "xkube"),
+		watchRestarts: r.NewCounter("skycluster_controller_watch_restarts_total", "Times the xkube watch ended and was established again."),
+		ready:         r.NewGauge("skycluster_controller_xkube_ready", "Whether an xkube is Ready, as of the last resync.", "xkube"),
+		synced:        r.NewGauge("skycluster_controller_xkube_synced", "Whether the last propagation to an xkube succeeded.", "xkube"),
+	}
+}
+
+// Metrics returns the registry of the metrics of c, to serve them or add
+// the metrics of the command running c.
+func (c *Controller) Metrics() *metrics.Registry {
+	return c.metrics.registry
+}
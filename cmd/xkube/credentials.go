@@ -0,0 +1,133 @@
+package xkube
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+// credentialProviders maps spec.providerRef.platform to its provider.
+var credentialProviders = map[string]skycluster.CredentialProvider{
+	"gcp":   gkeCredentials{},
+	"aws":   eksCredentials{},
+	"azure": aksCredentials{},
+}
+
+// gkeCredentials asks the GKE API for the cluster with the application
+// default credentials and falls back to gcloud container clusters
+// get-credentials, so that no SDK is needed where ADC is set up.
+type gkeCredentials struct{}
+
+func (gkeCredentials) AdminKubeconfig(ctx context.Context, obj *unstructured.Unstructured) ([]byte, error) {
+	clusterName, err := externalClusterName(obj)
+	if err != nil {
+		return nil, err
+	}
+	location, _, _ := unstructured.NestedString(obj.Object, "spec", "providerRef", "zones", "primary")
+	if location == "" {
+		return nil, fmt.Errorf("primary zone not set in providerRef.zones")
+	}
+	kubeconfig, err := gkeKubeconfig(ctx, clusterName, location)
+	if err == nil {
+		return kubeconfig, nil
+	}
+	if _, lookErr := exec.LookPath("gcloud"); lookErr != nil {
+		return nil, fmt.Errorf("GKE credentials for [%s]: %v (and gcloud is not installed)", obj.GetName(), err)
+	}
+	debugf("GKE API failed for [%s], falling back to gcloud: %v", obj.GetName(), err)
+	return kubeconfigFromCLI(ctx, obj.GetName(), func(path string) *exec.Cmd {
+		c := exec.CommandContext(ctx, "gcloud", "container", "clusters", "get-credentials", clusterName, "--location", location)
+		c.Env = append(os.Environ(), "KUBECONFIG="+path)
+		return c
+	})
+}
+
+// eksCredentials runs aws eks update-kubeconfig. The kubeconfig it writes
+// authenticates through "aws eks get-token", so the aws CLI must stay
+// available while it is used.
+type eksCredentials struct{}
+
+func (eksCredentials) AdminKubeconfig(ctx context.Context, obj *unstructured.Unstructured) ([]byte, error) {
+	clusterName, err := externalClusterName(obj)
+	if err != nil {
+		return nil, err
+	}
+	region, _, _ := unstructured.NestedString(obj.Object, "spec", "providerRef", "region")
+	if region == "" {
+		return nil, fmt.Errorf("region not set in providerRef")
+	}
+	return kubeconfigFromCLI(ctx, obj.GetName(), func(path string) *exec.Cmd {
+		return exec.CommandContext(ctx, "aws", "eks", "update-kubeconfig", "--name", clusterName, "--region", region, "--kubeconfig", path)
+	})
+}
+
+// aksCredentials runs az aks get-credentials, looking the resource group of
+// the cluster up first as the xkube does not record it.
+type aksCredentials struct{}
+
+func (aksCredentials) AdminKubeconfig(ctx context.Context, obj *unstructured.Unstructured) ([]byte, error) {
+	clusterName, err := externalClusterName(obj)
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.CommandContext(ctx, "az", "aks", "list", "--query", fmt.Sprintf("[?name=='%s'].resourceGroup", clusterName), "-o", "tsv").Output()
+	if err != nil {
+		return nil, fmt.Errorf("az failed to look up the resource group of cluster %s: %v", clusterName, cliError(err))
+	}
+	groups := strings.Fields(string(out))
+	switch len(groups) {
+	case 0:
+		return nil, fmt.Errorf("AKS cluster %s not found in the current az subscription", clusterName)
+	case 1:
+	default:
+		return nil, fmt.Errorf("AKS cluster %s exists in several resource groups: %s", clusterName, strings.Join(groups, ", "))
+	}
+	return kubeconfigFromCLI(ctx, obj.GetName(), func(path string) *exec.Cmd {
+		return exec.CommandContext(ctx, "az", "aks", "get-credentials", "--name", clusterName, "--resource-group", groups[0], "--file", path, "--overwrite-existing")
+	})
+}
+
+func externalClusterName(obj *unstructured.Unstructured) (string, error) {
+	name, _, _ := unstructured.NestedString(obj.Object, "status", "externalClusterName")
+	if name == "" {
+		return "", fmt.Errorf("status.externalClusterName not set for xkube %s", obj.GetName())
+	}
+	return name, nil
+}
+
+// kubeconfigFromCLI runs the command cmdFor builds to write a kubeconfig to a
+// temporary file and returns the file's content.
+func kubeconfigFromCLI(ctx context.Context, xkubeName string, cmdFor func(path string) *exec.Cmd) ([]byte, error) {
+	tmpFile, err := os.CreateTemp("", "xkube-kubeconfig-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary kubeconfig file for [%s]: %v", xkubeName, err)
+	}
+	tmpName := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpName)
+
+	c := cmdFor(tmpName)
+	debugf("running %s for [%s]", strings.Join(c.Args, " "), xkubeName)
+	if out, err := c.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s failed to get credentials for [%s]: %v\nOutput: %s", c.Args[0], xkubeName, err, string(out))
+	}
+	kubeconfigBytes, err := os.ReadFile(tmpName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig written by %s for [%s]: %v", c.Args[0], xkubeName, err)
+	}
+	return kubeconfigBytes, nil
+}
+
+// cliError adds the stderr of a failed command to err.
+func cliError(err error) error {
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package xkube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	proxyAddress string
+	proxyPort    int
+)
+
+func init() {
+	xKubeProxyCmd.Flags().StringVar(&proxyAddress, "address", "127.0.0.1", "Address to listen on")
+	xKubeProxyCmd.Flags().IntVarP(&proxyPort, "port", "p", 8001, "Port to listen on; 0 picks a free one")
+	xKubeCmd.AddCommand(xKubeExecCmd)
+	xKubeCmd.AddCommand(xKubeProxyCmd)
+}
+
+var xKubeExecCmd = &cobra.Command{
+	Use:   "exec [name] -- <command> [args...]",
+	Short: "Run a command with KUBECONFIG pointing at an xkube",
+	Long: `Fetch the kubeconfig of the xkube <name> the way 'xkube config' does, write
+it to a private temporary file and run the command with KUBECONFIG set to
+it, e.g.:
+
+  skycluster xkube exec gcp-us-east1 -- kubectl get pods -A
+
+Without <name> on a terminal, the xkube is picked from a list. The file is
+removed when the command exits, and its exit code is returned.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() == 0 && len(args) > 0 && utils.IsInteractive() {
+			return nil
+		}
+		if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+			return errors.New("expected <name> -- <command> [args...]")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var name string
+		if cmd.ArgsLenAtDash() == 0 {
+			picked, err := pickXKube(cmd.Context(), utils.SystemNamespace)
+			if err != nil {
+				return err
+			}
+			name = picked
+		} else {
+			name, args = args[0], args[1:]
+		}
+		kubeconfig, err := GetConfig(cmd.Context(), name, utils.SystemNamespace)
+		if err != nil {
+			return err
+		}
+		f, err := os.CreateTemp("", "xkube-"+name+"-*.yaml")
+		if err != nil {
+			return fmt.Errorf("creating temporary kubeconfig: %w", err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(kubeconfig); err != nil {
+			f.Close()
+			return fmt.Errorf("writing temporary kubeconfig: %w", err)
+		}
+		f.Close()
+
+		c := exec.Command(args[0], args[1:]...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		c.Env = append(os.Environ(), "KUBECONFIG="+f.Name())
+		debugf("running %v with KUBECONFIG=%s", args, f.Name())
+		// Interrupts reach the child through the terminal; keep running until it exits.
+		signal.Ignore(os.Interrupt)
+		err = c.Run()
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			os.Remove(f.Name())
+			os.Exit(ee.ExitCode())
+		}
+		return err
+	},
+}
+
+var xKubeProxyCmd = &cobra.Command{
+	Use:   "proxy [name]",
+	Short: "Serve the API of an xkube on a local port",
+	Long: `Fetch the kubeconfig of the xkube <name> and serve its API server on
+--address:--port without authentication, like kubectl proxy, e.g.:
+
+  skycluster xkube proxy gcp-us-east1 &
+  curl http://127.0.0.1:8001/api/v1/namespaces
+
+Anyone who can reach the port acts with the credentials of the kubeconfig, so
+keep --address on the loopback interface. The proxy runs until interrupted.
+Without <name> on a terminal, the xkube is picked from a list.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && utils.IsInteractive() {
+			return nil
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var name string
+		if len(args) == 0 {
+			picked, err := pickXKube(cmd.Context(), utils.SystemNamespace)
+			if err != nil {
+				return err
+			}
+			name = picked
+		} else {
+			name = args[0]
+		}
+		kubeconfig, err := GetConfig(cmd.Context(), name, utils.SystemNamespace)
+		if err != nil {
+			return err
+		}
+		restCfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+		if err != nil {
+			return fmt.Errorf("parsing kubeconfig of xkube %s: %w", name, err)
+		}
+		handler, err := apiProxy(restCfg)
+		if err != nil {
+			return err
+		}
+
+		ln, err := net.Listen("tcp", net.JoinHostPort(proxyAddress, fmt.Sprint(proxyPort)))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Serving xkube %s on http://%s\n", name, ln.Addr())
+
+		srv := &http.Server{Handler: handler, ReadHeaderTimeout: 30 * time.Second}
+		ctx := cmd.Context()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+// apiProxy returns a handler forwarding every request to the API server of
+// cfg, authenticated with its credentials.
+func apiProxy(cfg *rest.Config) (http.Handler, error) {
+	target, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, fmt.Errorf("parsing API server address %q: %w", cfg.Host, err)
+	}
+	if target.Scheme == "" {
+		target.Scheme = "https"
+	}
+	transport, err := rest.TransportFor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building transport: %w", err)
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.Host = target.Host
+			// Credentials come from the kubeconfig, never from the client.
+			r.Out.Header.Del("Authorization")
+			debugf("proxying %s %s", r.Out.Method, r.Out.URL.Path)
+		},
+		Transport:     transport,
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			fmt.Fprintf(os.Stderr, "warning: proxying %s %s: %v\n", r.Method, r.URL.Path, err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}, nil
+}
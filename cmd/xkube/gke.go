@@ -0,0 +1,245 @@
+package xkube
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	gkeAPI             = "https://container.googleapis.com/v1"
+	googleTokenURL     = "https://oauth2.googleapis.com/token"
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// gkeKubeconfig builds an admin kubeconfig for a GKE cluster from the GKE
+// API, authenticating with the application default credentials. The user
+// carries an access token, which is enough to set up the static access
+// xkube config hands out.
+func gkeKubeconfig(ctx context.Context, clusterName, location string) ([]byte, error) {
+	ts, project, err := googleCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p := viper.GetString("gcp.project"); p != "" {
+		project = p
+	}
+	if project == "" {
+		return nil, errors.New("no GCP project found; set gcp.project in the config")
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/%s/locations/%s/clusters/%s", gkeAPI, url.PathEscape(project), url.PathEscape(location), url.PathEscape(clusterName))
+	debugf("getting GKE cluster %s", endpoint)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := oauth2.NewClient(ctx, ts).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting GKE cluster %s: %w", clusterName, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting GKE cluster %s: %s: %s", clusterName, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var cluster struct {
+		Endpoint   string `json:"endpoint"`
+		MasterAuth struct {
+			ClusterCACertificate string `json:"clusterCaCertificate"`
+		} `json:"masterAuth"`
+	}
+	if err := json.Unmarshal(body, &cluster); err != nil {
+		return nil, fmt.Errorf("decoding GKE cluster %s: %w", clusterName, err)
+	}
+	if cluster.Endpoint == "" {
+		return nil, fmt.Errorf("GKE cluster %s has no endpoint yet", clusterName)
+	}
+	ca, err := base64.StdEncoding.DecodeString(cluster.MasterAuth.ClusterCACertificate)
+	if err != nil {
+		return nil, fmt.Errorf("decoding CA of GKE cluster %s: %w", clusterName, err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		return nil, fmt.Errorf("getting access token: %w", err)
+	}
+
+	name := fmt.Sprintf("gke_%s_%s_%s", project, location, clusterName)
+	cfg := clientcmdapi.NewConfig()
+	cfg.Clusters[name] = &clientcmdapi.Cluster{Server: "https://" + cluster.Endpoint, CertificateAuthorityData: ca}
+	cfg.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: tok.AccessToken}
+	cfg.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	cfg.CurrentContext = name
+	return clientcmd.Write(*cfg)
+}
+
+// gcloudConfigDir returns the configuration directory of gcloud:
+// $CLOUDSDK_CONFIG, or else %APPDATA%\gcloud on Windows and ~/.config/gcloud
+// elsewhere, macOS included.
+func gcloudConfigDir() string {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return dir
+	}
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud")
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud")
+}
+
+// googleCredentials finds the application default credentials the way the
+// Google SDKs do: the GOOGLE_APPLICATION_CREDENTIALS file, then the file
+// written by "gcloud auth application-default login", then the metadata
+// server. It returns their token source and the project they name, if any.
+func googleCredentials(ctx context.Context) (oauth2.TokenSource, string, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		if dir := gcloudConfigDir(); dir != "" {
+			path = filepath.Join(dir, "application_default_credentials.json")
+		}
+	}
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if project == "" {
+		project = os.Getenv("CLOUDSDK_CORE_PROJECT")
+	}
+
+	if b, err := os.ReadFile(path); err == nil {
+		debugf("using application default credentials from %s", path)
+		ts, fileProject, err := credentialsFromJSON(ctx, b)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", path, err)
+		}
+		if project == "" {
+			project = fileProject
+		}
+		return ts, project, nil
+	} else if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+		return nil, "", fmt.Errorf("reading GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+
+	md := metadataClient{host: "metadata.google.internal"}
+	if h := os.Getenv("GCE_METADATA_HOST"); h != "" {
+		md.host = h
+	}
+	mdProject, err := md.get(ctx, "project/project-id")
+	if err != nil {
+		return nil, "", fmt.Errorf("no application default credentials found and no metadata server: %w", err)
+	}
+	debugf("using the service account of the metadata server")
+	if project == "" {
+		project = mdProject
+	}
+	return oauth2.ReuseTokenSource(nil, md), project, nil
+}
+
+// credentialsFromJSON returns the token source of a service account key or
+// an authorized user credentials file.
+func credentialsFromJSON(ctx context.Context, b []byte) (oauth2.TokenSource, string, error) {
+	var f struct {
+		Type           string `json:"type"`
+		ProjectID      string `json:"project_id"`
+		QuotaProjectID string `json:"quota_project_id"`
+		ClientEmail    string `json:"client_email"`
+		PrivateKey     string `json:"private_key"`
+		PrivateKeyID   string `json:"private_key_id"`
+		TokenURI       string `json:"token_uri"`
+		ClientID       string `json:"client_id"`
+		ClientSecret   string `json:"client_secret"`
+		RefreshToken   string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, "", fmt.Errorf("decoding credentials: %w", err)
+	}
+	switch f.Type {
+	case "service_account":
+		cfg := &jwt.Config{
+			Email:        f.ClientEmail,
+			PrivateKey:   []byte(f.PrivateKey),
+			PrivateKeyID: f.PrivateKeyID,
+			Scopes:       []string{cloudPlatformScope},
+			TokenURL:     f.TokenURI,
+		}
+		if cfg.TokenURL == "" {
+			cfg.TokenURL = googleTokenURL
+		}
+		return cfg.TokenSource(ctx), f.ProjectID, nil
+	case "authorized_user":
+		cfg := &oauth2.Config{
+			ClientID:     f.ClientID,
+			ClientSecret: f.ClientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: googleTokenURL},
+			Scopes:       []string{cloudPlatformScope},
+		}
+		return cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: f.RefreshToken}), f.QuotaProjectID, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported credentials type %q", f.Type)
+	}
+}
+
+// metadataClient reads the GCE metadata server and serves the tokens of the
+// instance's service account.
+type metadataClient struct {
+	host string
+}
+
+func (m metadataClient) get(ctx context.Context, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+m.host+"/computeMetadata/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata %s: %s", path, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (m metadataClient) Token() (*oauth2.Token, error) {
+	body, err := m.get(context.Background(), "instance/service-accounts/default/token?scopes="+url.QueryEscape(cloudPlatformScope))
+	if err != nil {
+		return nil, err
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.Unmarshal([]byte(body), &t); err != nil {
+		return nil, fmt.Errorf("decoding metadata token: %w", err)
+	}
+	return &oauth2.Token{
+		AccessToken: t.AccessToken,
+		TokenType:   t.TokenType,
+		Expiry:      time.Now().Add(time.Duration(t.ExpiresIn) * time.Second),
+	}, nil
+}
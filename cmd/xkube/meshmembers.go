@@ -0,0 +1,63 @@
+package xkube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+var meshAddCmd = &cobra.Command{
+	Use:   "add <name>...",
+	Short: "Add xkubes to the mesh",
+	Long: `Add xkubes to spec.clusterNames of the mesh, leaving the other members as
+they are, and wait until the secrets are propagated to them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := updateMeshMembers(cmd.Context(), args, nil); err != nil {
+			return err
+		}
+		return waitForActivation(cmd.Context(), "")
+	},
+}
+
+var meshRemoveCmd = &cobra.Command{
+	Use:   "remove <name>...",
+	Short: "Remove xkubes from the mesh",
+	Long: `Remove xkubes from spec.clusterNames of the mesh, leaving the other
+members as they are. Use 'xkube mesh --disable' to remove the whole mesh.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateMeshMembers(cmd.Context(), nil, args)
+	},
+}
+
+// updateMeshMembers adds and removes names from spec.clusterNames of the
+// xkubemesh, retrying when the mesh changes concurrently.
+func updateMeshMembers(ctx context.Context, add, remove []string) error {
+	local, err := localClients()
+	if err != nil {
+		return err
+	}
+	for _, name := range add {
+		if _, err := utils.GetWithSuggestions(ctx, resource.XKube.Client(local.dynamicClient), resource.XKube.Name, name); err != nil {
+			return err
+		}
+	}
+	members, err := local.lib().UpdateMeshMembers(ctx, add, remove)
+	switch {
+	case errors.Is(err, skycluster.ErrMeshNotEnabled):
+		return fmt.Errorf("%w; run xkube mesh --enable first", err)
+	case errors.Is(err, skycluster.ErrMeshEmpty):
+		return fmt.Errorf("%w; use xkube mesh --disable instead", err)
+	case err != nil:
+		return err
+	}
+	fmt.Printf("updated xkubemesh/%s (clusterNames: %v)\n", skycluster.MeshName, members)
+	return nil
+}
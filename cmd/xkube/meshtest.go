@@ -0,0 +1,398 @@
+package xkube
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	meshTestNamespace = "skycluster-mesh-test"
+	meshTestPort      = 8080
+)
+
+var serviceExportGVR = schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: "v1alpha1", Resource: "serviceexports"}
+
+var (
+	meshTestTimeout     time.Duration
+	meshTestServerImage string
+	meshTestClientImage string
+	meshTestKeep        bool
+)
+
+func init() {
+	meshTestCmd.Flags().DurationVar(&meshTestTimeout, "timeout", 5*time.Minute, "Time to wait for the probe pods on each cluster")
+	meshTestCmd.Flags().StringVar(&meshTestServerImage, "server-image", "busybox:1.36", "Image of the probe servers; needs sh and httpd")
+	meshTestCmd.Flags().StringVar(&meshTestClientImage, "client-image", "curlimages/curl:8.10.1", "Image of the probe clients; needs sh and curl")
+	meshTestCmd.Flags().BoolVar(&meshTestKeep, "keep", false, "Keep the "+meshTestNamespace+" namespace on the clusters for debugging")
+	xkubeMeshCmd.AddCommand(meshTestCmd)
+}
+
+var meshTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Check pod-to-pod and service discovery connectivity between the mesh members",
+	Long: `Start a probe server on every member of the mesh, exported through
+lighthouse as mesh-probe-<xkube>, and a probe client on every member that
+reaches every other member both on the pod IP of its server and on its
+clusterset.local name. The latency of each pair is printed as a matrix, with
+the pod IP first and the service name second.
+
+The probes run in the ` + meshTestNamespace + ` namespace, which is removed at the
+end unless --keep is set. The command fails if any pair could not connect.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		members, err := meshMembers(ctx)
+		if err != nil {
+			return err
+		}
+		if len(members) < 2 {
+			return fmt.Errorf("the mesh needs at least two members to test, has %d", len(members))
+		}
+		cmd.SilenceUsage = true
+
+		probes := map[string]*meshProbe{}
+		defer func() {
+			if meshTestKeep {
+				return
+			}
+			for name, p := range probes {
+				if err := p.cs.CoreV1().Namespaces().Delete(context.Background(), meshTestNamespace, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+					fmt.Fprintf(os.Stderr, "warning: removing %s from %s: %v\n", meshTestNamespace, name, err)
+				}
+			}
+		}()
+
+		err = utils.RunWithSpinner("Starting probe servers", func() error {
+			for _, name := range members {
+				p, err := newMeshProbe(ctx, name)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				probes[name] = p
+				if err := p.startServer(ctx); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			for _, name := range members {
+				if err := probes[name].waitServer(ctx); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		results := map[string]map[string]probeResult{}
+		err = utils.RunWithSpinner("Probing the mesh", func() error {
+			for _, name := range members {
+				if err := probes[name].startClient(ctx, members, probes); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			for _, name := range members {
+				r, err := probes[name].clientResults(ctx)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				results[name] = r
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		failed := printMeshMatrix(members, probes, results)
+		if failed > 0 {
+			return fmt.Errorf("%d connection(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// meshMembers returns the xkubes listed in the XKubeMesh.
+func meshMembers(ctx context.Context) ([]string, error) {
+	dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+	if err != nil {
+		return nil, fmt.Errorf("creating dynamic client: %w", err)
+	}
+	mesh, err := resource.XKubeMesh.Client(dyn).Get(ctx, "xkube-cluster-mesh", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("the mesh is not enabled; run xkube mesh --enable first")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting xkubemesh: %w", err)
+	}
+	names, _, _ := unstructured.NestedStringSlice(mesh.Object, "spec", "clusterNames")
+	return names, nil
+}
+
+// meshProbe holds the clients and the probe state of one member.
+type meshProbe struct {
+	name  string
+	cs    *kubernetes.Clientset
+	dyn   dynamic.Interface
+	podIP string
+	// exported is false when the cluster has no ServiceExport CRD, i.e. no
+	// lighthouse; its service name is then not probed.
+	exported bool
+}
+
+// probeResult is the outcome of reaching one member from another.
+type probeResult struct {
+	pod, dns     time.Duration
+	podOK, dnsOK bool
+}
+
+func newMeshProbe(ctx context.Context, name string) (*meshProbe, error) {
+	kubeconfig, err := GetConfig(ctx, name, utils.SystemNamespace)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := utils.GetClientsetFromString(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := utils.GetDynamicClientFromString(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return &meshProbe{name: name, cs: cs, dyn: dyn}, nil
+}
+
+func probeServiceName(member string) string {
+	return "mesh-probe-" + member
+}
+
+func probeSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             ptr.To(true),
+		RunAsUser:                ptr.To(int64(65534)),
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+}
+
+// startServer creates the namespace, the probe server pod, its service and
+// the ServiceExport that makes lighthouse publish it.
+func (p *meshProbe) startServer(ctx context.Context) error {
+	labels := map[string]string{"skycluster.io/managed-by": "skycluster", "app": "mesh-probe"}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: meshTestNamespace, Labels: map[string]string{"skycluster.io/managed-by": "skycluster"}}}
+	if _, err := p.cs.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating namespace: %w", err)
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-probe-server", Namespace: meshTestNamespace, Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:            "server",
+				Image:           meshTestServerImage,
+				Command:         []string{"sh", "-c", fmt.Sprintf("mkdir -p /tmp/www && echo %s > /tmp/www/index.html && exec httpd -f -p %d -h /tmp/www", p.name, meshTestPort)},
+				Ports:           []corev1.ContainerPort{{ContainerPort: meshTestPort}},
+				SecurityContext: probeSecurityContext(),
+				ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+					TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(meshTestPort)},
+				}},
+			}},
+		},
+	}
+	if _, err := p.cs.CoreV1().Pods(meshTestNamespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating probe server: %w", err)
+	}
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: probeServiceName(p.name), Namespace: meshTestNamespace, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "mesh-probe"},
+			Ports:    []corev1.ServicePort{{Port: meshTestPort, TargetPort: intstr.FromInt32(meshTestPort)}},
+		},
+	}
+	if _, err := p.cs.CoreV1().Services(meshTestNamespace).Create(ctx, svc, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating probe service: %w", err)
+	}
+
+	export := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": serviceExportGVR.GroupVersion().String(),
+		"kind":       "ServiceExport",
+		"metadata":   map[string]interface{}{"name": svc.Name, "namespace": meshTestNamespace},
+	}}
+	_, err := p.dyn.Resource(serviceExportGVR).Namespace(meshTestNamespace).Create(ctx, export, metav1.CreateOptions{})
+	switch {
+	case err == nil || apierrors.IsAlreadyExists(err):
+		p.exported = true
+	case apierrors.IsNotFound(err):
+		fmt.Fprintf(os.Stderr, "warning: %s has no ServiceExport CRD; service discovery is not tested\n", p.name)
+	default:
+		return fmt.Errorf("exporting probe service: %w", err)
+	}
+	return nil
+}
+
+// waitServer waits for the probe server to be Ready and records its IP.
+func (p *meshProbe) waitServer(ctx context.Context) error {
+	return wait.PollUntilContextTimeout(ctx, 3*time.Second, meshTestTimeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := p.cs.CoreV1().Pods(meshTestNamespace).Get(ctx, "mesh-probe-server", metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue && pod.Status.PodIP != "" {
+				p.podIP = pod.Status.PodIP
+				debugf("probe server on %s ready at %s", p.name, p.podIP)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// startClient starts a pod that reaches every other member and prints one
+// RESULT line per check:
+//
+//	RESULT <member> <pod|dns> <curl exit code> <seconds>
+//
+// Lighthouse may take a while to publish a new export, so every check is
+// retried for up to half a minute.
+func (p *meshProbe) startClient(ctx context.Context, members []string, probes map[string]*meshProbe) error {
+	var script strings.Builder
+	script.WriteString("check() { for i in 1 2 3 4 5 6; do t=$(curl -s -o /dev/null -m 5 -w '%{time_total}' \"http://$3:" + strconv.Itoa(meshTestPort) + "/\"); rc=$?; [ $rc -eq 0 ] && break; sleep 5; done; echo \"RESULT $1 $2 $rc $t\"; }\n")
+	for _, m := range members {
+		if m == p.name {
+			continue
+		}
+		fmt.Fprintf(&script, "check %s pod %s\n", m, probes[m].podIP)
+		if probes[m].exported {
+			fmt.Fprintf(&script, "check %s dns %s.%s.svc.clusterset.local\n", m, probeServiceName(m), meshTestNamespace)
+		}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-probe-client", Namespace: meshTestNamespace, Labels: map[string]string{"skycluster.io/managed-by": "skycluster"}},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:            "client",
+				Image:           meshTestClientImage,
+				Command:         []string{"sh", "-c", script.String()},
+				SecurityContext: probeSecurityContext(),
+			}},
+		},
+	}
+	// A client left over by --keep would hold the results of an older run.
+	_ = p.cs.CoreV1().Pods(meshTestNamespace).Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: ptr.To(int64(0))})
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		_, err := p.cs.CoreV1().Pods(meshTestNamespace).Create(ctx, pod, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// clientResults waits for the client pod to finish and parses its output.
+func (p *meshProbe) clientResults(ctx context.Context) (map[string]probeResult, error) {
+	err := wait.PollUntilContextTimeout(ctx, 3*time.Second, meshTestTimeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := p.cs.CoreV1().Pods(meshTestNamespace).Get(ctx, "mesh-probe-client", metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("waiting for the probe client: %w", err)
+	}
+	logs, err := p.cs.CoreV1().Pods(meshTestNamespace).GetLogs("mesh-probe-client", &corev1.PodLogOptions{}).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading probe client logs: %w", err)
+	}
+
+	results := map[string]probeResult{}
+	scanner := bufio.NewScanner(strings.NewReader(string(logs)))
+	for scanner.Scan() {
+		f := strings.Fields(scanner.Text())
+		if len(f) < 4 || f[0] != "RESULT" {
+			continue
+		}
+		r := results[f[1]]
+		ok := f[3] == "0"
+		var took time.Duration
+		if len(f) > 4 {
+			if s, err := strconv.ParseFloat(f[4], 64); err == nil {
+				took = time.Duration(s * float64(time.Second))
+			}
+		}
+		switch f[2] {
+		case "pod":
+			r.podOK, r.pod = ok, took
+		case "dns":
+			r.dnsOK, r.dns = ok, took
+		}
+		results[f[1]] = r
+	}
+	return results, nil
+}
+
+// printMeshMatrix prints one row per source and one column per destination
+// and returns the number of failed checks.
+func printMeshMatrix(members []string, probes map[string]*meshProbe, results map[string]map[string]probeResult) int {
+	failed := 0
+	cell := func(ok bool, d time.Duration) string {
+		if !ok {
+			failed++
+			return "FAIL"
+		}
+		return d.Round(time.Millisecond).String()
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprint(writer, "FROM \\ TO")
+	for _, dst := range members {
+		fmt.Fprintf(writer, "\t%s", dst)
+	}
+	fmt.Fprintln(writer)
+	for _, src := range members {
+		fmt.Fprint(writer, src)
+		for _, dst := range members {
+			if src == dst {
+				fmt.Fprint(writer, "\t-")
+				continue
+			}
+			r, ok := results[src][dst]
+			if !ok {
+				failed++
+				fmt.Fprint(writer, "\tno result")
+				continue
+			}
+			dns := "n/a"
+			if probes[dst].exported {
+				dns = cell(r.dnsOK, r.dns)
+			}
+			fmt.Fprintf(writer, "\t%s / %s", cell(r.podOK, r.pod), dns)
+		}
+		fmt.Fprintln(writer)
+	}
+	writer.Flush()
+	return failed
+}
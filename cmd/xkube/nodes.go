@@ -0,0 +1,93 @@
+package xkube
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var nodeSelector string
+
+func init() {
+	xKubeNodesCmd.Flags().StringVarP(&nodeSelector, "selector", "l", "", "Label selector to filter the nodes, e.g. node-role.kubernetes.io/control-plane")
+	xKubeCmd.AddCommand(xKubeNodesCmd)
+}
+
+var xKubeNodesCmd = &cobra.Command{
+	Use:   "nodes <name>",
+	Short: "List the nodes of an xkube",
+	Long: `List the nodes of the xkube <name> with their status, instance type, zone
+and kubelet version. The kubeconfig is fetched the same way as 'xkube config'
+does, without writing it anywhere.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kubeconfig, err := GetConfig(cmd.Context(), args[0], utils.SystemNamespace)
+		if err != nil {
+			return err
+		}
+		cs, err := utils.GetClientsetFromString(kubeconfig)
+		if err != nil {
+			return fmt.Errorf("build clientset for xkube %s: %w", args[0], err)
+		}
+		nodes, err := cs.CoreV1().Nodes().List(cmd.Context(), metav1.ListOptions{LabelSelector: nodeSelector})
+		if err != nil {
+			return fmt.Errorf("listing nodes of xkube %s: %w", args[0], err)
+		}
+		if len(nodes.Items) == 0 {
+			fmt.Println("No nodes found.")
+			return nil
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tSTATUS\tINSTANCE TYPE\tZONE\tVERSION\tAGE")
+		for _, n := range nodes.Items {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				n.Name,
+				nodeStatus(&n),
+				nodeLabel(&n, corev1.LabelInstanceTypeStable, corev1.LabelInstanceType),
+				nodeLabel(&n, corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone),
+				n.Status.NodeInfo.KubeletVersion,
+				duration.HumanDuration(time.Since(n.CreationTimestamp.Time)),
+			)
+		}
+		return writer.Flush()
+	},
+}
+
+// nodeStatus renders the Ready condition the way kubectl get nodes does.
+func nodeStatus(n *corev1.Node) string {
+	status := "Unknown"
+	for _, c := range n.Status.Conditions {
+		if c.Type != corev1.NodeReady {
+			continue
+		}
+		if c.Status == corev1.ConditionTrue {
+			status = "Ready"
+		} else {
+			status = "NotReady"
+		}
+	}
+	if n.Spec.Unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return status
+}
+
+// nodeLabel returns the first of keys set on n, or "-".
+func nodeLabel(n *corev1.Node, keys ...string) string {
+	for _, k := range keys {
+		if v := strings.TrimSpace(n.Labels[k]); v != "" {
+			return v
+		}
+	}
+	return "-"
+}
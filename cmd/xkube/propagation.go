@@ -0,0 +1,73 @@
+package xkube
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PropagationRule selects the secrets on the management cluster that the
+// Controller copies to the mesh members. Rules are read from the
+// propagation.secrets config key.
+type PropagationRule struct {
+	// Selector is a label selector on the secrets.
+	Selector string `mapstructure:"selector"`
+	// Key names the data key holding the YAML of the secret to create on the
+	// members. When empty the secret itself is copied, keeping its namespace
+	// and name.
+	Key string `mapstructure:"key"`
+}
+
+// defaultPropagationRule fans out the remote CA secrets of the mesh.
+var defaultPropagationRule = PropagationRule{
+	Selector: "skycluster.io/secret-type=cluster-cacert",
+	Key:      "remote-secret.yaml",
+}
+
+func (r PropagationRule) String() string {
+	if r.Key == "" {
+		return r.Selector
+	}
+	return r.Selector + ":" + r.Key
+}
+
+func (r PropagationRule) validate() error {
+	if strings.TrimSpace(r.Selector) == "" {
+		return fmt.Errorf("empty selector")
+	}
+	if _, err := labels.Parse(r.Selector); err != nil {
+		return fmt.Errorf("invalid selector %q: %w", r.Selector, err)
+	}
+	return nil
+}
+
+// ParsePropagationRule parses a rule given as <selector>[:<key>]; label
+// selectors never contain a colon.
+func ParsePropagationRule(s string) (PropagationRule, error) {
+	sel, key, _ := strings.Cut(s, ":")
+	r := PropagationRule{Selector: strings.TrimSpace(sel), Key: strings.TrimSpace(key)}
+	if err := r.validate(); err != nil {
+		return PropagationRule{}, err
+	}
+	return r, nil
+}
+
+// PropagationRules returns the rules of the propagation.secrets config key,
+// or only the remote CA secrets rule when the key is not set.
+func PropagationRules() ([]PropagationRule, error) {
+	var rules []PropagationRule
+	if err := viper.UnmarshalKey("propagation.secrets", &rules); err != nil {
+		return nil, fmt.Errorf("invalid propagation.secrets config: %w", err)
+	}
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("propagation.secrets[%d]: %w", i, err)
+		}
+	}
+	if len(rules) == 0 {
+		rules = []PropagationRule{defaultPropagationRule}
+	}
+	return rules, nil
+}
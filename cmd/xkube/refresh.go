@@ -0,0 +1,145 @@
+package xkube
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+const expiryAnnoKey = skycluster.AnnotationExpiry
+
+var (
+	refreshWatch    bool
+	refreshBefore   time.Duration
+	refreshInterval time.Duration
+)
+
+func init() {
+	configRefreshCmd.Flags().BoolVarP(&refreshWatch, "watch", "w", false, "Keep running and renew the kubeconfigs before they expire")
+	configRefreshCmd.Flags().DurationVar(&refreshBefore, "before", 2*time.Hour, "Renew kubeconfigs expiring within this duration")
+	configRefreshCmd.Flags().DurationVar(&refreshInterval, "interval", 10*time.Minute, "With --watch, the longest time between two checks")
+	configShowCmd.AddCommand(configRefreshCmd)
+}
+
+var configRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Renew the static kubeconfigs of the xkubes before their tokens expire",
+	Long: `Renew the static kubeconfigs 'xkube config' caches on the management cluster.
+
+Every cached kubeconfig expiring within --before gets a new token through the
+TokenRequest API of its xkube, and its secret is updated. With --out, the
+kubeconfigs are also written to that file, e.g. the one exported earlier by
+'xkube config'. --xkube limits the renewal to the given xkubes.
+
+With --watch the command keeps running, checking again when the next
+kubeconfig is due and at least every --interval, until interrupted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if refreshBefore <= 0 || refreshInterval <= 0 {
+			return fmt.Errorf("--before and --interval must be positive")
+		}
+		if outPath != "" {
+			if err := utils.MkdirAndPreflight(outPath); err != nil {
+				return fmt.Errorf("cannot write kubeconfig to %s: %w", outPath, err)
+			}
+		}
+		ctx := cmd.Context()
+
+		for {
+			next, err := refreshOnce(ctx)
+			if !refreshWatch {
+				return err
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+			wait := refreshInterval
+			if !next.IsZero() {
+				wait = min(wait, max(time.Until(next.Add(-refreshBefore)), time.Minute))
+			}
+			debugf("next check in %s", wait.Round(time.Second))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+		}
+	},
+}
+
+// refreshOnce renews the static kubeconfigs that are due and rewrites outPath
+// when set. It returns the earliest expiry left, zero when there is none.
+func refreshOnce(ctx context.Context) (time.Time, error) {
+	local, err := localClients()
+	if err != nil {
+		return time.Time{}, err
+	}
+	secrets, err := local.clientSet.CoreV1().Secrets(staticAccessNS).List(ctx, metav1.ListOptions{LabelSelector: staticSecretLabel})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("listing static kubeconfigs: %w", err)
+	}
+
+	var next time.Time
+	var kubeconfigs []string
+	renewed, failed := 0, 0
+	for _, s := range secrets.Items {
+		id := s.Labels[staticClusterIDKey]
+		if id == "" || len(kubeNames) > 0 && !slices.Contains(kubeNames, id) {
+			continue
+		}
+		kubeconfig := string(withTLSOverrides(ctx, *local, id, s.Data["kubeconfig"]))
+		expiry, err := time.Parse(time.RFC3339, s.Annotations[expiryAnnoKey])
+		if err != nil || time.Until(expiry) < refreshBefore {
+			if kubeconfig, expiry, err = renewStaticKubeconfig(ctx, *local, id); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: renewing %s: %v\n", id, err)
+				failed++
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Renewed kubeconfig of %s, valid until %s\n", id, expiry.Local().Format(time.RFC1123))
+			renewed++
+		}
+		if next.IsZero() || expiry.Before(next) {
+			next = expiry
+		}
+		kubeconfigs = append(kubeconfigs, kubeconfig)
+	}
+	debugf("renewed %d static kubeconfig(s), %d failed", renewed, failed)
+
+	if outPath != "" && renewed > 0 {
+		merged, err := mergeKubeconfigs(kubeconfigs)
+		if err != nil {
+			return next, fmt.Errorf("merging kubeconfigs: %w", err)
+		}
+		if err := utils.WriteFileAtomic(outPath, merged, 0o600); err != nil {
+			return next, fmt.Errorf("writing kubeconfig to %s: %w", outPath, err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote kubeconfig to %s\n", outPath)
+	}
+	if failed > 0 {
+		return next, fmt.Errorf("%d kubeconfig(s) could not be renewed", failed)
+	}
+	return next, nil
+}
+
+// renewStaticKubeconfig mints a new token for the static ServiceAccount of the
+// xkube id and stores the new kubeconfig in its secret.
+func renewStaticKubeconfig(ctx context.Context, local clientSets, id string) (string, time.Time, error) {
+	obj, err := resource.XKube.Client(local.dynamicClient).Get(ctx, id, metav1.GetOptions{})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("getting xkube: %w", err)
+	}
+	lib := local.lib()
+	admin, err := lib.AdminKubeconfig(ctx, obj)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return lib.IssueStaticKubeconfig(ctx, admin, id)
+}
@@ -0,0 +1,203 @@
+package xkube
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+const (
+	// viewerClusterRole aggregates the built-in read-only "view" rules (which
+	// exclude secrets) with read access to the cluster-scoped resources that
+	// "view" leaves out.
+	viewerClusterRole      = "skycluster-viewer"
+	viewerAggregateLabel   = "skycluster.io/aggregate-to-viewer"
+	viewerClusterExtraRole = "skycluster-viewer-cluster"
+	shareNamespace         = utils.SystemNamespace
+)
+
+var (
+	shareClusters []string
+	shareRole     string
+	shareTTL      time.Duration
+)
+
+func init() {
+	configShareCmd.Flags().StringSliceVar(&shareClusters, "clusters", nil, "XKube names to include, separated by comma (defaults to --xkube)")
+	configShareCmd.Flags().StringVar(&shareRole, "role", "viewer", "Access granted to the shared kubeconfig (viewer)")
+	configShareCmd.Flags().DurationVar(&shareTTL, "ttl", 8*time.Hour, "Lifetime of the minted tokens")
+	configShowCmd.AddCommand(configShareCmd)
+}
+
+var configShareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Write a read-only, short-lived kubeconfig for the selected xkubes to hand to a teammate",
+	Long: `Write a read-only, short-lived kubeconfig for the selected xkubes.
+
+On every cluster a dedicated ServiceAccount is bound to the skycluster-viewer
+ClusterRole (read access without secrets), and a token valid for --ttl is
+minted for it. Unlike 'xkube config', the tokens are not stored on the
+management cluster and never carry cluster-admin rights.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if shareRole != "viewer" {
+			return fmt.Errorf("unsupported --role %q (only viewer is available)", shareRole)
+		}
+		if shareTTL < 10*time.Minute {
+			return fmt.Errorf("--ttl must be at least 10m, got %s", shareTTL)
+		}
+		names := shareClusters
+		if len(names) == 0 {
+			names = kubeNames
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no xkubes selected; use --clusters")
+		}
+		if outPath == "" {
+			return fmt.Errorf("flag --out is required")
+		}
+		if err := utils.MkdirAndPreflight(outPath); err != nil {
+			return fmt.Errorf("cannot write kubeconfig to %s: %w", outPath, err)
+		}
+
+		var kubeconfigs []string
+		var expiry time.Time
+		err := utils.RunWithSpinner("Minting viewer tokens", func() error {
+			for _, name := range names {
+				kc, exp, err := shareKubeconfig(cmd.Context(), name)
+				if err != nil {
+					return fmt.Errorf("xkube %s: %w", name, err)
+				}
+				kubeconfigs = append(kubeconfigs, kc)
+				if expiry.IsZero() || exp.Before(expiry) {
+					expiry = exp
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		merged, err := mergeKubeconfigs(kubeconfigs)
+		if err != nil {
+			return fmt.Errorf("merging kubeconfigs: %w", err)
+		}
+		if err := utils.WriteFileAtomic(outPath, merged, 0o600); err != nil {
+			return fmt.Errorf("writing kubeconfig to %s: %w", outPath, err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s kubeconfig for %d xkube(s) to %s, valid until %s\n",
+			shareRole, len(kubeconfigs), outPath, expiry.Local().Format(time.RFC1123))
+		return nil
+	},
+}
+
+// shareKubeconfig mints a viewer token on the xkube and returns a kubeconfig
+// using it, together with the token expiry.
+func shareKubeconfig(ctx context.Context, name string) (string, time.Time, error) {
+	adminKubeconfig, err := GetConfig(ctx, name, shareNamespace)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	parsed, err := clientcmd.Load([]byte(adminKubeconfig))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	cluster, err := skycluster.CurrentCluster(parsed)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig([]byte(adminKubeconfig))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("building rest config: %w", err)
+	}
+	cs, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("creating kubernetes client: %w", err)
+	}
+
+	saName := "skycluster-share-" + shareRole
+	if err := ensureViewerAccess(ctx, cs, saName); err != nil {
+		return "", time.Time{}, err
+	}
+	tr, err := cs.CoreV1().ServiceAccounts(shareNamespace).CreateToken(ctx, saName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: ptr.To(int64(shareTTL.Seconds()))},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("creating service account token: %w", err)
+	}
+	debugf("minted %s token for xkube %s, expires %s", shareRole, name, tr.Status.ExpirationTimestamp)
+	recordIssued(name, shareRole, tr.Status.ExpirationTimestamp.Time)
+
+	out, err := skycluster.TokenKubeconfig(cluster, name+"-"+shareRole, []byte(tr.Status.Token))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return string(out), tr.Status.ExpirationTimestamp.Time, nil
+}
+
+// ensureViewerAccess creates the viewer ClusterRoles, the ServiceAccount and
+// its ClusterRoleBinding on the remote cluster when they are missing.
+func ensureViewerAccess(ctx context.Context, cs *kubernetes.Clientset, saName string) error {
+	labels := map[string]string{"skycluster.io/managed-by": "skycluster"}
+	extraLabels := map[string]string{"skycluster.io/managed-by": "skycluster", viewerAggregateLabel: "true"}
+
+	if _, err := cs.CoreV1().Namespaces().Get(ctx, shareNamespace, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: shareNamespace}}
+		if _, err := cs.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("creating namespace %s: %w", shareNamespace, err)
+		}
+	}
+
+	roles := []*rbacv1.ClusterRole{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: viewerClusterRole, Labels: labels},
+			AggregationRule: &rbacv1.AggregationRule{
+				ClusterRoleSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"rbac.authorization.k8s.io/aggregate-to-view": "true"}},
+					{MatchLabels: map[string]string{viewerAggregateLabel: "true"}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: viewerClusterExtraRole, Labels: extraLabels},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"nodes", "namespaces", "persistentvolumes"}, Verbs: []string{"get", "list", "watch"}},
+				{APIGroups: []string{"storage.k8s.io"}, Resources: []string{"storageclasses"}, Verbs: []string{"get", "list", "watch"}},
+			},
+		},
+	}
+	for _, r := range roles {
+		if _, err := cs.RbacV1().ClusterRoles().Create(ctx, r, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("creating clusterrole %s: %w", r.Name, err)
+		}
+	}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: saName, Namespace: shareNamespace, Labels: labels}}
+	if _, err := cs.CoreV1().ServiceAccounts(shareNamespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating serviceaccount %s/%s: %w", shareNamespace, saName, err)
+	}
+
+	crb := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: saName, Labels: labels},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: saName, Namespace: shareNamespace}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: viewerClusterRole},
+	}
+	if _, err := cs.RbacV1().ClusterRoleBindings().Create(ctx, crb, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating clusterrolebinding %s: %w", saName, err)
+	}
+	return nil
+}
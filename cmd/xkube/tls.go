@@ -0,0 +1,169 @@
+package xkube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	utils "github.com/etesami/skycluster-cli/internal/utils"
+	"github.com/etesami/skycluster-cli/pkg/skycluster"
+)
+
+var (
+	tlsCAFile     string
+	tlsInsecure   bool
+	tlsServerName string
+	tlsClear      bool
+)
+
+func init() {
+	xKubeTLSCmd.Flags().StringVar(&tlsCAFile, "ca-file", "", "PEM bundle of the CA that signed the API server certificate of the xkube")
+	xKubeTLSCmd.Flags().BoolVar(&tlsInsecure, "insecure-skip-tls-verify", false, "Do not verify the API server certificate of the xkube")
+	xKubeTLSCmd.Flags().StringVar(&tlsServerName, "tls-server-name", "", "Name to verify the API server certificate against, instead of the host of its URL")
+	xKubeTLSCmd.Flags().BoolVar(&tlsClear, "clear", false, "Remove the TLS overrides of the xkube")
+	xKubeTLSCmd.MarkFlagsMutuallyExclusive("ca-file", "insecure-skip-tls-verify", "clear")
+	xKubeTLSCmd.MarkFlagsMutuallyExclusive("tls-server-name", "clear")
+	xKubeCmd.AddCommand(xKubeTLSCmd)
+}
+
+var xKubeTLSCmd = &cobra.Command{
+	Use:   "tls <name>",
+	Short: "Show or set how the API server certificate of an xkube is verified",
+	Long: `Show or set the TLS overrides of the xkube <name>, for clusters whose API
+server uses a private CA missing from the kubeconfig of the cloud:
+
+  skycluster xkube tls on-prem --ca-file ~/pki/onprem-ca.pem
+  skycluster xkube tls lab --insecure-skip-tls-verify
+  skycluster xkube tls edge --ca-file ca.pem --tls-server-name api.edge.local
+  skycluster xkube tls on-prem --clear
+
+The overrides are stored in the static kubeconfig secret of the xkube in
+skycluster-system, the CA bundle itself included, and are applied whenever
+the CLI builds a kubeconfig of the xkube, e.g. by 'xkube config', the secret
+propagation of 'xkube mesh --enable' and 'controller run', and the deploy
+commands. An xkube without a static kubeconfig yet gets one issued with the
+overrides. Without flags, the current overrides are shown.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		local, err := localClients()
+		if err != nil {
+			return err
+		}
+		obj, err := utils.GetWithSuggestions(ctx, resource.XKube.Client(local.dynamicClient), resource.XKube.Name, args[0])
+		if err != nil {
+			return err
+		}
+		id := obj.GetName()
+
+		if !cmd.Flags().Changed("ca-file") && !tlsInsecure && tlsServerName == "" && !tlsClear {
+			o, err := staticTLSOverrides(ctx, *local, id)
+			if err != nil {
+				return err
+			}
+			switch {
+			case o.Insecure:
+				fmt.Printf("xkube %s: certificate not verified\n", id)
+			case len(o.CA) > 0:
+				fmt.Printf("xkube %s: CA bundle of %s\n", id, strings.Join(o.CASubjects(), "; "))
+			case o.ServerName == "":
+				fmt.Printf("xkube %s: no TLS overrides\n", id)
+			}
+			if o.ServerName != "" {
+				fmt.Printf("xkube %s: certificate verified against %s\n", id, o.ServerName)
+			}
+			return nil
+		}
+
+		o := utils.TLSOverrides{Insecure: tlsInsecure, ServerName: tlsServerName}
+		if tlsCAFile != "" {
+			if o.CA, err = utils.ReadCA(resource.ExpandPath(tlsCAFile)); err != nil {
+				return err
+			}
+		} else if cmd.Flags().Changed("ca-file") {
+			return errors.New("--ca-file is empty; use --clear to remove the overrides")
+		}
+		if err := setStaticTLSOverrides(ctx, *local, obj, o); err != nil {
+			return err
+		}
+		if o.IsZero() {
+			fmt.Printf("Removed the TLS overrides of xkube %s\n", id)
+		} else {
+			fmt.Printf("Set the TLS overrides of xkube %s\n", id)
+		}
+		return nil
+	},
+}
+
+// staticTLSOverrides returns the TLS overrides stored on the static
+// kubeconfig secret of the xkube id, if any.
+func staticTLSOverrides(ctx context.Context, local clientSets, id string) (utils.TLSOverrides, error) {
+	secret, err := local.clientSet.CoreV1().Secrets(staticAccessNS).Get(ctx, skycluster.StaticSecretName(id), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return utils.TLSOverrides{}, nil
+	}
+	if err != nil {
+		return utils.TLSOverrides{}, fmt.Errorf("reading static kubeconfig secret of %s: %w", id, err)
+	}
+	return utils.TLSOverridesFrom(secret), nil
+}
+
+// setStaticTLSOverrides stores o on the static kubeconfig secret of the xkube
+// obj, replacing the previous overrides. Without a secret yet, a static
+// kubeconfig is issued with o, reaching the xkube through its admin
+// kubeconfig, so that the secret is never left without one.
+func setStaticTLSOverrides(ctx context.Context, local clientSets, obj *unstructured.Unstructured, o utils.TLSOverrides) error {
+	id := obj.GetName()
+	secrets := local.clientSet.CoreV1().Secrets(staticAccessNS)
+	secretName := skycluster.StaticSecretName(id)
+	err := utils.RetryOnConflict(ctx, func(ctx context.Context) error {
+		secret, err := secrets.Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		o.Store(secret)
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+	if !apierrors.IsNotFound(err) {
+		if err != nil {
+			return fmt.Errorf("updating secret %s/%s: %w", staticAccessNS, secretName, err)
+		}
+		return nil
+	}
+	if o.IsZero() {
+		return nil
+	}
+	lib := local.lib()
+	admin, err := lib.AdminKubeconfig(ctx, obj)
+	if err != nil {
+		return err
+	}
+	tls := skycluster.TLSOptions{Insecure: o.Insecure, CAData: o.CA, ServerName: o.ServerName}
+	if _, _, err := lib.IssueStaticKubeconfigWithTLS(ctx, admin, id, tls); err != nil {
+		return fmt.Errorf("issuing a static kubeconfig of %s with the overrides: %w", id, err)
+	}
+	return nil
+}
+
+// withTLSOverrides returns kubeconfig of the xkube id with its TLS overrides
+// applied. Failing that, kubeconfig is returned as it is.
+func withTLSOverrides(ctx context.Context, local clientSets, id string, kubeconfig []byte) []byte {
+	o, err := staticTLSOverrides(ctx, local, id)
+	if err == nil {
+		var out []byte
+		if out, err = o.Apply(kubeconfig); err == nil {
+			return out
+		}
+	}
+	fmt.Fprintf(os.Stderr, "warning: TLS overrides of %s not applied: %v\n", id, err)
+	return kubeconfig
+}
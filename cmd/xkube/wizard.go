@@ -0,0 +1,70 @@
+package xkube
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/cmd/subnet"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+func init() {
+	resource.WithWizard(xKubeCreateCmd, resource.XKube, createWizard)
+}
+
+// createWizard asks for the provider and the pod and service CIDRs,
+// suggesting the ranges the subnet calculator gives for the VPC of the
+// XProvider in that region.
+func createWizard(ctx context.Context, dyn dynamic.Interface) (map[string]interface{}, string, error) {
+	ref, _, err := resource.PickProviderRef(ctx, dyn)
+	if err != nil {
+		return nil, "", err
+	}
+	platform, region := ref["platform"].(string), ref["region"].(string)
+
+	providers, err := resource.XProvider.List(ctx, dyn)
+	if err != nil {
+		return nil, "", fmt.Errorf("listing xproviders: %w", err)
+	}
+	var vpc string
+	var used []string
+	for _, p := range providers {
+		c, _, _ := unstructured.NestedString(p.Object, "spec", "vpcCidr")
+		p1, _, _ := unstructured.NestedString(p.Object, "spec", "providerRef", "platform")
+		r1, _, _ := unstructured.NestedString(p.Object, "spec", "providerRef", "region")
+		if vpc == "" && p1 == platform && r1 == region {
+			vpc = c
+		}
+		used = append(used, c)
+	}
+	if vpc == "" {
+		vpc = subnet.NextFree(used)
+		fmt.Fprintf(os.Stderr, "warning: no XProvider found in %s %s; the XKube needs one. Suggesting ranges for VPC %s\n", platform, region, vpc)
+	} else {
+		debugf("suggesting ranges for the VPC %s of the XProvider in %s %s", vpc, platform, region)
+	}
+	r, err := subnet.Suggest(platform, vpc)
+	if err != nil {
+		return nil, "", fmt.Errorf("suggesting ranges for VPC %q: %w", vpc, err)
+	}
+	podCidr, err := utils.Ask("Pod CIDR", r.Pods)
+	if err != nil {
+		return nil, "", err
+	}
+	serviceCidr, err := utils.Ask("Service CIDR", r.Services)
+	if err != nil {
+		return nil, "", err
+	}
+
+	spec := map[string]interface{}{
+		"providerRef": ref,
+		"podCidr":     podCidr,
+		"serviceCidr": serviceCidr,
+	}
+	return spec, fmt.Sprintf("%s-%s", platform, region), nil
+}
@@ -2,14 +2,14 @@ package xkube
 
 import (
 	"github.com/spf13/cobra"
-)
 
-var debug bool
+	"github.com/etesami/skycluster-cli/internal/resource"
+)
 
 func init() {
 	xKubeCmd.AddCommand(xKubeCreateCmd)
-	xKubeCmd.AddCommand(xKubeDeleteCmd)
-	xKubeCmd.AddCommand(xKubeListCmd)
+	xKubeCmd.AddCommand(resource.NewDeleteCmd(resource.XKube, PruneBeforeDelete))
+	xKubeCmd.AddCommand(resource.NewListCmd(resource.XKube))
 	xKubeCmd.AddCommand(configShowCmd)
 	xKubeCmd.AddCommand(xkubeMeshCmd)
 }
@@ -29,7 +29,3 @@ func GetXKubeCmd() *cobra.Command {
 	return xKubeCmd
 }
 
-// SetDebug sets package-level debug flag after CLI flags are parsed.
-func SetDebug(d bool) {
-	debug = d
-}
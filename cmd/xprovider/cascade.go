@@ -0,0 +1,283 @@
+package xprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	xk "github.com/etesami/skycluster-cli/cmd/xkube"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	cascade        bool
+	cascadeTimeout time.Duration
+)
+
+// newDeleteCmd is the generic delete command with --cascade, which also
+// deletes the XKubes and XInstances of the XProviders, children first.
+func newDeleteCmd() *cobra.Command {
+	cmd := resource.NewDeleteCmd(resource.XProvider, nil)
+	cmd.Long += `
+
+With --cascade, the XKubes and XInstances whose providerRef has the
+platform, region and primary zone of the XProviders are deleted too; when
+that does not single out one XProvider, nothing is deleted. The whole tree
+is shown before confirming, and the access of the CLI is pruned from the
+XKubes first: if that fails for any of them, nothing is deleted. The
+XProviders are only deleted once their children are gone.`
+	plain := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if !cascade {
+			return plain(cmd, args)
+		}
+		names, _ := cmd.Flags().GetStringSlice(resource.XProvider.DeleteFlag)
+		names = append(names, args...)
+		selector, _ := cmd.Flags().GetString("selector")
+		all, _ := cmd.Flags().GetBool("all")
+		if len(names) > 0 && (selector != "" || all) {
+			return fmt.Errorf("names cannot be combined with --selector or --all")
+		}
+		if len(names) == 0 && selector == "" && !all {
+			return cmd.Help()
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		providers, err := selectProviders(cmd.Context(), dyn, names, selector)
+		if err != nil {
+			return err
+		}
+		return cascadeDelete(cmd.Context(), dyn, providers)
+	}
+	cmd.Flags().BoolVar(&cascade, "cascade", false, "Also delete the XKubes and XInstances of the XProviders, before them")
+	cmd.Flags().DurationVar(&cascadeTimeout, "timeout", 30*time.Minute, "How long to wait for the dependents to be removed with --cascade")
+	return cmd
+}
+
+func selectProviders(ctx context.Context, dyn dynamic.Interface, names []string, selector string) ([]unstructured.Unstructured, error) {
+	ri := resource.XProvider.Client(dyn)
+	if len(names) == 0 {
+		list, err := ri.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("listing xproviders: %w", err)
+		}
+		return list.Items, nil
+	}
+	var items []unstructured.Unstructured
+	for _, n := range names {
+		obj, err := utils.GetWithSuggestions(ctx, ri, resource.XProvider.Name, n)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *obj)
+	}
+	return items, nil
+}
+
+// dependent is an XKube or XInstance referring to an XProvider.
+type dependent struct {
+	t   *resource.Type
+	obj unstructured.Unstructured
+}
+
+func (d dependent) String() string {
+	return d.t.Name + "/" + d.obj.GetName()
+}
+
+// dependents returns the XKubes and XInstances of each provider: those
+// whose providerRef has the platform, region and primary zone of exactly one
+// XProvider of the cluster. A dependent that may belong to one of providers
+// but does not match a single XProvider exactly, e.g. has no primary zone or
+// shares its providerRef with several XProviders, fails the cascade rather
+// than risk deleting the resources of another XProvider.
+func dependents(ctx context.Context, dyn dynamic.Interface, providers []unstructured.Unstructured) (map[string][]dependent, error) {
+	all, err := resource.XProvider.List(ctx, dyn)
+	if err != nil {
+		return nil, fmt.Errorf("listing xproviders: %w", err)
+	}
+	selected := map[string]bool{}
+	for _, p := range providers {
+		selected[p.GetName()] = true
+	}
+	deps := map[string][]dependent{}
+	var ambiguous []string
+	for _, t := range []*resource.Type{resource.XKube, resource.XInstance} {
+		items, err := t.List(ctx, dyn)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", t.Plural(), err)
+		}
+		for _, it := range items {
+			d := dependent{t: t, obj: it}
+			var exact []string
+			loose := false
+			for i := range all {
+				switch providerMatch(&all[i], &it) {
+				case matchExact:
+					exact = append(exact, all[i].GetName())
+				case matchLoose:
+					loose = loose || selected[all[i].GetName()]
+				}
+			}
+			switch {
+			case len(exact) == 1 && selected[exact[0]]:
+				debugf("%s %s refers to xprovider %s", t.Kind, it.GetName(), exact[0])
+				deps[exact[0]] = append(deps[exact[0]], d)
+			case len(exact) > 1 && slices.ContainsFunc(exact, func(n string) bool { return selected[n] }):
+				ambiguous = append(ambiguous, fmt.Sprintf("%s (matches xproviders %s)", d, strings.Join(exact, ", ")))
+			case len(exact) == 0 && loose:
+				ambiguous = append(ambiguous, fmt.Sprintf("%s (providerRef without a matching primary zone)", d))
+			}
+		}
+	}
+	if len(ambiguous) > 0 {
+		return nil, fmt.Errorf("cannot tell which xprovider these resources belong to, delete them first or without --cascade: %s", strings.Join(ambiguous, "; "))
+	}
+	return deps, nil
+}
+
+// How the providerRef of a dependent matches the one of an XProvider.
+const (
+	matchNone = iota
+	// matchLoose is the same platform and region with the primary zone
+	// missing on one side.
+	matchLoose
+	// matchExact is the same platform, region and primary zone.
+	matchExact
+)
+
+// providerMatch compares the providerRefs of provider p and dependent d.
+func providerMatch(p, d *unstructured.Unstructured) int {
+	ref := func(obj *unstructured.Unstructured, path ...string) string {
+		v, _, _ := unstructured.NestedString(obj.Object, append([]string{"spec", "providerRef"}, path...)...)
+		return v
+	}
+	if ref(p, "platform") == "" || ref(p, "platform") != ref(d, "platform") || ref(p, "region") != ref(d, "region") {
+		return matchNone
+	}
+	zp, zd := ref(p, "zones", "primary"), ref(d, "zones", "primary")
+	switch {
+	case zp == zd:
+		return matchExact
+	case zp == "" || zd == "":
+		return matchLoose
+	}
+	return matchNone
+}
+
+// cascadeDelete shows providers with their dependents and, once confirmed,
+// deletes the dependents, waits for them to be removed and then deletes
+// providers.
+func cascadeDelete(ctx context.Context, dyn dynamic.Interface, providers []unstructured.Unstructured) error {
+	if len(providers) == 0 {
+		fmt.Println("No xproviders found.")
+		return nil
+	}
+	deps, err := dependents(ctx, dyn, providers)
+	if err != nil {
+		return err
+	}
+	var children []dependent
+	for _, p := range providers {
+		fmt.Printf("xprovider/%s\n", p.GetName())
+		ds := deps[p.GetName()]
+		for i, d := range ds {
+			branch := "├── "
+			if i == len(ds)-1 {
+				branch = "└── "
+			}
+			fmt.Printf("%s%s\n", branch, d)
+		}
+		children = append(children, ds...)
+	}
+	ok, err := resource.AskDelete(os.Stdin, os.Stdout, "resources", len(providers)+len(children))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Deletion cancelled.")
+		return nil
+	}
+
+	// Prune every xkube first, so that a failure leaves the tree whole.
+	var pruneErrs []error
+	for _, d := range children {
+		if d.t == resource.XKube {
+			if err := xk.PruneAccess(ctx, &d.obj); err != nil {
+				pruneErrs = append(pruneErrs, err)
+			}
+		}
+	}
+	if len(pruneErrs) > 0 {
+		return fmt.Errorf("nothing deleted: %w", errors.Join(pruneErrs...))
+	}
+	for _, d := range children {
+		debugf("deleting %s", d)
+		err := utils.RetryDelete(ctx, d.t.Client(dyn), d.obj.GetName(), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting %s: %w", d, err)
+		}
+		fmt.Printf("Deleting %s...\n", d)
+	}
+	if err := waitRemoved(ctx, dyn, children); err != nil {
+		return err
+	}
+
+	ri := resource.XProvider.Client(dyn)
+	for _, p := range providers {
+		if err := utils.RetryDelete(ctx, ri, p.GetName(), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting xprovider %s: %w", p.GetName(), err)
+		}
+		fmt.Printf("Deleted xprovider/%s\n", p.GetName())
+	}
+	return nil
+}
+
+// waitRemoved polls the dependents until none of them exists anymore,
+// reporting each one as it goes. Those left at the timeout are named in the
+// error, as they are usually held by a finalizer.
+func waitRemoved(ctx context.Context, dyn dynamic.Interface, pending []dependent) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	fmt.Printf("Waiting for %d dependents to be removed (timeout %s)...\n", len(pending), cascadeTimeout)
+	err := wait.PollUntilContextTimeout(ctx, 5*time.Second, cascadeTimeout, true, func(ctx context.Context) (bool, error) {
+		var left []dependent
+		for _, d := range pending {
+			_, err := d.t.Client(dyn).Get(ctx, d.obj.GetName(), metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				fmt.Printf("Deleted %s\n", d)
+				continue
+			}
+			if err != nil {
+				debugf("get %s failed: %v", d, err)
+			}
+			left = append(left, d)
+		}
+		pending = left
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		names := make([]string, 0, len(pending))
+		for _, d := range pending {
+			names = append(names, d.String())
+		}
+		return fmt.Errorf("%s not removed after %s, the xproviders were kept: %w", strings.Join(names, ", "), cascadeTimeout, err)
+	}
+	return nil
+}
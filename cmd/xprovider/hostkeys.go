@@ -0,0 +1,124 @@
+package xprovider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// AnnotationHostKeys holds the SSH host keys of the gateway of an XProvider,
+// one "<type> <base64 key>" per line, as pinned by ssh --enable --pin-hostkeys.
+const AnnotationHostKeys = "skycluster.io/ssh-host-keys"
+
+// knownHostsFile is where the pinned keys are written for ssh, keyed by the
+// XProvider name, which the ssh config entries use as HostKeyAlias.
+const knownHostsFile = "skycluster_known_hosts"
+
+// pinHostKeys returns the host keys of the gateway of res. Keys already
+// pinned in its annotation are returned as they are; otherwise the gateway at
+// addr is scanned and the keys are stored in the annotation.
+func pinHostKeys(ctx context.Context, ri dynamic.ResourceInterface, res *unstructured.Unstructured, addr string) ([]string, error) {
+	name := res.GetName()
+	if v := res.GetAnnotations()[AnnotationHostKeys]; strings.TrimSpace(v) != "" {
+		debugf("provider %s: using pinned host keys", name)
+		return strings.Split(strings.TrimSpace(v), "\n"), nil
+	}
+	keys, err := scanHostKeys(addr)
+	if err != nil {
+		return nil, fmt.Errorf("scanning host keys of %s (%s): %w", name, addr, err)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{AnnotationHostKeys: strings.Join(keys, "\n")},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = utils.Retry(ctx, func(ctx context.Context) error {
+		_, err := ri.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pinning host keys of %s: %w", name, err)
+	}
+	for _, k := range keys {
+		fmt.Printf("pinned host key of %s: %s\n", name, fingerprint(k))
+	}
+	return keys, nil
+}
+
+// scanHostKeys runs ssh-keyscan against addr and returns the keys it found,
+// sorted so that the annotation is stable.
+func scanHostKeys(addr string) ([]string, error) {
+	debugf("running ssh-keyscan -T 10 %s", addr)
+	out, err := exec.Command("ssh-keyscan", "-T", "10", addr).Output()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		keys = append(keys, fields[1]+" "+fields[2])
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no host key offered")
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
+
+// fingerprint returns the SHA256 fingerprint of a "<type> <base64 key>" as
+// printed by ssh-keygen -l.
+func fingerprint(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return key
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return key
+	}
+	sum := sha256.Sum256(blob)
+	return fmt.Sprintf("%s SHA256:%s", fields[0], base64.RawStdEncoding.EncodeToString(sum[:]))
+}
+
+// getKnownHostsPath returns the known hosts file of the pinned keys, next to
+// the ssh config.
+func getKnownHostsPath() string {
+	return filepath.Join(filepath.Dir(getSSHConfigPath()), knownHostsFile)
+}
+
+// upsertKnownHosts replaces the lines of host in the known hosts lines with
+// one line per key and returns the result and whether anything changed.
+func upsertKnownHosts(lines []string, host string, keys []string) ([]string, bool) {
+	var out, old []string
+	for _, l := range lines {
+		if f := strings.Fields(l); len(f) > 0 && f[0] == host {
+			old = append(old, l)
+			continue
+		}
+		out = append(out, l)
+	}
+	var added []string
+	for _, k := range keys {
+		added = append(added, host+" "+k)
+	}
+	return append(out, added...), !slices.Equal(old, added)
+}
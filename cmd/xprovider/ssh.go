@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/etesami/skycluster-cli/internal/utils"
@@ -15,13 +16,99 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 )
 
+// Prefixes of the ssh config Host aliases of the gateways of XProviders and
+// of XInstances, so that a provider and an instance of the same name do not
+// share an entry: ssh xprovider-<name>, ssh xinstance-<name>.
+const (
+	providerHostPrefix = "xprovider-"
+	instanceHostPrefix = "xinstance-"
+)
+
+// removeHostEntries removes the entries of alias and the bare name entry
+// earlier releases wrote instead, at one of ips.
+func removeHostEntries(lines []string, alias, name string, ips ...string) ([]string, bool) {
+	lines, removed := removeAllHostEntries(lines, alias)
+	lines, legacy := removeLegacyHostEntry(lines, name, ips...)
+	return lines, removed || legacy
+}
+
+// removeLegacyHostEntry removes the block earlier releases wrote under the
+// bare name. It is only recognised by its exact shape: a Host line of name
+// alone, User ubuntu, StrictHostKeyChecking, a HostName among ips and no
+// directive the CLI does not write, so a block of the user for a host of the
+// same name is left alone.
+func removeLegacyHostEntry(lines []string, name string, ips ...string) ([]string, bool) {
+	var out []string
+	removed := false
+	for i := 0; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), "Host ") {
+			j++
+		}
+		if !isLegacyHostBlock(lines[i:j], name, ips) {
+			out = append(out, lines[i:j]...)
+			i = j
+			continue
+		}
+		debugf("removing legacy Host block %s at line %d", name, i)
+		removed = true
+		for len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+			out = out[:len(out)-1]
+		}
+		i = j
+	}
+	return out, removed
+}
+
+func isLegacyHostBlock(block []string, name string, ips []string) bool {
+	if f := strings.Fields(block[0]); len(f) != 2 || f[0] != "Host" || f[1] != name {
+		return false
+	}
+	var hostName, user, strict bool
+	for _, line := range block[1:] {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return false
+		}
+		switch f[0] {
+		case "HostName":
+			hostName = slices.Contains(ips, f[1])
+		case "User":
+			user = f[1] == "ubuntu"
+		case "StrictHostKeyChecking":
+			strict = true
+		case "UserKnownHostsFile", "HostKeyAlias", "ProxyJump":
+		default:
+			return false
+		}
+	}
+	return hostName && user && strict
+}
+
+// gatewayIps returns the addresses the ssh entry of an XProvider may point at.
+func gatewayIps(res *unstructured.Unstructured) []string {
+	var ips []string
+	for _, k := range []string{"publicIp", "privateIp"} {
+		if ip, _, _ := unstructured.NestedString(res.Object, "status", "gateway", k); strings.TrimSpace(ip) != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
 func init() {
 	// ssh command flags
 	xProviderSSHCmd.PersistentFlags().Bool("enable", false, "Enable SSH entries for all XProviders")
 	xProviderSSHCmd.PersistentFlags().Bool("disable", false, "Disable SSH entries for XProviders")
 	xProviderSSHCmd.PersistentFlags().StringP("name", "n", "", "Name of the XProvider (used only with --disable)")
+	xProviderSSHCmd.PersistentFlags().Bool("proxy-jump", false, "Also manage entries for private-only XInstances that jump through their provider gateway")
+	xProviderSSHCmd.PersistentFlags().Bool("pin-hostkeys", false, "With --enable, pin the host keys of the gateways in their XProviders and check them instead of accepting any key")
 
 	// Note: hook-up of xProviderSSHCmd into the parent command tree should be done
 	// where commands are assembled (not shown here).
@@ -34,8 +121,10 @@ var xProviderSSHCmd = &cobra.Command{
 		enable, _ := cmd.Flags().GetBool("enable")
 		disable, _ := cmd.Flags().GetBool("disable")
 		name, _ := cmd.Flags().GetString("name")
+		proxyJump, _ := cmd.Flags().GetBool("proxy-jump")
+		pin, _ := cmd.Flags().GetBool("pin-hostkeys")
 
-		debugf("ssh command invoked: enable=%v disable=%v name=%q", enable, disable, name)
+		debugf("ssh command invoked: enable=%v disable=%v name=%q proxyJump=%v pin=%v", enable, disable, name, proxyJump, pin)
 
 		// Validate flags
 		if enable == disable {
@@ -49,18 +138,21 @@ var xProviderSSHCmd = &cobra.Command{
 			log.Fatalf("-n/--name is only valid when --disable is used")
 			return
 		}
+		if pin && !enable {
+			log.Fatalf("--pin-hostkeys is only valid when --enable is used")
+		}
 
 		ns := ""
 
 		if enable {
 			debugf("calling enableSSHEntries for namespace %q", ns)
-			if err := enableSSHEntries(ns); err != nil {
+			if err := enableSSHEntries(cmd.Context(), ns, proxyJump, pin); err != nil {
 				debugf("enableSSHEntries returned error: %v", err)
 				log.Fatalf("error enabling ssh entries: %v", err)
 			}
 		} else {
 			debugf("calling disableSSHEntries for namespace %q name=%q", ns, name)
-			if err := disableSSHEntries(ns, name); err != nil {
+			if err := disableSSHEntries(cmd.Context(), ns, name, proxyJump); err != nil {
 				debugf("disableSSHEntries returned error: %v", err)
 				log.Fatalf("error disabling ssh entries: %v", err)
 			}
@@ -70,7 +162,13 @@ var xProviderSSHCmd = &cobra.Command{
 
 // enableSSHEntries will ensure there is an ssh config entry for each xprovider that has a public IP.
 // It will create ~/.ssh/config if necessary. Existing entries for the same host name are updated.
-func enableSSHEntries(ns string) error {
+// Providers matching an ssh.bastions rule jump through its chain, and are reached on the private
+// gateway address when there is no public one.
+// When proxyJump is set, xinstances that only have a private IP get an entry that jumps through
+// the gateway entry of their provider.
+// When pin is set, the host keys of each gateway are scanned once and pinned in the
+// AnnotationHostKeys annotation of its XProvider, and its entry checks them strictly.
+func enableSSHEntries(ctx context.Context, ns string, proxyJump bool, pin bool) error {
 	kubeconfig := viper.GetString("kubeconfig")
 	debugf("enableSSHEntries: kubeconfig=%q namespace=%q", kubeconfig, ns)
 	dynamicClient, err := utils.GetDynamicClient(kubeconfig)
@@ -87,7 +185,11 @@ func enableSSHEntries(ns string) error {
 	}
 
 	debugf("listing xproviders in namespace %q", ns)
-	resources, err := dynamicClient.Resource(gvr).Namespace(ns).List(context.Background(), metav1.ListOptions{})
+	ri, err := utils.GetResourceClient(kubeconfig, gvr, ns)
+	if err != nil {
+		return fmt.Errorf("listing xproviders: %w", err)
+	}
+	resources, err := ri.List(ctx, metav1.ListOptions{})
 	if err != nil {
 		debugf("listing xproviders failed: %v", err)
 		return fmt.Errorf("listing xproviders: %w", err)
@@ -107,8 +209,18 @@ func enableSSHEntries(ns string) error {
 	}
 	debugf("read %d lines from ssh config", len(lines))
 
+	knownHostsPath := getKnownHostsPath()
+	var knownHosts []string
+	knownHostsUpdated := false
+	if pin {
+		if knownHosts, err = readSSHConfig(knownHostsPath); err != nil {
+			return err
+		}
+	}
+
 	// For each provider with a public IP ensure or update entry
 	updated := false
+	gateways := map[string]string{}
 	for _, res := range resources.Items {
 		name := res.GetName()
 		stat, found, _ := unstructured.NestedStringMap(res.Object, "status", "gateway")
@@ -120,24 +232,102 @@ func enableSSHEntries(ns string) error {
 		if v, ok := stat["publicIp"]; ok {
 			pubIp = v
 		}
+		platform, _, _ := unstructured.NestedString(res.Object, "spec", "providerRef", "platform")
+		bastions, err := utils.BastionChain(platform, name)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(pubIp) == "" && len(bastions) > 0 {
+			// Behind a bastion, the gateway is reachable on its private address.
+			pubIp = stat["privateIp"]
+		}
 		if strings.TrimSpace(pubIp) == "" {
 			fmt.Printf("skipping provider %s: no public IP\n", name)
 			debugf("provider %s has empty publicIp, skipping", name)
 			continue
 		}
 
-		debugf("ensuring ssh entry for provider %s -> %s", name, pubIp)
-		changedLines, changed := upsertHostBlock(lines, name, pubIp)
+		// Instance entries jump through the gateway entry, and so through its bastions.
+		gateways[name] = pubIp
+		alias := providerHostPrefix + name
+		var legacy bool
+		if lines, legacy = removeLegacyHostEntry(lines, name, pubIp); legacy {
+			updated = true
+		}
+		jump := strings.Join(bastions, ",")
+		pinnedHostKeys := ""
+		if pin {
+			if len(bastions) > 0 && res.GetAnnotations()[AnnotationHostKeys] == "" {
+				// ssh-keyscan cannot go through the bastions.
+				fmt.Printf("not pinning the host keys of %s: it is behind a bastion; set the %s annotation to pin them\n", name, AnnotationHostKeys)
+			} else {
+				keys, err := pinHostKeys(ctx, ri, &res, pubIp)
+				if err != nil {
+					return err
+				}
+				var changed bool
+				if knownHosts, changed = upsertKnownHosts(knownHosts, alias, keys); changed {
+					knownHostsUpdated = true
+				}
+				pinnedHostKeys = knownHostsPath
+			}
+		}
+		debugf("ensuring ssh entry for provider %s -> %s (bastions %q)", name, pubIp, jump)
+		changedLines, changed := upsertHostBlock(lines, alias, pubIp, jump, pinnedHostKeys)
 		if changed {
 			updated = true
 			lines = changedLines
-			fmt.Printf("added/updated ssh entry for %s -> %s\n", name, pubIp)
+			if jump != "" {
+				fmt.Printf("added/updated ssh entry %s -> %s (via %s)\n", alias, pubIp, jump)
+			} else {
+				fmt.Printf("added/updated ssh entry %s -> %s\n", alias, pubIp)
+			}
 			debugf("ssh entry updated for %s", name)
 		} else {
 			debugf("no change needed for %s", name)
 		}
 	}
 
+	if proxyJump {
+		instances, err := listXInstances(ctx, dynamicClient, ns)
+		if err != nil {
+			return err
+		}
+		for _, res := range instances {
+			name := res.GetName()
+			privIp, _, _ := unstructured.NestedString(res.Object, "status", "network", "privateIp")
+			pubIp, _, _ := unstructured.NestedString(res.Object, "status", "network", "publicIp")
+			providerName, _, _ := unstructured.NestedString(res.Object, "status", "providerName")
+			if strings.TrimSpace(pubIp) != "" || strings.TrimSpace(privIp) == "" {
+				debugf("instance %s: publicIp=%q privateIp=%q, no jump entry needed", name, pubIp, privIp)
+				continue
+			}
+			if _, ok := gateways[providerName]; !ok {
+				fmt.Printf("skipping instance %s: no gateway with a public IP for provider %q\n", name, providerName)
+				continue
+			}
+
+			alias, gateway := instanceHostPrefix+name, providerHostPrefix+providerName
+			var legacy bool
+			if lines, legacy = removeLegacyHostEntry(lines, name, privIp); legacy {
+				updated = true
+			}
+			debugf("ensuring ssh entry for instance %s -> %s via %s", name, privIp, gateway)
+			changedLines, changed := upsertHostBlock(lines, alias, privIp, gateway, "")
+			if changed {
+				updated = true
+				lines = changedLines
+				fmt.Printf("added/updated ssh entry %s -> %s (via %s)\n", alias, privIp, gateway)
+			}
+		}
+	}
+
+	if knownHostsUpdated {
+		debugf("writing pinned host keys to %s", knownHostsPath)
+		if err := writeSSHConfig(knownHostsPath, knownHosts); err != nil {
+			return fmt.Errorf("writing known hosts: %w", err)
+		}
+	}
 	if updated {
 		debugf("writing updated ssh config to %s", sshConfigPath)
 		if err := writeSSHConfig(sshConfigPath, lines); err != nil {
@@ -153,8 +343,8 @@ func enableSSHEntries(ns string) error {
 }
 
 // disableSSHEntries will remove the ssh config entry for a single provider (if name provided)
-// or for all providers otherwise.
-func disableSSHEntries(ns string, name string) error {
+// or for all providers otherwise. With proxyJump, entries of xinstances are removed as well.
+func disableSSHEntries(ctx context.Context, ns string, name string, proxyJump bool) error {
 	kubeconfig := viper.GetString("kubeconfig")
 	debugf("disableSSHEntries: kubeconfig=%q namespace=%q name=%q", kubeconfig, ns, name)
 	dynamicClient, err := utils.GetDynamicClient(kubeconfig)
@@ -171,7 +361,11 @@ func disableSSHEntries(ns string, name string) error {
 	}
 
 	debugf("listing xproviders in namespace %q", ns)
-	resources, err := dynamicClient.Resource(gvr).Namespace(ns).List(context.Background(), metav1.ListOptions{})
+	ri, err := utils.GetResourceClient(kubeconfig, gvr, ns)
+	if err != nil {
+		return fmt.Errorf("listing xproviders: %w", err)
+	}
+	resources, err := ri.List(ctx, metav1.ListOptions{})
 	if err != nil {
 		debugf("listing xproviders failed: %v", err)
 		return fmt.Errorf("listing xproviders: %w", err)
@@ -190,7 +384,13 @@ func disableSSHEntries(ns string, name string) error {
 	if name != "" {
 		debugf("removing entries for provider %s only", name)
 		// Only remove for the provided name
-		newLines, removed := removeAllHostEntries(lines, name)
+		var ips []string
+		for i := range resources.Items {
+			if resources.Items[i].GetName() == name {
+				ips = gatewayIps(&resources.Items[i])
+			}
+		}
+		newLines, removed := removeHostEntries(lines, providerHostPrefix+name, name, ips...)
 		if !removed {
 			fmt.Printf("no ssh entry found for %s\n", name)
 			debugf("no entries removed for %s", name)
@@ -200,17 +400,32 @@ func disableSSHEntries(ns string, name string) error {
 			debugf("writeSSHConfig failed: %v", err)
 			return fmt.Errorf("writing ssh config: %w", err)
 		}
-		fmt.Printf("removed ssh entry for %s\n", name)
+		fmt.Printf("removed ssh entry %s\n", providerHostPrefix+name)
 		debugf("removed entries for %s and wrote file", name)
 		return nil
 	}
 
 	debugf("removing entries for all providers")
 	// name == "" -> remove entries for all providers
-	// Build a set of provider names to remove
-	providerNames := map[string]struct{}{}
-	for _, res := range resources.Items {
-		providerNames[res.GetName()] = struct{}{}
+	// Build a set of host aliases to remove, with the names and addresses of
+	// the entries they replace
+	type legacyEntry struct {
+		name string
+		ips  []string
+	}
+	providerNames := map[string]legacyEntry{}
+	for i, res := range resources.Items {
+		providerNames[providerHostPrefix+res.GetName()] = legacyEntry{res.GetName(), gatewayIps(&resources.Items[i])}
+	}
+	if proxyJump {
+		instances, err := listXInstances(ctx, dynamicClient, ns)
+		if err != nil {
+			return err
+		}
+		for _, res := range instances {
+			privIp, _, _ := unstructured.NestedString(res.Object, "status", "network", "privateIp")
+			providerNames[instanceHostPrefix+res.GetName()] = legacyEntry{res.GetName(), []string{privIp}}
+		}
 	}
 	if len(providerNames) == 0 {
 		fmt.Printf("no xproviders found in namespace %s\n", ns)
@@ -220,13 +435,13 @@ func disableSSHEntries(ns string, name string) error {
 
 	newLines := lines
 	anyRemoved := false
-	for pname := range providerNames {
-		debugf("attempting to remove entries for provider %s", pname)
+	for pname, legacy := range providerNames {
+		debugf("attempting to remove entries for %s", pname)
 		var removed bool
-		newLines, removed = removeAllHostEntries(newLines, pname)
+		newLines, removed = removeHostEntries(newLines, pname, legacy.name, legacy.ips...)
 		if removed {
 			anyRemoved = true
-			fmt.Printf("removed ssh entry for %s\n", pname)
+			fmt.Printf("removed ssh entry %s\n", pname)
 			debugf("removed entries for %s", pname)
 		} else {
 			debugf("no ssh entry found for %s", pname)
@@ -246,6 +461,31 @@ func disableSSHEntries(ns string, name string) error {
 	return nil
 }
 
+// listXInstances returns all xinstances; used to build ProxyJump entries.
+func listXInstances(ctx context.Context, dynamicClient dynamic.Interface, ns string) ([]unstructured.Unstructured, error) {
+	gvr := schema.GroupVersionResource{
+		Group:    "skycluster.io",
+		Version:  "v1alpha1",
+		Resource: "xinstances",
+	}
+	debugf("listing xinstances in namespace %q", ns)
+	disc, err := utils.GetDiscoveryClient(viper.GetString("kubeconfig"))
+	if err != nil {
+		return nil, fmt.Errorf("creating discovery client: %w", err)
+	}
+	ri, err := utils.ResourceFor(dynamicClient, disc, gvr, ns)
+	if err != nil {
+		return nil, fmt.Errorf("listing xinstances: %w", err)
+	}
+	list, err := ri.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		debugf("listing xinstances failed: %v", err)
+		return nil, fmt.Errorf("listing xinstances: %w", err)
+	}
+	debugf("found %d xinstances", len(list.Items))
+	return list.Items, nil
+}
+
 // Helpers for ssh config manipulation
 
 func getSSHConfigPath() string {
@@ -297,8 +537,8 @@ func writeSSHConfig(path string, lines []string) error {
 	if !strings.HasSuffix(out, "\n") {
 		out += "\n"
 	}
-	// Write file with 0600 permission
-	if err := os.WriteFile(path, []byte(out), 0600); err != nil {
+	// Write atomically with 0600 permission so a failed write never truncates the config
+	if err := utils.WriteFileAtomic(path, []byte(out), 0600); err != nil {
 		debugf("writing ssh config %s failed: %v", path, err)
 		return fmt.Errorf("writing ssh config: %w", err)
 	}
@@ -307,10 +547,13 @@ func writeSSHConfig(path string, lines []string) error {
 }
 
 // upsertHostBlock ensures there is exactly one Host block for the given host name and
-// that the block sets HostName to the provided ip and User ubuntu.
+// that the block sets HostName to the provided ip and User ubuntu. A non-empty jump
+// adds a ProxyJump directive pointing at that host alias. A non-empty knownHosts is
+// the file holding the pinned keys of host, which are then checked strictly; without
+// it any host key is accepted.
 // Returns updated lines and whether a change occurred.
-func upsertHostBlock(lines []string, host string, ip string) ([]string, bool) {
-	debugf("upsertHostBlock host=%s ip=%s", host, ip)
+func upsertHostBlock(lines []string, host string, ip string, jump string, knownHosts string) ([]string, bool) {
+	debugf("upsertHostBlock host=%s ip=%s jump=%q knownHosts=%q", host, ip, jump, knownHosts)
 	// Remove all existing host blocks for `host` first to avoid duplicates.
 	cleaned, removedAny := removeAllHostEntries(lines, host)
 	debugf("removed existing entries=%v", removedAny)
@@ -323,6 +566,19 @@ func upsertHostBlock(lines []string, host string, ip string) ([]string, bool) {
 		"\tStrictHostKeyChecking no",
 		"\tUserKnownHostsFile /dev/null",
 	}
+	if knownHosts != "" {
+		block = []string{
+			fmt.Sprintf("Host %s", host),
+			fmt.Sprintf("\tHostName %s", ip),
+			"\tUser ubuntu",
+			fmt.Sprintf("\tHostKeyAlias %s", host),
+			"\tStrictHostKeyChecking yes",
+			fmt.Sprintf("\tUserKnownHostsFile %s", knownHosts),
+		}
+	}
+	if jump != "" {
+		block = append(block, fmt.Sprintf("\tProxyJump %s", jump))
+	}
 
 	// Append a blank line before the block if the file is non-empty and does not already end with a blank line
 	if len(cleaned) > 0 && strings.TrimSpace(cleaned[len(cleaned)-1]) != "" {
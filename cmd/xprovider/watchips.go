@@ -0,0 +1,207 @@
+package xprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/state"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+var (
+	watchIPsLog         string
+	watchIPsSyncSSH     bool
+	watchIPsProxyJump   bool
+	watchIPsPin         bool
+	watchIPsExec        string
+	watchIPsExecTimeout time.Duration
+)
+
+func init() {
+	xProviderWatchIPsCmd.Flags().StringVar(&watchIPsLog, "log", "", "File the changes are appended to as JSON lines")
+	xProviderWatchIPsCmd.Flags().BoolVar(&watchIPsSyncSSH, "sync-ssh", false, "Update the ~/.ssh/config entries of the gateways on every change, as ssh --enable does")
+	xProviderWatchIPsCmd.Flags().BoolVar(&watchIPsProxyJump, "proxy-jump", false, "With --sync-ssh, also update the entries of private-only XInstances")
+	xProviderWatchIPsCmd.Flags().BoolVar(&watchIPsPin, "pin-hostkeys", false, "With --sync-ssh, check the pinned host keys of the gateways")
+	xProviderWatchIPsCmd.Flags().StringVar(&watchIPsExec, "exec", "", "Shell command run per change, e.g. to update DNS; the change is passed as JSON on stdin and as SKYCLUSTER_* variables")
+	xProviderWatchIPsCmd.Flags().DurationVar(&watchIPsExecTimeout, "exec-timeout", time.Minute, "Time after which the --exec command is killed")
+	xProviderCmd.AddCommand(xProviderWatchIPsCmd)
+}
+
+var xProviderWatchIPsCmd = &cobra.Command{
+	Use:   "watch-ips",
+	Short: "Record changes of the gateway addresses of the XProviders",
+	Long: `Watch the gateway addresses of the XProviders and report every change, e.g.
+a new public IP after maintenance of the cloud. Each change is printed and,
+with --log, appended to the file as a JSON line with its time, so that the
+file keeps the history of the addresses. The addresses last seen are kept in
+the local state of the CLI and compared on start, so changes made while not
+watching are reported too.
+
+On a change, --sync-ssh rewrites the ~/.ssh/config entries of the gateways
+and --exec runs a command through sh, e.g. a script updating DNS records,
+with the change as JSON on stdin and in the SKYCLUSTER_NAME,
+SKYCLUSTER_PUBLIC_IP, SKYCLUSTER_PRIVATE_IP, SKYCLUSTER_PREVIOUS_PUBLIC_IP and
+SKYCLUSTER_PREVIOUS_PRIVATE_IP variables.
+
+The command runs until interrupted.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		last := map[string]addresses{}
+		if err := state.Load(addressesState, addressesStateVersion, &last); err != nil {
+			return err
+		}
+		save := func() {
+			if err := state.Save(addressesState, addressesStateVersion, last); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: saving the addresses: %v\n", err)
+			}
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		ctx := cmd.Context()
+
+		fmt.Fprintln(os.Stderr, "Watching the gateway addresses of the XProviders")
+		check := func(obj *unstructured.Unstructured) {
+			cur := gatewayAddresses(obj)
+			prev, known := last[obj.GetName()]
+			if known && prev == cur {
+				return
+			}
+			last[obj.GetName()] = cur
+			save()
+			if !known {
+				return
+			}
+			recordIPChange(ctx, ipChange{
+				Time:              time.Now().UTC(),
+				Name:              obj.GetName(),
+				PublicIP:          cur.PublicIP,
+				PrivateIP:         cur.PrivateIP,
+				PreviousPublicIP:  prev.PublicIP,
+				PreviousPrivateIP: prev.PrivateIP,
+			})
+		}
+		resource.XProvider.Follow(ctx, dyn, resource.Handler{
+			Synced: func(items []unstructured.Unstructured) {
+				for i := range items {
+					check(&items[i])
+				}
+			},
+			Changed: func(typ watch.EventType, obj *unstructured.Unstructured) {
+				if typ == watch.Deleted {
+					delete(last, obj.GetName())
+					save()
+					return
+				}
+				check(obj)
+			},
+			Failed: func(err error) {
+				fmt.Fprintf(os.Stderr, "warning: watching xproviders: %v\n", err)
+			},
+		})
+		return nil
+	},
+}
+
+// addressesState is the local state holding the gateway addresses last seen
+// by watch-ips, per XProvider.
+const (
+	addressesState        = "gateway-addresses"
+	addressesStateVersion = 1
+)
+
+// addresses are the gateway addresses of an XProvider.
+type addresses struct {
+	PublicIP  string `json:"publicIp"`
+	PrivateIP string `json:"privateIp"`
+}
+
+// ipChange is a change of the gateway addresses of an XProvider, as printed,
+// logged and passed to --exec.
+type ipChange struct {
+	Time              time.Time `json:"time"`
+	Name              string    `json:"name"`
+	PublicIP          string    `json:"publicIp"`
+	PrivateIP         string    `json:"privateIp"`
+	PreviousPublicIP  string    `json:"previousPublicIp"`
+	PreviousPrivateIP string    `json:"previousPrivateIp"`
+}
+
+func gatewayAddresses(obj *unstructured.Unstructured) addresses {
+	var a addresses
+	a.PublicIP, _, _ = unstructured.NestedString(obj.Object, "status", "gateway", "publicIp")
+	a.PrivateIP, _, _ = unstructured.NestedString(obj.Object, "status", "gateway", "privateIp")
+	return a
+}
+
+// recordIPChange prints, logs and hands on c. Failures are reported but do
+// not stop the watch.
+func recordIPChange(ctx context.Context, c ipChange) {
+	fmt.Printf("%s %s public %s -> %s, private %s -> %s\n", c.Time.Local().Format(time.RFC3339), c.Name,
+		orDash(c.PreviousPublicIP), orDash(c.PublicIP), orDash(c.PreviousPrivateIP), orDash(c.PrivateIP))
+	body := logIPChange(c)
+	if watchIPsSyncSSH {
+		if err := enableSSHEntries(ctx, "", watchIPsProxyJump, watchIPsPin); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: updating ssh entries: %v\n", err)
+		}
+	}
+	if watchIPsExec != "" {
+		if err := runIPChangeExec(ctx, c, body); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: --exec for %s: %v\n", c.Name, err)
+		}
+	}
+}
+
+// logIPChange appends c to the --log file, if any, and returns it as JSON.
+func logIPChange(c ipChange) []byte {
+	// An ipChange always encodes.
+	body, _ := json.Marshal(c)
+	if watchIPsLog != "" {
+		if err := appendLine(watchIPsLog, body); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: writing %s: %v\n", watchIPsLog, err)
+		}
+	}
+	return body
+}
+
+func appendLine(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runIPChangeExec(ctx context.Context, c ipChange, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, watchIPsExecTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", watchIPsExec)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"SKYCLUSTER_NAME="+c.Name,
+		"SKYCLUSTER_PUBLIC_IP="+c.PublicIP,
+		"SKYCLUSTER_PRIVATE_IP="+c.PrivateIP,
+		"SKYCLUSTER_PREVIOUS_PUBLIC_IP="+c.PreviousPublicIP,
+		"SKYCLUSTER_PREVIOUS_PRIVATE_IP="+c.PreviousPrivateIP,
+	)
+	debugf("running %q for %s", watchIPsExec, c.Name)
+	return cmd.Run()
+}
@@ -0,0 +1,56 @@
+package xprovider
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/cmd/subnet"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+func init() {
+	resource.WithWizard(xProviderCreateCmd, resource.XProvider, createWizard)
+}
+
+// createWizard asks for the provider and a VPC CIDR, suggesting one that no
+// other XProvider uses.
+func createWizard(ctx context.Context, dyn dynamic.Interface) (map[string]interface{}, string, error) {
+	ref, _, err := resource.PickProviderRef(ctx, dyn)
+	if err != nil {
+		return nil, "", err
+	}
+	providers, err := resource.XProvider.List(ctx, dyn)
+	if err != nil {
+		return nil, "", fmt.Errorf("listing xproviders: %w", err)
+	}
+	var used []string
+	for _, p := range providers {
+		if c, _, _ := unstructured.NestedString(p.Object, "spec", "vpcCidr"); c != "" {
+			used = append(used, c)
+		}
+	}
+	vpc, err := utils.Ask("VPC CIDR", subnet.NextFree(used))
+	if err != nil {
+		return nil, "", err
+	}
+	platform := ref["platform"].(string)
+	r, err := subnet.Suggest(platform, vpc)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid VPC CIDR %q: %w", vpc, err)
+	}
+	fmt.Printf("Subnets of the XProvider will be in %s", r.Subnet)
+	if r.Nodes != "" {
+		fmt.Printf(", GKE nodes in %s", r.Nodes)
+	}
+	fmt.Printf("; XKube pods in %s and services in %s (see 'skycluster subnet %s -p %s').\n", r.Pods, r.Services, vpc, platform)
+
+	spec := map[string]interface{}{
+		"providerRef": ref,
+		"vpcCidr":     vpc,
+	}
+	return spec, fmt.Sprintf("%s-%s", platform, ref["region"]), nil
+}
@@ -1,18 +1,16 @@
 package xprovider
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/spf13/cobra"
-)
 
-var debug bool
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/resource"
+)
 
 func init() {
-	xProviderCmd.AddCommand(xProviderListCmd)
+	xProviderCmd.AddCommand(resource.NewListCmd(resource.XProvider))
 	xProviderCmd.AddCommand(xProviderCreateCmd)
-	xProviderCmd.AddCommand(xProviderDeleteCmd)
+	xProviderCmd.AddCommand(newDeleteCmd())
 	xProviderCmd.AddCommand(xProviderSSHCmd)
 }
 
@@ -27,18 +25,12 @@ var xProviderCmd = &cobra.Command{
 	},
 }
 
-// debugf prints debug messages to stderr when debug is enabled.
+// debugf logs a debug message of the xprovider commands.
 func debugf(format string, args ...interface{}) {
-	if debug {
-		_, _ = fmt.Fprintf(os.Stderr, "DEBUG: "+format+"\n", args...)
-	}
+	log.Debugf("xprovider", format, args...)
 }
 
 func GetXProviderCmd() *cobra.Command {
 	return xProviderCmd
 }
 
-// SetDebug sets package-level debug flag after CLI flags are parsed.
-func SetDebug(d bool) {
-	debug = d
-}
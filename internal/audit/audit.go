@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/etesami/skycluster-cli/internal/version"
+)
+
+// Annotation keys stamped on resources created or updated by the CLI.
+const (
+	AnnotationCreatedBy   = "skycluster.io/created-by"
+	AnnotationCreatedAt   = "skycluster.io/created-at"
+	AnnotationUpdatedBy   = "skycluster.io/updated-by"
+	AnnotationUpdatedAt   = "skycluster.io/updated-at"
+	AnnotationCLIVersion  = "skycluster.io/cli-version"
+	AnnotationOperationID = "skycluster.io/operation-id"
+
+	// AnnotationOperationStartedAt is when the invocation that last touched
+	// the resource started; AnnotationOperationDuration is how long it had
+	// been running at that point, or until its --wait finished.
+	AnnotationOperationStartedAt = "skycluster.io/operation-started-at"
+	AnnotationOperationDuration  = "skycluster.io/operation-duration"
+)
+
+// Identity describes who is running the CLI.
+type Identity struct {
+	LocalUser   string // OS user name
+	Host        string // local hostname
+	KubeContext string // current context of the management kubeconfig
+	KubeUser    string // kubeconfig user (AuthInfo) of that context
+}
+
+// String renders the identity as stamped into created-by/updated-by,
+// e.g. "alice@laptop (kube-user: admin)".
+func (i Identity) String() string {
+	s := i.LocalUser + "@" + i.Host
+	if i.KubeUser != "" {
+		s += " (kube-user: " + i.KubeUser + ")"
+	}
+	return s
+}
+
+var (
+	opOnce  sync.Once
+	opID    string
+	opStart = time.Now()
+)
+
+// OperationID returns an identifier shared by every resource stamped during
+// this CLI invocation.
+func OperationID() string {
+	opOnce.Do(func() {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			opID = fmt.Sprintf("%x", time.Now().UnixNano())
+			return
+		}
+		opID = hex.EncodeToString(b)
+	})
+	return opID
+}
+
+// OperationDuration returns how long this CLI invocation has been running,
+// rounded to the second.
+func OperationDuration() time.Duration {
+	return time.Since(opStart).Round(time.Second)
+}
+
+// operationAnnotations returns the started-at/duration pair for this
+// invocation.
+func operationAnnotations() map[string]string {
+	return map[string]string{
+		AnnotationOperationStartedAt: opStart.UTC().Format(time.RFC3339),
+		AnnotationOperationDuration:  OperationDuration().String(),
+	}
+}
+
+// RecordDuration patches the operation annotations of an existing resource
+// so that they cover the whole invocation, e.g. after a --wait completed.
+func RecordDuration(ctx context.Context, dyn dynamic.Interface, gvr schema.GroupVersionResource, namespace, name string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": operationAnnotations()},
+	})
+	if err != nil {
+		return err
+	}
+	ri := dyn.Resource(gvr).Namespace(namespace)
+	if _, err := ri.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("recording the operation duration on %s %s: %w", gvr.Resource, name, err)
+	}
+	return nil
+}
+
+// WhoAmI resolves the identity of the current invocation. Lookup failures
+// leave the corresponding field as "unknown" (or empty for kubeconfig fields).
+func WhoAmI() Identity {
+	id := Identity{LocalUser: "unknown", Host: "unknown"}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		id.LocalUser = u.Username
+	} else if v := os.Getenv("USER"); v != "" {
+		id.LocalUser = v
+	}
+	if h, err := os.Hostname(); err == nil && h != "" {
+		id.Host = h
+	}
+
+	kubeconfig := viper.GetString("kubeconfig")
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	if raw, err := rules.Load(); err == nil {
+		id.KubeContext = raw.CurrentContext
+		if c := viper.GetString("context"); c != "" {
+			id.KubeContext = c
+		}
+		if ctx, ok := raw.Contexts[id.KubeContext]; ok {
+			id.KubeUser = ctx.AuthInfo
+		}
+	}
+	return id
+}
+
+// Stamp adds the creation annotations to obj before it is created.
+func Stamp(obj *unstructured.Unstructured) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	setAnnotations(obj, map[string]string{
+		AnnotationCreatedBy:   WhoAmI().String(),
+		AnnotationCreatedAt:   now,
+		AnnotationCLIVersion:  version.Get(),
+		AnnotationOperationID: OperationID(),
+	})
+	setAnnotations(obj, operationAnnotations())
+}
+
+// StampUpdate annotates obj, about to replace existing, as updated by this
+// invocation while keeping the original created-by/created-at values.
+func StampUpdate(obj, existing *unstructured.Unstructured) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	ann := map[string]string{
+		AnnotationUpdatedBy:   WhoAmI().String(),
+		AnnotationUpdatedAt:   now,
+		AnnotationCLIVersion:  version.Get(),
+		AnnotationOperationID: OperationID(),
+	}
+	for k, v := range operationAnnotations() {
+		ann[k] = v
+	}
+	prev := existing.GetAnnotations()
+	for _, k := range []string{AnnotationCreatedBy, AnnotationCreatedAt} {
+		if v, ok := prev[k]; ok {
+			ann[k] = v
+		}
+	}
+	setAnnotations(obj, ann)
+}
+
+func setAnnotations(obj *unstructured.Unstructured, ann map[string]string) {
+	merged := obj.GetAnnotations()
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	for k, v := range ann {
+		merged[k] = v
+	}
+	obj.SetAnnotations(merged)
+}
@@ -0,0 +1,134 @@
+// Package log is the logger of the CLI. Commands log through Debugf and
+// Infof with the name of their component; Setup, called once the flags are
+// parsed, picks the level (-v, -vv or --debug) and the format (--log-format):
+// plain lines for people or JSON objects for log tooling. Logs go to stderr,
+// apart from the output of the commands.
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Formats of Setup.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var logger = slog.New(newTextHandler(os.Stderr, slog.LevelWarn))
+
+// Level returns the level of a verbosity: warnings only at 0, info from 1
+// (-v) and debug from 2 (-vv).
+func Level(verbosity int) slog.Level {
+	switch {
+	case verbosity >= 2:
+		return slog.LevelDebug
+	case verbosity == 1:
+		return slog.LevelInfo
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// Setup makes the logger write to w at the level of verbosity, in format.
+func Setup(w io.Writer, verbosity int, format string) error {
+	level := Level(verbosity)
+	switch format {
+	case "", FormatText:
+		logger = slog.New(newTextHandler(w, level))
+	case FormatJSON:
+		logger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+	default:
+		return fmt.Errorf("invalid log format %q (%s or %s)", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// Logger returns the logger, for structured attributes.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// Debugf logs a debug message of component.
+func Debugf(component, format string, args ...interface{}) {
+	logf(slog.LevelDebug, component, format, args...)
+}
+
+// Infof logs an informational message of component.
+func Infof(component, format string, args ...interface{}) {
+	logf(slog.LevelInfo, component, format, args...)
+}
+
+// Warnf logs a warning of component.
+func Warnf(component, format string, args ...interface{}) {
+	logf(slog.LevelWarn, component, format, args...)
+}
+
+func logf(level slog.Level, component, format string, args ...interface{}) {
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, fmt.Sprintf(format, args...), "component", component)
+}
+
+// textHandler writes records as "DEBUG [component]: message key=value", the
+// way the CLI has always printed its debug lines.
+type textHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Level
+	attrs []slog.Attr
+}
+
+func newTextHandler(w io.Writer, level slog.Level) *textHandler {
+	return &textHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Level.String())
+	var rest []slog.Attr
+	add := func(a slog.Attr) bool {
+		if a.Key == "component" {
+			fmt.Fprintf(&b, " [%s]", a.Value.String())
+		} else {
+			rest = append(rest, a)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+	b.WriteString(": " + r.Message)
+	for _, a := range rest {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+	}
+	b.WriteString("\n")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &c
+}
+
+// WithGroup is not supported by the text format; groups are flattened.
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
@@ -0,0 +1,171 @@
+// Package metrics keeps the counters and gauges of the long-running commands
+// and serves them in the Prometheus text format, for /metrics endpoints.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and serves them.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// metric is a counter or gauge with a value per set of label values.
+type metric struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64 // joined label values -> value
+}
+
+// Counter is a metric that only goes up, e.g. the number of retries.
+type Counter struct{ m *metric }
+
+// Gauge is a metric that goes up and down, e.g. whether a cluster is ready.
+type Gauge struct{ m *metric }
+
+// NewCounter registers a counter named name with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.add(name, help, "counter", labels)}
+}
+
+// NewGauge registers a gauge named name with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.add(name, help, "gauge", labels)}
+}
+
+func (r *Registry) add(name, help, typ string, labels []string) *metric {
+	m := &metric{name: name, help: help, typ: typ, labels: labels, values: map[string]float64{}}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// Inc adds one to the counter of the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.m.update(labelValues, func(v float64) float64 { return v + 1 })
+}
+
+// Set sets the gauge of the label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.update(labelValues, func(float64) float64 { return v })
+}
+
+// SetBool sets the gauge of the label values to 1 when b holds, else 0.
+func (g *Gauge) SetBool(b bool, labelValues ...string) {
+	v := 0.0
+	if b {
+		v = 1
+	}
+	g.Set(v, labelValues...)
+}
+
+// Delete drops the gauge of the label values, e.g. of a deleted cluster.
+func (g *Gauge) Delete(labelValues ...string) {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	delete(g.m.values, g.m.key(labelValues))
+}
+
+// Retain drops the gauges whose first label value keep rejects.
+func (g *Gauge) Retain(keep func(first string) bool) {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	for k := range g.m.values {
+		if first, _, _ := strings.Cut(k, "\xff"); !keep(first) {
+			delete(g.m.values, k)
+		}
+	}
+}
+
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got %d values", m.name, m.labels, len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *metric) update(labelValues []string, f func(float64) float64) {
+	k := m.key(labelValues)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[k] = f(m.values[k])
+}
+
+// Write writes every metric to w in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.mu.Unlock()
+	var b strings.Builder
+	for _, m := range metrics {
+		m.write(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (m *metric) write(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, escape(m.help, false), m.name, m.typ)
+	if len(m.labels) == 0 && len(m.values) == 0 {
+		// a counter without labels is 0 before its first Inc
+		fmt.Fprintf(b, "%s 0\n", m.name)
+		return
+	}
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(m.name)
+		if len(m.labels) > 0 {
+			b.WriteByte('{')
+			for i, v := range strings.Split(k, "\xff") {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				fmt.Fprintf(b, "%s=\"%s\"", m.labels[i], escape(v, true))
+			}
+			b.WriteByte('}')
+		}
+		fmt.Fprintf(b, " %s\n", strconv.FormatFloat(m.values[k], 'g', -1, 64))
+	}
+}
+
+// escape escapes s for a HELP line, or with quoted for a label value.
+func escape(s string, quoted bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quoted {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+// Handler serves the metrics of r.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
@@ -0,0 +1,257 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+const (
+	// ConfigMapName is the ConfigMap (in ConfigMapNamespace) whose data keys
+	// hold policy files shared by every user of the management cluster.
+	ConfigMapName      = "skycluster-policies"
+	ConfigMapNamespace = utils.SystemNamespace
+)
+
+// Rule is a single guardrail. Expression is a CEL expression that must
+// evaluate to true for the object to be accepted; Message is reported
+// when it evaluates to false.
+type Rule struct {
+	Name       string   `json:"name"`
+	Kinds      []string `json:"kinds,omitempty"` // empty means all kinds
+	Expression string   `json:"expression"`
+	Message    string   `json:"message,omitempty"`
+
+	source string
+}
+
+// File is the on-disk (or in-ConfigMap) layout of a policy file.
+type File struct {
+	Policies []Rule `json:"policies"`
+}
+
+// Violation is returned by Enforce when one or more rules reject an object.
+type Violation struct {
+	Kind     string
+	Name     string
+	Messages []string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s %q rejected by policy: %s", v.Kind, v.Name, strings.Join(v.Messages, "; "))
+}
+
+// DefaultDir returns the local policy directory (~/.skycluster/policies),
+// overridable through the "policies.dir" config key.
+func DefaultDir() string {
+	if d := strings.TrimSpace(viper.GetString("policies.dir")); d != "" {
+		return d
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, ".skycluster", "policies")
+}
+
+// LoadDir reads every *.yaml/*.yml file in dir. A missing directory is not an error.
+func LoadDir(dir string) ([]Rule, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading policy dir %s: %w", dir, err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	var rules []Rule
+	for _, n := range names {
+		path := filepath.Join(dir, n)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading policy file %s: %w", path, err)
+		}
+		parsed, err := parse(raw, path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules, nil
+}
+
+// LoadConfigMap reads policy files stored as data keys of the shared policy
+// ConfigMap. A missing ConfigMap is not an error.
+func LoadConfigMap(ctx context.Context, kubeconfig string) ([]Rule, error) {
+	cs, err := utils.GetClientset(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating clientset: %w", err)
+	}
+	cm, err := cs.CoreV1().ConfigMaps(ConfigMapNamespace).Get(ctx, ConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting configmap %s/%s: %w", ConfigMapNamespace, ConfigMapName, err)
+	}
+
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var rules []Rule
+	for _, k := range keys {
+		parsed, err := parse([]byte(cm.Data[k]), fmt.Sprintf("configmap/%s[%s]", ConfigMapName, k))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules, nil
+}
+
+func parse(raw []byte, source string) ([]Rule, error) {
+	var f File
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("parsing policy file %s: %w", source, err)
+	}
+	for i := range f.Policies {
+		r := &f.Policies[i]
+		r.source = source
+		if strings.TrimSpace(r.Expression) == "" {
+			return nil, fmt.Errorf("policy %q in %s has no expression", r.Name, source)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s#%d", filepath.Base(source), i)
+		}
+	}
+	return f.Policies, nil
+}
+
+// Evaluate runs rules against obj and returns a *Violation if any rule
+// evaluates to false. The CEL environment exposes "object" (the full
+// resource), "kind", "name" and "namespace".
+func Evaluate(rules []Rule, obj *unstructured.Unstructured, debugf utils.DebugfFunc) error {
+	if len(rules) == 0 || obj == nil {
+		return nil
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("kind", cel.StringType),
+		cel.Variable("name", cel.StringType),
+		cel.Variable("namespace", cel.StringType),
+	)
+	if err != nil {
+		return fmt.Errorf("creating CEL environment: %w", err)
+	}
+
+	input := map[string]interface{}{
+		"object":    obj.Object,
+		"kind":      obj.GetKind(),
+		"name":      obj.GetName(),
+		"namespace": obj.GetNamespace(),
+	}
+
+	var messages []string
+	for _, r := range rules {
+		if !appliesTo(r, obj.GetKind()) {
+			continue
+		}
+		ast, iss := env.Compile(r.Expression)
+		if iss != nil && iss.Err() != nil {
+			return fmt.Errorf("compiling policy %q (%s): %w", r.Name, r.source, iss.Err())
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return fmt.Errorf("building policy %q (%s): %w", r.Name, r.source, err)
+		}
+		out, _, err := prg.Eval(input)
+		if err != nil {
+			// missing fields surface as evaluation errors; treat them as a rejection
+			// so a policy can never be bypassed by omitting a field.
+			messages = append(messages, fmt.Sprintf("%s: evaluation error: %v", r.Name, err))
+			continue
+		}
+		allowed, ok := out.Value().(bool)
+		if !ok {
+			return fmt.Errorf("policy %q (%s) must evaluate to a bool, got %T", r.Name, r.source, out.Value())
+		}
+		if debugf != nil {
+			debugf("policy %q (%s) on %s/%s -> %v", r.Name, r.source, obj.GetKind(), obj.GetName(), allowed)
+		}
+		if !allowed {
+			msg := r.Message
+			if msg == "" {
+				msg = fmt.Sprintf("expression %q evaluated to false", r.Expression)
+			}
+			messages = append(messages, fmt.Sprintf("%s: %s", r.Name, msg))
+		}
+	}
+
+	if len(messages) > 0 {
+		return &Violation{Kind: obj.GetKind(), Name: obj.GetName(), Messages: messages}
+	}
+	return nil
+}
+
+// Enforce loads local and cluster-wide policies and evaluates them against obj.
+// It is meant to be called by every command right before it creates or updates
+// a resource on the management cluster, which must not go ahead when it
+// returns an error: a violation, or policies that could not be loaded.
+func Enforce(ctx context.Context, obj *unstructured.Unstructured, debugf utils.DebugfFunc) error {
+	rules, err := LoadDir(DefaultDir())
+	if err != nil {
+		return err
+	}
+
+	kubeconfig := viper.GetString("kubeconfig")
+	cmRules, err := LoadConfigMap(ctx, kubeconfig)
+	if err != nil {
+		// A missing ConfigMap is no error; any other failure would drop the
+		// shared rules, so nothing is created without them.
+		return fmt.Errorf("loading the shared policies: %w", err)
+	}
+	rules = append(rules, cmRules...)
+
+	if debugf != nil {
+		debugf("policy: evaluating %d rule(s) against %s/%s", len(rules), obj.GetKind(), obj.GetName())
+	}
+	return Evaluate(rules, obj, debugf)
+}
+
+func appliesTo(r Rule, kind string) bool {
+	if len(r.Kinds) == 0 {
+		return true
+	}
+	for _, k := range r.Kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/policy"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// AfterCreate runs once a create command has created or updated u, e.g. to
+// wait for it to become ready.
+type AfterCreate func(cmd *cobra.Command, dyn dynamic.Interface, u *unstructured.Unstructured) error
+
+// BeforeDelete runs once a delete has been confirmed, right before obj is
+// deleted, e.g. to release what it holds on other clusters. Returning an
+// error aborts the remaining deletions.
+type BeforeDelete func(ctx context.Context, dyn dynamic.Interface, obj *unstructured.Unstructured) error
+
+// NewCommand returns the "<name>" command with create, list and delete
+// subcommands for t.
+func NewCommand(t *Type) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   t.Name,
+		Short: t.Kind + " commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewCreateCmd(t, nil))
+	cmd.AddCommand(NewListCmd(t))
+	cmd.AddCommand(NewDeleteCmd(t, nil))
+	return cmd
+}
+
+// NewCreateCmd returns a create command building t resources from a spec
+// file, one per YAML document (see ReadSpecs). after, when not nil, runs for
+// each resource once all of them have been applied; callers add the flags it
+// needs to the returned command.
+func NewCreateCmd(t *Type, after AfterCreate) *cobra.Command {
+	var (
+		specFile       string
+		name           string
+		showDiff       bool
+		serverSide     bool
+		forceConflicts bool
+	)
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: fmt.Sprintf("Create or update %s resources from a YAML spec", t.Kind),
+		Long: fmt.Sprintf(`Create or update %[1]s resources from a YAML spec file.
+
+The file holds the spec fields of the %[1]s, or several of them separated by
+---. A single spec is named by --name. In a file with several specs, each one
+is named by its metadata.name field, or else --name followed by the number of
+the document, e.g. worker-1, worker-2. Every spec is applied even when one
+fails, and a summary of the created, updated and failed ones is printed.`, t.Kind),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.TrimSpace(specFile) == "" {
+				return errors.New("flag --spec-file is required")
+			}
+			debugf("%s create invoked: spec-file=%q name=%q", t.Name, specFile, name)
+			docs, err := t.ReadSpecs(specFile, strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+			if err != nil {
+				return fmt.Errorf("build dynamic client: %w", err)
+			}
+			if len(docs) == 1 {
+				return createOne(cmd, t, dyn, t.New(docs[0].Name, docs[0].Spec), showDiff, serverSide, forceConflicts, after)
+			}
+
+			var (
+				applied []*unstructured.Unstructured
+				results [][2]string
+				failed  int
+			)
+			for _, d := range docs {
+				u := t.New(d.Name, d.Spec)
+				res, err := applyOne(cmd, t, dyn, u, showDiff, serverSide, forceConflicts)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error: %s %s (document %d): %v\n", t.Kind, d.Name, d.Index, err)
+					res = "failed"
+					failed++
+				} else if !showDiff {
+					applied = append(applied, u)
+				}
+				results = append(results, [2]string{d.Name, res})
+			}
+			printCreateSummary(t, results)
+
+			if after != nil {
+				for _, u := range applied {
+					if err := after(cmd, dyn, u); err != nil {
+						fmt.Fprintf(os.Stderr, "error: %s %s: %v\n", t.Kind, u.GetName(), err)
+						failed++
+					}
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d %s failed", failed, len(docs), t.Plural())
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&specFile, "spec-file", "f", "", fmt.Sprintf("Path to YAML file containing the %s spec, or several separated by --- (required)", t.Kind))
+	cmd.Flags().StringVarP(&name, "name", "n", "", fmt.Sprintf("Name of the %s resource to create/update; the name prefix with several specs", t.Kind))
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Show the changes against the live resource and exit without applying")
+	cmd.Flags().BoolVar(&serverSide, "server-side", false, "Update with server-side apply so only the fields in the spec are changed (recommended)")
+	cmd.Flags().BoolVar(&forceConflicts, "force-conflicts", false, "With --server-side, take over the fields managed by others instead of failing")
+	return cmd
+}
+
+// createOne applies a single resource the way create always has: errors are
+// returned right away and after runs once it is applied.
+func createOne(cmd *cobra.Command, t *Type, dyn dynamic.Interface, u *unstructured.Unstructured, showDiff, serverSide, force bool, after AfterCreate) error {
+	if _, err := applyOne(cmd, t, dyn, u, showDiff, serverSide, force); err != nil {
+		return err
+	}
+	if after != nil && !showDiff {
+		return after(cmd, dyn, u)
+	}
+	return nil
+}
+
+// applyOne enforces the policies on u and creates or updates it, or with
+// showDiff only prints the changes. It returns the result of CreateOrUpdate,
+// or "unchanged"/"changed" with showDiff.
+func applyOne(cmd *cobra.Command, t *Type, dyn dynamic.Interface, u *unstructured.Unstructured, showDiff, serverSide, force bool) (string, error) {
+	name := u.GetName()
+	if err := policy.Enforce(cmd.Context(), u, debugf); err != nil {
+		return "", err
+	}
+	if t.Check != nil {
+		if err := t.Check(cmd.Context(), u); err != nil {
+			return "", err
+		}
+	}
+
+	if showDiff {
+		changed, err := t.Diff(cmd.Context(), os.Stdout, dyn, u)
+		if err != nil {
+			return "", fmt.Errorf("diff %s %s: %w", t.Kind, name, err)
+		}
+		if !changed {
+			fmt.Fprintf(os.Stdout, "%s %s: no changes\n", t.Kind, name)
+			return "unchanged", nil
+		}
+		return "changed", nil
+	}
+
+	res, err := t.CreateOrUpdate(cmd.Context(), dyn, u, serverSide, force)
+	if err != nil {
+		return "", fmt.Errorf("create/update %s %s: %w", t.Kind, name, err)
+	}
+	fmt.Fprintf(os.Stdout, "%s %s ensured successfully\n", t.Kind, name)
+	return res, nil
+}
+
+// printCreateSummary prints the result of every resource of a multi-document
+// create and the count per result.
+func printCreateSummary(t *Type, results [][2]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "\nNAME\tRESULT")
+	counts := map[string]int{}
+	var order []string
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\n", r[0], r[1])
+		if counts[r[1]] == 0 {
+			order = append(order, r[1])
+		}
+		counts[r[1]]++
+	}
+	_ = w.Flush()
+	parts := make([]string, 0, len(order))
+	for _, res := range order {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[res], res))
+	}
+	fmt.Printf("%d %s: %s\n", len(results), t.Plural(), strings.Join(parts, ", "))
+}
+
+// NewListCmd returns a list command printing t's printer columns. The
+// output flags default to the preferences in the config file.
+func NewListCmd(t *Type) *cobra.Command {
+	var (
+		watch         bool
+		format        string
+		columns       []string
+		sortBy        string
+		allNamespaces bool
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List " + t.Plural(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o := t.Preferences()
+			if cmd.Flags().Changed("output") {
+				o.Format = format
+			}
+			if cmd.Flags().Changed("columns") {
+				o.Columns = columns
+			}
+			if cmd.Flags().Changed("sort-by") {
+				o.SortBy = sortBy
+			}
+			o.AllNamespaces = allNamespaces
+			dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+			if err != nil {
+				return fmt.Errorf("build dynamic client: %w", err)
+			}
+			if watch {
+				return t.Watch(cmd.Context(), os.Stdout, dyn, o)
+			}
+			items, err := t.list(cmd.Context(), dyn, allNamespaces, "")
+			if err != nil {
+				return fmt.Errorf("listing %s: %w", t.Plural(), err)
+			}
+			if len(items) == 0 && (o.Format == "table" || o.Format == "wide") {
+				fmt.Printf("No %s found.\n", t.Plural())
+				return nil
+			}
+			return t.Print(os.Stdout, items, o)
+		},
+	}
+	cmd.PersistentFlags().BoolVarP(&watch, "watch", "w", false, "Watch "+t.Plural()+", redrawing the table with one row each and marking what changed")
+	cmd.Flags().StringVarP(&format, "output", "o", "table", "Output format: "+strings.Join(Formats, ", ")+" (config: output.format)")
+	cmd.Flags().StringSliceVar(&columns, "columns", nil, "Columns to show, separated by comma (config: output."+t.Name+".columns)")
+	cmd.Flags().StringVar(&sortBy, "sort-by", "NAME", "Column to sort by, prefixed with - for descending order (config: output.sortBy)")
+	if t.Namespace != "" {
+		cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List "+t.Plural()+" in all namespaces, not only the one of --namespace ("+t.Namespace+" by default)")
+	}
+	return cmd
+}
+
+// NewDeleteCmd returns a delete command taking the names as arguments or
+// through t's delete flag, or selecting the resources with -l or --all.
+// before is passed on to Delete.
+func NewDeleteCmd(t *Type, before BeforeDelete) *cobra.Command {
+	var (
+		names    []string
+		selector string
+		all      bool
+	)
+	flagName := t.DeleteFlag
+	if flagName == "" {
+		flagName = "name"
+	}
+	cmd := &cobra.Command{
+		Use:   "delete [name...]",
+		Short: "Delete " + t.Plural(),
+		Long: fmt.Sprintf(`Delete the %[1]s given by name, those matching the label selector of
+-l, or all of them with --all. The matching %[1]s are listed and deleted
+once confirmed; when there are more than delete.typedConfirmAbove of them
+(default %[2]d), their number has to be typed to confirm.`, t.Plural(), DefaultTypedConfirmAbove),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			named := append(append([]string{}, names...), args...)
+			if len(named) > 0 && (selector != "" || all) {
+				return fmt.Errorf("names cannot be combined with --selector or --all")
+			}
+			if len(named) == 0 && selector == "" && !all {
+				return cmd.Help()
+			}
+			dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+			if err != nil {
+				return fmt.Errorf("build dynamic client: %w", err)
+			}
+			if len(named) == 0 {
+				return t.DeleteSelected(cmd.Context(), dyn, selector, before)
+			}
+			return t.Delete(cmd.Context(), dyn, named, before)
+		},
+	}
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "Delete the "+t.Plural()+" matching this label selector, e.g. env=test")
+	cmd.Flags().BoolVar(&all, "all", false, "Delete all "+t.Plural())
+	cmd.MarkFlagsMutuallyExclusive("selector", "all")
+	cmd.PersistentFlags().StringSliceVarP(&names, flagName, "n", nil, t.Kind+" names, separated by comma")
+	return cmd
+}
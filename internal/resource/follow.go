@@ -0,0 +1,74 @@
+package resource
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// followRetry is how long Follow waits before listing again after an error.
+const followRetry = 5 * time.Second
+
+// Handler receives what Follow observes.
+type Handler struct {
+	// Synced receives the full list of resources, first and whenever the
+	// watch had to be restarted.
+	Synced func(items []unstructured.Unstructured)
+	// Changed receives every Added, Modified and Deleted event.
+	Changed func(typ watch.EventType, obj *unstructured.Unstructured)
+	// Failed, when not nil, receives list and watch errors; Follow retries
+	// after a short delay.
+	Failed func(err error)
+}
+
+// Follow keeps h informed of the resources of type t until ctx is done: it
+// lists them, then passes on the watch events, and lists again whenever the
+// watch ends.
+func (t *Type) Follow(ctx context.Context, dyn dynamic.Interface, h Handler) {
+	t.follow(ctx, t.Client(dyn), h)
+}
+
+// follow is Follow on the resources ri lists.
+func (t *Type) follow(ctx context.Context, ri dynamic.ResourceInterface, h Handler) {
+	failed := func(err error) {
+		if ctx.Err() != nil {
+			return
+		}
+		debugf("following %s: %v", t.Plural(), err)
+		if h.Failed != nil {
+			h.Failed(err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(followRetry):
+		}
+	}
+	for ctx.Err() == nil {
+		list, err := ri.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			failed(err)
+			continue
+		}
+		h.Synced(list.Items)
+
+		w, err := ri.Watch(ctx, metav1.ListOptions{ResourceVersion: list.GetResourceVersion()})
+		if err != nil {
+			failed(err)
+			continue
+		}
+		for ev := range w.ResultChan() {
+			if ev.Type == watch.Error {
+				// Mostly an expired resource version; list again.
+				break
+			}
+			if obj, ok := ev.Object.(*unstructured.Unstructured); ok {
+				h.Changed(ev.Type, obj)
+			}
+		}
+		w.Stop()
+	}
+}
@@ -0,0 +1,185 @@
+package resource
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ReadManifests reads the SkyCluster resources of every file, "-" being
+// stdin, and fails when none holds a resource. A directory stands for its
+// .yaml and .yml files, read recursively in lexical order.
+func ReadManifests(paths []string) ([]*unstructured.Unstructured, error) {
+	files, err := expandDirs(paths)
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	for _, f := range files {
+		docs, err := readManifests(f)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, docs...)
+	}
+	if len(objs) == 0 {
+		return nil, errors.New("no resources found in the given files")
+	}
+	return objs, nil
+}
+
+// expandDirs replaces the directories of paths with the YAML files under
+// them.
+func expandDirs(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		if p == "-" {
+			files = append(files, p)
+			continue
+		}
+		info, err := os.Stat(ExpandPath(p))
+		if err != nil || !info.IsDir() {
+			// readDocuments reports a missing file
+			files = append(files, p)
+			continue
+		}
+		// WalkDir visits the entries of a directory in lexical order.
+		err = filepath.WalkDir(ExpandPath(p), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", p, err)
+		}
+	}
+	return files, nil
+}
+
+// readManifests splits path into its YAML documents and validates each one.
+// Empty documents (e.g. a trailing ---) are skipped.
+func readManifests(path string) ([]*unstructured.Unstructured, error) {
+	docs, err := readDocuments(path)
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	for _, d := range docs {
+		u := &unstructured.Unstructured{Object: d.object}
+		if _, err := Validate(u); err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", path, d.index, err)
+		}
+		debugf("%s: document %d is %s %s", path, d.index, u.GetKind(), u.GetName())
+		objs = append(objs, u)
+	}
+	return objs, nil
+}
+
+// SpecDocument is the spec of one resource read from a spec file.
+type SpecDocument struct {
+	// Index is the number of the YAML document in the file, from 1.
+	Index int
+	Name  string
+	Spec  map[string]interface{}
+}
+
+// ReadSpecs reads the specs of a spec file, one per YAML document. A document
+// holds the spec fields of a t, optionally with metadata.name naming it, or a
+// full t manifest. name names a single spec; with several, it is the prefix
+// of the specs without metadata.name, followed by their number from 1.
+func (t *Type) ReadSpecs(path, name string) ([]SpecDocument, error) {
+	docs, err := readDocuments(path)
+	if err != nil {
+		return nil, fmt.Errorf("read spec file: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("spec file %s is empty", path)
+	}
+	specs := make([]SpecDocument, 0, len(docs))
+	seen := map[string]int{}
+	for _, d := range docs {
+		spec := d.object
+		if kind, ok := spec["kind"].(string); ok {
+			if kind != t.Kind {
+				return nil, fmt.Errorf("%s: document %d is a %s, not a %s", path, d.index, kind, t.Kind)
+			}
+			spec, _, _ = unstructured.NestedMap(d.object, "spec")
+		}
+		docName, _, _ := unstructured.NestedString(d.object, "metadata", "name")
+		delete(spec, "metadata")
+
+		switch {
+		case len(docs) == 1 && name != "":
+			docName = name
+		case docName == "" && name != "":
+			docName = fmt.Sprintf("%s-%d", name, len(specs)+1)
+		case docName == "":
+			return nil, fmt.Errorf("%s: document %d has no metadata.name; set it or pass --name", path, d.index)
+		}
+		if prev, ok := seen[docName]; ok {
+			return nil, fmt.Errorf("%s: documents %d and %d are both named %s", path, prev, d.index, docName)
+		}
+		seen[docName] = d.index
+		specs = append(specs, SpecDocument{Index: d.index, Name: docName, Spec: spec})
+	}
+	debugf("read %d specs from %s", len(specs), path)
+	return specs, nil
+}
+
+// document is a non-empty YAML document of a file, numbered from 1.
+type document struct {
+	index  int
+	object map[string]interface{}
+}
+
+// readDocuments splits path, "-" being stdin, into its YAML documents.
+// Empty documents (e.g. a trailing ---) are skipped.
+func readDocuments(path string) ([]document, error) {
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(ExpandPath(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var docs []document
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(raw)))
+	for i := 1; ; i++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: reading document %d: %w", path, i, err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		var m map[string]interface{}
+		if err := yaml.Unmarshal(doc, &m); err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", path, i, err)
+		}
+		if len(m) == 0 {
+			continue
+		}
+		docs = append(docs, document{index: i, object: m})
+	}
+	return docs, nil
+}
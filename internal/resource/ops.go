@@ -0,0 +1,293 @@
+package resource
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// Result values reported by CreateOrUpdate.
+const (
+	Created           = "created"
+	Configured        = "configured"
+	ServerSideApplied = "serverside-applied"
+)
+
+// CreateOrUpdate creates u, or merges it onto the existing resource, and
+// reports which of the two happened. With serverSide set, u is sent as a
+// server-side apply patch instead, taking over the fields managed by others
+// only with force.
+func (t *Type) CreateOrUpdate(ctx context.Context, dyn dynamic.Interface, u *unstructured.Unstructured, serverSide, force bool) (string, error) {
+	ri := t.ClientFor(dyn, u)
+	if serverSide {
+		debugf("server-side applying %s %s", t.Kind, u.GetName())
+		err := utils.Retry(ctx, func(ctx context.Context) error {
+			_, err := utils.ServerSideApply(ctx, ri, u, force)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		return ServerSideApplied, nil
+	}
+
+	// The resource is read again on every attempt, so that a conflicting
+	// change or a create that went through before a connection reset end in
+	// an update of the latest version.
+	var result string
+	err := utils.RetryOnConflict(ctx, func(ctx context.Context) error {
+		existing, err := ri.Get(ctx, u.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			debugf("%s %s not found, creating", t.Kind, u.GetName())
+			audit.Stamp(u)
+			created, err := ri.Create(ctx, u, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// created concurrently; update it on the next attempt
+				return apierrors.NewConflict(t.GVR.GroupResource(), u.GetName(), err)
+			}
+			if err != nil {
+				return err
+			}
+			debugf("created %s %s (uid: %v)", t.Kind, u.GetName(), created.GetUID())
+			result = Created
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Overlay u onto existing so unspecified fields are preserved.
+		debugf("%s %s exists (uid: %v), merging", t.Kind, u.GetName(), existing.GetUID())
+		merged := existing.DeepCopy()
+		merged.Object = MergeMaps(merged.Object, u.Object)
+		audit.StampUpdate(merged, existing)
+		if _, err := ri.Update(ctx, merged, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		result = Configured
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// Diff writes what CreateOrUpdate would change to w and reports whether
+// anything would.
+func (t *Type) Diff(ctx context.Context, w io.Writer, dyn dynamic.Interface, u *unstructured.Unstructured) (bool, error) {
+	return utils.DiffAgainstLive(ctx, w, t.ClientFor(dyn, u), u, MergeMaps)
+}
+
+// List returns the resources of type t sorted by name.
+func (t *Type) List(ctx context.Context, dyn dynamic.Interface) ([]unstructured.Unstructured, error) {
+	return t.list(ctx, dyn, false, "")
+}
+
+// ListAll lists the resources of type t in every namespace.
+func (t *Type) ListAll(ctx context.Context, dyn dynamic.Interface) ([]unstructured.Unstructured, error) {
+	return t.list(ctx, dyn, true, "")
+}
+
+func (t *Type) list(ctx context.Context, dyn dynamic.Interface, allNamespaces bool, selector string) ([]unstructured.Unstructured, error) {
+	list, err := t.listClient(dyn, allNamespaces).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].GetNamespace() != items[j].GetNamespace() {
+			return items[i].GetNamespace() < items[j].GetNamespace()
+		}
+		return items[i].GetName() < items[j].GetName()
+	})
+	return items, nil
+}
+
+// listClient returns the interface list and watch use: t's namespace, or
+// every namespace when allNamespaces is set.
+func (t *Type) listClient(dyn dynamic.Interface, allNamespaces bool) dynamic.ResourceInterface {
+	if allNamespaces {
+		return dyn.Resource(t.GVR)
+	}
+	return t.Client(dyn)
+}
+
+// Names returns the names of the resources of type t.
+func (t *Type) Names(ctx context.Context, dyn dynamic.Interface) ([]string, error) {
+	items, err := t.List(ctx, dyn)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(items))
+	for _, it := range items {
+		names = append(names, it.GetName())
+	}
+	return names, nil
+}
+
+// Delete looks up every name, shows them and deletes them once confirmed on
+// stdin. before, when not nil, runs right before each deletion.
+func (t *Type) Delete(ctx context.Context, dyn dynamic.Interface, names []string, before BeforeDelete) error {
+	ri := t.Client(dyn)
+	var items []unstructured.Unstructured
+	for _, n := range names {
+		obj, err := utils.GetWithSuggestions(ctx, ri, t.Name, n)
+		if err != nil {
+			return err
+		}
+		items = append(items, *obj)
+	}
+	return t.DeleteItems(ctx, dyn, items, before)
+}
+
+// DeleteSelected deletes the resources of type t matching the label
+// selector, or all of them when selector is empty, as DeleteItems does.
+func (t *Type) DeleteSelected(ctx context.Context, dyn dynamic.Interface, selector string, before BeforeDelete) error {
+	items, err := t.list(ctx, dyn, false, selector)
+	if err != nil {
+		return fmt.Errorf("listing %s: %w", t.Plural(), err)
+	}
+	return t.DeleteItems(ctx, dyn, items, before)
+}
+
+// DeleteItems shows items and deletes them once ConfirmDelete agrees.
+func (t *Type) DeleteItems(ctx context.Context, dyn dynamic.Interface, items []unstructured.Unstructured, before BeforeDelete) error {
+	if len(items) == 0 {
+		fmt.Printf("No %s found.\n", t.Plural())
+		return nil
+	}
+	ok, err := ConfirmDelete(os.Stdin, os.Stdout, t.Plural(), items)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Deletion cancelled.")
+		return nil
+	}
+
+	ri := t.Client(dyn)
+	fmt.Printf("Deleting %s...\n", t.Plural())
+	success := 0
+	for i := range items {
+		it := &items[i]
+		if before != nil {
+			if err := before(ctx, dyn, it); err != nil {
+				return err
+			}
+		}
+		debugf("deleting %s %s", t.Kind, it.GetName())
+		if err := utils.RetryDelete(ctx, ri, it.GetName(), metav1.DeleteOptions{}); err != nil {
+			fmt.Printf("Deleted %d/%d %s\n", success, len(items), t.Plural())
+			return fmt.Errorf("deleting %s %s: %w", t.Kind, it.GetName(), err)
+		}
+		success++
+	}
+	fmt.Printf("Deleted %d/%d %s\n", success, len(items), t.Plural())
+	return nil
+}
+
+// DefaultTypedConfirmAbove is how many resources a delete may match before
+// the count has to be typed to confirm it, unless delete.typedConfirmAbove
+// is set.
+const DefaultTypedConfirmAbove = 5
+
+// ConfirmDelete lists items on w and asks on r whether to delete them, as
+// AskDelete does.
+func ConfirmDelete(r io.Reader, w io.Writer, plural string, items []unstructured.Unstructured) (bool, error) {
+	writer := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tAGE\tLABELS")
+	for _, it := range items {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", it.GetName(), duration.HumanDuration(time.Since(it.GetCreationTimestamp().Time)), labelsString(it.GetLabels()))
+	}
+	writer.Flush()
+	return AskDelete(r, w, plural, len(items))
+}
+
+// AskDelete asks on r whether to delete n resources that have been shown.
+// Up to delete.typedConfirmAbove resources a "y" is enough; above, their
+// number has to be typed, so that a too broad selector is not confirmed by
+// habit. With --yes it does not ask; without it, a stdin that is not a
+// terminal is an error.
+func AskDelete(r io.Reader, w io.Writer, plural string, n int) (bool, error) {
+	if utils.AssumeYes() {
+		return true, nil
+	}
+	if err := utils.CanPrompt(r); err != nil {
+		return false, err
+	}
+	limit := DefaultTypedConfirmAbove
+	if viper.IsSet("delete.typedConfirmAbove") {
+		limit = viper.GetInt("delete.typedConfirmAbove")
+	}
+	reader := bufio.NewReader(r)
+	if n <= limit {
+		fmt.Fprintf(w, "Deleting these %s? (y/N): ", plural)
+		response, _ := reader.ReadString('\n')
+		return strings.TrimSpace(strings.ToLower(response)) == "y", nil
+	}
+	fmt.Fprintf(w, "This deletes %d %s. Type %d to confirm: ", n, plural, n)
+	response, _ := reader.ReadString('\n')
+	return strings.TrimSpace(response) == strconv.Itoa(n), nil
+}
+
+func labelsString(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// MergeMaps overlays src onto dst recursively. Maps are merged key by key;
+// any other value from src, including slices, replaces the one in dst. Nil
+// values in src are skipped. dst is mutated and returned.
+func MergeMaps(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+	for k, sv := range src {
+		if sv == nil {
+			continue
+		}
+		if svMap, ok := sv.(map[string]interface{}); ok {
+			dvMap, _ := dst[k].(map[string]interface{})
+			dst[k] = MergeMaps(dvMap, svMap)
+			continue
+		}
+		dst[k] = sv
+	}
+	return dst
+}
+
+// ExpandPath expands leading '~' to the user home directory.
+func ExpandPath(p string) string {
+	if strings.HasPrefix(p, "~/") || p == "~" {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~/"))
+		}
+	}
+	return p
+}
@@ -0,0 +1,242 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// Output formats understood by Print.
+var Formats = []string{"table", "wide", "name", "yaml", "json"}
+
+// Output holds how resources are listed. The defaults come from the "output"
+// section of the config file, where a section named after a type overrides
+// the global keys for that type:
+//
+//	output:
+//	  format: table
+//	  sortBy: NAME
+//	  noColor: true
+//	  xkube:
+//	    columns: [NAME, PLATFORM, READY]
+//	    sortBy: -READY
+type Output struct {
+	// Format is one of Formats.
+	Format string
+	// Columns are the headers of the table columns, in order; empty shows
+	// all. The wide format always shows all.
+	Columns []string
+	// SortBy is the header of the column to sort by, prefixed with "-" for
+	// descending order.
+	SortBy string
+	// AllNamespaces lists a namespaced type in every namespace instead of
+	// its own, with a NAMESPACE column first.
+	AllNamespaces bool
+}
+
+// Preferences returns the output preferences of t from the config file.
+func (t *Type) Preferences() Output {
+	get := func(key string) string {
+		if v := strings.TrimSpace(viper.GetString("output." + t.Name + "." + key)); v != "" {
+			return v
+		}
+		return strings.TrimSpace(viper.GetString("output." + key))
+	}
+	o := Output{Format: get("format"), SortBy: get("sortBy")}
+	o.Columns = viper.GetStringSlice("output." + t.Name + ".columns")
+	if len(o.Columns) == 0 {
+		o.Columns = viper.GetStringSlice("output.columns")
+	}
+	if o.Format == "" {
+		o.Format = "table"
+	}
+	return o
+}
+
+// Print writes items to w as o describes.
+func (t *Type) Print(w io.Writer, items []unstructured.Unstructured, o Output) error {
+	tbl, opts, err := t.sortedTable(items, o)
+	if err != nil {
+		return err
+	}
+	var out []byte
+	switch o.Format {
+	case "", "table", "wide":
+		return tbl.Write(w, utils.TableOptions{Columns: opts.Columns})
+	case "name":
+		for i := range items {
+			fmt.Fprintf(w, "%s/%s\n", t.Name, items[i].GetName())
+		}
+		return nil
+	case "json", "yaml":
+		objs := make([]interface{}, 0, len(items))
+		for i := range items {
+			objs = append(objs, items[i].Object)
+		}
+		list := map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": objs}
+		if o.Format == "json" {
+			out, err = json.MarshalIndent(list, "", "  ")
+			out = append(out, '\n')
+		} else {
+			out, err = yaml.Marshal(list)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+	return fmt.Errorf("unknown output format %q (supported: %s)", o.Format, strings.Join(Formats, ", "))
+}
+
+// allColumns returns NAME followed by the type's columns.
+func (t *Type) allColumns() []Column {
+	name := Column{"NAME", func(obj *unstructured.Unstructured) string { return obj.GetName() }}
+	return append([]Column{name}, t.Columns...)
+}
+
+// messageWidth is how much of a condition message the MESSAGE column shows.
+const messageWidth = 60
+
+// wideColumns are shown after the columns of every type by the wide format,
+// and can be picked with --columns.
+var wideColumns = []Column{
+	{"AGE", func(obj *unstructured.Unstructured) string {
+		return duration.HumanDuration(time.Since(obj.GetCreationTimestamp().Time))
+	}},
+	{"REASON", orDash(func(obj *unstructured.Unstructured) string { return lastCondition(obj)["reason"] })},
+	{"MESSAGE", orDash(func(obj *unstructured.Unstructured) string {
+		msg := strings.Join(strings.Fields(lastCondition(obj)["message"]), " ")
+		if r := []rune(msg); len(r) > messageWidth {
+			msg = string(r[:messageWidth-3]) + "..."
+		}
+		return msg
+	})},
+}
+
+// lastCondition returns the condition of obj that explains its state: the
+// latest one that is not True, or else the latest one.
+func lastCondition(obj *unstructured.Unstructured) map[string]string {
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	var last map[string]string
+	lastFailing := false
+	for _, c := range conds {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cond := map[string]string{}
+		for _, k := range []string{"type", "status", "reason", "message", "lastTransitionTime"} {
+			cond[k], _ = m[k].(string)
+		}
+		failing := cond["status"] != "True"
+		switch {
+		case last == nil, failing && !lastFailing:
+		case failing != lastFailing:
+			continue
+		case cond["lastTransitionTime"] < last["lastTransitionTime"]:
+			// RFC 3339 times of the same zone order as strings
+			continue
+		}
+		last, lastFailing = cond, failing
+	}
+	return last
+}
+
+// availableColumns returns the columns that can be shown or sorted by.
+func (t *Type) availableColumns() []Column {
+	return append(t.allColumns(), wideColumns...)
+}
+
+// table returns items as a table of the available columns, after NAMESPACE
+// when listing all namespaces, and the options selecting the columns and the
+// order o asks for. The wide format shows every column; without columns,
+// those of allColumns are shown. The rows are in the order of items.
+func (t *Type) table(items []unstructured.Unstructured, o Output) (*utils.Table, utils.TableOptions) {
+	cols := t.availableColumns()
+	var first []string
+	if o.AllNamespaces {
+		ns := Column{"NAMESPACE", func(obj *unstructured.Unstructured) string { return obj.GetNamespace() }}
+		cols = append([]Column{ns}, cols...)
+		first = []string{ns.Header}
+	}
+	opts := utils.TableOptions{SortBy: o.SortBy}
+	if opts.SortBy == "" {
+		opts.SortBy = "NAME"
+	}
+	switch {
+	case o.Format == "wide":
+	case len(o.Columns) == 0:
+		opts.Columns = append(first, headers(t.allColumns())...)
+	default:
+		opts.Columns = append(first, o.Columns...)
+	}
+	tbl := utils.NewTable(headers(cols)...)
+	for i := range items {
+		tbl.Append(cells(cols, &items[i])...)
+	}
+	return tbl, opts
+}
+
+// sortedTable sorts items as o asks, by name on ties, and returns their
+// table. The ages shown are rounded, so AGE sorts by the creation time.
+func (t *Type) sortedTable(items []unstructured.Unstructured, o Output) (*utils.Table, utils.TableOptions, error) {
+	slices.SortStableFunc(items, func(a, b unstructured.Unstructured) int { return strings.Compare(a.GetName(), b.GetName()) })
+	tbl, opts := t.table(items, o)
+	if err := tbl.Check(opts); err != nil {
+		return nil, opts, fmt.Errorf("%s: %w", t.Plural(), err)
+	}
+	keys := tbl
+	if i, ok := tbl.Column(strings.TrimPrefix(opts.SortBy, "-")); ok && tbl.Headers[i] == "AGE" {
+		keys = utils.NewTable(tbl.Headers...)
+		for k, r := range tbl.Rows {
+			r = slices.Clone(r)
+			r[i] = fmt.Sprint(int64(time.Since(items[k].GetCreationTimestamp().Time).Seconds()))
+			keys.Append(r...)
+		}
+	}
+	less, err := keys.Less(opts.SortBy)
+	if err != nil {
+		return nil, opts, err
+	}
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return less(keys.Rows[idx[a]], keys.Rows[idx[b]]) })
+	sorted := make([]unstructured.Unstructured, len(items))
+	rows := make([][]string, len(items))
+	for k, i := range idx {
+		sorted[k], rows[k] = items[i], tbl.Rows[i]
+	}
+	copy(items, sorted)
+	tbl.Rows = rows
+	return tbl, opts, nil
+}
+
+func headers(cols []Column) []string {
+	hs := make([]string, 0, len(cols))
+	for _, c := range cols {
+		hs = append(hs, c.Header)
+	}
+	return hs
+}
+
+func cells(cols []Column, obj *unstructured.Unstructured) []string {
+	out := make([]string, 0, len(cols))
+	for _, c := range cols {
+		out = append(out, c.Value(obj))
+	}
+	return out
+}
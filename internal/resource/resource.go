@@ -0,0 +1,182 @@
+// Package resource describes the SkyCluster custom resources the CLI manages
+// and implements the create/list/delete logic shared by all of them. Adding a
+// Type to the registry is enough to get full CRUD commands for a new CRD.
+package resource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// Column is a printer column shown by list. Value returns the cell for obj.
+type Column struct {
+	Header string
+	Value  func(obj *unstructured.Unstructured) string
+}
+
+// Type describes one SkyCluster custom resource.
+type Type struct {
+	// Name is the lower-case singular name, used as the command name.
+	Name string
+	// Kind is the CRD kind, e.g. "XProvider".
+	Kind string
+	GVR  schema.GroupVersionResource
+	// Namespace is where a namespaced type lives unless --namespace says
+	// otherwise; empty for cluster-scoped types.
+	Namespace string
+	// Columns are printed after NAME by list.
+	Columns []Column
+	// DeleteFlag is the name of the delete flag holding the resources to
+	// delete. Defaults to "name".
+	DeleteFlag string
+	// Check, when set, validates an object before create submits it, e.g.
+	// against what its provider offers.
+	Check func(ctx context.Context, u *unstructured.Unstructured) error
+}
+
+var registry = map[string]*Type{}
+
+// Register adds t to the registry and returns it. Registering the same kind
+// twice panics, as that is a programming error.
+func Register(t *Type) *Type {
+	if _, ok := registry[t.Kind]; ok {
+		panic(fmt.Sprintf("resource: kind %s registered twice", t.Kind))
+	}
+	registry[t.Kind] = t
+	return t
+}
+
+// ForKind returns the registered type of kind.
+func ForKind(kind string) (*Type, bool) {
+	t, ok := registry[kind]
+	return t, ok
+}
+
+// ForName returns the registered type whose command name is name.
+func ForName(name string) (*Type, bool) {
+	for _, t := range registry {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// All returns the registered types sorted by name.
+func All() []*Type {
+	out := make([]*Type, 0, len(registry))
+	for _, t := range registry {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Kinds returns the registered kinds sorted by name, for messages.
+func Kinds() []string {
+	var out []string
+	for _, t := range All() {
+		out = append(out, t.Kind)
+	}
+	return out
+}
+
+// APIVersion returns the apiVersion objects of t carry.
+func (t *Type) APIVersion() string {
+	return t.GVR.GroupVersion().String()
+}
+
+// Plural returns the kind in plural form for messages, e.g. "XProviders".
+func (t *Type) Plural() string {
+	return t.Kind + "s"
+}
+
+// CurrentNamespace returns the namespace the commands work in for t: the
+// one of --namespace, or else t's; empty for cluster-scoped types.
+func (t *Type) CurrentNamespace() string {
+	if t.Namespace == "" {
+		return ""
+	}
+	return utils.Namespace(t.Namespace)
+}
+
+// Client returns the resource interface for t in its current namespace.
+func (t *Type) Client(dyn dynamic.Interface) dynamic.ResourceInterface {
+	if t.Namespace == "" {
+		return dyn.Resource(t.GVR)
+	}
+	return dyn.Resource(t.GVR).Namespace(t.CurrentNamespace())
+}
+
+// ClientFor returns the resource interface for u, defaulting its namespace
+// to the current one of the type when u does not set it.
+func (t *Type) ClientFor(dyn dynamic.Interface, u *unstructured.Unstructured) dynamic.ResourceInterface {
+	if u.GetNamespace() == "" && t.Namespace != "" {
+		u.SetNamespace(t.CurrentNamespace())
+	}
+	if u.GetNamespace() == "" {
+		return dyn.Resource(t.GVR)
+	}
+	return dyn.Resource(t.GVR).Namespace(u.GetNamespace())
+}
+
+// New returns an object of type t with the given name and spec.
+func (t *Type) New(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name}
+	if t.Namespace != "" {
+		metadata["namespace"] = t.CurrentNamespace()
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": t.APIVersion(),
+		"kind":       t.Kind,
+		"metadata":   metadata,
+		"spec":       spec,
+	}}
+}
+
+// LegacyGroup is the API group of the SkyCluster resources before they moved
+// to skycluster.io.
+const LegacyGroup = "xrds.skycluster.io"
+
+// UseLegacyGroup makes the registered types of skycluster.io use LegacyGroup
+// instead, for management clusters still serving the old API group.
+func UseLegacyGroup() {
+	for _, t := range registry {
+		if t.GVR.Group == "skycluster.io" {
+			debugf("using %s for %s", LegacyGroup, t.Kind)
+			t.GVR.Group = LegacyGroup
+		}
+	}
+}
+
+// Validate checks that u is a complete object of a registered kind.
+func Validate(u *unstructured.Unstructured) (*Type, error) {
+	if u.GetAPIVersion() == "" || u.GetKind() == "" {
+		return nil, fmt.Errorf("apiVersion and kind are required")
+	}
+	t, ok := ForKind(u.GetKind())
+	if !ok {
+		return nil, fmt.Errorf("unsupported kind %q (supported: %s)", u.GetKind(), strings.Join(Kinds(), ", "))
+	}
+	if u.GetAPIVersion() != t.APIVersion() {
+		return nil, fmt.Errorf("%s must have apiVersion %s, got %s", u.GetKind(), t.APIVersion(), u.GetAPIVersion())
+	}
+	if u.GetName() == "" {
+		return nil, fmt.Errorf("%s has no metadata.name", u.GetKind())
+	}
+	return t, nil
+}
+
+// debugf logs a debug message of the resource commands.
+func debugf(format string, args ...interface{}) {
+	log.Debugf("resource", format, args...)
+}
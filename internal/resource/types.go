@@ -0,0 +1,137 @@
+package resource
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// The registered SkyCluster resources.
+var (
+	XProvider = Register(&Type{
+		Name:       "xprovider",
+		Kind:       "XProvider",
+		GVR:        schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xproviders"},
+		DeleteFlag: "provider-name",
+		Columns: []Column{
+			{"PRIVATE_IP", field("status", "gateway", "privateIp")},
+			{"PUBLIC_IP", field("status", "gateway", "publicIp")},
+			{"CIDR_BLOCK", field("spec", "vpcCidr")},
+		},
+	})
+
+	XKube = Register(&Type{
+		Name:       "xkube",
+		Kind:       "XKube",
+		GVR:        schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xkubes"},
+		DeleteFlag: "provider-name",
+		Columns: []Column{
+			{"PLATFORM", field("spec", "providerRef", "platform")},
+			{"POD_CIDR", field("status", "podCidr")},
+			{"SERVICE_CIDR", field("status", "serviceCidr")},
+			{"LOCATION", field("spec", "providerRef", "zones", "primary")},
+			{"EXTERNAL_NAME", field("status", "externalClusterName")},
+			{"READY", condition("Ready")},
+		},
+	})
+
+	XInstance = Register(&Type{
+		Name:       "xinstance",
+		Kind:       "XInstance",
+		GVR:        schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xinstances"},
+		DeleteFlag: "instance-name",
+		Columns: []Column{
+			{"PROVIDER", field("status", "providerName")},
+			{"FLAVOR", orDash(field("spec", "flavor"))},
+			{"GPU", func(obj *unstructured.Unstructured) string {
+				flavor, _, _ := unstructured.NestedString(obj.Object, "spec", "flavor")
+				if flavor == "" {
+					return "-"
+				}
+				return utils.GPUFromFlavorName(flavor).String()
+			}},
+			{"PRIVATE_IP", orDash(field("status", "network", "privateIp"))},
+			{"PUBLIC_IP", orDash(field("status", "network", "publicIp"))},
+			{"SPOT", func(obj *unstructured.Unstructured) string {
+				v, found, _ := unstructured.NestedBool(obj.Object, "status", "spotInstance")
+				if !found {
+					return "-"
+				}
+				s := fmt.Sprint(v)
+				return strings.ToUpper(s[:1]) + s[1:]
+			}},
+			{"SYNC", func(obj *unstructured.Unstructured) string {
+				if s := utils.GetConditionStatus(obj, "Synced"); s != "" {
+					return s
+				}
+				return utils.GetConditionStatus(obj, "Sync")
+			}},
+			{"READY", condition("Ready")},
+		},
+	})
+
+	XSetup = Register(&Type{
+		Name: "xsetup",
+		Kind: "XSetup",
+		GVR:  schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xsetups"},
+		Columns: []Column{
+			{"SYNC", condition("Synced")},
+			{"READY", condition("Ready")},
+		},
+	})
+
+	XKubeMesh = Register(&Type{
+		Name: "xkubemesh",
+		Kind: "XKubeMesh",
+		GVR:  schema.GroupVersionResource{Group: "skycluster.io", Version: "v1alpha1", Resource: "xkubemeshes"},
+		Columns: []Column{
+			{"CLUSTERS", func(obj *unstructured.Unstructured) string {
+				names, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "clusterNames")
+				return strings.Join(names, ",")
+			}},
+			{"SYNC", condition("Synced")},
+			{"READY", condition("Ready")},
+		},
+	})
+
+	ProviderProfile = Register(&Type{
+		Name:      "profile",
+		Kind:      "ProviderProfile",
+		GVR:       schema.GroupVersionResource{Group: "core.skycluster.io", Version: "v1alpha1", Resource: "providerprofiles"},
+		Namespace: utils.SystemNamespace,
+		Columns: []Column{
+			{"PLATFORM", field("status", "platform")},
+			{"REGION", field("status", "region")},
+			{"READY", condition("Ready")},
+		},
+	})
+)
+
+// field returns a column value reading the string at path.
+func field(path ...string) func(*unstructured.Unstructured) string {
+	return func(obj *unstructured.Unstructured) string {
+		v, _, _ := unstructured.NestedString(obj.Object, path...)
+		return v
+	}
+}
+
+// condition returns a column value reading the status of a condition.
+func condition(condType string) func(*unstructured.Unstructured) string {
+	return func(obj *unstructured.Unstructured) string {
+		return utils.GetConditionStatus(obj, condType)
+	}
+}
+
+// orDash shows "-" for empty values of f.
+func orDash(f func(*unstructured.Unstructured) string) func(*unstructured.Unstructured) string {
+	return func(obj *unstructured.Unstructured) string {
+		if v := f(obj); v != "" {
+			return v
+		}
+		return "-"
+	}
+}
@@ -0,0 +1,282 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// watchHighlight is how long Watch marks a row and highlights its changed
+// cells; deleted rows are dropped after it.
+const watchHighlight = 5 * time.Second
+
+// Event markers Watch shows next to a changed row.
+const (
+	markerAdded    = "ADDED"
+	markerModified = "MODIFIED"
+	markerDeleted  = "DELETED"
+)
+
+// watchRow is the latest state of a watched resource.
+type watchRow struct {
+	obj     unstructured.Unstructured
+	cells   []string
+	changed []bool
+	marker  string
+	at      time.Time
+}
+
+// watchTable keeps one row per watched resource.
+type watchTable struct {
+	mu      sync.Mutex
+	t       *Type
+	o       Output
+	opts    utils.TableOptions
+	headers []string
+	rows    map[string]*watchRow
+	err     error
+}
+
+// cells returns the cells of obj in the columns shown.
+func (tbl *watchTable) cells(obj *unstructured.Unstructured) []string {
+	full, _ := tbl.t.table([]unstructured.Unstructured{*obj}, tbl.o)
+	return full.Select(tbl.opts).Rows[0]
+}
+
+// Watch shows the resources of type t with the columns o selects until ctx
+// is done. On a terminal the table is redrawn in place with one row per
+// resource, marking added, modified and deleted ones and highlighting the
+// cells that changed; otherwise every change is printed as a new row.
+func (t *Type) Watch(ctx context.Context, w io.Writer, dyn dynamic.Interface, o Output) error {
+	empty, opts, err := t.sortedTable(nil, o)
+	if err != nil {
+		return err
+	}
+	tbl := &watchTable{t: t, o: o, opts: opts, headers: empty.Select(opts).Headers, rows: map[string]*watchRow{}}
+	if f, ok := w.(*os.File); !ok || !term.IsTerminal(int(f.Fd())) {
+		return tbl.stream(ctx, w, dyn)
+	}
+
+	area, err := pterm.DefaultArea.Start()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = area.Stop() }()
+	render := func() { area.Update(tbl.render(time.Now())) }
+
+	go t.follow(ctx, t.listClient(dyn, o.AllNamespaces), Handler{
+		Synced: func(items []unstructured.Unstructured) {
+			tbl.sync(items)
+			render()
+		},
+		Changed: func(typ watch.EventType, obj *unstructured.Unstructured) {
+			tbl.change(typ, obj, time.Now())
+			render()
+		},
+		Failed: func(err error) {
+			tbl.fail(err)
+			render()
+		},
+	})
+	// redraw to let the markers and highlights of past changes fade
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+			render()
+		}
+	}
+}
+
+// stream prints a row with an event column for every change, for output
+// that cannot be redrawn.
+func (tbl *watchTable) stream(ctx context.Context, w io.Writer, dyn dynamic.Interface) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(tbl.headers, "\t")+"\tEVENT")
+	writer.Flush()
+	emit := func(obj *unstructured.Unstructured, marker string) {
+		fmt.Fprintln(writer, strings.Join(tbl.cells(obj), "\t")+"\t"+marker)
+		writer.Flush()
+	}
+	tbl.t.follow(ctx, tbl.t.listClient(dyn, tbl.o.AllNamespaces), Handler{
+		Synced: func(items []unstructured.Unstructured) {
+			// after a relist, only print what differs from the last rows
+			tbl.mu.Lock()
+			defer tbl.mu.Unlock()
+			seen := map[string]bool{}
+			for i := range items {
+				key := watchKey(&items[i])
+				seen[key] = true
+				cells := tbl.cells(&items[i])
+				if r, ok := tbl.rows[key]; ok && slices.Equal(r.cells, cells) {
+					continue
+				}
+				tbl.rows[key] = &watchRow{obj: items[i], cells: cells}
+				emit(&items[i], "")
+			}
+			for key, r := range tbl.rows {
+				if !seen[key] {
+					delete(tbl.rows, key)
+					emit(&r.obj, markerDeleted)
+				}
+			}
+		},
+		Changed: func(typ watch.EventType, obj *unstructured.Unstructured) {
+			tbl.mu.Lock()
+			defer tbl.mu.Unlock()
+			key := watchKey(obj)
+			cells := tbl.cells(obj)
+			r, ok := tbl.rows[key]
+			switch {
+			case typ == watch.Deleted:
+				delete(tbl.rows, key)
+				emit(obj, markerDeleted)
+				return
+			case ok && slices.Equal(r.cells, cells):
+				// nothing shown changed, e.g. only the resource version
+				return
+			}
+			marker := markerModified
+			if !ok {
+				marker = markerAdded
+			}
+			tbl.rows[key] = &watchRow{obj: *obj.DeepCopy(), cells: cells}
+			emit(obj, marker)
+		},
+		Failed: func(err error) {
+			fmt.Fprintf(os.Stderr, "warning: watching %s: %v\n", tbl.t.Plural(), err)
+		},
+	})
+	return nil
+}
+
+// sync replaces the rows with items, keeping the markers of the rows that
+// did not change.
+func (tbl *watchTable) sync(items []unstructured.Unstructured) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	tbl.err = nil
+	rows := make(map[string]*watchRow, len(items))
+	for i := range items {
+		key := watchKey(&items[i])
+		cells := tbl.cells(&items[i])
+		r := &watchRow{obj: items[i], cells: cells, changed: make([]bool, len(cells))}
+		if old, ok := tbl.rows[key]; ok && old.marker != markerDeleted {
+			r.marker, r.at = old.marker, old.at
+			copy(r.changed, old.changed)
+		}
+		rows[key] = r
+	}
+	tbl.rows = rows
+}
+
+// change records a watch event at now.
+func (tbl *watchTable) change(typ watch.EventType, obj *unstructured.Unstructured, now time.Time) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	tbl.err = nil
+	key := watchKey(obj)
+	old, existed := tbl.rows[key]
+	if typ == watch.Deleted {
+		if existed {
+			old.marker, old.at = markerDeleted, now
+		}
+		return
+	}
+	cells := tbl.cells(obj)
+	r := &watchRow{obj: *obj.DeepCopy(), cells: cells, changed: make([]bool, len(cells)), marker: markerAdded, at: now}
+	if existed && old.marker != markerDeleted {
+		r.marker = markerModified
+		same := true
+		for i := range cells {
+			r.changed[i] = i < len(old.cells) && cells[i] != old.cells[i]
+			same = same && !r.changed[i]
+		}
+		if same {
+			// nothing shown changed; keep the earlier marker
+			r.changed, r.marker, r.at = old.changed, old.marker, old.at
+		}
+	}
+	tbl.rows[key] = r
+}
+
+// fail records a list or watch error, shown under the table until the
+// next event.
+func (tbl *watchTable) fail(err error) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	tbl.err = err
+}
+
+// render returns the table as of now, dropping the rows deleted earlier
+// than watchHighlight ago.
+func (tbl *watchTable) render(now time.Time) string {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	items := make([]unstructured.Unstructured, 0, len(tbl.rows))
+	for key, r := range tbl.rows {
+		if r.marker == markerDeleted && now.Sub(r.at) > watchHighlight {
+			delete(tbl.rows, key)
+			continue
+		}
+		items = append(items, r.obj)
+	}
+	_, _, _ = tbl.t.sortedTable(items, tbl.o)
+
+	data := [][]string{append(slices.Clone(tbl.headers), "EVENT")}
+	for i := range items {
+		r := tbl.rows[watchKey(&items[i])]
+		fresh := r.marker != "" && now.Sub(r.at) <= watchHighlight
+		line := make([]string, 0, len(r.cells)+1)
+		for j, c := range r.cells {
+			switch {
+			case fresh && r.marker == markerDeleted:
+				c = pterm.Gray(c)
+			case fresh && r.changed[j]:
+				c = pterm.Yellow(c)
+			}
+			line = append(line, c)
+		}
+		marker := ""
+		if fresh {
+			switch r.marker {
+			case markerAdded:
+				marker = pterm.Green(r.marker)
+			case markerModified:
+				marker = pterm.Yellow(r.marker)
+			case markerDeleted:
+				marker = pterm.Red(r.marker)
+			}
+		}
+		data = append(data, append(line, marker))
+	}
+	out, _ := pterm.DefaultTable.WithHasHeader().WithData(data).Srender()
+	if len(items) == 0 {
+		out = fmt.Sprintf("No %s found.", tbl.t.Plural())
+	}
+	if tbl.err != nil {
+		out += "\n" + pterm.Red(fmt.Sprintf("watching %s: %v (retrying)", tbl.t.Plural(), tbl.err))
+	}
+	return out
+}
+
+func watchKey(obj *unstructured.Unstructured) string {
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -0,0 +1,192 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// Wizard asks the user for the spec of a new resource. It returns the spec
+// and a name to offer for the resource.
+type Wizard func(ctx context.Context, dyn dynamic.Interface) (spec map[string]interface{}, name string, err error)
+
+// WithWizard adds --interactive to the create command cmd of t. With it, w
+// builds the spec, which is shown, saved to a file and, if the user agrees,
+// created by cmd as if it had been passed with --spec-file.
+func WithWizard(cmd *cobra.Command, t *Type, w Wizard) *cobra.Command {
+	var interactive bool
+	create := cmd.RunE
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, fmt.Sprintf("Build the %s spec step by step, then save and optionally create it", t.Kind))
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if !interactive {
+			return create(cmd, args)
+		}
+		if cmd.Flags().Changed("spec-file") {
+			return errors.New("--interactive and --spec-file cannot be combined")
+		}
+		if !utils.IsInteractive() {
+			return errors.New("--interactive needs a terminal")
+		}
+		dyn, err := utils.GetDynamicClient(viper.GetString("kubeconfig"))
+		if err != nil {
+			return fmt.Errorf("build dynamic client: %w", err)
+		}
+		spec, name, err := w(cmd.Context(), dyn)
+		if err != nil {
+			return err
+		}
+		if n, _ := cmd.Flags().GetString("name"); n != "" {
+			name = n
+		}
+		if name, err = utils.Ask(fmt.Sprintf("%s name", t.Kind), name); err != nil {
+			return err
+		}
+
+		out, err := yaml.Marshal(spec)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s", out)
+		path, err := utils.Ask("Save the spec to", name+".yaml")
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err == nil {
+			ok, err := utils.Confirm(fmt.Sprintf("%s exists. Overwrite it?", path), false)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("aborted; the spec was not saved")
+			}
+		}
+		if err := os.WriteFile(path, out, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Saved the spec to %s\n", path)
+
+		ok, err := utils.Confirm(fmt.Sprintf("Create %s %s now?", t.Kind, name), true)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Printf("Create it later with:\n  %s -n %s -f %s --server-side\n", cmd.CommandPath(), name, path)
+			return nil
+		}
+		if err := cmd.Flags().Set("spec-file", path); err != nil {
+			return err
+		}
+		if err := cmd.Flags().Set("name", name); err != nil {
+			return err
+		}
+		return create(cmd, args)
+	}
+	return cmd
+}
+
+// PickProviderRef asks for a platform, a region and a zone among those of the
+// ProviderProfiles and returns them as a providerRef, along with the profile.
+func PickProviderRef(ctx context.Context, dyn dynamic.Interface) (map[string]interface{}, *unstructured.Unstructured, error) {
+	profiles, err := ProviderProfile.List(ctx, dyn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing providerprofiles: %w", err)
+	}
+	if len(profiles) == 0 {
+		return nil, nil, errors.New("no ProviderProfile found; create one with 'skycluster profile create' first")
+	}
+
+	var platforms []string
+	for i := range profiles {
+		if p := profileField(&profiles[i], "platform"); p != "" && !slices.Contains(platforms, p) {
+			platforms = append(platforms, p)
+		}
+	}
+	sort.Strings(platforms)
+	platform, err := utils.PickOne("Platform", plainOptions(platforms))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var regions []utils.PickOption
+	byRegion := map[string]*unstructured.Unstructured{}
+	for i := range profiles {
+		p := &profiles[i]
+		region := profileField(p, "region")
+		if profileField(p, "platform") != platform || region == "" || byRegion[region] != nil {
+			continue
+		}
+		byRegion[region] = p
+		regions = append(regions, utils.PickOption{Name: region, Ready: utils.GetConditionStatus(p, "Ready") == "True"})
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].Name < regions[j].Name })
+	region, err := utils.PickOne("Region", regions)
+	if err != nil {
+		return nil, nil, err
+	}
+	profile := byRegion[region]
+
+	// The default zone is offered first; disabled zones are left out.
+	var zones []string
+	list, _, _ := unstructured.NestedSlice(profile.Object, "spec", "zones")
+	for _, z := range list {
+		zm, ok := z.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := zm["name"].(string)
+		if enabled, ok := zm["enabled"].(bool); name == "" || ok && !enabled {
+			continue
+		}
+		if d, _ := zm["defaultZone"].(bool); d {
+			zones = append([]string{name}, zones...)
+			continue
+		}
+		zones = append(zones, name)
+	}
+	var zone string
+	if len(zones) == 0 {
+		zone, err = utils.Ask(fmt.Sprintf("ProviderProfile %s has no enabled zone. Zone", profile.GetName()), "")
+	} else {
+		zone, err = utils.PickOne("Zone", plainOptions(zones))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ref := map[string]interface{}{
+		"platform": platform,
+		"region":   region,
+		"zones":    map[string]interface{}{"primary": zone},
+	}
+	return ref, profile, nil
+}
+
+// profileField returns a top-level field of the spec of a ProviderProfile,
+// falling back to its status.
+func profileField(p *unstructured.Unstructured, key string) string {
+	if s, _, _ := unstructured.NestedString(p.Object, "spec", key); s != "" {
+		return s
+	}
+	s, _, _ := unstructured.NestedString(p.Object, "status", key)
+	return s
+}
+
+// plainOptions returns picker options for names that have no readiness.
+func plainOptions(names []string) []utils.PickOption {
+	opts := make([]utils.PickOption, 0, len(names))
+	for _, n := range names {
+		opts = append(opts, utils.PickOption{Name: n, Ready: true})
+	}
+	return opts
+}
@@ -0,0 +1,44 @@
+//go:build !unix
+
+package state
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleAfter is the age after which a lock file is taken to be left behind
+// by an invocation that was killed.
+const staleAfter = 10 * time.Minute
+
+// fileLock is a lock file created exclusively; readers take the same lock
+// as writers on this platform.
+type fileLock struct {
+	path string
+}
+
+func tryLock(exclusive bool) (*fileLock, error) {
+	if err := os.MkdirAll(Dir(), 0o700); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", Dir(), err)
+	}
+	p := lockPath() + ".held"
+	if fi, err := os.Stat(p); err == nil && time.Since(fi.ModTime()) > staleAfter {
+		os.Remove(p)
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if os.IsExist(err) {
+		return nil, errLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking %s: %w", p, err)
+	}
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Close()
+	_ = os.WriteFile(lockPath(), []byte(fmt.Sprintf("%d\n", os.Getpid())), 0o600)
+	return &fileLock{path: p}, nil
+}
+
+func (l *fileLock) unlock() {
+	os.Remove(l.path)
+}
@@ -0,0 +1,231 @@
+// Package state keeps the local state of the CLI, such as what a watch last
+// saw, under ~/.skycluster/state. Every file holds one versioned JSON
+// document, and reads and writes hold a lock on the directory, so that two
+// invocations running at once never see or leave a half-written file.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// lockTimeout is how long an invocation waits for another one to release the
+// state directory.
+const lockTimeout = 10 * time.Second
+
+// ErrNewerVersion is returned for a state file written by a newer CLI with a
+// schema this one does not know.
+var ErrNewerVersion = errors.New("state file written by a newer version of skycluster")
+
+// envelope is the on-disk form of a state file.
+type envelope struct {
+	Version   int             `json:"version"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Info describes a state file, as listed by state show.
+type Info struct {
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Size      int64     `json:"size"`
+}
+
+// Home returns the directory of the CLI, ~/.skycluster.
+func Home() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, ".skycluster")
+}
+
+// Dir returns the state directory, ~/.skycluster/state.
+func Dir() string {
+	return filepath.Join(Home(), "state")
+}
+
+func path(name string) string {
+	return filepath.Join(Dir(), name+".json")
+}
+
+// Load reads the state name into v. A missing file, or one of an older
+// version, leaves v as it is.
+func Load(name string, version int, v any) error {
+	l, err := lock(false)
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	return load(name, version, v)
+}
+
+// Save replaces the state name with v.
+func Save(name string, version int, v any) error {
+	l, err := lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	return save(name, version, v)
+}
+
+// Update reads the state name into v, calls fn to change it and saves it,
+// holding the lock throughout so that no other invocation changes it in
+// between. Nothing is saved when fn fails.
+func Update(name string, version int, v any, fn func() error) error {
+	l, err := lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	if err := load(name, version, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return save(name, version, v)
+}
+
+func load(name string, version int, v any) error {
+	data, err := os.ReadFile(path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading state %s: %w", name, err)
+	}
+	var e envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("reading state %s: %w; remove it with 'skycluster state clean %s'", name, err, name)
+	}
+	switch {
+	case e.Version > version:
+		return fmt.Errorf("%w: %s has version %d, this one knows %d", ErrNewerVersion, name, e.Version, version)
+	case e.Version < version:
+		// There is no migration yet; an outdated state starts afresh.
+		return nil
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("reading state %s: %w", name, err)
+	}
+	return nil
+}
+
+func save(name string, version int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding state %s: %w", name, err)
+	}
+	out, err := json.MarshalIndent(envelope{Version: version, UpdatedAt: time.Now().UTC(), Data: data}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state %s: %w", name, err)
+	}
+	return utils.WriteFileAtomic(path(name), append(out, '\n'), 0o600)
+}
+
+// List describes the state files, sorted by name.
+func List() ([]Info, error) {
+	l, err := lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer l.unlock()
+	entries, err := os.ReadDir(Dir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infos []Info
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		info := Info{Name: name}
+		if fi, err := e.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		if data, err := os.ReadFile(path(name)); err == nil {
+			var env envelope
+			if json.Unmarshal(data, &env) == nil {
+				info.Version, info.UpdatedAt = env.Version, env.UpdatedAt
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// Remove deletes the state files of names. Missing ones are skipped.
+func Remove(names ...string) error {
+	l, err := lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	var errs []error
+	for _, n := range names {
+		if n == "" || filepath.Base(n) != n || strings.HasPrefix(n, ".") {
+			errs = append(errs, fmt.Errorf("invalid state name %q", n))
+			continue
+		}
+		if err := os.Remove(path(n)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Holder returns the pid of the invocation holding the state directory for
+// writing, or 0 when it is free.
+func Holder() int {
+	l, err := tryLock(true)
+	if err == nil {
+		l.unlock()
+		return 0
+	}
+	data, _ := os.ReadFile(lockPath())
+	var pid int
+	fmt.Sscanf(string(data), "%d", &pid)
+	return pid
+}
+
+func lockPath() string {
+	return filepath.Join(Dir(), ".lock")
+}
+
+// lock takes the lock of the state directory, exclusive when writing, and
+// waits up to lockTimeout for another invocation to release it.
+func lock(exclusive bool) (*fileLock, error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		l, err := tryLock(exclusive)
+		if err == nil {
+			return l, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is in use by another skycluster invocation (pid %d)", Dir(), Holder())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// errLocked is returned by tryLock when another invocation holds the lock.
+var errLocked = errors.New("locked")
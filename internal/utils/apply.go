@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/ptr"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
+)
+
+// FieldManager is the field manager recorded for server-side applies.
+const FieldManager = "skycluster-cli"
+
+// ServerSideApply sends u as a server-side apply patch. Only the fields set in
+// u become owned by the CLI, so fields filled in by controllers are left
+// alone and lists are merged by the API server instead of being replaced.
+// Fields of u owned by another field manager are a conflict error, unless
+// force is set to take them over, as kubectl apply --server-side
+// --force-conflicts does.
+func ServerSideApply(ctx context.Context, ri dynamic.ResourceInterface, u *unstructured.Unstructured, force bool) (*unstructured.Unstructured, error) {
+	existing, err := ri.Get(ctx, u.GetName(), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		audit.Stamp(u)
+	case err != nil:
+		return nil, err
+	default:
+		audit.StampUpdate(u, existing)
+	}
+	data, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := ri.Patch(ctx, u.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        ptr.To(force),
+	})
+	if apierrors.IsConflict(err) {
+		return nil, fmt.Errorf("%w; the fields are managed by someone else, apply again with --force-conflicts to take them over", err)
+	}
+	return applied, err
+}
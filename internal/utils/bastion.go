@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/spf13/viper"
+)
+
+// BastionRule routes SSH to the gateways and instances of the matching
+// providers through a chain of jump hosts. Rules are read from the
+// ssh.bastions config key.
+type BastionRule struct {
+	// Platform matches spec.providerRef.platform; empty matches any.
+	Platform string `mapstructure:"platform"`
+	// Provider is a glob matched against the XProvider name; empty matches any.
+	Provider string `mapstructure:"provider"`
+	// Jump lists the jump hosts in order, as [user@]host[:port] or ssh config
+	// aliases.
+	Jump []string `mapstructure:"jump"`
+}
+
+// BastionChain returns the jump hosts of the first ssh.bastions rule
+// matching platform and provider, or nil when none does.
+func BastionChain(platform, provider string) ([]string, error) {
+	var rules []BastionRule
+	if err := viper.UnmarshalKey("ssh.bastions", &rules); err != nil {
+		return nil, fmt.Errorf("invalid ssh.bastions config: %w", err)
+	}
+	for i, r := range rules {
+		if len(r.Jump) == 0 {
+			return nil, fmt.Errorf("ssh.bastions[%d] has no jump hosts", i)
+		}
+		if r.Platform != "" && r.Platform != platform {
+			continue
+		}
+		if r.Provider != "" {
+			ok, err := path.Match(r.Provider, provider)
+			if err != nil {
+				return nil, fmt.Errorf("ssh.bastions[%d]: invalid provider pattern %q: %w", i, r.Provider, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		return r.Jump, nil
+	}
+	return nil, nil
+}
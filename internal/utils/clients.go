@@ -1,13 +1,21 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+
 )
 
 func GetDynamicClientFromString(kubeconfigContent string) (dynamic.Interface, error) {
@@ -24,22 +32,41 @@ func GetDynamicClientFromString(kubeconfigContent string) (dynamic.Interface, er
 	return dynamicClient, nil
 }
 
-func GetDynamicClient(kubeconfig string) (dynamic.Interface, error) {
-	// check if the file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-		return nil, err
-	}
+// configErr is why the config file of the CLI could not be used, if it
+// could not.
+var configErr error
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return nil, err
-	}
+// SetConfigError records why the config file could not be used. The
+// commands still start, and only those that reach the management cluster
+// fail, with err.
+func SetConfigError(err error) {
+	configErr = err
+}
 
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return nil, err
+// errNoKubeconfig is returned when no kubeconfig is set anywhere and the CLI
+// does not run in a pod.
+var errNoKubeconfig = errors.New("no kubeconfig and not running in a pod: set kubeconfig in the config file (skycluster config init), pass --kubeconfig or set KUBECONFIG")
+
+// RESTConfig returns the config of the management cluster in the kubeconfig
+// file, using the context set with --context (config: context) instead of
+// its current one when given, or the in-cluster config in a pod when no
+// kubeconfig is set. It is loaded once and copied for each caller.
+func RESTConfig(kubeconfig string) (*rest.Config, error) {
+	return clients.RESTConfig(kubeconfig)
+}
+
+// expandHome expands a leading ~ of p to the home directory.
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") || p == "~" {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~/"))
+		}
 	}
-	return dynamicClient, nil
+	return p
+}
+
+func GetDynamicClient(kubeconfig string) (dynamic.Interface, error) {
+	return clients.Dynamic(kubeconfig)
 }
 
 func GetClientsetFromString(kubeconfigContent string) (*clientset.Clientset, error) {
@@ -58,21 +85,7 @@ func GetClientsetFromString(kubeconfigContent string) (*clientset.Clientset, err
 }
 
 func GetClientsetExtended(kubeconfig string) (*apiextv1.Clientset, error) {
-	// check if the file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-		return nil, err
-	}
-
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return nil, err
-	}
-
-	csExt, err := apiextv1.NewForConfig(config)
-	if err != nil {
-		return nil, err
-	}
-	return csExt, nil
+	return clients.ClientsetExtended(kubeconfig)
 }
 
 func GetClientsetExtendedFromString(kubeconfigContent string) (*apiextv1.Clientset, error) {
@@ -90,37 +103,64 @@ func GetClientsetExtendedFromString(kubeconfigContent string) (*apiextv1.Clients
 }
 
 func GetClientset(kubeconfig string) (*clientset.Clientset, error) {
-	// check if the file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
-		return nil, err
-	}
+	return clients.Clientset(kubeconfig)
+}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return nil, err
-	}
+func GetDiscoveryClient(kubeconfig string) (*discovery.DiscoveryClient, error) {
+	return clients.Discovery(kubeconfig)
+}
+
+var (
+	scopeMu sync.Mutex
+	// scopes caches whether a resource is namespaced, as discovery reports it.
+	scopes = map[schema.GroupVersionResource]bool{}
+)
 
-	clientset, err := clientset.NewForConfig(config)
+// IsNamespaced reports whether gvr is a namespaced resource, asking the API
+// server once per resource.
+func IsNamespaced(disc discovery.DiscoveryInterface, gvr schema.GroupVersionResource) (bool, error) {
+	scopeMu.Lock()
+	defer scopeMu.Unlock()
+	if namespaced, ok := scopes[gvr]; ok {
+		return namespaced, nil
+	}
+	list, err := disc.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("discovering %s: %w", gvr.GroupVersion(), err)
 	}
-	return clientset, nil
+	for _, r := range list.APIResources {
+		if r.Name == gvr.Resource {
+			scopes[gvr] = r.Namespaced
+			return r.Namespaced, nil
+		}
+	}
+	return false, fmt.Errorf("the server has no resource %s in %s", gvr.Resource, gvr.GroupVersion())
 }
 
-func GetDiscoveryClient(kubeconfig string) (*discovery.DiscoveryClient, error) {
-	// check if the file exists
-	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
+// ResourceFor returns the resource interface for gvr matching its scope:
+// cluster-scoped resources ignore ns, namespaced ones use ns, or all
+// namespaces when ns is empty.
+func ResourceFor(dyn dynamic.Interface, disc discovery.DiscoveryInterface, gvr schema.GroupVersionResource, ns string) (dynamic.ResourceInterface, error) {
+	namespaced, err := IsNamespaced(disc, gvr)
+	if err != nil {
 		return nil, err
 	}
+	if !namespaced || ns == "" {
+		return dyn.Resource(gvr), nil
+	}
+	return dyn.Resource(gvr).Namespace(ns), nil
+}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+// GetResourceClient builds the clients for kubeconfig and returns the
+// resource interface for gvr as ResourceFor does.
+func GetResourceClient(kubeconfig string, gvr schema.GroupVersionResource, ns string) (dynamic.ResourceInterface, error) {
+	dyn, err := GetDynamicClient(kubeconfig)
 	if err != nil {
 		return nil, err
 	}
-
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	disc, err := GetDiscoveryClient(kubeconfig)
 	if err != nil {
 		return nil, err
 	}
-	return discoveryClient, nil
+	return ResourceFor(dyn, disc, gvr, ns)
 }
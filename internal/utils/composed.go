@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// ResourceRefs returns the composed resources of a Crossplane composite,
+// from spec.crossplane.resourceRefs or the older spec.resourceRefs, as
+// objects holding only their apiVersion, kind, name and namespace.
+func ResourceRefs(obj *unstructured.Unstructured) []*unstructured.Unstructured {
+	refs, found, _ := unstructured.NestedSlice(obj.Object, "spec", "crossplane", "resourceRefs")
+	if !found {
+		refs, _, _ = unstructured.NestedSlice(obj.Object, "spec", "resourceRefs")
+	}
+	var out []*unstructured.Unstructured
+	for _, r := range refs {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		u.SetAPIVersion(fmt.Sprint(m["apiVersion"]))
+		u.SetKind(fmt.Sprint(m["kind"]))
+		name, _ := m["name"].(string)
+		ns, _ := m["namespace"].(string)
+		u.SetName(name)
+		u.SetNamespace(ns)
+		if name != "" {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// GetRef fetches the resource a reference returned by ResourceRefs points to.
+func GetRef(ctx context.Context, dyn dynamic.Interface, mapper meta.RESTMapper, ref *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(ref.GetAPIVersion())
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := mapper.RESTMapping(gv.WithKind(ref.GetKind()).GroupKind(), gv.Version)
+	if err != nil {
+		return nil, err
+	}
+	if ref.GetNamespace() != "" {
+		return dyn.Resource(mapping.Resource).Namespace(ref.GetNamespace()).Get(ctx, ref.GetName(), metav1.GetOptions{})
+	}
+	return dyn.Resource(mapping.Resource).Get(ctx, ref.GetName(), metav1.GetOptions{})
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ErrNoTTY is returned instead of prompting when stdin is not a terminal.
+var ErrNoTTY = errors.New("confirmation needed but stdin is not a terminal; pass --yes to proceed without it")
+
+var assumeYes bool
+
+// SetAssumeYes makes the confirmations answer yes without prompting, as
+// --yes and --force do.
+func SetAssumeYes(b bool) {
+	assumeYes = b
+}
+
+// AssumeYes reports whether confirmations are skipped.
+func AssumeYes() bool {
+	return assumeYes
+}
+
+// CanPrompt returns ErrNoTTY when r is a file that is not a terminal, such
+// as stdin in CI, rather than letting a prompt read EOF as a no. Readers
+// that are not files are assumed to have an answer.
+func CanPrompt(r io.Reader) error {
+	if f, ok := r.(*os.File); ok && !term.IsTerminal(int(f.Fd())) {
+		return ErrNoTTY
+	}
+	return nil
+}
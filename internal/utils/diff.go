@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	diffContext = 3
+
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// MergeFunc overlays src onto dst the way a create command does on update.
+type MergeFunc func(dst, src map[string]interface{}) map[string]interface{}
+
+// DiffAgainstLive writes the diff between the live copy of u and the object
+// that merge would produce from it, without modifying anything. A missing
+// live object is shown as fully added.
+func DiffAgainstLive(ctx context.Context, w io.Writer, ri dynamic.ResourceInterface, u *unstructured.Unstructured, merge MergeFunc) (bool, error) {
+	existing, err := ri.Get(ctx, u.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return WriteObjectDiff(w, nil, u)
+	}
+	if err != nil {
+		return false, err
+	}
+	merged := existing.DeepCopy()
+	merged.Object = merge(merged.Object, u.DeepCopy().Object)
+	return WriteObjectDiff(w, existing, merged)
+}
+
+// WriteObjectDiff writes a unified diff between the live object and the one
+// that would be sent to the API server. A nil live object is shown as fully
+// added. Server-managed noise (managedFields, status) is left out, and lines
+// are colorized like kubectl diff when stdout is a terminal. It reports whether
+// the two objects differ.
+func WriteObjectDiff(w io.Writer, live, desired *unstructured.Unstructured) (bool, error) {
+	name := desired.GetName()
+	if desired.GetNamespace() != "" {
+		name = desired.GetNamespace() + "/" + name
+	}
+	from, err := diffYAML(live)
+	if err != nil {
+		return false, err
+	}
+	to, err := diffYAML(desired)
+	if err != nil {
+		return false, err
+	}
+	lines := DiffLines(from, to)
+	changed := false
+	for _, l := range lines {
+		if l[0] != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	color := IsInteractive()
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + ansiReset
+	}
+	kind := strings.ToLower(desired.GetKind())
+	fmt.Fprintln(w, paint(ansiCyan, fmt.Sprintf("--- live %s/%s", kind, name)))
+	fmt.Fprintln(w, paint(ansiCyan, fmt.Sprintf("+++ desired %s/%s", kind, name)))
+	for _, h := range hunks(lines) {
+		fmt.Fprintln(w, paint(ansiCyan, h.header))
+		for _, l := range h.lines {
+			switch l[0] {
+			case '-':
+				fmt.Fprintln(w, paint(ansiRed, l))
+			case '+':
+				fmt.Fprintln(w, paint(ansiGreen, l))
+			default:
+				fmt.Fprintln(w, l)
+			}
+		}
+	}
+	return true, nil
+}
+
+func diffYAML(obj *unstructured.Unstructured) ([]string, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	c := obj.DeepCopy()
+	unstructured.RemoveNestedField(c.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(c.Object, "status")
+	out, err := yaml.Marshal(c.Object)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(out), "\n"), "\n"), nil
+}
+
+// DiffLines returns the line diff of a and b: every line is prefixed with
+// ' ' (common), '-' (only in a) or '+' (only in b).
+func DiffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+a[i])
+			i++
+		default:
+			out = append(out, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		out = append(out, "+"+b[j])
+	}
+	return out
+}
+
+type hunk struct {
+	header string
+	lines  []string
+}
+
+// hunks groups a DiffLines result into unified-diff hunks with diffContext
+// lines of context around each change.
+func hunks(lines []string) []hunk {
+	var out []hunk
+	start := -1
+	end := -1
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		aStart, bStart := 1, 1
+		for _, l := range lines[:start] {
+			if l[0] != '+' {
+				aStart++
+			}
+			if l[0] != '-' {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, l := range lines[start:end] {
+			if l[0] != '+' {
+				aLen++
+			}
+			if l[0] != '-' {
+				bLen++
+			}
+		}
+		// An empty range is addressed by the line before it, as in diff -u.
+		if aLen == 0 {
+			aStart--
+		}
+		if bLen == 0 {
+			bStart--
+		}
+		out = append(out, hunk{
+			header: fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aLen, bStart, bLen),
+			lines:  lines[start:end],
+		})
+		start, end = -1, -1
+	}
+	for i, l := range lines {
+		if l[0] == ' ' {
+			continue
+		}
+		from := max(i-diffContext, 0)
+		to := min(i+diffContext+1, len(lines))
+		if start >= 0 && from > end {
+			flush()
+		}
+		if start < 0 {
+			start = from
+		}
+		end = to
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"sync"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/spf13/viper"
+)
+
+// clientKey identifies a management cluster: a kubeconfig and the context
+// used in it, empty for its current one.
+type clientKey struct {
+	kubeconfig string
+	context    string
+}
+
+// ClientFactory builds the clients of the management cluster lazily and
+// caches them, with their rest.Config, per kubeconfig and context, so that a
+// command asking for the same client many times loads the kubeconfig and
+// sets up the transport once. It is safe for concurrent use.
+type ClientFactory struct {
+	// QPS and Burst tune the client-side rate limit of the clients built
+	// after they are set; zero keeps the client-go defaults.
+	QPS   float32
+	Burst int
+
+	mu        sync.Mutex
+	configs   map[clientKey]*rest.Config
+	dynamics  map[clientKey]dynamic.Interface
+	typed     map[clientKey]*clientset.Clientset
+	ext       map[clientKey]*apiextv1.Clientset
+	discovery map[clientKey]*discovery.DiscoveryClient
+}
+
+// NewClientFactory returns an empty factory.
+func NewClientFactory() *ClientFactory {
+	return &ClientFactory{
+		configs:   map[clientKey]*rest.Config{},
+		dynamics:  map[clientKey]dynamic.Interface{},
+		typed:     map[clientKey]*clientset.Clientset{},
+		ext:       map[clientKey]*apiextv1.Clientset{},
+		discovery: map[clientKey]*discovery.DiscoveryClient{},
+	}
+}
+
+// clients is the factory behind GetDynamicClient and the other Get*Client
+// functions.
+var clients = NewClientFactory()
+
+// SetClientRateLimits sets the QPS and burst of the clients of the
+// management cluster, as --kube-qps and --kube-burst do.
+func SetClientRateLimits(qps float32, burst int) {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	clients.QPS, clients.Burst = qps, burst
+}
+
+// InCluster reports whether the CLI runs in a pod with a service account,
+// whose config is used when no kubeconfig is set.
+func InCluster() bool {
+	_, err := rest.InClusterConfig()
+	return err == nil
+}
+
+// RESTConfig returns a copy of the config of kubeconfig with the context in
+// use, so that callers may change it.
+func (f *ClientFactory) RESTConfig(kubeconfig string) (*rest.Config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cfg, _, err := f.config(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return rest.CopyConfig(cfg), nil
+}
+
+// config loads the config of kubeconfig once per context. f.mu is held.
+func (f *ClientFactory) config(kubeconfig string) (*rest.Config, clientKey, error) {
+	key := clientKey{kubeconfig: expandHome(kubeconfig), context: viper.GetString("context")}
+	// A config error recorded since is reported even for cached configs.
+	if configErr != nil {
+		return nil, key, configErr
+	}
+	if cfg, ok := f.configs[key]; ok {
+		return cfg, key, nil
+	}
+	var cfg *rest.Config
+	if kubeconfig == "" {
+		// In a pod, e.g. the controller, the service account is used when no
+		// kubeconfig is set.
+		incluster, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, key, errNoKubeconfig
+		}
+		cfg = incluster
+	} else {
+		rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: key.kubeconfig}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: key.context}
+		loaded, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+		if err != nil {
+			return nil, key, err
+		}
+		cfg = loaded
+	}
+	if f.QPS > 0 {
+		cfg.QPS = f.QPS
+	}
+	if f.Burst > 0 {
+		cfg.Burst = f.Burst
+	}
+	f.configs[key] = cfg
+	return cfg, key, nil
+}
+
+// Dynamic returns the dynamic client of kubeconfig.
+func (f *ClientFactory) Dynamic(kubeconfig string) (dynamic.Interface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cfg, key, err := f.config(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := f.dynamics[key]; ok {
+		return c, nil
+	}
+	c, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f.dynamics[key] = c
+	return c, nil
+}
+
+// Clientset returns the typed client of kubeconfig.
+func (f *ClientFactory) Clientset(kubeconfig string) (*clientset.Clientset, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cfg, key, err := f.config(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := f.typed[key]; ok {
+		return c, nil
+	}
+	c, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f.typed[key] = c
+	return c, nil
+}
+
+// ClientsetExtended returns the apiextensions client of kubeconfig.
+func (f *ClientFactory) ClientsetExtended(kubeconfig string) (*apiextv1.Clientset, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cfg, key, err := f.config(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := f.ext[key]; ok {
+		return c, nil
+	}
+	c, err := apiextv1.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f.ext[key] = c
+	return c, nil
+}
+
+// Discovery returns the discovery client of kubeconfig.
+func (f *ClientFactory) Discovery(kubeconfig string) (*discovery.DiscoveryClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cfg, key, err := f.config(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := f.discovery[key]; ok {
+		return c, nil
+	}
+	c, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f.discovery[key] = c
+	return c, nil
+}
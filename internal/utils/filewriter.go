@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// freeSpaceMargin is kept free on top of the bytes being written so that a
+// local artifact never fills the disk completely.
+const freeSpaceMargin = 1 << 20 // 1 MiB
+
+// WriteFileAtomic writes data to path so that readers either see the old
+// content or the complete new content, never a truncated file. The parent
+// directory is created if needed, write permission and free space are checked
+// up front, and data is written to a temp file in the same directory that is
+// fsynced and renamed over path.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating directory %s: %w", dir, err)
+	}
+	if err := PreflightWrite(path, int64(len(data))); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temp file in %s: %w", dir, err)
+	}
+	tmpName := tmp.Name()
+	// the temp file is removed on any failure; after the rename this is a no-op
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("setting permissions on %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", path, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("replacing %s: %w", path, err)
+	}
+	return nil
+}
+
+// MkdirAndPreflight creates the parent directory of path and runs PreflightWrite
+// with no expected size, so commands can fail fast before doing expensive work.
+func MkdirAndPreflight(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating directory %s: %w", dir, err)
+	}
+	return PreflightWrite(path, 0)
+}
+
+// PreflightWrite checks that path can be written and that its filesystem has
+// room for size more bytes. It does not modify anything.
+func PreflightWrite(path string, size int64) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("checking directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if fi, err := os.Stat(path); err == nil {
+		if fi.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("%s is not writable: %w", path, err)
+		}
+		f.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("checking %s: %w", path, err)
+	}
+
+	probe, err := os.CreateTemp(dir, ".skycluster-probe-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, ok := freeBytes(dir)
+	if ok && free < uint64(size)+freeSpaceMargin {
+		return fmt.Errorf("not enough free space in %s: need %d bytes, %d available", dir, uint64(size)+freeSpaceMargin, free)
+	}
+	return nil
+}
+
+// LockFile takes the "<path>.lock" lock file that kubectl and client-go also
+// use when they modify a kubeconfig, waiting up to timeout while another
+// process holds it. The returned function releases the lock.
+func LockFile(path string, timeout time.Duration) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another process; remove %s if it is stale", path, lockPath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
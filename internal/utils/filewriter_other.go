@@ -0,0 +1,8 @@
+//go:build !unix
+
+package utils
+
+// freeBytes is not implemented on this platform; the free-space check is skipped.
+func freeBytes(dir string) (uint64, bool) {
+	return 0, false
+}
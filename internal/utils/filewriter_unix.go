@@ -0,0 +1,15 @@
+//go:build unix
+
+package utils
+
+import "golang.org/x/sys/unix"
+
+// freeBytes reports the bytes available to unprivileged users on the
+// filesystem holding dir.
+func freeBytes(dir string) (uint64, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true
+}
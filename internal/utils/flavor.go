@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// GPUSpec describes the accelerators attached to a flavor.
+type GPUSpec struct {
+	Enabled      bool   `json:"enabled,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Count        int    `json:"count,omitempty"`
+	Memory       string `json:"memory,omitempty"`
+}
+
+// FlavorSpec is the value of a flavor entry in a provider-mappings ConfigMap.
+type FlavorSpec struct {
+	Name  string `json:"name,omitempty"`
+	VCPUs int    `json:"vcpus,omitempty"`
+	RAM   string `json:"ram,omitempty"`
+	Arch  string `json:"arch,omitempty"`
+	// Price is the hourly price of the flavor, in the currency of the
+	// provider; 0 when unknown.
+	Price float64 `json:"price,omitempty"`
+	GPU   GPUSpec `json:"gpu,omitempty"`
+}
+
+// vcpuSegment and ramSegment match the "<n>vCPU" and "<n>GB" parts of
+// flavor names such as "8vCPU-61GB-1xV100-16GB".
+var (
+	vcpuSegment = regexp.MustCompile(`^(\d+)vCPU$`)
+	ramSegment  = regexp.MustCompile(`^(\d+(?:\.\d+)?)GB$`)
+)
+
+// gpuSegment matches the "<count>x<model>" part of flavor names such as
+// "8vCPU-61GB-1xV100-16GB".
+var gpuSegment = regexp.MustCompile(`^(\d+)x([A-Za-z][A-Za-z0-9]*)$`)
+
+// ParseFlavor decodes a provider-mappings flavor entry. The value may be a
+// YAML/JSON document or just the provider-specific flavor name; in the latter
+// case (or when the document has no gpu block) the GPU, vCPUs and RAM are
+// derived from key.
+func ParseFlavor(key, value string) FlavorSpec {
+	var f FlavorSpec
+	if err := yaml.Unmarshal([]byte(value), &f); err != nil || f.Name == "" {
+		f.Name = strings.TrimSpace(value)
+	}
+	if f.GPU.Count == 0 && f.GPU.Model == "" {
+		f.GPU = GPUFromFlavorName(key)
+	}
+	// vCPUs and RAM not in the document are derived from key; the first GB
+	// segment is the RAM, a later one the memory of the GPU.
+	for _, seg := range strings.Split(key, "-") {
+		if m := vcpuSegment.FindStringSubmatch(seg); m != nil && f.VCPUs == 0 {
+			f.VCPUs, _ = strconv.Atoi(m[1])
+		} else if ramSegment.MatchString(seg) && f.RAM == "" {
+			f.RAM = seg
+		}
+	}
+	if f.GPU.Count > 0 || f.GPU.Model != "" {
+		f.GPU.Enabled = true
+	}
+	return f
+}
+
+// GPUFromFlavorName extracts the GPU model and count encoded in a generic
+// flavor name; a flavor without such a segment yields an empty GPUSpec.
+func GPUFromFlavorName(name string) GPUSpec {
+	for _, seg := range strings.Split(name, "-") {
+		m := gpuSegment.FindStringSubmatch(seg)
+		if m == nil {
+			continue
+		}
+		count, _ := strconv.Atoi(m[1])
+		return GPUSpec{Enabled: count > 0, Model: m[2], Count: count}
+	}
+	return GPUSpec{}
+}
+
+// MappingName returns the name a provider-mappings key offers: the key
+// without a leading kind such as "flavor-", "image_" or "image.".
+func MappingName(key, kind string) string {
+	if rest, ok := strings.CutPrefix(key, kind); ok && len(rest) > 1 && strings.ContainsRune("-_.", rune(rest[0])) {
+		return rest[1:]
+	}
+	return key
+}
+
+// RAMGB returns the RAM of f in GB, or 0 when it is not known.
+func (f FlavorSpec) RAMGB() float64 {
+	gb, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(f.RAM), "GB"), 64)
+	return gb
+}
+
+// String renders the GPU as "<count>x<model>", or "-" when there is none.
+func (g GPUSpec) String() string {
+	if !g.Enabled {
+		return "-"
+	}
+	count := g.Count
+	if count == 0 {
+		count = 1
+	}
+	model := g.Model
+	if model == "" {
+		model = "gpu"
+	}
+	return strconv.Itoa(count) + "x" + model
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// SystemNamespace is where SkyCluster keeps its secrets, its ConfigMaps and,
+// by default, the ProviderProfiles.
+const SystemNamespace = "skycluster-system"
+
+// Namespace returns the namespace of --namespace, or of the namespace key of
+// the config file, and def when neither is set. Commands use it for the
+// namespaced resources users manage, not for SystemNamespace itself.
+func Namespace(def string) string {
+	if ns := strings.TrimSpace(viper.GetString("namespace")); ns != "" {
+		return ns
+	}
+	return def
+}
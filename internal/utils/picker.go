@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PickOption is a single entry offered by the interactive pickers.
+type PickOption struct {
+	Name  string
+	Ready bool
+}
+
+// IsInteractive reports whether both stdin and stdout are attached to a terminal,
+// i.e. whether it is safe to prompt the user.
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// ReadinessOptions converts resources into picker options using their Ready condition.
+func ReadinessOptions(items []unstructured.Unstructured) []PickOption {
+	opts := make([]PickOption, 0, len(items))
+	for i := range items {
+		opts = append(opts, PickOption{
+			Name:  items[i].GetName(),
+			Ready: GetConditionStatus(&items[i], "Ready") == "True",
+		})
+	}
+	return opts
+}
+
+// PickOne shows a single-choice selection list and returns the chosen name.
+func PickOne(title string, opts []PickOption) (string, error) {
+	if len(opts) == 0 {
+		return "", errors.New("nothing to select from")
+	}
+	labels, byLabel := pickLabels(opts)
+	choice, err := pterm.DefaultInteractiveSelect.
+		WithOptions(labels).
+		WithMaxHeight(15).
+		Show(title)
+	if err != nil {
+		return "", err
+	}
+	return byLabel[choice], nil
+}
+
+// PickMany shows a multi-choice selection list and returns the chosen names.
+func PickMany(title string, opts []PickOption) ([]string, error) {
+	if len(opts) == 0 {
+		return nil, errors.New("nothing to select from")
+	}
+	labels, byLabel := pickLabels(opts)
+	choices, err := pterm.DefaultInteractiveMultiselect.
+		WithOptions(labels).
+		WithMaxHeight(15).
+		Show(title)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(choices))
+	for _, c := range choices {
+		names = append(names, byLabel[c])
+	}
+	return names, nil
+}
+
+// pickLabels renders each option with a readiness indicator and returns
+// a reverse lookup from label to resource name.
+func pickLabels(opts []PickOption) ([]string, map[string]string) {
+	labels := make([]string, 0, len(opts))
+	byLabel := make(map[string]string, len(opts))
+	for _, o := range opts {
+		label := fmt.Sprintf("%s %s", pterm.Green("●"), o.Name)
+		if !o.Ready {
+			label = fmt.Sprintf("%s %s (not ready)", pterm.Red("○"), o.Name)
+		}
+		labels = append(labels, label)
+		byLabel[label] = o.Name
+	}
+	return labels, byLabel
+}
+
+// Ask shows a text prompt prefilled with def and returns the trimmed answer,
+// or def when the answer is empty.
+func Ask(title, def string) (string, error) {
+	answer, err := pterm.DefaultInteractiveTextInput.
+		WithDefaultValue(def).
+		Show(title)
+	if err != nil {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// Confirm shows a yes/no prompt and returns the answer.
+func Confirm(title string, def bool) (bool, error) {
+	return pterm.DefaultInteractiveConfirm.
+		WithDefaultValue(def).
+		Show(title)
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Modes of --progress.
+const (
+	ProgressAuto  = "auto"
+	ProgressTUI   = "tui"
+	ProgressPlain = "plain"
+	ProgressJSON  = "json"
+)
+
+var progressMode = ProgressAuto
+
+// SetProgressMode sets how waits report progress, as --progress does.
+func SetProgressMode(mode string) error {
+	switch mode {
+	case "":
+		mode = ProgressAuto
+	case ProgressAuto, ProgressTUI, ProgressPlain, ProgressJSON:
+	default:
+		return fmt.Errorf("unknown --progress %q (auto, tui, plain or json)", mode)
+	}
+	progressMode = mode
+	return nil
+}
+
+// ProgressMode returns the mode set by SetProgressMode.
+func ProgressMode() string {
+	return progressMode
+}
+
+// StartProgress returns the sink of the progress mode: JSON lines or plain
+// lines on w, or the TUI, which auto uses on a terminal. stop must be called
+// with the outcome of the wait.
+func StartProgress(w io.Writer, debugf DebugfFunc) (sink ProgressSink, stop func(error)) {
+	switch progressMode {
+	case ProgressJSON:
+		return JSONProgressSink(w), func(error) {}
+	case ProgressTUI, ProgressAuto:
+		if progressMode == ProgressTUI || IsInteractive() {
+			renderer := NewTUIRenderer()
+			if err := renderer.Start(); err == nil {
+				return renderer.Sink, renderer.Stop
+			} else if debugf != nil {
+				debugf("cannot start the TUI, falling back to plain output: %v", err)
+			}
+		}
+	}
+	return PlainProgressSink(w), func(error) {}
+}
+
+// ProgressLine is a JSON line of JSONProgressSink.
+type ProgressLine struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	State     string    `json:"state"`
+	Message   string    `json:"message"`
+	Step      string    `json:"step"`
+	Resource  string    `json:"resource"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Index     int       `json:"index"`
+	Total     int       `json:"total"`
+	Percent   int       `json:"percent"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// JSONProgressSink writes every progress event to w as a ProgressLine, one
+// JSON object per line, so that wrappers and CI systems can render their
+// own progress.
+func JSONProgressSink(w io.Writer) ProgressSink {
+	var mu sync.Mutex
+	return func(ev ProgressEvent) {
+		state := "waiting"
+		switch {
+		case ev.Err != nil:
+			state = "failed"
+		case ev.ResourceCompleted:
+			state = "ready"
+		}
+		line := ProgressLine{
+			Time:      time.Now().UTC(),
+			Event:     "progress",
+			State:     state,
+			Message:   ev.Message,
+			Step:      ev.KindDescription,
+			Resource:  ev.GVR.Resource,
+			Namespace: ev.Namespace,
+			Name:      ev.Name,
+			Index:     ev.CurrentIndex,
+			Total:     ev.Total,
+			Percent:   int(ev.OverallPercent),
+		}
+		if ev.Err != nil {
+			line.Error = ev.Err.Error()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		WriteJSONLine(w, line)
+	}
+}
+
+// WriteJSONLine writes v to w as one line of JSON.
+func WriteJSONLine(w io.Writer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: encoding progress: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, string(b))
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"k8s.io/client-go/dynamic"
+)
+
+// PlainProgressSink writes one line per progress event to w. It is meant for
+// non-interactive output (CI logs, pipes) where the TUI cannot be used.
+func PlainProgressSink(w io.Writer) ProgressSink {
+	return func(ev ProgressEvent) {
+		if ev.Err != nil {
+			fmt.Fprintf(w, "[ERROR] %s (%s/%s %s): %v\n",
+				ev.KindDescription, ev.Namespace, ev.Name, ev.GVR.Resource, ev.Err)
+			return
+		}
+		status := "waiting"
+		if ev.ResourceCompleted {
+			status = "ready"
+		}
+		fmt.Fprintf(w, "[%.0f%%] (%d/%d) %-30s %-7s %s/%s %s\n",
+			ev.OverallPercent, ev.CurrentIndex, ev.Total,
+			ev.KindDescription, status, ev.Namespace, ev.Name, ev.GVR.Resource)
+	}
+}
+
+// WaitWithProgress waits for resources, reporting progress on w or the
+// terminal as StartProgress does.
+func WaitWithProgress(ctx context.Context, dyn dynamic.Interface, resources []WaitResourceSpec, w io.Writer, debugf DebugfFunc) error {
+	sink, stop := StartProgress(w, debugf)
+	err := WaitForResourcesReadyConcurrent(ctx, dyn, resources, sink, debugf)
+	stop(err)
+	return err
+}
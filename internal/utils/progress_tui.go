@@ -30,7 +30,7 @@ func NewTUIRenderer() *TUIRenderer {
 }
 
 // Start initializes spinner + area. Call this once before you pass
-// TUIRenderer.Sink() to WaitForResourcesReadySequential.
+// TUIRenderer.Sink() to one of the WaitForResourcesReady* functions.
 func (r *TUIRenderer) Start() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -79,7 +79,7 @@ func (r *TUIRenderer) Stop(err error) {
 }
 
 // Sink implements ProgressSink and can be passed directly to
-// WaitForResourcesReadySequential.
+// WaitForResourcesReadySequential or WaitForResourcesReadyConcurrent.
 func (r *TUIRenderer) Sink(ev ProgressEvent) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/etesami/skycluster-cli/internal/log"
+)
+
+// APIBackoff is how Retry and RetryOnConflict space their attempts: five
+// attempts over about four seconds.
+var APIBackoff = wait.Backoff{
+	Duration: 250 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+	Cap:      5 * time.Second,
+}
+
+// IsTransient reports whether err is an API error that may go away when the
+// call is repeated: throttling, server timeouts and unavailability, and
+// connections reset or refused on the way, e.g. while the API server
+// restarts.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case apierrors.IsTooManyRequests(err),
+		apierrors.IsServerTimeout(err),
+		apierrors.IsTimeout(err),
+		apierrors.IsServiceUnavailable(err),
+		apierrors.IsUnexpectedServerError(err):
+		return true
+	case utilnet.IsConnectionReset(err),
+		utilnet.IsConnectionRefused(err),
+		utilnet.IsProbableEOF(err),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+	return false
+}
+
+// Retry runs fn until it succeeds, fails with an error that is not
+// transient, APIBackoff runs out or ctx is done, and returns the last error
+// of fn. It is meant for single create, delete and patch calls; updates of
+// an object read before go through RetryOnConflict.
+func Retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	return retry(ctx, IsTransient, fn)
+}
+
+// RetryOnConflict is Retry that also retries conflicts, for fn that reads
+// an object, changes it and updates it: fn must read it again on every
+// attempt so that the update is based on the latest version.
+func RetryOnConflict(ctx context.Context, fn func(ctx context.Context) error) error {
+	return retry(ctx, func(err error) bool {
+		return apierrors.IsConflict(err) || IsTransient(err)
+	}, fn)
+}
+
+func retry(ctx context.Context, retriable func(error) bool, fn func(ctx context.Context) error) error {
+	var last error
+	attempt := 0
+	err := wait.ExponentialBackoffWithContext(ctx, APIBackoff, func(ctx context.Context) (bool, error) {
+		attempt++
+		last = fn(ctx)
+		switch {
+		case last == nil:
+			return true, nil
+		case retriable(last):
+			log.Debugf("retry", "attempt %d failed, retrying: %v", attempt, last)
+			return false, nil
+		default:
+			return false, last
+		}
+	})
+	if err != nil && last != nil {
+		// Out of attempts or interrupted: what fn reported says more.
+		return last
+	}
+	return err
+}
+
+// RetryDelete deletes name through ri as Retry does. A resource that is gone
+// on a retry was deleted by an attempt whose response was lost, so only a
+// first NotFound is returned.
+func RetryDelete(ctx context.Context, ri dynamic.ResourceInterface, name string, opts metav1.DeleteOptions) error {
+	first := true
+	return Retry(ctx, func(ctx context.Context) error {
+		err := ri.Delete(ctx, name, opts)
+		if apierrors.IsNotFound(err) && !first {
+			return nil
+		}
+		first = false
+		return err
+	})
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -9,6 +10,13 @@ import (
 // RunWithSpinner runs f() while showing a simple spinner and message on stderr.
 // It returns f()'s error. The spinner writes to stderr to avoid clobbering stdout.
 func RunWithSpinner(msg string, f func() error) error {
+	return RunWithSpinnerContext(context.Background(), msg, func(context.Context) error { return f() })
+}
+
+// RunWithSpinnerContext is RunWithSpinner for work that stops with ctx: when
+// ctx is cancelled, e.g. by Ctrl-C, the spinner stops right away and the
+// error of ctx is returned, without waiting for f to notice.
+func RunWithSpinnerContext(ctx context.Context, msg string, f func(ctx context.Context) error) error {
 	stop := make(chan struct{})
 	spinnerDone := make(chan struct{})
 	resultCh := make(chan error, 1)
@@ -32,11 +40,17 @@ func RunWithSpinner(msg string, f func() error) error {
 
 	// run the work
 	go func() {
-		resultCh <- f()
+		resultCh <- f(ctx)
 	}()
 
-	// wait for work to finish
-	err := <-resultCh
+	// wait for work to finish, or to be interrupted
+	var err error
+	interrupted := false
+	select {
+	case err = <-resultCh:
+	case <-ctx.Done():
+		err, interrupted = ctx.Err(), true
+	}
 
 	// signal spinner to stop and wait for it to actually exit (important!)
 	close(stop)
@@ -44,6 +58,10 @@ func RunWithSpinner(msg string, f func() error) error {
 
 	// clear the spinner line (carriage return + ANSI clear line) and print final status on its own line
 	fmt.Fprint(os.Stderr, "\r\033[K")
+	if interrupted {
+		fmt.Fprintf(os.Stderr, "%s... interrupted\n", msg)
+		return err
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s... failed\n", msg)
 		fmt.Fprintf(os.Stderr, "error: %v\n", err) // will be on the next line
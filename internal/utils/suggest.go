@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// GetWithSuggestions fetches a resource by name. If it does not exist, the live
+// names are listed and the returned error suggests the closest matches instead
+// of surfacing the raw NotFound error from the API, which it wraps so that
+// apierrors.IsNotFound still holds.
+func GetWithSuggestions(ctx context.Context, ri dynamic.ResourceInterface, kind, name string) (*unstructured.Unstructured, error) {
+	obj, err := ri.Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return obj, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var names []string
+	if list, lerr := ri.List(ctx, metav1.ListOptions{}); lerr == nil {
+		for _, it := range list.Items {
+			names = append(names, it.GetName())
+		}
+	}
+	return nil, &wrappedError{msg: NotFoundError(kind, name, names).Error(), err: err}
+}
+
+// wrappedError reads msg but unwraps to err.
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string { return e.msg }
+func (e *wrappedError) Unwrap() error { return e.err }
+
+// NotFoundError builds a "not found" error that lists close matches among candidates.
+func NotFoundError(kind, name string, candidates []string) error {
+	suggestions := SuggestNames(name, candidates, 3)
+	if len(suggestions) == 0 {
+		return fmt.Errorf("%s %q not found", kind, name)
+	}
+	return fmt.Errorf("%s %q not found; did you mean %s?", kind, name, strings.Join(suggestions, ", "))
+}
+
+// SuggestNames returns up to max candidates that are close to name, closest first.
+// A candidate qualifies when its edit distance is at most a third of the longer
+// string's length, or when one name contains the other.
+func SuggestNames(name string, candidates []string, max int) []string {
+	type scored struct {
+		name string
+		dist int
+	}
+	var matches []scored
+	lname := strings.ToLower(name)
+	for _, c := range candidates {
+		lc := strings.ToLower(c)
+		d := Levenshtein(lname, lc)
+		limit := len(lc)
+		if len(lname) > limit {
+			limit = len(lname)
+		}
+		limit /= 3
+		if limit < 1 {
+			limit = 1
+		}
+		if d <= limit || (lname != "" && (strings.Contains(lc, lname) || strings.Contains(lname, lc))) {
+			matches = append(matches, scored{name: c, dist: d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	out := []string{}
+	for i := 0; i < len(matches) && i < max; i++ {
+		out = append(out, matches[i].name)
+	}
+	return out
+}
+
+// Levenshtein computes the edit distance between a and b.
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
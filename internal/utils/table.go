@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Table is the output of a list command: every column it can show, of which
+// TableOptions select and sort the printed ones.
+type Table struct {
+	Headers []string
+	Rows    [][]string
+}
+
+// TableOptions are the --columns and --sort-by flags of a list command.
+type TableOptions struct {
+	// Columns are the headers of the columns to print, in order; empty
+	// prints all.
+	Columns []string
+	// SortBy is the header of the column to sort by, prefixed with "-" for
+	// descending order; empty keeps the order of the rows.
+	SortBy string
+}
+
+// AddTableFlags adds --columns and --sort-by to cmd, filling o. sortBy is
+// the default of --sort-by.
+func AddTableFlags(cmd *cobra.Command, o *TableOptions, sortBy string) {
+	cmd.Flags().StringSliceVar(&o.Columns, "columns", nil, "Columns to show, separated by comma")
+	cmd.Flags().StringVar(&o.SortBy, "sort-by", sortBy, "Column to sort by, prefixed with - for descending order")
+}
+
+// NewTable returns an empty table with headers.
+func NewTable(headers ...string) *Table {
+	return &Table{Headers: headers}
+}
+
+// Append adds a row.
+func (t *Table) Append(cells ...string) {
+	t.Rows = append(t.Rows, cells)
+}
+
+// Column returns the index of the column named h, ignoring case and
+// accepting "-" for "_", so that "public-ip" finds PUBLIC_IP.
+func (t *Table) Column(h string) (int, bool) {
+	i := slices.Index(t.Headers, ColumnKey(h))
+	return i, i >= 0
+}
+
+// ColumnKey is the header a --columns or --sort-by value names.
+func ColumnKey(h string) string {
+	return strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(h)), "-", "_")
+}
+
+// Check reports columns or a sort column that t does not have, so a list
+// command can fail before doing any work.
+func (t *Table) Check(o TableOptions) error {
+	for _, h := range o.Columns {
+		if _, ok := t.Column(h); !ok {
+			return fmt.Errorf("no column %q (available: %s)", h, strings.Join(t.Headers, ", "))
+		}
+	}
+	if by := strings.TrimPrefix(o.SortBy, "-"); by != "" {
+		if _, ok := t.Column(by); !ok {
+			return fmt.Errorf("cannot sort by %q: no such column (available: %s)", by, strings.Join(t.Headers, ", "))
+		}
+	}
+	return nil
+}
+
+// Sort orders the rows by the column sortBy names, keeping the order of
+// equal rows.
+func (t *Table) Sort(sortBy string) error {
+	less, err := t.Less(sortBy)
+	if err != nil || less == nil {
+		return err
+	}
+	sort.SliceStable(t.Rows, func(a, b int) bool { return less(t.Rows[a], t.Rows[b]) })
+	return nil
+}
+
+// Less returns the order of rows of t that sortBy selects, comparing cells
+// as CompareCells does and putting missing values last; nil when sortBy is
+// empty. A command can use it to order other output, e.g. JSON, like the
+// table.
+func (t *Table) Less(sortBy string) (func(a, b []string) bool, error) {
+	desc := strings.HasPrefix(sortBy, "-")
+	sortBy = strings.TrimPrefix(sortBy, "-")
+	if sortBy == "" {
+		return nil, nil
+	}
+	i, ok := t.Column(sortBy)
+	if !ok {
+		return nil, fmt.Errorf("cannot sort by %q: no such column (available: %s)", sortBy, strings.Join(t.Headers, ", "))
+	}
+	return func(a, b []string) bool {
+		x, y := a[i], b[i]
+		// missing values last, in either order
+		if missing(x) != missing(y) {
+			return missing(y)
+		}
+		c := CompareCells(x, y)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	}, nil
+}
+
+// Select returns the table of the columns o selects, in its order; t itself
+// when o selects none. The columns must be in t, as Check reports.
+func (t *Table) Select(o TableOptions) *Table {
+	if len(o.Columns) == 0 {
+		return t
+	}
+	idx := make([]int, 0, len(o.Columns))
+	for _, h := range o.Columns {
+		i, _ := t.Column(h)
+		idx = append(idx, i)
+	}
+	pick := func(cells []string) []string {
+		out := make([]string, 0, len(idx))
+		for _, i := range idx {
+			out = append(out, cells[i])
+		}
+		return out
+	}
+	out := &Table{Headers: pick(t.Headers)}
+	for _, r := range t.Rows {
+		out.Rows = append(out.Rows, pick(r))
+	}
+	return out
+}
+
+// Write sorts t and prints the columns o selects to w.
+func (t *Table) Write(w io.Writer, o TableOptions) error {
+	if err := t.Check(o); err != nil {
+		return err
+	}
+	if err := t.Sort(o.SortBy); err != nil {
+		return err
+	}
+	s := t.Select(o)
+	writer := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(s.Headers, "\t"))
+	for _, r := range s.Rows {
+		fmt.Fprintln(writer, strings.Join(r, "\t"))
+	}
+	return writer.Flush()
+}
+
+// leadingNumber matches the number a cell starts with, after an optional
+// currency sign, e.g. 16 in "16GB" or 0.05 in "$0.05".
+var leadingNumber = regexp.MustCompile(`^\$?(\d+(\.\d+)?)`)
+
+// CompareCells compares two table cells: by the number they start with when
+// both do, e.g. "8GB" before "16GB", and as strings otherwise or on a tie.
+func CompareCells(a, b string) int {
+	ma, mb := leadingNumber.FindStringSubmatch(a), leadingNumber.FindStringSubmatch(b)
+	if ma != nil && mb != nil {
+		na, _ := strconv.ParseFloat(ma[1], 64)
+		nb, _ := strconv.ParseFloat(mb[1], 64)
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func missing(cell string) bool {
+	return cell == "" || cell == "-"
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// AnnotationTLSInsecure on the static kubeconfig secret of an xkube skips the
+// verification of the TLS certificate of its API server.
+const AnnotationTLSInsecure = "skycluster.io/tls-insecure-skip-verify"
+
+// AnnotationTLSServerName on the static kubeconfig secret of an xkube is the
+// name the certificate of its API server is verified against, instead of the
+// host of its URL.
+const AnnotationTLSServerName = "skycluster.io/tls-server-name"
+
+// DataKeyTLSCA is the key of the static kubeconfig secret of an xkube holding
+// the PEM bundle of the CA that signed the certificate of its API server.
+const DataKeyTLSCA = "tls-ca.crt"
+
+// TLSOverrides replace the certificate authority of the clusters of a
+// kubeconfig, for clusters whose API server uses a private CA.
+type TLSOverrides struct {
+	// CA is a PEM bundle used instead of the CA of the kubeconfig.
+	CA []byte
+	// Insecure skips the verification of the server certificate.
+	Insecure bool
+	// ServerName is the name the server certificate is verified against.
+	ServerName string
+}
+
+// TLSOverridesFrom reads the overrides stored on secret, which may be nil.
+func TLSOverridesFrom(secret *corev1.Secret) TLSOverrides {
+	if secret == nil {
+		return TLSOverrides{}
+	}
+	insecure, _ := strconv.ParseBool(secret.Annotations[AnnotationTLSInsecure])
+	return TLSOverrides{CA: secret.Data[DataKeyTLSCA], Insecure: insecure, ServerName: secret.Annotations[AnnotationTLSServerName]}
+}
+
+// IsZero reports whether o overrides nothing.
+func (o TLSOverrides) IsZero() bool {
+	return len(o.CA) == 0 && !o.Insecure && o.ServerName == ""
+}
+
+// Store sets o on secret, replacing the overrides stored there, for
+// TLSOverridesFrom to read.
+func (o TLSOverrides) Store(secret *corev1.Secret) {
+	delete(secret.Annotations, AnnotationTLSInsecure)
+	delete(secret.Annotations, AnnotationTLSServerName)
+	delete(secret.Data, DataKeyTLSCA)
+	if secret.Annotations == nil && (o.Insecure || o.ServerName != "") {
+		secret.Annotations = map[string]string{}
+	}
+	if o.Insecure {
+		secret.Annotations[AnnotationTLSInsecure] = "true"
+	}
+	if o.ServerName != "" {
+		secret.Annotations[AnnotationTLSServerName] = o.ServerName
+	}
+	if len(o.CA) > 0 {
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[DataKeyTLSCA] = o.CA
+	}
+}
+
+// ReadCA reads the CA bundle at path and checks it holds a certificate.
+func ReadCA(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificate in CA bundle %s", path)
+	}
+	return data, nil
+}
+
+// CASubjects returns the subjects of the certificates of the CA bundle of o.
+func (o TLSOverrides) CASubjects() []string {
+	var subjects []string
+	rest := o.CA
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return subjects
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			subjects = append(subjects, cert.Subject.String())
+		}
+	}
+}
+
+// Apply returns kubeconfig with the overrides set on each of its clusters.
+// The CA bundle is embedded, so that the result works wherever it is used,
+// e.g. by GetClientsetFromString.
+func (o TLSOverrides) Apply(kubeconfig []byte) ([]byte, error) {
+	if o.IsZero() {
+		return kubeconfig, nil
+	}
+	var ca []byte
+	if !o.Insecure {
+		ca = o.CA
+	}
+	cfg, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	for _, c := range cfg.Clusters {
+		if o.ServerName != "" {
+			c.TLSServerName = o.ServerName
+		}
+		if len(o.CA) == 0 && !o.Insecure {
+			continue
+		}
+		// client-go refuses a CA together with the insecure flag.
+		c.CertificateAuthority = ""
+		c.CertificateAuthorityData = ca
+		c.InsecureSkipTLSVerify = o.Insecure
+	}
+	return clientcmd.Write(*cfg)
+}
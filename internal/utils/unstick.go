@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// DefaultFinalizerGrace is how long ForceDelete leaves the controllers to
+// finalize a resource before it removes the finalizers.
+const DefaultFinalizerGrace = 10 * time.Second
+
+// ForceDelete deletes name through res and makes sure it goes: if it is
+// still there after grace, its finalizers are removed and it is deleted
+// again, and as a last resort it is deleted with a zero grace period. It
+// reports whether finalizers had to be removed or the delete forced; a
+// resource that is already gone is not an error.
+func ForceDelete(ctx context.Context, res dynamic.ResourceInterface, name string, grace time.Duration, debugf DebugfFunc) (bool, error) {
+	if debugf == nil {
+		debugf = func(string, ...interface{}) {}
+	}
+	forced := false
+
+	// 1. Normal delete, which is all that is needed when nothing is stuck.
+	debugf("deleting %s", name)
+	del := func(opts metav1.DeleteOptions) error {
+		return Retry(ctx, func(ctx context.Context) error { return res.Delete(ctx, name, opts) })
+	}
+	if err := del(metav1.DeleteOptions{}); apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("deleting %s: %w", name, err)
+	}
+
+	// 2. Give the controllers holding it the time to finalize it.
+	debugf("waiting up to %s for %s to go", grace, name)
+	deadline := time.Now().Add(grace)
+	var obj *unstructured.Unstructured
+	for {
+		var err error
+		obj, err = res.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("getting %s: %w", name, err)
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	// 3. Remove the finalizers still holding it.
+	if len(obj.GetFinalizers()) > 0 {
+		debugf("removing finalizers %v from %s", obj.GetFinalizers(), name)
+		err := Retry(ctx, func(ctx context.Context) error {
+			_, err := res.Patch(ctx, name, types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+			return err
+		})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("removing finalizers of %s: %w", name, err)
+		}
+		forced = true
+	}
+
+	// 4. Delete again, and with a zero grace period if it is still there.
+	if err := del(metav1.DeleteOptions{}); apierrors.IsNotFound(err) {
+		return forced, nil
+	}
+	if _, err := res.Get(ctx, name, metav1.GetOptions{}); err == nil {
+		debugf("force deleting %s", name)
+		zero := int64(0)
+		if err := del(metav1.DeleteOptions{GracePeriodSeconds: &zero}); err != nil && !apierrors.IsNotFound(err) {
+			return forced, fmt.Errorf("force deleting %s: %w", name, err)
+		}
+		forced = true
+	}
+	return forced, nil
+}
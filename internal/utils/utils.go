@@ -1,13 +1,9 @@
 package utils
 
 import (
-	"errors"
 	"fmt"
-	"log"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-
-	"github.com/spf13/viper"
 )
 
 // helper to extract a condition's "status" (e.g. "True"/"False"/"Unknown")
@@ -93,17 +89,3 @@ func TraverseMapString(m map[string]interface{}, fields ...string) (string, erro
 	}
 	return s, nil
 }
-
-func getKubeconfig(name string) (string, error) {
-	kubeCfgs, ok := viper.Get("kubeconfig").(map[string]interface{})
-	if !ok {
-		log.Fatalf("Error getting kubeconfig: %v", ok)
-		return "", errors.New("Error getting kubeconfig")
-	}
-	skyKubeCfg, ok := kubeCfgs["sky-manager"].(string)
-	if !ok {
-		log.Fatalf("Error getting sky-manager kubeconfig: %v", ok)
-		return "", errors.New("Error getting sky-manager kubeconfig")
-	}
-	return skyKubeCfg, nil
-}
@@ -4,6 +4,7 @@ package utils
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -57,6 +58,12 @@ type WaitResourceSpec struct {
 	ConditionType        string        // e.g. "Ready", "Available"
 	Timeout              time.Duration // overall timeout per resource
 	PollInterval         time.Duration // polling interval
+
+	// Optional, only used by WaitForResourcesReadyConcurrent: ID identifies the
+	// spec (defaults to KindDescription) and DependsOn lists the IDs that must
+	// become ready before this resource is waited on.
+	ID        string
+	DependsOn []string
 }
 
 // ResolveResourceNamesFromManifest performs the "pre-watch phase":
@@ -241,6 +248,184 @@ func WaitForResourcesReadySequential(
 	return nil
 }
 
+// WaitForResourcesReadyConcurrent waits for all resources in parallel, honoring
+// DependsOn edges between specs: a resource is only waited on once all of its
+// dependencies are ready, and its Timeout starts counting from that moment.
+// The first failure cancels the remaining waits and is returned.
+func WaitForResourcesReadyConcurrent(
+	parentCtx context.Context,
+	dyn dynamic.Interface,
+	resources []WaitResourceSpec,
+	progressSink ProgressSink,
+	debugf DebugfFunc,
+) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	if progressSink == nil {
+		progressSink = func(ProgressEvent) {}
+	}
+
+	// index specs by ID and validate the dependency graph up-front
+	ids := make(map[string]int, len(resources))
+	for i, spec := range resources {
+		id := specID(spec)
+		if _, dup := ids[id]; dup {
+			return fmt.Errorf("duplicate wait resource id %q", id)
+		}
+		ids[id] = i
+	}
+	for _, spec := range resources {
+		for _, dep := range spec.DependsOn {
+			if _, ok := ids[dep]; !ok {
+				return fmt.Errorf("resource %q depends on unknown id %q", specID(spec), dep)
+			}
+		}
+	}
+	if err := checkAcyclic(resources, ids); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
+
+	total := len(resources)
+	var (
+		mu        sync.Mutex
+		completed int
+		firstErr  error
+		wg        sync.WaitGroup
+	)
+	done := make([]chan struct{}, total)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// emit serializes sink calls and computes the overall percentage.
+	emit := func(ev ProgressEvent, finished bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			completed++
+		}
+		ev.Total = total
+		ev.OverallPercent = float64(completed) / float64(total) * 100
+		progressSink(ev)
+	}
+
+	for i, spec := range resources {
+		wg.Add(1)
+		go func(index int, spec WaitResourceSpec) {
+			defer wg.Done()
+
+			base := ProgressEvent{
+				CurrentIndex:    index,
+				KindDescription: spec.KindDescription,
+				Namespace:       coalesce(spec.Namespace, "<cluster-scope>"),
+				Name:            spec.Name,
+				GVR:             spec.GVR,
+			}
+
+			for _, dep := range spec.DependsOn {
+				select {
+				case <-done[ids[dep]]:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			ev := base
+			ev.Message = fmt.Sprintf("Waiting for %s", spec.KindDescription)
+			emit(ev, false)
+
+			waitCtx, waitCancel := context.WithTimeout(ctx, spec.Timeout)
+			err := waitForSingleResourceReady(waitCtx, dyn, spec, debugf)
+			waitCancel()
+			if err != nil {
+				mu.Lock()
+				// errors caused by an earlier failure's cancellation are not reported
+				if firstErr != nil {
+					mu.Unlock()
+					return
+				}
+				firstErr = fmt.Errorf("resource %s (%s %s/%s) did not become %s=True: %w",
+					spec.KindDescription,
+					spec.GVR.Resource,
+					coalesce(spec.Namespace, "<cluster-scope>"),
+					spec.Name,
+					spec.ConditionType,
+					err,
+				)
+				mu.Unlock()
+				cancel()
+
+				ev := base
+				ev.Message = fmt.Sprintf("Error waiting for %s", spec.KindDescription)
+				ev.Err = err
+				emit(ev, false)
+				return
+			}
+
+			ev = base
+			ev.Message = fmt.Sprintf("%s is Ready", spec.KindDescription)
+			ev.ResourceCompleted = true
+			emit(ev, true)
+			close(done[index-1])
+		}(i+1, spec)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return parentCtx.Err()
+}
+
+// checkAcyclic reports an error if DependsOn edges form a cycle.
+func checkAcyclic(resources []WaitResourceSpec, ids map[string]int) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(resources))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("dependency cycle detected at %q", specID(resources[i]))
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, dep := range resources[i].DependsOn {
+			if err := visit(ids[dep]); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+	for i := range resources {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func specID(spec WaitResourceSpec) string {
+	return coalesce(spec.ID, spec.KindDescription)
+}
+
+// WaitForResourceReady waits for the single resource spec describes, until its
+// condition is True or spec.Timeout has passed.
+func WaitForResourceReady(ctx context.Context, dyn dynamic.Interface, spec WaitResourceSpec, debugf DebugfFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, spec.Timeout)
+	defer cancel()
+	return waitForSingleResourceReady(ctx, dyn, spec, debugf)
+}
+
 // waitForSingleResourceReady polls a single resource until the given condition
 // is True. The first GET happens immediately (no wait).
 func waitForSingleResourceReady(
@@ -0,0 +1,70 @@
+package version
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed versions.yaml
+var defaultManifest []byte
+
+// Chart is a Helm chart pinned by the versions manifest.
+type Chart struct {
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+	Version    string `json:"version"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// Manifest lists the chart versions a CLI release installs and upgrades to.
+type Manifest struct {
+	Charts []Chart `json:"charts"`
+}
+
+// Pinned returns the versions manifest built into the CLI.
+func Pinned() Manifest {
+	m, err := parseManifest(defaultManifest)
+	if err != nil {
+		panic(fmt.Sprintf("version: built-in versions manifest: %v", err))
+	}
+	return m
+}
+
+// LoadManifest reads a versions manifest from path.
+func LoadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m, err := parseManifest(data)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func parseManifest(data []byte) (Manifest, error) {
+	var m Manifest
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return Manifest{}, err
+	}
+	for i, c := range m.Charts {
+		if c.Name == "" || c.Version == "" {
+			return Manifest{}, fmt.Errorf("charts[%d]: name and version are required", i)
+		}
+	}
+	return m, nil
+}
+
+// Chart returns the chart name of m, if it is pinned.
+func (m Manifest) Chart(name string) (Chart, bool) {
+	for _, c := range m.Charts {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Chart{}, false
+}
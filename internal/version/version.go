@@ -0,0 +1,21 @@
+package version
+
+import "runtime/debug"
+
+// Version is the CLI version, set at build time with
+//
+//	go build -ldflags "-X github.com/etesami/skycluster-cli/internal/version.Version=v0.3.0"
+//
+// When unset, the module version recorded by `go install` is used, or "dev".
+var Version = ""
+
+// Get returns the CLI version string.
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
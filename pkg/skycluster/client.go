@@ -0,0 +1,68 @@
+// Package skycluster is the Go API behind the skycluster CLI. It creates and
+// updates the SkyCluster resources, waits for them to become ready, fetches
+// the kubeconfigs of xkubes and manages their mesh, so that other tools and
+// operators can do the same without running the CLI.
+//
+// A Client works on the management cluster:
+//
+//	c, err := skycluster.NewForKubeconfig(os.ExpandEnv("$HOME/.kube/config"))
+//	...
+//	kubeconfig, err := c.Kubeconfig(ctx, "gcp-us-east1")
+package skycluster
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Client runs the SkyCluster operations against a management cluster. Only
+// Dynamic and Kube are required; the other fields are optional hooks.
+type Client struct {
+	Dynamic dynamic.Interface
+	Kube    kubernetes.Interface
+
+	// Credentials obtain the admin kubeconfig of an xkube per platform
+	// (spec.providerRef.platform). They are used when the xkube has no
+	// cluster secret, and always for gcp.
+	Credentials map[string]CredentialProvider
+	// Logf receives debug messages.
+	Logf func(format string, args ...interface{})
+	// OnIssued is called when a token is minted for an xkube.
+	OnIssued func(cluster, scope string, expiry time.Time)
+	// OnUsed is called when a cached static kubeconfig is used.
+	OnUsed func(cluster, scope string)
+}
+
+// New returns a Client for the management cluster of cfg.
+func New(cfg *rest.Config) (*Client, error) {
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("build dynamic client: %w", err)
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("build clientset: %w", err)
+	}
+	return &Client{Dynamic: dyn, Kube: cs}, nil
+}
+
+// NewForKubeconfig returns a Client for the management cluster of the
+// kubeconfig file at path.
+func NewForKubeconfig(path string) (*Client, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
@@ -0,0 +1,373 @@
+package skycluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/utils/ptr"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// StaticNamespace is where the static kubeconfigs of the xkubes are kept on
+// the management cluster, and where their ServiceAccounts live on the xkubes.
+const StaticNamespace = "skycluster-system"
+
+// Annotations of the static kubeconfig secrets.
+const (
+	AnnotationExpiry   = "skycluster.io/expiry"
+	AnnotationIssuedAt = "skycluster.io/issued-at"
+	AnnotationLastUsed = "skycluster.io/last-used"
+	AnnotationScope    = "skycluster.io/scope"
+)
+
+// Labels of the static kubeconfig secrets.
+const (
+	LabelSecretType = "skycluster.io/secret-type"
+	LabelClusterID  = "skycluster.io/cluster-id"
+)
+
+// StaticSAPrefix prefixes the name of the ServiceAccount the static
+// kubeconfigs authenticate as, followed by the xkube name.
+const StaticSAPrefix = "skycluster-static-sa-"
+
+// staticTokenTTL is the lifetime requested for the static tokens.
+const staticTokenTTL = 24 * time.Hour
+
+// lastUseResolution is how stale the last-used annotation may get before a
+// use of the kubeconfig updates it, so that every use does not write to the
+// management cluster.
+const lastUseResolution = 5 * time.Minute
+
+// CredentialProvider obtains an admin kubeconfig for the managed clusters of
+// one platform, usually through the platform's CLI or API.
+type CredentialProvider interface {
+	// AdminKubeconfig returns a kubeconfig with admin rights on the cluster of obj.
+	AdminKubeconfig(ctx context.Context, obj *unstructured.Unstructured) ([]byte, error)
+}
+
+// StaticSecretName returns the name of the secret holding the static
+// kubeconfig of the xkube id.
+func StaticSecretName(id string) string {
+	return id + "-static-kubeconfig"
+}
+
+// Kubeconfig returns a kubeconfig of the xkube name, authenticating with a
+// token of a cluster-admin ServiceAccount on it, as KubeconfigFor does.
+func (c *Client) Kubeconfig(ctx context.Context, name string) (string, error) {
+	obj, err := resource.XKube.Client(c.Dynamic).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return c.KubeconfigFor(ctx, obj)
+}
+
+// KubeconfigFor returns a kubeconfig of the xkube obj. The static kubeconfig
+// cached on the management cluster is used while it is valid; otherwise a
+// new token is minted with the admin kubeconfig of the xkube and cached.
+// The TLS overrides of the xkube are applied either way.
+func (c *Client) KubeconfigFor(ctx context.Context, obj *unstructured.Unstructured) (string, error) {
+	name := obj.GetName()
+	clusterName, _, _ := unstructured.NestedString(obj.Object, "status", "externalClusterName")
+	if clusterName == "" {
+		return "", fmt.Errorf("externalClusterName not present for xkube [%s]", name)
+	}
+
+	cached, err := c.CachedKubeconfig(ctx, name)
+	if err == nil {
+		return string(cached), nil
+	}
+	c.logf("no cached kubeconfig for %s: %v", name, err)
+
+	admin, err := c.AdminKubeconfig(ctx, obj)
+	if err != nil {
+		return "", err
+	}
+	kubeconfig, _, err := c.IssueStaticKubeconfig(ctx, admin, name)
+	if err != nil {
+		return "", fmt.Errorf("error creating static kubeconfig for [%s]: %v", name, err)
+	}
+	return kubeconfig, nil
+}
+
+// CachedKubeconfig returns the static kubeconfig of the xkube id cached on
+// the management cluster, with its TLS overrides applied, if it has not
+// expired.
+func (c *Client) CachedKubeconfig(ctx context.Context, id string) ([]byte, error) {
+	secretName := StaticSecretName(id)
+	secret, err := c.Kube.CoreV1().Secrets(StaticNamespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error checking existing secret %s/%s: %w", StaticNamespace, secretName, err)
+	}
+	kubeconfig := secret.Data["kubeconfig"]
+	expiry, err := time.Parse(time.RFC3339, secret.Annotations[AnnotationExpiry])
+	if len(kubeconfig) == 0 || err != nil || !time.Now().UTC().Before(expiry) {
+		return nil, fmt.Errorf("static kubeconfig secret %s/%s not found or expired", StaticNamespace, secretName)
+	}
+	c.markUsed(ctx, id, secret.Annotations)
+	return utils.TLSOverridesFrom(secret).Apply(kubeconfig)
+}
+
+// markUsed updates the last-used annotation of the static kubeconfig secret
+// of id, at most once per lastUseResolution, and calls OnUsed. Failures only
+// show in the debug output: tracking must never get in the way of using the
+// kubeconfig.
+func (c *Client) markUsed(ctx context.Context, id string, annotations map[string]string) {
+	now := time.Now().UTC()
+	last, _ := time.Parse(time.RFC3339, annotations[AnnotationLastUsed])
+	if now.Sub(last) >= lastUseResolution {
+		patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, AnnotationLastUsed, now.Format(time.RFC3339))
+		secretName := StaticSecretName(id)
+		if _, err := c.Kube.CoreV1().Secrets(StaticNamespace).Patch(ctx, secretName, types.MergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+			c.logf("recording last use of %s/%s: %v", StaticNamespace, secretName, err)
+		}
+	}
+	if c.OnUsed != nil {
+		scope := annotations[AnnotationScope]
+		if scope == "" {
+			scope = "cluster-admin"
+		}
+		c.OnUsed(id, scope)
+	}
+}
+
+// AdminKubeconfig returns the provider-issued (admin) kubeconfig of the xkube
+// obj. GKE clusters always go through their credential provider; for the
+// other platforms the secret named in status.clusterSecretName is used when
+// there is one, and the platform's credential provider otherwise.
+func (c *Client) AdminKubeconfig(ctx context.Context, obj *unstructured.Unstructured) ([]byte, error) {
+	name := obj.GetName()
+	platform, _, _ := unstructured.NestedString(obj.Object, "spec", "providerRef", "platform")
+	secretName, _, _ := unstructured.NestedString(obj.Object, "status", "clusterSecretName")
+	if provider, ok := c.Credentials[platform]; ok && (platform == "gcp" || secretName == "") {
+		return provider.AdminKubeconfig(ctx, obj)
+	}
+	if secretName == "" {
+		return nil, fmt.Errorf("secret name not found for config [%s] and no credential provider for platform %q", name, platform)
+	}
+
+	// Secrets for xkube objects with kubeconfig are stored in skycluster-system
+	secret, err := c.Kube.CoreV1().Secrets(StaticNamespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching secret %s for config [%s]: %v", secretName, name, err)
+	}
+	kubeconfig, ok := secret.Data["kubeconfig"]
+	if !ok {
+		return nil, fmt.Errorf("secret data not found for config [%s]", name)
+	}
+	return kubeconfig, nil
+}
+
+// IssueStaticKubeconfig ensures a ServiceAccount bound to cluster-admin exists
+// on the xkube id reached with the admin kubeconfig, mints a token for it
+// and returns a kubeconfig using that token, with the TLS overrides of the
+// xkube applied, and its expiry. The kubeconfig is cached in the secret
+// StaticSecretName(id) on the management cluster, keeping the TLS overrides
+// already set there.
+func (c *Client) IssueStaticKubeconfig(ctx context.Context, admin []byte, id string) (string, time.Time, error) {
+	// TLS overrides apply to the admin kubeconfig too and are kept on the secret
+	var tls utils.TLSOverrides
+	if existing, err := c.Kube.CoreV1().Secrets(StaticNamespace).Get(ctx, StaticSecretName(id), metav1.GetOptions{}); err == nil {
+		tls = utils.TLSOverridesFrom(existing)
+	}
+	return c.issueStaticKubeconfig(ctx, admin, id, tls)
+}
+
+// TLSOptions override how the API server certificate of an xkube is
+// verified, for xkubes whose API server uses a private CA.
+type TLSOptions struct {
+	// Insecure skips the verification of the certificate.
+	Insecure bool
+	// CAData is a PEM bundle used instead of the CA of the kubeconfig.
+	CAData []byte
+	// ServerName is the name the certificate is verified against, instead
+	// of the host of the server URL.
+	ServerName string
+}
+
+// IssueStaticKubeconfigWithTLS is IssueStaticKubeconfig with the TLS options
+// tls, which are stored on the secret in place of those set there.
+func (c *Client) IssueStaticKubeconfigWithTLS(ctx context.Context, admin []byte, id string, tls TLSOptions) (string, time.Time, error) {
+	return c.issueStaticKubeconfig(ctx, admin, id, utils.TLSOverrides{CA: tls.CAData, Insecure: tls.Insecure, ServerName: tls.ServerName})
+}
+
+func (c *Client) issueStaticKubeconfig(ctx context.Context, admin []byte, id string, tls utils.TLSOverrides) (string, time.Time, error) {
+	secretName := StaticSecretName(id)
+	secrets := c.Kube.CoreV1().Secrets(StaticNamespace)
+	adminBytes, err := tls.Apply(admin)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("applying TLS overrides of %s: %w", id, err)
+	}
+	restCfg, err := clientcmd.RESTConfigFromKubeConfig(adminBytes)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("building rest config from kubeconfig: %w", err)
+	}
+	remote, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("creating kubernetes client: %w", err)
+	}
+	parsed, err := clientcmd.Load(admin)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	cluster, err := CurrentCluster(parsed)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	saName := StaticSAPrefix + id
+	if err := ensureStaticAccess(ctx, remote, saName); err != nil {
+		return "", time.Time{}, err
+	}
+	tr, err := remote.CoreV1().ServiceAccounts(StaticNamespace).CreateToken(ctx, saName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: ptr.To(int64(staticTokenTTL.Seconds()))},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("creating service account token: %w", err)
+	}
+	out, err := TokenKubeconfig(cluster, id, []byte(tr.Status.Token))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiry := tr.Status.ExpirationTimestamp.Time.UTC()
+	if tr.Status.ExpirationTimestamp.IsZero() {
+		// fallback if unavailable
+		expiry = time.Now().UTC().Add(10 * time.Hour)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: StaticNamespace,
+			Labels: map[string]string{
+				"skycluster.io/managed-by": "skycluster",
+				LabelSecretType:            "static-kubeconfig",
+				LabelClusterID:             id,
+			},
+			Annotations: map[string]string{
+				AnnotationExpiry:   expiry.Format(time.RFC3339),
+				AnnotationIssuedAt: now,
+				AnnotationLastUsed: now,
+				AnnotationScope:    "cluster-admin",
+			},
+		},
+		Data: map[string][]byte{"kubeconfig": out},
+		Type: corev1.SecretTypeOpaque,
+	}
+	tls.Store(secret)
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return "", time.Time{}, fmt.Errorf("creating secret %s/%s: %w", StaticNamespace, secretName, err)
+		}
+		if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return "", time.Time{}, fmt.Errorf("creating/updating secret %s/%s: %w", StaticNamespace, secretName, err)
+		}
+	}
+	if c.OnIssued != nil {
+		c.OnIssued(id, "cluster-admin", expiry)
+	}
+
+	// The secret keeps the kubeconfig as issued; the overrides are applied on read
+	out, err = tls.Apply(out)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("applying TLS overrides of %s: %w", id, err)
+	}
+	return string(out), expiry, nil
+}
+
+// ensureStaticAccess creates the namespace, the ServiceAccount saName and its
+// cluster-admin binding on the remote cluster when they are missing.
+func ensureStaticAccess(ctx context.Context, remote kubernetes.Interface, saName string) error {
+	if _, err := remote.CoreV1().Namespaces().Get(ctx, StaticNamespace, metav1.GetOptions{}); err != nil {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: StaticNamespace}}
+		if _, err := remote.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating namespace %s: %w", StaticNamespace, err)
+		}
+	}
+
+	_, err := remote.CoreV1().ServiceAccounts(StaticNamespace).Get(ctx, saName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:      saName,
+			Namespace: StaticNamespace,
+			Labels:    map[string]string{"skycluster.io/managed-by": "skycluster"},
+		}}
+		if _, err := remote.CoreV1().ServiceAccounts(StaticNamespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating serviceaccount %s/%s: %w", StaticNamespace, saName, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("error checking serviceaccount %s/%s: %w", StaticNamespace, saName, err)
+	}
+
+	crbName := saName + "-crb"
+	_, err = remote.RbacV1().ClusterRoleBindings().Get(ctx, crbName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		crb := &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: crbName},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: saName, Namespace: StaticNamespace}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "cluster-admin"},
+		}
+		if _, err := remote.RbacV1().ClusterRoleBindings().Create(ctx, crb, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating clusterrolebinding %s: %w", crbName, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("error checking clusterrolebinding %s: %w", crbName, err)
+	}
+	return nil
+}
+
+// TokenKubeconfig returns a kubeconfig for cluster authenticating with
+// token, with its cluster, user and context named after name.
+func TokenKubeconfig(cluster *api.Cluster, name string, token []byte) ([]byte, error) {
+	cfg := api.NewConfig()
+	// unique names avoid collisions when kubeconfigs are merged
+	clusterName := name + "-cluster"
+	cfg.Clusters[clusterName] = &api.Cluster{
+		Server:                   cluster.Server,
+		CertificateAuthorityData: cluster.CertificateAuthorityData,
+		InsecureSkipTLSVerify:    cluster.InsecureSkipTLSVerify,
+	}
+	cfg.AuthInfos[name] = &api.AuthInfo{Token: string(token)}
+	cfg.Contexts[name] = &api.Context{Cluster: clusterName, AuthInfo: name}
+	cfg.CurrentContext = name
+
+	out, err := clientcmd.Write(*cfg)
+	if err != nil {
+		return nil, fmt.Errorf("writing new kubeconfig: %w", err)
+	}
+	return out, nil
+}
+
+// CurrentCluster returns the cluster of the current context of cfg, or of
+// any context when none is current.
+func CurrentCluster(cfg *api.Config) (*api.Cluster, error) {
+	ctxName := cfg.CurrentContext
+	if ctxName == "" {
+		for k := range cfg.Contexts {
+			ctxName = k
+			break
+		}
+	}
+	kctx, ok := cfg.Contexts[ctxName]
+	if !ok {
+		return nil, fmt.Errorf("no context found in kubeconfig")
+	}
+	cluster, ok := cfg.Clusters[kctx.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q not found in kubeconfig", kctx.Cluster)
+	}
+	return cluster, nil
+}
@@ -0,0 +1,159 @@
+package skycluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/etesami/skycluster-cli/internal/audit"
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// MeshName is the name of the single XKubeMesh connecting the xkubes.
+const MeshName = "xkube-cluster-mesh"
+
+// Errors of UpdateMeshMembers.
+var (
+	ErrMeshNotEnabled = errors.New("the mesh is not enabled")
+	ErrMeshEmpty      = errors.New("the mesh would have no members left")
+)
+
+// MeshOptions configure EnableMesh.
+type MeshOptions struct {
+	// PodCIDR and ServiceCIDR are those of the management cluster.
+	PodCIDR     string
+	ServiceCIDR string
+	// Clusters are the xkubes to connect; all of them when empty.
+	Clusters []string
+}
+
+// EnableMesh creates or updates the XKubeMesh with the xkubes of o and
+// returns Created or Configured and its members. Without any xkube, nothing
+// is done and the result is empty.
+func (c *Client) EnableMesh(ctx context.Context, o MeshOptions) (string, []string, error) {
+	xkubes, err := c.List(ctx, XKube, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("listing xkubes: %w", err)
+	}
+	c.logf("listed %d xkubes", len(xkubes))
+
+	var members []string
+	for _, it := range xkubes {
+		if len(o.Clusters) > 0 && !slices.Contains(o.Clusters, it.GetName()) {
+			continue
+		}
+		members = append(members, it.GetName())
+	}
+	for _, name := range o.Clusters {
+		if !slices.Contains(members, name) {
+			return "", nil, fmt.Errorf("xkube %q not found", name)
+		}
+	}
+	if len(members) == 0 {
+		return "", nil, nil
+	}
+
+	clusterNames := make([]interface{}, 0, len(members))
+	for _, m := range members {
+		clusterNames = append(clusterNames, m)
+	}
+	ri := resource.XKubeMesh.Client(c.Dynamic)
+	existing, err := ri.Get(ctx, MeshName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		mesh := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": resource.XKubeMesh.GVR.GroupVersion().String(),
+			"kind":       XKubeMesh,
+			"metadata":   map[string]interface{}{"name": MeshName},
+			"spec": map[string]interface{}{
+				"clusterNames": clusterNames,
+				"localCluster": map[string]interface{}{
+					"podCidr":     o.PodCIDR,
+					"serviceCidr": o.ServiceCIDR,
+				},
+			},
+		}}
+		c.logf("creating xkubemesh %s with %d clusterNames", MeshName, len(members))
+		audit.Stamp(mesh)
+		if _, err := ri.Create(ctx, mesh, metav1.CreateOptions{}); err != nil {
+			return "", nil, fmt.Errorf("creating xkubemesh %s: %w", MeshName, err)
+		}
+		return Created, members, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("getting existing xkubemesh: %w", err)
+	}
+
+	updated := existing.DeepCopy()
+	if err := unstructured.SetNestedField(updated.Object, clusterNames, "spec", "clusterNames"); err != nil {
+		return "", nil, fmt.Errorf("setting spec.clusterNames: %w", err)
+	}
+	if err := unstructured.SetNestedField(updated.Object, o.PodCIDR, "spec", "localCluster", "podCidr"); err != nil {
+		return "", nil, fmt.Errorf("setting spec.localCluster.podCidr: %w", err)
+	}
+	if err := unstructured.SetNestedField(updated.Object, o.ServiceCIDR, "spec", "localCluster", "serviceCidr"); err != nil {
+		return "", nil, fmt.Errorf("setting spec.localCluster.serviceCidr: %w", err)
+	}
+	c.logf("updating xkubemesh %s with %d clusterNames", MeshName, len(members))
+	audit.StampUpdate(updated, existing)
+	if _, err := ri.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return "", nil, fmt.Errorf("updating xkubemesh %s: %w", MeshName, err)
+	}
+	return Configured, members, nil
+}
+
+// DisableMesh deletes the XKubeMesh and reports whether there was one.
+func (c *Client) DisableMesh(ctx context.Context) (bool, error) {
+	err := utils.RetryDelete(ctx, resource.XKubeMesh.Client(c.Dynamic), MeshName, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("deleting xkubemesh %s: %w", MeshName, err)
+	}
+	return true, nil
+}
+
+// UpdateMeshMembers adds and removes xkubes from the XKubeMesh, retrying when
+// it changes concurrently, and returns its members.
+func (c *Client) UpdateMeshMembers(ctx context.Context, add, remove []string) ([]string, error) {
+	ri := resource.XKubeMesh.Client(c.Dynamic)
+	var members []string
+	err := utils.RetryOnConflict(ctx, func(ctx context.Context) error {
+		existing, err := ri.Get(ctx, MeshName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return ErrMeshNotEnabled
+		}
+		if err != nil {
+			return fmt.Errorf("getting xkubemesh %s: %w", MeshName, err)
+		}
+		members, _, _ = unstructured.NestedStringSlice(existing.Object, "spec", "clusterNames")
+		for _, name := range remove {
+			if !slices.Contains(members, name) {
+				return fmt.Errorf("xkube %s is not a member of the mesh", name)
+			}
+			members = slices.DeleteFunc(members, func(m string) bool { return m == name })
+		}
+		for _, name := range add {
+			if !slices.Contains(members, name) {
+				members = append(members, name)
+			}
+		}
+		if len(members) == 0 {
+			return ErrMeshEmpty
+		}
+		if err := unstructured.SetNestedStringSlice(existing.Object, members, "spec", "clusterNames"); err != nil {
+			return fmt.Errorf("setting spec.clusterNames: %w", err)
+		}
+		audit.StampUpdate(existing, existing)
+		c.logf("updating xkubemesh %s clusterNames=%v", MeshName, members)
+		_, err = ri.Update(ctx, existing, metav1.UpdateOptions{})
+		return err
+	})
+	return members, err
+}
@@ -0,0 +1,83 @@
+package skycluster
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/etesami/skycluster-cli/internal/resource"
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// Kinds of the SkyCluster resources.
+const (
+	XProvider       = "XProvider"
+	XKube           = "XKube"
+	XInstance       = "XInstance"
+	XKubeMesh       = "XKubeMesh"
+	ProviderProfile = "ProviderProfile"
+)
+
+// Results of Apply.
+const (
+	Created           = resource.Created
+	Configured        = resource.Configured
+	ServerSideApplied = resource.ServerSideApplied
+)
+
+func typeOf(kind string) (*resource.Type, error) {
+	t, ok := resource.ForKind(kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	return t, nil
+}
+
+// Apply creates obj, or merges it onto the existing resource of its kind and
+// name, and returns Created or Configured. With serverSide set, obj is sent
+// as a server-side apply patch instead and ServerSideApplied is returned;
+// fields managed by others are a conflict error unless force is set.
+func (c *Client) Apply(ctx context.Context, obj *unstructured.Unstructured, serverSide, force bool) (string, error) {
+	t, err := typeOf(obj.GetKind())
+	if err != nil {
+		return "", err
+	}
+	if obj.GetAPIVersion() == "" {
+		obj.SetAPIVersion(t.GVR.GroupVersion().String())
+	}
+	return t.CreateOrUpdate(ctx, c.Dynamic, obj, serverSide, force)
+}
+
+// Get returns the resource of kind named name.
+func (c *Client) Get(ctx context.Context, kind, name string) (*unstructured.Unstructured, error) {
+	t, err := typeOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	return t.Client(c.Dynamic).Get(ctx, name, metav1.GetOptions{})
+}
+
+// List returns the resources of kind matching the label selector, all of
+// them when selector is empty.
+func (c *Client) List(ctx context.Context, kind, selector string) ([]unstructured.Unstructured, error) {
+	t, err := typeOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	list, err := t.Client(c.Dynamic).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Delete deletes the resource of kind named name.
+func (c *Client) Delete(ctx context.Context, kind, name string) error {
+	t, err := typeOf(kind)
+	if err != nil {
+		return err
+	}
+	return utils.RetryDelete(ctx, t.Client(c.Dynamic), name, metav1.DeleteOptions{})
+}
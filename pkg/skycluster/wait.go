@@ -0,0 +1,36 @@
+package skycluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/etesami/skycluster-cli/internal/utils"
+)
+
+// WaitReady polls the resource of kind named name every interval until its
+// Ready condition is True and returns it. It gives up when ctx is done; give
+// ctx a deadline to bound the wait.
+func (c *Client) WaitReady(ctx context.Context, kind, name string, interval time.Duration) (*unstructured.Unstructured, error) {
+	var obj *unstructured.Unstructured
+	err := wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (bool, error) {
+		cur, err := c.Get(ctx, kind, name)
+		if err != nil {
+			c.logf("get %s %s failed: %v", kind, name, err)
+			return false, nil
+		}
+		obj = cur
+		return utils.GetConditionStatus(cur, "Ready") == "True", nil
+	})
+	if err != nil {
+		ready := "<unknown>"
+		if obj != nil {
+			ready = utils.GetConditionStatus(obj, "Ready")
+		}
+		return obj, fmt.Errorf("%s %s not ready (Ready=%q): %w", kind, name, ready, err)
+	}
+	return obj, nil
+}